@@ -0,0 +1,326 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"l0/internal/db"
+	"l0/internal/export"
+	"l0/internal/models"
+)
+
+// progressInterval is the minimal interval between progress reports
+const progressInterval = 5 * time.Second
+
+// saveAttempts is the number of attempts to save an order failing with a transient error
+const saveAttempts = 3
+
+// retryDelay is the delay before the second attempt to save an order, it grows with every attempt
+var retryDelay = 200 * time.Millisecond
+
+// An orderSaver saves orders, SaveOrders saves all orders of the batch or none
+type orderSaver interface {
+	SaveOrder(ctx context.Context, order *models.Order) (bool, error)
+	SaveOrders(ctx context.Context, orders []*models.Order) error
+}
+
+// A checkpoint is the state of the import stored after every batch. All records of the file
+// up to Line are imported or rejected, so the import resumes from the next line
+type checkpoint struct {
+	File      string    `json:"file"`
+	Line      int       `json:"line"`
+	Imported  int       `json:"imported"`
+	Rejected  int       `json:"rejected"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// A reject is a record which wasn't imported with the reason
+type reject struct {
+	Line     int    `json:"line"`
+	OrderUID string `json:"order_uid,omitempty"`
+	Error    string `json:"error"`
+}
+
+// A batch is a group of records saved at once. Line is the last line of the batch in the file
+type batch struct {
+	seq      int
+	line     int
+	orders   []*models.Order
+	lines    []int
+	rejects  []reject
+	imported int
+	err      error
+}
+
+// An importer reads orders from a file and saves them with parallel workers. Batches are completed
+// in any order, but the checkpoint advances only over batches all preceding batches of which are completed
+type importer struct {
+	saver     orderSaver
+	batchSize int
+	workers   int
+	rejects   io.Writer
+	errOut    io.Writer
+
+	checkpointPath string
+
+	mu           sync.Mutex
+	checkpoint   checkpoint
+	completed    map[int]*batch
+	nextSeq      int
+	err          error
+	cancel       context.CancelFunc
+	lastProgress time.Time
+}
+
+// fileFormat returns the format of the file by its extension if the format isn't set
+func fileFormat(path, format string) (string, error) {
+	if format == "" {
+		format = strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")
+		if format == "jsonl" {
+			format = export.FormatNDJSON
+		}
+	}
+	switch format {
+	case export.FormatNDJSON, export.FormatCSV:
+		return format, nil
+	default:
+		return "", fmt.Errorf("unknown import format %q", format)
+	}
+}
+
+// loadCheckpoint reads the checkpoint of the file, a new checkpoint is returned if there is no checkpoint file
+func loadCheckpoint(path, file string) (checkpoint, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return checkpoint{File: file}, nil
+	}
+	if err != nil {
+		return checkpoint{}, err
+	}
+
+	var cp checkpoint
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return checkpoint{}, fmt.Errorf("invalid checkpoint %s: %w", path, err)
+	}
+	if cp.File != file {
+		return checkpoint{}, fmt.Errorf("checkpoint %s belongs to %s, not to %s", path, cp.File, file)
+	}
+	return cp, nil
+}
+
+// saveCheckpoint writes the checkpoint to a temporary file and renames it, so an interrupted write
+// doesn't corrupt the previous checkpoint
+func saveCheckpoint(path string, cp checkpoint) error {
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// run imports records of the reader following the checkpoint. Reading stops when the context is canceled,
+// batches which are already read are saved before run returns
+func (imp *importer) run(ctx context.Context, reader export.Reader) (checkpoint, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	imp.cancel = cancel
+	imp.completed = make(map[int]*batch)
+
+	batches := make(chan *batch, imp.workers)
+	var wg sync.WaitGroup
+	for i := 0; i < imp.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for b := range batches {
+				// Batches which are read are finished even after interruption, so the checkpoint moves forward
+				imp.save(context.WithoutCancel(ctx), b)
+				imp.complete(b)
+			}
+		}()
+	}
+
+	readErr := imp.read(ctx, reader, batches)
+	close(batches)
+	wg.Wait()
+
+	imp.mu.Lock()
+	defer imp.mu.Unlock()
+	if imp.err != nil {
+		return imp.checkpoint, imp.err
+	}
+	return imp.checkpoint, readErr
+}
+
+// read groups records into batches, invalid records are added to batches as rejects.
+// Rejects count towards the batch size, so a run of invalid records still advances the checkpoint
+func (imp *importer) read(ctx context.Context, reader export.Reader, batches chan<- *batch) error {
+	skip := imp.checkpoint.Line
+	current := &batch{}
+	send := func() bool {
+		select {
+		case batches <- current:
+			current = &batch{seq: current.seq + 1}
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	for ctx.Err() == nil {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if record.Line <= skip {
+			continue
+		}
+
+		current.line = record.Line
+		err = record.Err
+		if err == nil {
+			err = record.Order.Validate()
+		}
+		if err != nil {
+			rejected := reject{Line: record.Line, Error: err.Error()}
+			if record.Order != nil {
+				rejected.OrderUID = record.Order.OrderUID
+			}
+			current.rejects = append(current.rejects, rejected)
+		} else {
+			current.orders = append(current.orders, record.Order)
+			current.lines = append(current.lines, record.Line)
+		}
+
+		if len(current.orders)+len(current.rejects) >= imp.batchSize && !send() {
+			return nil
+		}
+	}
+
+	if ctx.Err() == nil && (len(current.orders) > 0 || len(current.rejects) > 0) {
+		send()
+	}
+	return nil
+}
+
+// save saves orders of the batch. If the batch fails, orders are saved one by one to find the failing ones.
+// Only orders with invalid data are rejected, the batch fails if an order still can't be saved
+// after retries for another reason, e.g. if the database is not available
+func (imp *importer) save(ctx context.Context, b *batch) {
+	if len(b.orders) == 0 {
+		return
+	}
+	if err := imp.saver.SaveOrders(ctx, b.orders); err == nil {
+		b.imported = len(b.orders)
+		return
+	}
+
+	var failed []reject
+	imported := 0
+	for i, order := range b.orders {
+		err := imp.saveOrder(ctx, order)
+		if err == nil {
+			imported++
+			continue
+		}
+		if !permanentError(err) {
+			b.err = fmt.Errorf("failed to save order %s at line %d: %w", order.OrderUID, b.lines[i], err)
+			return
+		}
+		failed = append(failed, reject{Line: b.lines[i], OrderUID: order.OrderUID, Error: err.Error()})
+	}
+	b.imported = imported
+	b.rejects = append(b.rejects, failed...)
+}
+
+// saveOrder saves the order, transient errors are retried
+func (imp *importer) saveOrder(ctx context.Context, order *models.Order) error {
+	var err error
+	for attempt := 1; attempt <= saveAttempts; attempt++ {
+		if _, err = imp.saver.SaveOrder(ctx, order); err == nil || permanentError(err) {
+			return err
+		}
+		if attempt < saveAttempts {
+			time.Sleep(retryDelay * time.Duration(attempt))
+		}
+	}
+	return err
+}
+
+// permanentError reports whether the order can't be saved because of its data, so it's rejected
+func permanentError(err error) bool {
+	var validationErr models.ValidationError
+	return errors.As(err, &validationErr) || db.IsDataError(err)
+}
+
+// complete advances the checkpoint over completed batches, rejects are written in the order of the file.
+// The first failed batch stops the import
+func (imp *importer) complete(b *batch) {
+	imp.mu.Lock()
+	defer imp.mu.Unlock()
+
+	if imp.err != nil {
+		return
+	}
+	if b.err != nil {
+		imp.err = b.err
+		imp.cancel()
+		return
+	}
+
+	imp.completed[b.seq] = b
+	advanced := false
+	for {
+		next, ok := imp.completed[imp.nextSeq]
+		if !ok {
+			break
+		}
+		delete(imp.completed, imp.nextSeq)
+		imp.nextSeq++
+
+		for _, rejected := range next.rejects {
+			data, _ := json.Marshal(rejected)
+			if _, err := imp.rejects.Write(append(data, '\n')); err != nil {
+				imp.err = fmt.Errorf("failed to write rejects: %w", err)
+				imp.cancel()
+				return
+			}
+		}
+		imp.checkpoint.Line = next.line
+		imp.checkpoint.Imported += next.imported
+		imp.checkpoint.Rejected += len(next.rejects)
+		advanced = true
+	}
+	if !advanced || imp.checkpointPath == "" {
+		return
+	}
+
+	imp.checkpoint.UpdatedAt = time.Now().UTC()
+	if err := saveCheckpoint(imp.checkpointPath, imp.checkpoint); err != nil {
+		imp.err = fmt.Errorf("failed to save checkpoint: %w", err)
+		imp.cancel()
+		return
+	}
+	if time.Since(imp.lastProgress) >= progressInterval {
+		imp.lastProgress = time.Now()
+		fmt.Fprintf(
+			imp.errOut, "Line %d: imported %d, rejected %d\n",
+			imp.checkpoint.Line, imp.checkpoint.Imported, imp.checkpoint.Rejected,
+		)
+	}
+}
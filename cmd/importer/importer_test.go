@@ -0,0 +1,231 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"l0/internal/export"
+	"l0/internal/models"
+)
+
+// A mockSaver records saved orders, fails orders with listed UIDs by a constraint violation
+// and orders with listed attempts by a serialization failure
+type mockSaver struct {
+	mu        sync.Mutex
+	saved     map[string]bool
+	failing   map[string]bool
+	transient map[string]int
+	down      bool
+}
+
+func (m *mockSaver) SaveOrder(ctx context.Context, order *models.Order) (bool, error) {
+	return true, m.SaveOrders(ctx, []*models.Order{order})
+}
+
+func (m *mockSaver) SaveOrders(ctx context.Context, orders []*models.Order) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.down {
+		return errors.New("database is not available")
+	}
+	for _, order := range orders {
+		if m.failing[order.OrderUID] {
+			return fmt.Errorf("order %s: %w", order.OrderUID, &pgconn.PgError{Code: "23505", Message: "duplicate key"})
+		}
+		if m.transient[order.OrderUID] > 0 {
+			m.transient[order.OrderUID]--
+			return fmt.Errorf("order %s: %w", order.OrderUID, &pgconn.PgError{Code: "40001"})
+		}
+	}
+	for _, order := range orders {
+		m.saved[order.OrderUID] = true
+	}
+	return nil
+}
+
+// testFile returns NDJSON with valid orders, order3 is invalid
+func testFile(t *testing.T, count int) string {
+	var lines []string
+	for i := 1; i <= count; i++ {
+		order := fmt.Sprintf(
+			`{"order_uid":"order%d","track_number":"TRACK","entry":"WBIL","customer_id":"customer1","sm_id":1,`+
+				`"delivery":{"name":"Test User","phone":"+79990000000","city":"Moscow","address":"Lenina 1"},`+
+				`"payment":{"transaction":"order%d","currency":"USD","provider":"wbpay","amount":100,"goods_total":100},`+
+				`"items":[{"chrt_id":%d,"track_number":"TRACK","price":100,"name":"Item","total_price":100,`+
+				`"nm_id":1,"brand":"Nike"}]}`, i, i, i,
+		)
+		if i == 3 {
+			order = `{"order_uid":"order3"}`
+		}
+		lines = append(lines, order)
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+func newTestImporter(t *testing.T, saver *mockSaver, cp checkpoint) (*importer, *bytes.Buffer) {
+	retryDelay = time.Millisecond
+	rejects := &bytes.Buffer{}
+	return &importer{
+		saver:          saver,
+		batchSize:      2,
+		workers:        3,
+		rejects:        rejects,
+		errOut:         &bytes.Buffer{},
+		checkpointPath: filepath.Join(t.TempDir(), "orders.checkpoint"),
+		checkpoint:     cp,
+	}, rejects
+}
+
+func readRejects(t *testing.T, data *bytes.Buffer) []reject {
+	var rejects []reject
+	for _, line := range strings.Split(strings.TrimSpace(data.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var r reject
+		if err := json.Unmarshal([]byte(line), &r); err != nil {
+			t.Fatalf("error: %v", err)
+		}
+		rejects = append(rejects, r)
+	}
+	return rejects
+}
+
+func TestImporter_Run(t *testing.T) {
+	saver := &mockSaver{saved: map[string]bool{}, failing: map[string]bool{"order6": true}}
+	imp, rejectsOut := newTestImporter(t, saver, checkpoint{File: "orders.ndjson"})
+	reader, _ := export.NewReader(export.FormatNDJSON, strings.NewReader(testFile(t, 9)))
+
+	result, err := imp.run(context.Background(), reader)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if result.Line != 9 || result.Imported != 7 || result.Rejected != 2 {
+		t.Errorf("error: unexpected checkpoint %+v", result)
+	}
+	if len(saver.saved) != 7 || !saver.saved["order5"] || saver.saved["order6"] {
+		t.Errorf("error: expected orders of the failed batch to be saved one by one, got %v", saver.saved)
+	}
+
+	rejects := readRejects(t, rejectsOut)
+	if len(rejects) != 2 || rejects[0].Line != 3 || rejects[1].OrderUID != "order6" {
+		t.Errorf("error: expected rejects in the order of the file, got %+v", rejects)
+	}
+
+	stored, err := loadCheckpoint(imp.checkpointPath, "orders.ndjson")
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if stored.Line != 9 || stored.Imported != 7 {
+		t.Errorf("error: unexpected stored checkpoint %+v", stored)
+	}
+	if _, err := loadCheckpoint(imp.checkpointPath, "other.ndjson"); err == nil {
+		t.Errorf("error: expected error for a checkpoint of another file")
+	}
+}
+
+func TestImporter_Resume(t *testing.T) {
+	saver := &mockSaver{saved: map[string]bool{}}
+	imp, _ := newTestImporter(t, saver, checkpoint{File: "orders.ndjson", Line: 4, Imported: 3, Rejected: 1})
+	reader, _ := export.NewReader(export.FormatNDJSON, strings.NewReader(testFile(t, 6)))
+
+	result, err := imp.run(context.Background(), reader)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if len(saver.saved) != 2 || !saver.saved["order5"] || !saver.saved["order6"] {
+		t.Errorf("error: expected only orders after the checkpoint to be saved, got %v", saver.saved)
+	}
+	if result.Line != 6 || result.Imported != 5 || result.Rejected != 1 {
+		t.Errorf("error: unexpected checkpoint %+v", result)
+	}
+}
+
+func TestImporter_DatabaseDown(t *testing.T) {
+	saver := &mockSaver{saved: map[string]bool{}, down: true}
+	imp, rejectsOut := newTestImporter(t, saver, checkpoint{File: "orders.ndjson"})
+	reader, _ := export.NewReader(export.FormatNDJSON, strings.NewReader(testFile(t, 9)))
+
+	result, err := imp.run(context.Background(), reader)
+	if err == nil {
+		t.Fatalf("error: expected error when the database is down")
+	}
+	if result.Line != 0 || result.Imported != 0 {
+		t.Errorf("error: expected the checkpoint not to advance, got %+v", result)
+	}
+	if rejectsOut.Len() != 0 {
+		t.Errorf("error: expected orders not to be rejected when the database is down")
+	}
+}
+
+func TestImporter_InvalidRun(t *testing.T) {
+	saver := &mockSaver{saved: map[string]bool{}}
+	imp, _ := newTestImporter(t, saver, checkpoint{File: "orders.ndjson"})
+	batches := make(chan *batch, 10)
+	data := strings.Repeat(`{"order_uid":"invalid"}`+"\n", 5)
+	reader, _ := export.NewReader(export.FormatNDJSON, strings.NewReader(data))
+
+	if err := imp.read(context.Background(), reader, batches); err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	close(batches)
+
+	var sizes []int
+	for b := range batches {
+		sizes = append(sizes, len(b.rejects))
+	}
+	if fmt.Sprint(sizes) != "[2 2 1]" {
+		t.Errorf("error: expected rejects to be flushed in batches of the batch size, got %v", sizes)
+	}
+}
+
+func TestImporter_TransientErrors(t *testing.T) {
+	saver := &mockSaver{saved: map[string]bool{}, transient: map[string]int{"order2": 2}}
+	imp, rejectsOut := newTestImporter(t, saver, checkpoint{File: "orders.ndjson"})
+	reader, _ := export.NewReader(export.FormatNDJSON, strings.NewReader(testFile(t, 4)))
+
+	result, err := imp.run(context.Background(), reader)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if result.Imported != 3 || result.Rejected != 1 || !saver.saved["order2"] {
+		t.Errorf("error: expected the order to be saved after retries, got %+v", result)
+	}
+	if rejects := readRejects(t, rejectsOut); len(rejects) != 1 || rejects[0].Line != 3 {
+		t.Errorf("error: expected only the invalid order to be rejected, got %+v", rejects)
+	}
+
+	saver = &mockSaver{saved: map[string]bool{}, transient: map[string]int{"order2": saveAttempts + 1}}
+	imp, rejectsOut = newTestImporter(t, saver, checkpoint{File: "orders.ndjson"})
+	reader, _ = export.NewReader(export.FormatNDJSON, strings.NewReader(testFile(t, 4)))
+
+	result, err = imp.run(context.Background(), reader)
+	if err == nil || !strings.Contains(err.Error(), "order2") {
+		t.Fatalf("error: expected the import to fail on the order failing after retries, got %v", err)
+	}
+	if result.Line != 0 || rejectsOut.Len() != 0 {
+		t.Errorf("error: expected the checkpoint not to pass the order which wasn't saved, got %+v", result)
+	}
+}
+
+func TestFileFormat(t *testing.T) {
+	tests := map[string]string{"orders.csv": "csv", "orders.ndjson": "ndjson", "orders.jsonl": "ndjson"}
+	for path, expected := range tests {
+		if format, err := fileFormat(path, ""); err != nil || format != expected {
+			t.Errorf("error: expected %s for %s, got %s, %v", expected, path, format, err)
+		}
+	}
+	if _, err := fileFormat("orders.parquet", ""); err == nil {
+		t.Errorf("error: expected error for parquet files")
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/joho/godotenv"
+
+	"l0/internal/config"
+	"l0/internal/db"
+	"l0/internal/export"
+)
+
+func main() {
+	godotenv.Load("deployments/.env")
+
+	configPath := flag.String("config", "config/config.yml", "Path to the service config with database settings")
+	file := flag.String("file", "", "File with orders: NDJSON or CSV with one row per item")
+	format := flag.String("format", "", "Format of the file: ndjson or csv, detected by extension by default")
+	batchSize := flag.Int("batch", 500, "Number of orders saved in one transaction")
+	workers := flag.Int("workers", 4, "Number of batches saved in parallel")
+	checkpointPath := flag.String("checkpoint", "", "Checkpoint file, <file>.checkpoint by default")
+	rejectsPath := flag.String("rejects", "", "File with rejected records, <file>.rejects by default")
+	events := flag.Bool("events", false, "Add order.saved events to the outbox if the outbox is enabled")
+	flag.Parse()
+
+	if *file == "" {
+		log.Fatal("File is required")
+	}
+	if *batchSize <= 0 || *workers <= 0 {
+		log.Fatal("Batch size and workers have to be positive")
+	}
+	path, err := filepath.Abs(*file)
+	if err != nil {
+		log.Fatalf("Invalid file: %v", err)
+	}
+	fileFormat, err := fileFormat(path, *format)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *checkpointPath == "" {
+		*checkpointPath = path + ".checkpoint"
+	}
+	if *rejectsPath == "" {
+		*rejectsPath = path + ".rejects"
+	}
+
+	cfg, err := config.LoadConfig(*configPath)
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		log.Fatalf("Invalid config: %v", err)
+	}
+	// Historical orders are not published as new ones unless asked
+	cfg.Outbox.Enabled = cfg.Outbox.Enabled && *events
+
+	cp, err := loadCheckpoint(*checkpointPath, path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if cp.Line > 0 {
+		fmt.Printf("Resuming after line %d: imported %d, rejected %d\n", cp.Line, cp.Imported, cp.Rejected)
+	}
+
+	input, err := os.Open(path)
+	if err != nil {
+		log.Fatalf("Failed to open file: %v", err)
+	}
+	defer input.Close()
+	reader, err := export.NewReader(fileFormat, input)
+	if err != nil {
+		log.Fatalf("Failed to read file: %v", err)
+	}
+
+	rejects, err := os.OpenFile(*rejectsPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		log.Fatalf("Failed to open rejects file: %v", err)
+	}
+	defer rejects.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	repository, err := db.NewOrderRepo(ctx, cfg)
+	if err != nil {
+		log.Fatalf("Failed to initialize repository: %v", err)
+	}
+	defer repository.Close()
+
+	imp := &importer{
+		saver:          repository,
+		batchSize:      *batchSize,
+		workers:        *workers,
+		rejects:        rejects,
+		errOut:         os.Stderr,
+		checkpointPath: *checkpointPath,
+		checkpoint:     cp,
+	}
+
+	start := time.Now()
+	result, err := imp.run(ctx, reader)
+	elapsed := time.Since(start)
+	fmt.Printf(
+		"Line %d: imported %d, rejected %d, this run imported %d in %s (%.0f orders/s)\n",
+		result.Line, result.Imported, result.Rejected, result.Imported-cp.Imported, elapsed.Round(time.Millisecond),
+		float64(result.Imported-cp.Imported)/elapsed.Seconds(),
+	)
+	if result.Rejected > 0 {
+		fmt.Printf("Rejected records are written to %s\n", *rejectsPath)
+	}
+	if err != nil {
+		log.Fatalf("Import failed, run again to resume: %v", err)
+	}
+	if ctx.Err() != nil {
+		fmt.Println("Import interrupted, run again to resume")
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"l0/internal/config"
+	"l0/internal/db"
+	"l0/internal/kafka"
+	"l0/internal/models"
+)
+
+const adminUsage = `Usage: order_service admin <command> [flags]
+
+Commands:
+  reset-offsets  move the consumer group to earliest, latest, a timestamp or partition offsets
+  replay         reprocess a range of messages in a separate consumer group
+
+Offsets in Kafka can be reset only while the service is stopped, use the HTTP endpoints for a running service.
+`
+
+// runAdmin runs the admin command and returns the exit code
+func runAdmin(cfg *config.Config, args []string, logger *zerolog.Logger) int {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, adminUsage)
+		return 2
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	var err error
+	switch args[0] {
+	case "reset-offsets":
+		err = runResetOffsets(ctx, cfg, args[1:], logger)
+	case "replay":
+		err = runReplay(ctx, cfg, args[1:], logger)
+	default:
+		fmt.Fprint(os.Stderr, adminUsage)
+		return 2
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s failed: %v\n", args[0], err)
+		return 1
+	}
+	return 0
+}
+
+// runResetOffsets resets offsets of the consumer group and prints them
+func runResetOffsets(ctx context.Context, cfg *config.Config, args []string, logger *zerolog.Logger) error {
+	flags := flag.NewFlagSet("reset-offsets", flag.ContinueOnError)
+	topic := flags.String("topic", cfg.Kafka.Topic, "topic to reset offsets in")
+	to := flags.String("to", "", "reset strategy: earliest, latest, timestamp or offsets")
+	timestamp := flags.String("timestamp", "", "RFC 3339 time for the timestamp strategy")
+	partitionOffsets := flags.String("offsets", "", "offsets for the offsets strategy as partition=offset,...")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	reset := models.OffsetReset{Topic: *topic, To: *to}
+	var err error
+	if *timestamp != "" {
+		if reset.Timestamp, err = parseTime(*timestamp); err != nil {
+			return err
+		}
+	}
+	if *partitionOffsets != "" {
+		if reset.Offsets, err = parseOffsets(*partitionOffsets); err != nil {
+			return err
+		}
+	}
+
+	consumer, closeConsumer, err := newAdminConsumer(ctx, cfg, logger)
+	if err != nil {
+		return err
+	}
+	defer closeConsumer()
+
+	result, err := consumer.ResetGroup(ctx, reset)
+	if err != nil {
+		return err
+	}
+	return printJSON(map[string]any{"group_id": cfg.Kafka.GroupID, "topic": reset.Topic, "offsets": result})
+}
+
+// runReplay replays the range of messages and prints the result
+func runReplay(ctx context.Context, cfg *config.Config, args []string, logger *zerolog.Logger) error {
+	flags := flag.NewFlagSet("replay", flag.ContinueOnError)
+	topic := flags.String("topic", cfg.Kafka.Topic, "topic to replay")
+	group := flags.String("group", "", "replay consumer group, group_id with replay_group_suffix by default")
+	from := flags.String("from", "", "start of the range as RFC 3339 time or partition=offset,..., earliest by default")
+	to := flags.String("to", "", "end of the range as RFC 3339 time or partition=offset,..., latest by default")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	replay := models.Replay{Topic: *topic, GroupID: *group}
+	var err error
+	if replay.FromTime, replay.FromOffsets, err = parseBound(*from); err != nil {
+		return err
+	}
+	if replay.ToTime, replay.ToOffsets, err = parseBound(*to); err != nil {
+		return err
+	}
+
+	consumer, closeConsumer, err := newAdminConsumer(ctx, cfg, logger)
+	if err != nil {
+		return err
+	}
+	defer closeConsumer()
+
+	status, err := consumer.Replay(ctx, replay)
+	if printErr := printJSON(status); printErr != nil {
+		return printErr
+	}
+	return err
+}
+
+// newAdminConsumer creates a consumer which isn't started, with the order service processing replayed messages
+func newAdminConsumer(
+	ctx context.Context, cfg *config.Config, logger *zerolog.Logger,
+) (*kafka.Consumer, func(), error) {
+	repository, err := db.NewOrderRepo(ctx, cfg)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to initialize repository: %w", err)
+	}
+
+	orderService, err := newOrderService(cfg, repository, nil, logger)
+	if err != nil {
+		repository.Close()
+		return nil, nil, err
+	}
+
+	kafkaLogger := logger.With().Str("component", "kafka-admin").Logger()
+	consumer := kafka.NewConsumer(*cfg, orderService, repository, &kafkaLogger)
+	consumer.RegisterHandler(kafka.HandlerPaymentUpdates, kafka.NewPaymentUpdateHandler(orderService, &kafkaLogger))
+
+	return consumer, repository.Close, nil
+}
+
+// parseBound parses a bound of the replay range as RFC 3339 time or partition offsets
+func parseBound(value string) (*time.Time, map[int]int64, error) {
+	if value == "" {
+		return nil, nil, nil
+	}
+	if strings.Contains(value, "=") {
+		partitionOffsets, err := parseOffsets(value)
+		return nil, partitionOffsets, err
+	}
+	at, err := parseTime(value)
+	return at, nil, err
+}
+
+// parseTime parses RFC 3339 time
+func parseTime(value string) (*time.Time, error) {
+	at, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid time %q: %w", value, err)
+	}
+	return &at, nil
+}
+
+// parseOffsets parses offsets set as partition=offset,...
+func parseOffsets(value string) (map[int]int64, error) {
+	result := make(map[int]int64)
+	for _, pair := range strings.Split(value, ",") {
+		partition, offset, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid partition offset %q", pair)
+		}
+		p, err := strconv.Atoi(partition)
+		if err != nil || p < 0 {
+			return nil, fmt.Errorf("invalid partition %q", partition)
+		}
+		o, err := strconv.ParseInt(offset, 10, 64)
+		if err != nil || o < 0 {
+			return nil, fmt.Errorf("invalid offset %q", offset)
+		}
+		result[p] = o
+	}
+	return result, nil
+}
+
+// printJSON prints the value to stdout as indented JSON
+func printJSON(value any) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(value)
+}
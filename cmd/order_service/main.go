@@ -11,14 +11,22 @@ import (
 
 	"github.com/rs/zerolog"
 
+	"l0/internal/audit"
+	"l0/internal/auth"
 	"l0/internal/cache"
 	"l0/internal/cache/lru_cache"
 	"l0/internal/config"
+	"l0/internal/currency"
 	"l0/internal/db"
+	"l0/internal/events"
+	"l0/internal/interfaces"
 	"l0/internal/kafka"
 	"l0/internal/models"
+	"l0/internal/outbox"
+	"l0/internal/retention"
 	"l0/internal/server"
 	"l0/internal/service"
+	"l0/internal/webhook"
 )
 
 func main() {
@@ -35,6 +43,10 @@ func main() {
 
 	logger := zerolog.New(os.Stdout).With().Timestamp().Logger()
 
+	if len(os.Args) > 1 && os.Args[1] == "admin" {
+		os.Exit(runAdmin(cfg, os.Args[2:], &logger))
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
@@ -48,26 +60,109 @@ func main() {
 		logger.Fatal().Err(err).Msg("Failed to initialize repository")
 	}
 
-	lruCache, err := lru_cache.NewLRUCache[string, *models.Order](cfg.Cache.Capacity)
-	if err != nil {
-		logger.Fatal().Err(err).Msg("Failed to initialize LRU cache")
+	if cfg.Privacy.Encryption.RotateOnStart {
+		go rotateEncryptionKeys(ctx, repository, &logger)
 	}
 
-	cacheLogger := logger.With().Str("component", "cache-manager").Logger()
-	cacheManager := cache.NewManager(lruCache, repository, &cacheLogger)
+	var bus *events.Bus
+	var changes interfaces.OrderChangePublisher
+	if cfg.Server.Stream.Enabled {
+		bus = events.NewBus(cfg.Server.Stream)
+		changes = bus
+	}
 
-	serviceLogger := logger.With().Str("component", "order-service").Logger()
-	orderService := service.NewOrderService(cacheManager, &serviceLogger)
+	orderService, err := newOrderService(cfg, repository, changes, &logger)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("Failed to initialize order service")
+	}
 
 	if err := orderService.WarmCache(ctx); err != nil {
 		logger.Warn().Err(err).Msg("Failed to warm cache, continuing with empty cache")
 	}
 
-	serverLogger := logger.With().Str("component", "http-server").Logger()
-	httpServer := server.New(cfg, orderService, &serverLogger)
+	var authenticator *auth.Authenticator
+	if cfg.Auth.Enabled {
+		authenticator, err = auth.NewAuthenticator(cfg.Auth)
+		if err != nil {
+			logger.Fatal().Err(err).Msg("Failed to initialize authenticator")
+		}
+	}
 
 	kafkaLogger := logger.With().Str("component", "kafka-consumer").Logger()
-	kafkaConsumer := kafka.NewConsumer(*cfg, orderService, &kafkaLogger)
+	kafkaConsumer := kafka.NewConsumer(*cfg, orderService, repository, &kafkaLogger)
+	kafkaConsumer.RegisterHandler(kafka.HandlerPaymentUpdates, kafka.NewPaymentUpdateHandler(orderService, &kafkaLogger))
+	if cfg.Kafka.Backpressure.Enabled {
+		kafkaConsumer.AddPauseCondition("order_service_circuit_open", orderService.CircuitOpen)
+		if threshold := cfg.Kafka.Backpressure.PoolSaturation; threshold > 0 {
+			kafkaConsumer.AddPauseCondition(
+				"db_pool_saturated", func() bool {
+					return repository.PoolSaturation() >= threshold
+				},
+			)
+		}
+	}
+
+	reportLogger := logger.With().Str("component", "report-service").Logger()
+	reportService := service.NewReportService(
+		db.NewReportRepo(database, cfg.Reports.MaterializedView), cfg.Reports, &reportLogger,
+	)
+	if err := reportService.Start(ctx); err != nil {
+		logger.Fatal().Err(err).Msg("Failed to start report service")
+	}
+
+	var auditLog *audit.Log
+	var auditRecorder interfaces.AuditLog
+	if cfg.Audit.Enabled {
+		auditLogger := logger.With().Str("component", "audit-log").Logger()
+		auditLog = audit.NewLog(repository, cfg.Audit, &auditLogger)
+		if err := auditLog.Start(ctx); err != nil {
+			logger.Fatal().Err(err).Msg("Failed to start audit log")
+		}
+		auditRecorder = auditLog
+	}
+
+	var webhookWorker *webhook.Worker
+	var webhookService interfaces.WebhookService
+	if cfg.Webhooks.Enabled {
+		webhookService = webhook.NewService(repository)
+		webhookLogger := logger.With().Str("component", "webhook-worker").Logger()
+		webhookWorker = webhook.NewWorker(repository, cfg.Webhooks, &webhookLogger)
+		if err := webhookWorker.Start(ctx); err != nil {
+			logger.Fatal().Err(err).Msg("Failed to start webhook worker")
+		}
+	}
+
+	serverLogger := logger.With().Str("component", "http-server").Logger()
+	httpServer := server.New(
+		cfg, orderService, reportService, kafkaConsumer, auditRecorder, bus, webhookService, authenticator,
+		&serverLogger,
+	)
+
+	var eventPublisher *kafka.EventPublisher
+	var outboxRelay *outbox.Relay
+	if cfg.Outbox.Enabled {
+		eventPublisher, err = kafka.NewEventPublisher(cfg.Kafka, cfg.Outbox.Topic)
+		if err != nil {
+			logger.Fatal().Err(err).Msg("Failed to initialize event publisher")
+		}
+		outboxLogger := logger.With().Str("component", "outbox-relay").Logger()
+		outboxRelay = outbox.NewRelay(repository, eventPublisher, cfg.Outbox, &outboxLogger)
+		if cfg.Webhooks.Enabled {
+			outboxRelay.KeepPendingWebhooks()
+		}
+		if err := outboxRelay.Start(ctx); err != nil {
+			logger.Fatal().Err(err).Msg("Failed to start outbox relay")
+		}
+	}
+
+	var retentionJob *retention.Job
+	if cfg.Privacy.Retention.Enabled {
+		retentionLogger := logger.With().Str("component", "retention-job").Logger()
+		retentionJob = retention.NewJob(repository, orderService, cfg.Privacy.Retention, &retentionLogger)
+		if err := retentionJob.Start(ctx); err != nil {
+			logger.Fatal().Err(err).Msg("Failed to start retention job")
+		}
+	}
 
 	var wg sync.WaitGroup
 	errChan := make(chan error, 2)
@@ -128,6 +223,23 @@ func main() {
 		}()
 
 		stopWg.Wait()
+		reportService.Stop()
+		if auditLog != nil {
+			auditLog.Stop()
+		}
+		if retentionJob != nil {
+			retentionJob.Stop()
+		}
+		if webhookWorker != nil {
+			webhookWorker.Stop()
+		}
+
+		if outboxRelay != nil {
+			outboxRelay.Stop()
+			if err := eventPublisher.Close(); err != nil {
+				stopErrors = append(stopErrors, fmt.Errorf("failed to close event publisher: %w", err))
+			}
+		}
 
 		database.Close()
 
@@ -139,4 +251,69 @@ func main() {
 	}()
 
 	<-ctx.Done()
-}
\ No newline at end of file
+}
+
+// newOrderService creates the order service with the cache, the currency converter and the publisher of changes
+func newOrderService(
+	cfg *config.Config, repository *db.OrderRepo, changes interfaces.OrderChangePublisher, logger *zerolog.Logger,
+) (*service.OrderService, error) {
+	lruCache, err := lru_cache.NewLRUCache[string, *models.Order](cfg.Cache.Capacity)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize LRU cache: %w", err)
+	}
+
+	cacheLogger := logger.With().Str("component", "cache-manager").Logger()
+	cacheManager := cache.NewManager(lruCache, repository, &cacheLogger)
+
+	var converter *currency.Converter
+	if cfg.Currency.RatesFile != "" {
+		rateProvider, err := currency.NewFileRateProvider(cfg.Currency.RatesFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load currency rates: %w", err)
+		}
+		converter, err = currency.NewConverter(rateProvider, cfg.Currency.BaseCurrency)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize currency converter: %w", err)
+		}
+	}
+
+	serviceLogger := logger.With().Str("component", "order-service").Logger()
+	return service.NewOrderService(
+		cacheManager, repository, repository, repository, repository, converter, changes, &serviceLogger,
+	), nil
+}
+
+// rotateEncryptionKeys re-encrypts stored deliveries and webhook secrets with the active key in batches
+func rotateEncryptionKeys(ctx context.Context, repository *db.OrderRepo, logger *zerolog.Logger) {
+	total := 0
+	for {
+		rotated, err := repository.RotateDeliveryKeys(ctx, 500)
+		if err != nil {
+			logger.Error().Err(err).Int("rotated", total).Msg("Failed to rotate delivery encryption keys")
+			return
+		}
+		total += rotated
+		if rotated == 0 {
+			break
+		}
+	}
+	if total > 0 {
+		logger.Info().Int("rotated", total).Msg("Delivery encryption keys rotated")
+	}
+
+	total = 0
+	for {
+		rotated, err := repository.RotateWebhookSecretKeys(ctx, 500)
+		if err != nil {
+			logger.Error().Err(err).Int("rotated", total).Msg("Failed to rotate webhook secret encryption keys")
+			return
+		}
+		total += rotated
+		if rotated == 0 {
+			break
+		}
+	}
+	if total > 0 {
+		logger.Info().Int("rotated", total).Msg("Webhook secret encryption keys rotated")
+	}
+}
@@ -0,0 +1,314 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/segmentio/kafka-go"
+
+	"l0/internal/envelope"
+	"l0/internal/export"
+	"l0/internal/models"
+)
+
+// Formats of fixture files
+const (
+	FixtureJSON   = "json"
+	FixtureNDJSON = "ndjson"
+	FixtureCSV    = "csv"
+)
+
+// fixtureBatchSize is the number of orders written to Kafka at once
+const fixtureBatchSize = 100
+
+// A fixture is an order read from a file with its position in the file for reports
+type fixture struct {
+	source string
+	order  *models.Order
+	err    error
+}
+
+// fixtureFormat returns the format of the file by its extension if the format isn't set
+func fixtureFormat(path, format string) (string, error) {
+	if format == "" {
+		format = strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")
+		if format == "jsonl" {
+			format = FixtureNDJSON
+		}
+	}
+	switch format {
+	case FixtureJSON, FixtureNDJSON, FixtureCSV:
+		return format, nil
+	default:
+		return "", fmt.Errorf("unknown fixture format %q", format)
+	}
+}
+
+// readFixtures reads orders of the format, records which can't be parsed are returned with errors.
+// An error is returned only if the file can't be read at all
+func readFixtures(r io.Reader, format string) ([]fixture, error) {
+	switch format {
+	case FixtureJSON:
+		return readJSONFixtures(r)
+	case FixtureNDJSON:
+		return readNDJSONFixtures(r)
+	case FixtureCSV:
+		return readCSVFixtures(r)
+	default:
+		return nil, fmt.Errorf("unknown fixture format %q", format)
+	}
+}
+
+// readJSONFixtures reads a JSON array of orders
+func readJSONFixtures(r io.Reader) ([]fixture, error) {
+	decoder := json.NewDecoder(r)
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read JSON array: %w", err)
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != '[' {
+		return nil, errors.New("JSON fixtures have to be an array of orders")
+	}
+
+	var fixtures []fixture
+	for i := 1; decoder.More(); i++ {
+		var raw json.RawMessage
+		if err := decoder.Decode(&raw); err != nil {
+			return fixtures, fmt.Errorf("record %d: %w", i, err)
+		}
+		fixtures = append(fixtures, parseJSONFixture(fmt.Sprintf("record %d", i), raw))
+	}
+	return fixtures, nil
+}
+
+// readNDJSONFixtures reads orders one per line, empty lines are skipped
+func readNDJSONFixtures(r io.Reader) ([]fixture, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+
+	var fixtures []fixture
+	for line := 1; scanner.Scan(); line++ {
+		data := bytes.TrimSpace(scanner.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+		fixtures = append(fixtures, parseJSONFixture(fmt.Sprintf("line %d", line), data))
+	}
+	return fixtures, scanner.Err()
+}
+
+// parseJSONFixture parses an order, fixtures of older payload versions are upcast like consumed messages
+func parseJSONFixture(source string, data []byte) fixture {
+	wrapped, err := envelope.Parse(data)
+	if err != nil {
+		return fixture{source: source, err: err}
+	}
+	payload, err := envelope.DefaultUpcasters().Upcast(wrapped.SchemaVersion, wrapped.Payload)
+	if err != nil {
+		return fixture{source: source, err: err}
+	}
+
+	var decoded envelope.OrderPayload
+	if err := json.Unmarshal(payload, &decoded); err != nil {
+		return fixture{source: source, err: err}
+	}
+	return fixture{source: source, order: decoded.Order}
+}
+
+// readCSVFixtures reads flattened orders with one row per item. Rows of one order are grouped by order_uid,
+// orders keep the order of their first rows
+func readCSVFixtures(r io.Reader) ([]fixture, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV header: %w", err)
+	}
+	uidColumn := -1
+	for i, column := range header {
+		header[i] = strings.TrimSpace(column)
+		if !export.KnownColumn(header[i]) {
+			return nil, fmt.Errorf("unknown CSV column %q", header[i])
+		}
+		if header[i] == "order_uid" {
+			uidColumn = i
+		}
+	}
+	if uidColumn < 0 {
+		return nil, errors.New("CSV column order_uid is required")
+	}
+
+	var fixtures []*fixture
+	byUID := make(map[string]*fixture)
+	for row := 2; ; row++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			fixtures = append(fixtures, &fixture{source: fmt.Sprintf("row %d", row), err: err})
+			continue
+		}
+
+		uid := record[uidColumn]
+		current, ok := byUID[uid]
+		if !ok {
+			current = &fixture{source: fmt.Sprintf("row %d", row), order: &models.Order{}}
+			byUID[uid] = current
+			fixtures = append(fixtures, current)
+		}
+
+		var item models.Item
+		for i, value := range record {
+			// Order fields are taken from the first row, so rows of one order don't have to repeat them
+			if ok && !strings.HasPrefix(header[i], "item_") {
+				continue
+			}
+			if err := export.SetColumn(current.order, &item, header[i], strings.TrimSpace(value)); err != nil {
+				current.err = errors.Join(current.err, fmt.Errorf("row %d: column %s: %w", row, header[i], err))
+			}
+		}
+		current.order.Items = append(current.order.Items, item)
+	}
+
+	result := make([]fixture, len(fixtures))
+	for i, f := range fixtures {
+		result[i] = *f
+	}
+	return result, nil
+}
+
+// messageKey returns the key of the order message by the key field
+func messageKey(order *models.Order, field string) ([]byte, error) {
+	switch field {
+	case "order_uid":
+		return []byte(order.OrderUID), nil
+	case "transaction":
+		return []byte(order.Payment.Transaction), nil
+	case "customer_id":
+		return []byte(order.CustomerID), nil
+	case "track_number":
+		return []byte(order.TrackNumber), nil
+	case "none":
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unknown key field %q", field)
+	}
+}
+
+// headerFlags collects message headers set as key=value
+type headerFlags []kafka.Header
+
+// String is an implementation of flag.Value
+func (h *headerFlags) String() string {
+	pairs := make([]string, len(*h))
+	for i, header := range *h {
+		pairs[i] = header.Key + "=" + string(header.Value)
+	}
+	return strings.Join(pairs, ",")
+}
+
+// Set is an implementation of flag.Value
+func (h *headerFlags) Set(value string) error {
+	key, headerValue, ok := strings.Cut(value, "=")
+	if !ok || strings.TrimSpace(key) == "" {
+		return fmt.Errorf("header has to be set as key=value: %q", value)
+	}
+	*h = append(*h, kafka.Header{Key: strings.TrimSpace(key), Value: []byte(headerValue)})
+	return nil
+}
+
+// A fixtureReport collects results of the file replay
+type fixtureReport struct {
+	read    int
+	invalid int
+	sent    int
+	failed  int
+}
+
+// runFixtures validates orders of the file and publishes valid ones, invalid records are reported to errOut
+func runFixtures(
+	ctx context.Context, writer *kafka.Writer, path, format, keyField string, headers []kafka.Header,
+	dryRun bool, errOut io.Writer,
+) (*fixtureReport, error) {
+	format, err := fixtureFormat(path, format)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := messageKey(&models.Order{}, keyField); err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	fixtures, err := readFixtures(file, format)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &fixtureReport{read: len(fixtures)}
+	var batch []kafka.Message
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := writer.WriteMessages(ctx, batch...); err != nil {
+			fmt.Fprintf(errOut, "Failed to send %d orders: %v\n", len(batch), err)
+			report.failed += len(batch)
+		} else {
+			report.sent += len(batch)
+		}
+		batch = batch[:0]
+	}
+
+	for _, f := range fixtures {
+		err := f.err
+		if err == nil {
+			err = f.order.Validate()
+		}
+		if err != nil {
+			report.invalid++
+			fmt.Fprintf(errOut, "Invalid %s: %v\n", f.source, err)
+			continue
+		}
+		if dryRun {
+			continue
+		}
+
+		value, err := json.Marshal(f.order)
+		if err != nil {
+			report.invalid++
+			fmt.Fprintf(errOut, "Invalid %s: %v\n", f.source, err)
+			continue
+		}
+		key, _ := messageKey(f.order, keyField)
+		batch = append(batch, kafka.Message{Key: key, Value: value, Headers: headers})
+		if len(batch) == fixtureBatchSize {
+			flush()
+		}
+	}
+	flush()
+
+	return report, nil
+}
+
+// print writes the report
+func (r *fixtureReport) print(w io.Writer) {
+	fmt.Fprintf(
+		w, "Read: %d, invalid: %d, sent: %d, failed: %d\n", r.read, r.invalid, r.sent, r.failed,
+	)
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+const fixtureCSV = `order_uid,track_number,entry,customer_id,sm_id,date_created,delivery_name,delivery_phone,delivery_city,` +
+	`delivery_address,payment_transaction,payment_currency,payment_provider,payment_amount,payment_goods_total,` +
+	`item_chrt_id,item_track_number,item_price,item_name,item_total_price,item_nm_id,item_brand
+order1,TRACK1,WBIL,customer1,1,2024-01-02T03:04:05Z,Test User,+79990000000,Moscow,Lenina 1,order1,USD,wbpay,300,300,` +
+	`1,TRACK1,100,Item 1,100,1,Nike
+order2,TRACK2,WBIL,customer2,1,2024-01-02T03:04:05Z,Test User,+79990000000,Moscow,Lenina 1,order2,USD,wbpay,100,100,` +
+	`3,TRACK2,100,Item 3,100,3,Zara
+order1,,,,,,,,,,,,,,,2,TRACK1,200,Item 2,200,2,Adidas
+order3,TRACK3,WBIL,customer3,one,2024-01-02T03:04:05Z,Test User,+79990000000,Moscow,Lenina 1,order3,USD,wbpay,1,1,` +
+	`4,TRACK3,1,Item 4,1,4,Zara
+`
+
+func TestReadCSVFixtures(t *testing.T) {
+	fixtures, err := readCSVFixtures(strings.NewReader(fixtureCSV))
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if len(fixtures) != 3 {
+		t.Fatalf("error: expected 3 orders, got %d", len(fixtures))
+	}
+
+	order := fixtures[0].order
+	if fixtures[0].err != nil || order.OrderUID != "order1" || len(order.Items) != 2 {
+		t.Fatalf("error: expected order1 with 2 items, got %+v, %v", order, fixtures[0].err)
+	}
+	if err := order.Validate(); err != nil {
+		t.Errorf("error: expected valid order, got %v", err)
+	}
+	if fixtures[1].order.OrderUID != "order2" || len(fixtures[1].order.Items) != 1 {
+		t.Errorf("error: expected order2 with 1 item")
+	}
+	if fixtures[2].err == nil || !strings.Contains(fixtures[2].err.Error(), "sm_id") {
+		t.Errorf("error: expected error in sm_id of order3, got %v", fixtures[2].err)
+	}
+
+	if _, err := readCSVFixtures(strings.NewReader("order_uid,colour\norder1,red\n")); err == nil {
+		t.Errorf("error: expected error for an unknown column")
+	}
+}
+
+func TestReadJSONFixtures(t *testing.T) {
+	fixtures, err := readCSVFixtures(strings.NewReader(fixtureCSV))
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	data, _ := json.Marshal(fixtures[0].order)
+
+	array := "[" + string(data) + `, {"order_uid": 5}]`
+	parsed, err := readFixtures(strings.NewReader(array), FixtureJSON)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if len(parsed) != 2 || parsed[0].err != nil || parsed[1].err == nil || parsed[1].source != "record 2" {
+		t.Errorf("error: expected one valid and one broken record, got %+v", parsed)
+	}
+
+	lines := string(data) + "\n\n{\n"
+	parsed, err = readFixtures(strings.NewReader(lines), FixtureNDJSON)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if len(parsed) != 2 || parsed[0].order.OrderUID != "order1" || parsed[1].source != "line 3" {
+		t.Errorf("error: expected one valid and one broken line, got %+v", parsed)
+	}
+
+	if _, err := readFixtures(strings.NewReader(`{"order_uid":"order1"}`), FixtureJSON); err == nil {
+		t.Errorf("error: expected error for JSON which isn't an array")
+	}
+}
+
+func TestFixtureFormatAndKeys(t *testing.T) {
+	tests := map[string]string{"orders.json": FixtureJSON, "orders.jsonl": FixtureNDJSON, "orders.CSV": FixtureCSV}
+	for path, expected := range tests {
+		if format, err := fixtureFormat(path, ""); err != nil || format != expected {
+			t.Errorf("error: expected %s for %s, got %s, %v", expected, path, format, err)
+		}
+	}
+	if _, err := fixtureFormat("orders.txt", ""); err == nil {
+		t.Errorf("error: expected error for an unknown extension")
+	}
+
+	var headers headerFlags
+	if err := headers.Set("content-type=application/json"); err != nil || len(headers) != 1 {
+		t.Errorf("error: expected header to be parsed, got %v", err)
+	}
+	if err := headers.Set("broken"); err == nil {
+		t.Errorf("error: expected error for a header without value")
+	}
+}
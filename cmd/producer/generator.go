@@ -0,0 +1,365 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"l0/internal/models"
+)
+
+// ruleMalformed is the invalid rule producing messages which aren't JSON
+const ruleMalformed = "malformed"
+
+// duplicateHistory is the number of last sent orders duplicates are picked from
+const duplicateHistory = 1000
+
+// Item IDs keep the run start in seconds above itemSeqBits and the number of the item in the run below
+const (
+	itemSeqBits = 31
+	maxItemSeq  = 1<<itemSeqBits - 1
+)
+
+// invalidRules break orders so they fail the named validation rule
+var invalidRules = map[string]func(order *models.Order){
+	"order_uid": func(order *models.Order) {
+		order.OrderUID += "!"
+	},
+	"missing_order_uid": func(order *models.Order) {
+		order.OrderUID = ""
+	},
+	"track_number": func(order *models.Order) {
+		order.TrackNumber = ""
+	},
+	"customer_id": func(order *models.Order) {
+		order.CustomerID = ""
+	},
+	"items": func(order *models.Order) {
+		order.Items = nil
+	},
+	"date_created": func(order *models.Order) {
+		order.DateCreated = time.Now().Add(24 * time.Hour)
+	},
+	"locale": func(order *models.Order) {
+		order.Locale = "english"
+	},
+	"sm_id": func(order *models.Order) {
+		order.SmID = 0
+	},
+	"goods_total": func(order *models.Order) {
+		order.Payment.GoodsTotal++
+	},
+	"amount": func(order *models.Order) {
+		order.Payment.Amount++
+	},
+	"phone": func(order *models.Order) {
+		order.Delivery.Phone = "call me"
+	},
+	"email": func(order *models.Order) {
+		order.Delivery.Email = "not-an-email"
+	},
+	"currency": func(order *models.Order) {
+		order.Payment.Currency = strings.ToLower(order.Payment.Currency)
+	},
+	"item_total_price": func(order *models.Order) {
+		// Totals are kept consistent, so only the item rule fails
+		order.Items[0].TotalPrice++
+		order.Payment.GoodsTotal++
+		order.Payment.Amount++
+	},
+	"sale": func(order *models.Order) {
+		order.Items[0].Sale = 150
+	},
+}
+
+// An itemDistribution returns the number of items of an order
+type itemDistribution func(rnd *rand.Rand) int
+
+// parseItemDistribution parses the distribution of item counts: "fixed:N", "uniform:MIN-MAX" or "poisson:MEAN"
+func parseItemDistribution(spec string) (itemDistribution, error) {
+	kind, value, _ := strings.Cut(spec, ":")
+	switch kind {
+	case "fixed":
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("invalid fixed item count %q", value)
+		}
+		return func(*rand.Rand) int {
+			return n
+		}, nil
+	case "uniform":
+		minValue, maxValue, ok := strings.Cut(value, "-")
+		lo, loErr := strconv.Atoi(minValue)
+		hi, hiErr := strconv.Atoi(maxValue)
+		if !ok || loErr != nil || hiErr != nil || lo < 1 || hi < lo {
+			return nil, fmt.Errorf("invalid uniform item count range %q", value)
+		}
+		return func(rnd *rand.Rand) int {
+			return lo + rnd.Intn(hi-lo+1)
+		}, nil
+	case "poisson":
+		mean, err := strconv.ParseFloat(value, 64)
+		if err != nil || mean <= 0 {
+			return nil, fmt.Errorf("invalid poisson item count mean %q", value)
+		}
+		return func(rnd *rand.Rand) int {
+			// Knuth's algorithm, orders have at least one item
+			limit, k, p := math.Exp(-mean), 0, 1.0
+			for {
+				p *= rnd.Float64()
+				if p <= limit {
+					break
+				}
+				k++
+			}
+			return max(k, 1)
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown item distribution %q", spec)
+	}
+}
+
+// A weightedChoice picks values with probabilities proportional to their weights
+type weightedChoice struct {
+	values  []string
+	weights []float64
+	total   float64
+}
+
+// parseWeightedChoice parses values with optional weights as "USD:70,EUR:20,RUB", the default weight is 1
+func parseWeightedChoice(spec string) (*weightedChoice, error) {
+	choice := &weightedChoice{}
+	for _, elem := range strings.Split(spec, ",") {
+		value, weightValue, hasWeight := strings.Cut(strings.TrimSpace(elem), ":")
+		if value == "" {
+			continue
+		}
+		weight := 1.0
+		if hasWeight {
+			var err error
+			if weight, err = strconv.ParseFloat(weightValue, 64); err != nil || weight <= 0 {
+				return nil, fmt.Errorf("invalid weight of %q", value)
+			}
+		}
+		choice.values = append(choice.values, value)
+		choice.weights = append(choice.weights, weight)
+		choice.total += weight
+	}
+	if len(choice.values) == 0 {
+		return nil, fmt.Errorf("no values in %q", spec)
+	}
+	return choice, nil
+}
+
+// pick returns a random value
+func (c *weightedChoice) pick(rnd *rand.Rand) string {
+	r := rnd.Float64() * c.total
+	for i, weight := range c.weights {
+		if r < weight {
+			return c.values[i]
+		}
+		r -= weight
+	}
+	return c.values[len(c.values)-1]
+}
+
+// An invalidShare is the percentage of messages breaking the rule
+type invalidShare struct {
+	rule    string
+	percent float64
+}
+
+// parseInvalidShares parses percentages of invalid messages by rules as "phone:1,malformed:0.5"
+func parseInvalidShares(spec string) ([]invalidShare, error) {
+	var shares []invalidShare
+	total := 0.0
+	for _, elem := range strings.Split(spec, ",") {
+		rule, percentValue, ok := strings.Cut(strings.TrimSpace(elem), ":")
+		if rule == "" && !ok {
+			continue
+		}
+		if _, known := invalidRules[rule]; !known && rule != ruleMalformed {
+			return nil, fmt.Errorf("unknown validation rule %q, known rules: %s", rule, strings.Join(ruleNames(), ", "))
+		}
+		percent, err := strconv.ParseFloat(percentValue, 64)
+		if !ok || err != nil || percent < 0 {
+			return nil, fmt.Errorf("invalid percentage of rule %q", rule)
+		}
+		shares = append(shares, invalidShare{rule: rule, percent: percent})
+		total += percent
+	}
+	if total > 100 {
+		return nil, fmt.Errorf("invalid messages exceed 100%%: %v", total)
+	}
+	return shares, nil
+}
+
+// ruleNames returns sorted names of validation rules which can be broken
+func ruleNames() []string {
+	names := []string{ruleMalformed}
+	for name := range invalidRules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// A message is a generated Kafka message
+type message struct {
+	key       string
+	value     []byte
+	rule      string
+	duplicate bool
+}
+
+// A generator creates random orders with IDs unique across runs. It isn't safe for concurrent use
+type generator struct {
+	rnd        *rand.Rand
+	runID      string
+	seq        int64
+	itemBase   int64
+	itemSeq    int64
+	items      itemDistribution
+	currencies *weightedChoice
+	locales    *weightedChoice
+	invalid    []invalidShare
+	duplicates float64
+	history    []message
+}
+
+// newGenerator creates a generator, the run start is added to order and item IDs so runs don't collide
+func newGenerator(
+	seed int64, items itemDistribution, currencies, locales *weightedChoice, invalid []invalidShare,
+	duplicates float64,
+) *generator {
+	start := time.Now()
+	return &generator{
+		rnd:        rand.New(rand.NewSource(seed)),
+		runID:      strconv.FormatInt(start.UnixMilli(), 36),
+		itemBase:   (start.Unix() & maxItemSeq) << itemSeqBits,
+		items:      items,
+		currencies: currencies,
+		locales:    locales,
+		invalid:    invalid,
+		duplicates: duplicates,
+	}
+}
+
+// next returns the next message: a duplicate of a sent order, an invalid order or a valid order
+func (g *generator) next() message {
+	roll := g.rnd.Float64() * 100
+	if len(g.history) > 0 && roll < g.duplicates {
+		duplicate := g.history[g.rnd.Intn(len(g.history))]
+		duplicate.duplicate = true
+		return duplicate
+	}
+
+	order := g.order()
+	rule := g.pickRule()
+	if mutate, ok := invalidRules[rule]; ok {
+		mutate(order)
+	}
+
+	value, _ := json.Marshal(order)
+	if rule == ruleMalformed {
+		value = value[:len(value)/2]
+	}
+
+	msg := message{key: order.OrderUID, value: value, rule: rule}
+	if rule == "" {
+		if len(g.history) < duplicateHistory {
+			g.history = append(g.history, msg)
+		} else {
+			g.history[g.rnd.Intn(duplicateHistory)] = msg
+		}
+	}
+	return msg
+}
+
+// pickRule returns the rule to break or an empty string for a valid order
+func (g *generator) pickRule() string {
+	roll := g.rnd.Float64() * 100
+	for _, share := range g.invalid {
+		if roll < share.percent {
+			return share.rule
+		}
+		roll -= share.percent
+	}
+	return ""
+}
+
+// itemID returns the next item ID of the run. Item IDs are unique while a run has less than 2^31 items
+// and runs start in different seconds
+func (g *generator) itemID() int64 {
+	g.itemSeq = g.itemSeq%maxItemSeq + 1
+	return g.itemBase | g.itemSeq
+}
+
+// order creates a valid order
+func (g *generator) order() *models.Order {
+	g.seq++
+	id := fmt.Sprintf("%s%08d", g.runID, g.seq)
+	now := time.Now().Add(-time.Second)
+
+	items := make([]models.Item, g.items(g.rnd))
+	goodsTotal := 0
+	for i := range items {
+		price := 100 + g.rnd.Intn(9900)
+		sale := g.rnd.Intn(51)
+		items[i] = models.Item{
+			ChrtID:      g.itemID(),
+			TrackNumber: "TRACK" + id,
+			Price:       price,
+			Rid:         fmt.Sprintf("rid%s%02d", id, i),
+			Name:        fmt.Sprintf("Item %d", i+1),
+			Sale:        sale,
+			Size:        []string{"XS", "S", "M", "L", "XL"}[g.rnd.Intn(5)],
+			TotalPrice:  price - price*sale/100,
+			NmID:        1 + g.rnd.Int63n(9999999),
+			Brand:       []string{"Vivienne Sabo", "Nike", "Adidas", "Zara"}[g.rnd.Intn(4)],
+			Status:      202,
+		}
+		goodsTotal += items[i].TotalPrice
+	}
+	deliveryCost := g.rnd.Intn(1500)
+	customFee := g.rnd.Intn(3) * 100
+
+	return &models.Order{
+		OrderUID:    "load" + id,
+		TrackNumber: "TRACK" + id,
+		Entry:       "WBIL",
+		Delivery: models.Delivery{
+			Name:    "Test User",
+			Phone:   fmt.Sprintf("+7%010d", g.rnd.Int63n(1e10)),
+			Zip:     fmt.Sprintf("%06d", g.rnd.Intn(1e6)),
+			City:    "Test City",
+			Address: fmt.Sprintf("%d Test St", 1+g.rnd.Intn(200)),
+			Region:  "Test Region",
+			Email:   fmt.Sprintf("user%d@example.com", g.rnd.Intn(1e6)),
+		},
+		Payment: models.Payment{
+			Transaction:  "load" + id,
+			Currency:     g.currencies.pick(g.rnd),
+			Provider:     "wbpay",
+			Amount:       goodsTotal + deliveryCost + customFee,
+			PaymentDt:    now.Unix(),
+			Bank:         []string{"alpha", "sber", "tinkoff"}[g.rnd.Intn(3)],
+			DeliveryCost: deliveryCost,
+			GoodsTotal:   goodsTotal,
+			CustomFee:    customFee,
+		},
+		Items:           items,
+		Locale:          g.locales.pick(g.rnd),
+		CustomerID:      fmt.Sprintf("customer%04d", g.rnd.Intn(1000)),
+		DeliveryService: "meest",
+		Shardkey:        strconv.Itoa(g.rnd.Intn(10)),
+		SmID:            1 + g.rnd.Intn(100),
+		DateCreated:     now,
+		OofShard:        "1",
+	}
+}
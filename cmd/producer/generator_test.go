@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/json"
+	"math/rand"
+	"testing"
+	"time"
+
+	"l0/internal/models"
+)
+
+func newTestGenerator(t *testing.T, invalid string, duplicates float64) *generator {
+	items, err := parseItemDistribution("uniform:1-5")
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	currencies, _ := parseWeightedChoice("USD:70,EUR:20,RUB:10")
+	locales, _ := parseWeightedChoice("en,ru")
+	shares, err := parseInvalidShares(invalid)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	return newGenerator(1, items, currencies, locales, shares, duplicates)
+}
+
+func TestGenerator_ValidOrders(t *testing.T) {
+	gen := newTestGenerator(t, "", 0)
+
+	seen := make(map[string]bool)
+	seenItems := make(map[int64]bool)
+	for range 200 {
+		msg := gen.next()
+		var order models.Order
+		if err := json.Unmarshal(msg.value, &order); err != nil {
+			t.Fatalf("error: %v", err)
+		}
+		if err := order.Validate(); err != nil {
+			t.Errorf("error: expected valid order, got %v", err)
+		}
+		if seen[order.OrderUID] {
+			t.Errorf("error: expected unique order IDs, got %s twice", order.OrderUID)
+		}
+		seen[order.OrderUID] = true
+		for _, item := range order.Items {
+			if seenItems[item.ChrtID] {
+				t.Errorf("error: expected unique item IDs, got %d twice", item.ChrtID)
+			}
+			seenItems[item.ChrtID] = true
+		}
+	}
+
+	other := newTestGenerator(t, "", 0)
+	other.itemBase += 1 << itemSeqBits
+	if id := other.itemID(); seenItems[id] || id <= 0 {
+		t.Errorf("error: expected item IDs of a later run not to collide, got %d", id)
+	}
+}
+
+func TestInvalidRules(t *testing.T) {
+	gen := newTestGenerator(t, "", 0)
+
+	for rule, mutate := range invalidRules {
+		order := gen.order()
+		mutate(order)
+		if err := order.Validate(); err == nil {
+			t.Errorf("error: expected rule %s to break validation", rule)
+		}
+	}
+}
+
+func TestGenerator_InvalidAndDuplicates(t *testing.T) {
+	gen := newTestGenerator(t, "malformed:100", 0)
+	msg := gen.next()
+	if json.Valid(msg.value) || msg.rule != ruleMalformed {
+		t.Errorf("error: expected malformed message")
+	}
+
+	gen = newTestGenerator(t, "", 50)
+	duplicates := 0
+	for range 1000 {
+		if gen.next().duplicate {
+			duplicates++
+		}
+	}
+	if duplicates < 400 || duplicates > 600 {
+		t.Errorf("error: expected about 500 duplicates, got %d", duplicates)
+	}
+
+	if _, err := parseInvalidShares("unknown:1"); err == nil {
+		t.Errorf("error: expected error for an unknown rule")
+	}
+	if _, err := parseInvalidShares("phone:60,email:60"); err == nil {
+		t.Errorf("error: expected error for more than 100%% of invalid messages")
+	}
+}
+
+func TestItemDistribution(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for _, spec := range []string{"fixed:3", "uniform:2-4", "poisson:3"} {
+		dist, err := parseItemDistribution(spec)
+		if err != nil {
+			t.Fatalf("error: %v", err)
+		}
+		for range 100 {
+			if n := dist(rnd); n < 1 || (spec != "poisson:3" && (n < 2 || n > 4)) {
+				t.Errorf("error: unexpected item count %d for %s", n, spec)
+			}
+		}
+	}
+	for _, spec := range []string{"fixed:0", "uniform:5-2", "normal:3"} {
+		if _, err := parseItemDistribution(spec); err == nil {
+			t.Errorf("error: expected error for %s", spec)
+		}
+	}
+}
+
+func TestLoadOptions_Interval(t *testing.T) {
+	opts := loadOptions{rate: 100, rampUp: 10 * time.Second}
+	if interval := opts.interval(5 * time.Second); interval != 20*time.Millisecond {
+		t.Errorf("error: expected 20ms at the middle of the ramp-up, got %s", interval)
+	}
+	if interval := opts.interval(0); interval != time.Second {
+		t.Errorf("error: expected the minimal rate at the start, got %s", interval)
+	}
+	if interval := opts.interval(time.Minute); interval != 10*time.Millisecond {
+		t.Errorf("error: expected the target rate after the ramp-up, got %s", interval)
+	}
+
+	sorted := []time.Duration{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+	if p := percentile(sorted, 50); p != 5 {
+		t.Errorf("error: expected p50 5, got %d", p)
+	}
+	if p := percentile(sorted, 99); p != 10 {
+		t.Errorf("error: expected p99 10, got %d", p)
+	}
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// minRate is the rate at the start of the ramp-up, so the first message isn't delayed forever
+const minRate = 1.0
+
+// loadOptions are settings of the load run. The run stops after count messages if duration is zero
+type loadOptions struct {
+	count    int
+	duration time.Duration
+	rate     float64
+	rampUp   time.Duration
+	workers  int
+	verbose  bool
+}
+
+// A loadReport collects results of sent messages
+type loadReport struct {
+	mu         sync.Mutex
+	started    time.Time
+	finished   time.Time
+	sent       int
+	failed     int
+	duplicates int
+	invalid    map[string]int
+	latencies  []time.Duration
+}
+
+// add records the result of the sent message
+func (r *loadReport) add(msg message, latency time.Duration, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err != nil {
+		r.failed++
+		return
+	}
+	r.sent++
+	r.latencies = append(r.latencies, latency)
+	if msg.duplicate {
+		r.duplicates++
+	}
+	if msg.rule != "" {
+		r.invalid[msg.rule]++
+	}
+}
+
+// interval returns the interval before the next message at the elapsed time,
+// the rate grows linearly from minRate to the target during the ramp-up
+func (o loadOptions) interval(elapsed time.Duration) time.Duration {
+	if o.rate <= 0 {
+		return 0
+	}
+	rate := o.rate
+	if o.rampUp > 0 && elapsed < o.rampUp {
+		rate = math.Max(minRate, o.rate*float64(elapsed)/float64(o.rampUp))
+	}
+	return time.Duration(float64(time.Second) / rate)
+}
+
+// runLoad sends generated messages with the target rate by several workers and returns the report
+func runLoad(ctx context.Context, writer *kafka.Writer, gen *generator, opts loadOptions) *loadReport {
+	report := &loadReport{invalid: make(map[string]int), started: time.Now()}
+	if opts.duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.duration)
+		defer cancel()
+	}
+
+	jobs := make(chan message, opts.workers)
+	var wg sync.WaitGroup
+	for range opts.workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for msg := range jobs {
+				start := time.Now()
+				err := writer.WriteMessages(
+					context.WithoutCancel(ctx), kafka.Message{Key: []byte(msg.key), Value: msg.value},
+				)
+				report.add(msg, time.Since(start), err)
+				if opts.verbose {
+					if err != nil {
+						fmt.Printf("Failed to send order %s: %v\n", msg.key, err)
+					} else {
+						fmt.Printf("Sent order: %s\n", msg.key)
+					}
+				}
+			}
+		}()
+	}
+
+	next := time.Now()
+	for i := 0; opts.duration > 0 || i < opts.count; i++ {
+		if wait := time.Until(next); wait > 0 {
+			timer := time.NewTimer(wait)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+			}
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		select {
+		case jobs <- gen.next():
+		case <-ctx.Done():
+		}
+
+		// The schedule isn't caught up after stalls, so a slow broker doesn't get bursts
+		next = maxTime(next, time.Now().Add(-time.Second)).Add(opts.interval(time.Since(report.started)))
+	}
+	close(jobs)
+	wg.Wait()
+
+	report.finished = time.Now()
+	return report
+}
+
+// maxTime returns the later time
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+// percentile returns the p-th percentile of sorted durations
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	idx := int(math.Ceil(p/100*float64(len(sorted)))) - 1
+	return sorted[min(max(idx, 0), len(sorted)-1)]
+}
+
+// print writes the report
+func (r *loadReport) print(w io.Writer) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	elapsed := r.finished.Sub(r.started)
+	rate := 0.0
+	if elapsed > 0 {
+		rate = float64(r.sent) / elapsed.Seconds()
+	}
+
+	fmt.Fprintf(w, "Sent: %d, failed: %d in %s (%.1f msg/s)\n", r.sent, r.failed, elapsed.Round(time.Millisecond), rate)
+	fmt.Fprintf(w, "Duplicates: %d\n", r.duplicates)
+
+	rules := make([]string, 0, len(r.invalid))
+	for rule := range r.invalid {
+		rules = append(rules, rule)
+	}
+	sort.Strings(rules)
+	for _, rule := range rules {
+		fmt.Fprintf(w, "Invalid %s: %d\n", rule, r.invalid[rule])
+	}
+
+	sorted := append([]time.Duration(nil), r.latencies...)
+	sort.Slice(
+		sorted, func(i, j int) bool {
+			return sorted[i] < sorted[j]
+		},
+	)
+	printLatencies(w, "Send latency", sorted)
+}
+
+// printLatencies writes percentiles of sorted latencies
+func printLatencies(w io.Writer, title string, sorted []time.Duration) {
+	if len(sorted) == 0 {
+		return
+	}
+	fmt.Fprintf(
+		w, "%s: p50 %s, p90 %s, p95 %s, p99 %s, max %s\n", title,
+		percentile(sorted, 50).Round(time.Microsecond),
+		percentile(sorted, 90).Round(time.Microsecond),
+		percentile(sorted, 95).Round(time.Microsecond),
+		percentile(sorted, 99).Round(time.Microsecond),
+		sorted[len(sorted)-1].Round(time.Microsecond),
+	)
+}
@@ -2,95 +2,105 @@ package main
 
 import (
 	"context"
-	"encoding/json"
 	"flag"
 	"fmt"
-	"github.com/joho/godotenv"
 	"log"
+	"net/http"
 	"os"
+	"os/signal"
 	"strings"
+	"syscall"
+	"time"
 
+	"github.com/joho/godotenv"
 	"github.com/segmentio/kafka-go"
 
-	"math/rand"
-	"time"
-
-	"l0/internal/models"
+	"l0/internal/config"
+	"l0/internal/kafka/security"
 )
 
-func generateOrder() *models.Order {
-	now := time.Now()
-	id := rand.Intn(100000000)
-
-	return &models.Order{
-		OrderUID:    fmt.Sprintf("test%08d", id),
-		TrackNumber: fmt.Sprintf("TRACK%08d", id),
-		Entry:       "WBIL",
-		Delivery: models.Delivery{
-			Name:    "Test User",
-			Phone:   "+7(999)777-32-32",
-			Zip:     "12345",
-			City:    "Test City",
-			Address: "123 Test St",
-			Region:  "NY",
-			Email:   "test@example.com",
-		},
-		Payment: models.Payment{
-			Transaction:  fmt.Sprintf("test%08d", id),
-			RequestID:    fmt.Sprintf("req%08d", id),
-			Currency:     "USD",
-			Provider:     "test",
-			Amount:       1000,
-			PaymentDt:    now.Unix(),
-			Bank:         "test",
-			DeliveryCost: 100,
-			GoodsTotal:   900,
-			CustomFee:    0,
-		},
-		Items: []models.Item{
-			{
-				ChrtID:      int64(id),
-				TrackNumber: fmt.Sprintf("TRACK%08d", id),
-				Price:       1000,
-				Rid:         fmt.Sprintf("rid%08d", id),
-				Name:        "Test Item",
-				Sale:        10,
-				Size:        "M",
-				TotalPrice:  900,
-				NmID:        int64(id),
-				Brand:       "Test",
-				Status:      200,
-			},
-		},
-		Locale:            "en",
-		InternalSignature: fmt.Sprintf("sig%08d", id),
-		CustomerID:        fmt.Sprintf("cust%08d", id),
-		DeliveryService:   "test",
-		Shardkey:          "1",
-		SmID:              1,
-		DateCreated:       now,
-		OofShard:          "1",
-	}
-}
-
 func main() {
 	godotenv.Load("deployments/.env")
 
-	count := flag.Int("count", 1, "Number of orders")
+	mode := flag.String(
+		"mode", "load",
+		"Mode: load generates orders, file publishes orders from a file, probe measures end-to-end latency",
+	)
+	count := flag.Int("count", 1, "Number of orders, ignored if duration is set")
+	configPath := flag.String("config", "config/config.yml", "Path to the service config with Kafka settings")
+	duration := flag.Duration("duration", 0, "Send orders for the duration instead of count")
+	rate := flag.Float64("rate", 0, "Target rate in messages per second, 0 sends as fast as possible")
+	rampUp := flag.Duration("ramp-up", 0, "Duration of linear growth of the rate up to the target")
+	workers := flag.Int("workers", 4, "Number of concurrent writers")
+	items := flag.String("items", "fixed:1", "Item count distribution: fixed:N, uniform:MIN-MAX or poisson:MEAN")
+	currencies := flag.String("currencies", "USD", "Currencies with optional weights, e.g. USD:70,EUR:20,RUB:10")
+	locales := flag.String("locales", "en", "Locales with optional weights, e.g. en:80,ru:20")
+	invalid := flag.String(
+		"invalid", "", "Percentages of invalid messages by validation rule, e.g. phone:1,malformed:0.5",
+	)
+	duplicates := flag.Float64("duplicates", 0, "Percentage of messages replaying already sent orders")
+	seed := flag.Int64("seed", time.Now().UnixNano(), "Seed of the random generator")
+	verbose := flag.Bool("verbose", false, "Print every sent order")
+	file := flag.String("file", "", "File with orders: a JSON array, NDJSON or CSV with one row per item")
+	format := flag.String("format", "", "Format of the file: json, ndjson or csv, detected by extension by default")
+	key := flag.String("key", "order_uid", "Message key: order_uid, transaction, customer_id, track_number or none")
+	dryRun := flag.Bool("dry-run", false, "Validate orders of the file without publishing")
+	var headers headerFlags
+	flag.Var(&headers, "header", "Message header as key=value, may be repeated")
+	apiURL := flag.String("api", "", "Base URL of the order service API, localhost with the configured port by default")
+	apiKey := flag.String("api-key", os.Getenv("PROBE_API_KEY"), "API key for the order service if auth is enabled")
+	interval := flag.Duration("interval", time.Second, "Interval between probe orders")
+	timeout := flag.Duration("timeout", 30*time.Second, "Time for a probe order to become visible")
+	poll := flag.Duration("poll", 250*time.Millisecond, "Initial interval of polling probe orders")
+	maxLatency := flag.Duration("max-latency", 0, "Probe fails if p99 end-to-end latency is above it")
 	flag.Parse()
 
-	var brokers string
-	if env := os.Getenv("KAFKA_BROKERS"); env != "" {
-		brokers = env
+	cfg, err := config.LoadConfig(*configPath)
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
 	}
-	var topic string
-	if env := os.Getenv("KAFKA_TOPIC"); env != "" {
-		topic = env
+
+	var itemDist itemDistribution
+	var currencyChoice, localeChoice *weightedChoice
+	var invalidShares []invalidShare
+	switch *mode {
+	case "load", "probe":
+		itemDist, err = parseItemDistribution(*items)
+		if err != nil {
+			log.Fatalf("Invalid items: %v", err)
+		}
+		currencyChoice, err = parseWeightedChoice(*currencies)
+		if err != nil {
+			log.Fatalf("Invalid currencies: %v", err)
+		}
+		localeChoice, err = parseWeightedChoice(*locales)
+		if err != nil {
+			log.Fatalf("Invalid locales: %v", err)
+		}
+		invalidShares, err = parseInvalidShares(*invalid)
+		if err != nil {
+			log.Fatalf("Invalid rules: %v", err)
+		}
+		if *duplicates < 0 || *duplicates > 100 {
+			log.Fatalf("Invalid duplicates percentage: %v", *duplicates)
+		}
+		if *workers < 1 {
+			log.Fatalf("Invalid number of workers: %d", *workers)
+		}
+		if *apiURL == "" {
+			*apiURL = fmt.Sprintf("http://localhost:%d", cfg.Server.Port)
+		}
+	case "file":
+		if *file == "" {
+			log.Fatalf("File is required in the file mode")
+		}
+	default:
+		log.Fatalf("Unknown mode: %q", *mode)
 	}
 
-	writer := &kafka.Writer{
-		Addr:  kafka.TCP(strings.Split(brokers, ",")...),
-		Topic: topic,
+	writer, err := newWriter(cfg)
+	if err != nil {
+		log.Fatalf("Failed to configure Kafka connection: %v", err)
 	}
 	defer func(writer *kafka.Writer) {
 		err := writer.Close()
@@ -99,22 +109,78 @@ func main() {
 		}
 	}(writer)
 
-	ctx := context.Background()
-	for i := range *count {
-		order := generateOrder()
-		data, _ := json.Marshal(order)
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
 
-		err := writer.WriteMessages(
-			ctx, kafka.Message{
-				Key:   []byte(order.OrderUID),
-				Value: data,
+	switch *mode {
+	case "load":
+		gen := newGenerator(*seed, itemDist, currencyChoice, localeChoice, invalidShares, *duplicates)
+		report := runLoad(
+			ctx, writer, gen, loadOptions{
+				count:    *count,
+				duration: *duration,
+				rate:     *rate,
+				rampUp:   *rampUp,
+				workers:  *workers,
+				verbose:  *verbose,
 			},
 		)
-
+		report.print(os.Stdout)
+	case "file":
+		report, err := runFixtures(ctx, writer, *file, *format, *key, headers, *dryRun, os.Stderr)
 		if err != nil {
-			log.Printf("Failed to send order %d: %v", i+1, err)
-		} else {
-			fmt.Printf("Sent order: %s\n", order.OrderUID)
+			log.Fatalf("Failed to publish orders from file: %v", err)
+		}
+		report.print(os.Stdout)
+		if report.failed > 0 {
+			os.Exit(1)
+		}
+	case "probe":
+		gen := newGenerator(*seed, itemDist, currencyChoice, localeChoice, nil, 0)
+		report := runProbe(
+			ctx, writer, gen, &http.Client{Timeout: 5 * time.Second}, probeOptions{
+				count:      *count,
+				interval:   *interval,
+				timeout:    *timeout,
+				poll:       *poll,
+				apiURL:     strings.TrimRight(*apiURL, "/"),
+				apiKey:     *apiKey,
+				maxLatency: *maxLatency,
+			},
+		)
+		report.print(os.Stdout)
+		if !report.healthy(*maxLatency) {
+			os.Exit(1)
 		}
 	}
-}
\ No newline at end of file
+}
+
+// newWriter creates a writer to the orders topic, brokers and the topic may be overridden by the environment
+func newWriter(cfg *config.Config) (*kafka.Writer, error) {
+	brokers := cfg.Kafka.Listeners
+	if env := os.Getenv("KAFKA_BROKERS"); env != "" {
+		brokers = env
+	}
+	topic := cfg.Kafka.Topic
+	if env := os.Getenv("KAFKA_TOPIC"); env != "" {
+		topic = env
+	}
+
+	transport, err := security.NewTransport(cfg.Kafka.Security)
+	if err != nil {
+		return nil, err
+	}
+
+	addrs := strings.Split(brokers, ",")
+	for i, addr := range addrs {
+		addrs[i] = strings.TrimSpace(addr)
+	}
+
+	return &kafka.Writer{
+		Addr:         kafka.TCP(addrs...),
+		Topic:        topic,
+		Transport:    transport,
+		Balancer:     &kafka.Hash{},
+		BatchTimeout: 10 * time.Millisecond,
+	}, nil
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+
+	"l0/internal/models"
+)
+
+// HeaderSentAt is the header with the send time of probe orders in Unix milliseconds
+const HeaderSentAt = "sent-at"
+
+// maxPollInterval limits the interval of polling a probe order, which doubles after every poll
+const maxPollInterval = 2 * time.Second
+
+// An orderWriter writes order messages to Kafka
+type orderWriter interface {
+	WriteMessages(ctx context.Context, messages ...kafka.Message) error
+}
+
+// probeOptions are settings of the probe run
+type probeOptions struct {
+	count      int
+	interval   time.Duration
+	timeout    time.Duration
+	poll       time.Duration
+	apiURL     string
+	apiKey     string
+	maxLatency time.Duration
+}
+
+// A probeReport collects end-to-end latencies of probe orders
+type probeReport struct {
+	mu        sync.Mutex
+	sent      int
+	failed    int
+	lost      int
+	latencies []time.Duration
+}
+
+// runProbe publishes probe orders with the interval and polls the HTTP API until every order is visible.
+// Probe orders belong to the probe customer, so they don't count in summaries of real customers and in reports
+func runProbe(
+	ctx context.Context, writer orderWriter, gen *generator, client *http.Client, opts probeOptions,
+) *probeReport {
+	report := &probeReport{}
+	var wg sync.WaitGroup
+
+	for i := 0; i < opts.count && ctx.Err() == nil; i++ {
+		if i > 0 {
+			select {
+			case <-time.After(opts.interval):
+			case <-ctx.Done():
+			}
+			if ctx.Err() != nil {
+				break
+			}
+		}
+
+		order := gen.order()
+		order.CustomerID = models.ProbeCustomerID
+		value, _ := json.Marshal(order)
+		sentAt := time.Now()
+		err := writer.WriteMessages(
+			ctx, kafka.Message{
+				Key:   []byte(order.OrderUID),
+				Value: value,
+				Headers: []kafka.Header{
+					{Key: HeaderSentAt, Value: []byte(strconv.FormatInt(sentAt.UnixMilli(), 10))},
+				},
+			},
+		)
+
+		report.mu.Lock()
+		if err != nil {
+			report.failed++
+			report.mu.Unlock()
+			fmt.Printf("Failed to send probe order %s: %v\n", order.OrderUID, err)
+			continue
+		}
+		report.sent++
+		report.mu.Unlock()
+
+		wg.Add(1)
+		go func(orderUID string) {
+			defer wg.Done()
+			latency, err := waitVisible(ctx, client, opts, orderUID, sentAt)
+
+			report.mu.Lock()
+			defer report.mu.Unlock()
+			if err != nil {
+				report.lost++
+				fmt.Printf("Probe order %s isn't visible: %v\n", orderUID, err)
+				return
+			}
+			report.latencies = append(report.latencies, latency)
+		}(order.OrderUID)
+	}
+	wg.Wait()
+
+	return report
+}
+
+// waitVisible polls the order until the API returns it and returns the time since the order was sent.
+// The poll interval backs off up to maxPollInterval, rate limited polls wait as long as the API asks
+func waitVisible(
+	ctx context.Context, client *http.Client, opts probeOptions, orderUID string, sentAt time.Time,
+) (time.Duration, error) {
+	ctx, cancel := context.WithDeadline(ctx, sentAt.Add(opts.timeout))
+	defer cancel()
+
+	endpoint := opts.apiURL + "/order/" + url.PathEscape(orderUID)
+	lastErr := fmt.Errorf("not found in %s", opts.timeout)
+	interval := opts.poll
+	for {
+		wait := interval
+		request, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+		if err != nil {
+			return 0, err
+		}
+		if opts.apiKey != "" {
+			request.Header.Set("X-API-Key", opts.apiKey)
+		}
+
+		response, err := client.Do(request)
+		if err == nil {
+			_, _ = io.Copy(io.Discard, response.Body)
+			_ = response.Body.Close()
+			switch response.StatusCode {
+			case http.StatusOK:
+				return time.Since(sentAt), nil
+			case http.StatusNotFound:
+			case http.StatusTooManyRequests:
+				lastErr = errors.New("rate limited")
+				if retryAfter := retryAfter(response); retryAfter > wait {
+					wait = retryAfter
+				}
+			default:
+				lastErr = fmt.Errorf("unexpected status %d", response.StatusCode)
+			}
+		} else if ctx.Err() == nil {
+			lastErr = err
+		}
+
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return 0, lastErr
+		}
+		interval = min(2*interval, maxPollInterval)
+	}
+}
+
+// retryAfter returns the delay of the Retry-After header in seconds, zero if it's missing
+func retryAfter(response *http.Response) time.Duration {
+	seconds, err := strconv.Atoi(response.Header.Get("Retry-After"))
+	if err != nil || seconds < 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// sortedLatencies returns sorted latencies of visible orders
+func (r *probeReport) sortedLatencies() []time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	sorted := append([]time.Duration(nil), r.latencies...)
+	sort.Slice(
+		sorted, func(i, j int) bool {
+			return sorted[i] < sorted[j]
+		},
+	)
+	return sorted
+}
+
+// healthy reports if every probe order is visible and p99 latency isn't above maxLatency if it's set
+func (r *probeReport) healthy(maxLatency time.Duration) bool {
+	sorted := r.sortedLatencies()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.failed > 0 || r.lost > 0 || r.sent == 0 {
+		return false
+	}
+	return maxLatency <= 0 || percentile(sorted, 99) <= maxLatency
+}
+
+// print writes the report
+func (r *probeReport) print(w io.Writer) {
+	sorted := r.sortedLatencies()
+
+	r.mu.Lock()
+	fmt.Fprintf(w, "Probes sent: %d, failed: %d, visible: %d, lost: %d\n", r.sent, r.failed, len(sorted), r.lost)
+	r.mu.Unlock()
+
+	printLatencies(w, "End-to-end latency", sorted)
+}
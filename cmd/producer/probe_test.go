@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+
+	"l0/internal/models"
+)
+
+// A visibilityWriter makes written orders visible to the API after the delay.
+// The first limited requests are rate limited
+type visibilityWriter struct {
+	mu        sync.Mutex
+	delay     time.Duration
+	visible   map[string]time.Time
+	headers   []kafka.Header
+	customers []string
+	limited   int
+	requests  int
+}
+
+func (w *visibilityWriter) WriteMessages(ctx context.Context, messages ...kafka.Message) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, message := range messages {
+		w.visible[string(message.Key)] = time.Now().Add(w.delay)
+		w.headers = message.Headers
+
+		var order models.Order
+		_ = json.Unmarshal(message.Value, &order)
+		w.customers = append(w.customers, order.CustomerID)
+	}
+	return nil
+}
+
+func (w *visibilityWriter) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	w.mu.Lock()
+	visibleAt, ok := w.visible[strings.TrimPrefix(r.URL.Path, "/order/")]
+	w.requests++
+	limited := w.requests <= w.limited
+	w.mu.Unlock()
+
+	if limited {
+		rw.Header().Set("Retry-After", "1")
+		rw.WriteHeader(http.StatusTooManyRequests)
+		return
+	}
+
+	if r.Header.Get("X-API-Key") != "probe-key" {
+		rw.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if !ok || time.Now().Before(visibleAt) {
+		rw.WriteHeader(http.StatusNotFound)
+		return
+	}
+	rw.WriteHeader(http.StatusOK)
+}
+
+func TestRunProbe(t *testing.T) {
+	writer := &visibilityWriter{delay: 30 * time.Millisecond, visible: make(map[string]time.Time)}
+	server := httptest.NewServer(writer)
+	defer server.Close()
+
+	opts := probeOptions{
+		count:    3,
+		interval: time.Millisecond,
+		timeout:  time.Second,
+		poll:     5 * time.Millisecond,
+		apiURL:   server.URL,
+		apiKey:   "probe-key",
+	}
+	report := runProbe(context.Background(), writer, newTestGenerator(t, "", 0), server.Client(), opts)
+
+	latencies := report.sortedLatencies()
+	if report.sent != 3 || len(latencies) != 3 || report.lost != 0 {
+		t.Fatalf("error: expected 3 visible probes, got sent %d, visible %d, lost %d",
+			report.sent, len(latencies), report.lost)
+	}
+	if latencies[0] < writer.delay {
+		t.Errorf("error: expected latency not less than %s, got %s", writer.delay, latencies[0])
+	}
+	if len(writer.headers) != 1 || writer.headers[0].Key != HeaderSentAt {
+		t.Errorf("error: expected probe orders to be stamped with %s", HeaderSentAt)
+	}
+	if !report.healthy(time.Second) || report.healthy(time.Millisecond) {
+		t.Errorf("error: expected probe to be healthy only with a latency threshold above p99")
+	}
+	for _, customerID := range writer.customers {
+		if customerID != models.ProbeCustomerID {
+			t.Errorf("error: expected probe orders to belong to the probe customer, got %s", customerID)
+		}
+	}
+
+	writer.delay = time.Hour
+	opts.count, opts.timeout = 1, 50*time.Millisecond
+	report = runProbe(context.Background(), writer, newTestGenerator(t, "", 0), server.Client(), opts)
+	if report.lost != 1 || report.healthy(0) {
+		t.Errorf("error: expected lost probe, got lost %d", report.lost)
+	}
+}
+
+func TestWaitVisible_Backoff(t *testing.T) {
+	writer := &visibilityWriter{delay: time.Hour, visible: map[string]time.Time{"order1": time.Now().Add(time.Hour)}}
+	server := httptest.NewServer(writer)
+	defer server.Close()
+
+	opts := probeOptions{
+		timeout: 300 * time.Millisecond, poll: 10 * time.Millisecond, apiURL: server.URL, apiKey: "probe-key",
+	}
+	if _, err := waitVisible(context.Background(), server.Client(), opts, "order1", time.Now()); err == nil {
+		t.Fatalf("error: expected the order not to be visible")
+	}
+	// Without the backoff the order would be polled 30 times
+	if writer.requests > 6 {
+		t.Errorf("error: expected the poll interval to back off, got %d requests", writer.requests)
+	}
+}
+
+func TestWaitVisible_RateLimited(t *testing.T) {
+	writer := &visibilityWriter{visible: map[string]time.Time{"order1": time.Now()}, limited: 1}
+	server := httptest.NewServer(writer)
+	defer server.Close()
+
+	opts := probeOptions{
+		timeout: 3 * time.Second, poll: 10 * time.Millisecond, apiURL: server.URL, apiKey: "probe-key",
+	}
+	latency, err := waitVisible(context.Background(), server.Client(), opts, "order1", time.Now())
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if latency < time.Second || writer.requests != 2 {
+		t.Errorf(
+			"error: expected to poll again after Retry-After, got %s after %d requests", latency, writer.requests,
+		)
+	}
+}
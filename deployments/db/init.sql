@@ -9,6 +9,8 @@ CREATE TABLE IF NOT EXISTS deliveries (
     address TEXT,
     region TEXT,
     email TEXT,
+    key_id TEXT,
+    erased_at TIMESTAMPTZ,
 
     PRIMARY KEY (id)
 );
@@ -26,6 +28,9 @@ CREATE TABLE IF NOT EXISTS orders (
     sm_id INT,
     date_created TIMESTAMPTZ,
     oof_shard INT,
+    base_payment JSONB,
+    schema_version TEXT,
+    deleted_at TIMESTAMPTZ,
 
     PRIMARY KEY (order_uid),
     FOREIGN KEY (delivery_id) REFERENCES deliveries (id) ON DELETE NO ACTION
@@ -66,7 +71,152 @@ CREATE TABLE IF NOT EXISTS items (
     ) ON DELETE CASCADE
 );
 
+CREATE TABLE IF NOT EXISTS consumer_offsets (
+    group_id TEXT NOT NULL,
+    topic TEXT NOT NULL,
+    partition INT NOT NULL,
+    next_offset BIGINT NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
 
-CREATE INDEX idx_orders_delivery ON orders (delivery_id);
-CREATE INDEX idx_items_order ON items (track_number);
-CREATE INDEX idx_payments_order ON payments (transaction);
\ No newline at end of file
+    PRIMARY KEY (group_id, topic, partition)
+);
+
+CREATE TABLE IF NOT EXISTS outbox (
+    id BIGSERIAL,
+    aggregate_id TEXT NOT NULL,
+    event_type TEXT NOT NULL,
+    payload JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    published_at TIMESTAMPTZ,
+    webhooks_enqueued_at TIMESTAMPTZ,
+
+    PRIMARY KEY (id)
+);
+
+-- Deletions, erasures and retention runs, rows are written in transactions of the recorded changes
+CREATE TABLE IF NOT EXISTS audit_log (
+    id BIGSERIAL,
+    principal TEXT NOT NULL,
+    action TEXT NOT NULL,
+    order_uid TEXT,
+    customer_id TEXT,
+    ip TEXT,
+    details JSONB,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    chain_seq BIGINT UNIQUE,
+    prev_hash TEXT,
+    hash TEXT,
+
+    PRIMARY KEY (id)
+);
+
+CREATE TABLE IF NOT EXISTS webhook_subscriptions (
+    id BIGSERIAL,
+    url TEXT NOT NULL,
+    secret TEXT NOT NULL,
+    key_id TEXT,
+    event_types TEXT[] NOT NULL,
+    customer_id TEXT,
+    delivery_service TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+    PRIMARY KEY (id)
+);
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id BIGSERIAL,
+    subscription_id BIGINT NOT NULL REFERENCES webhook_subscriptions (id) ON DELETE CASCADE,
+    event_id BIGINT NOT NULL,
+    event_type TEXT NOT NULL,
+    payload JSONB NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending',
+    attempts INT NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_status_code INT,
+    last_error TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    delivered_at TIMESTAMPTZ,
+
+    PRIMARY KEY (id),
+    UNIQUE (subscription_id, event_id)
+);
+
+-- Columns added to tables of existing databases, the script can be run again to upgrade them
+ALTER TABLE deliveries ADD COLUMN IF NOT EXISTS key_id TEXT;
+ALTER TABLE deliveries ADD COLUMN IF NOT EXISTS erased_at TIMESTAMPTZ;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS base_payment JSONB;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS schema_version TEXT;
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+ALTER TABLE outbox ADD COLUMN IF NOT EXISTS webhooks_enqueued_at TIMESTAMPTZ;
+ALTER TABLE audit_log ADD COLUMN IF NOT EXISTS chain_seq BIGINT UNIQUE;
+ALTER TABLE audit_log ADD COLUMN IF NOT EXISTS prev_hash TEXT;
+ALTER TABLE audit_log ADD COLUMN IF NOT EXISTS hash TEXT;
+ALTER TABLE webhook_subscriptions ADD COLUMN IF NOT EXISTS key_id TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_orders_delivery ON orders (delivery_id);
+CREATE INDEX IF NOT EXISTS idx_orders_customer ON orders (customer_id, date_created DESC);
+CREATE INDEX IF NOT EXISTS idx_orders_created ON orders (date_created);
+CREATE INDEX IF NOT EXISTS idx_items_order ON items (track_number);
+CREATE INDEX IF NOT EXISTS idx_payments_order ON payments (transaction);
+CREATE INDEX IF NOT EXISTS idx_outbox_pending ON outbox (id) WHERE published_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_outbox_published ON outbox (published_at) WHERE published_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_outbox_webhooks ON outbox (id) WHERE webhooks_enqueued_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_due ON webhook_deliveries (next_attempt_at) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_audit_unsealed ON audit_log (id) WHERE hash IS NULL;
+CREATE INDEX IF NOT EXISTS idx_audit_order ON audit_log (order_uid, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_audit_principal ON audit_log (principal, created_at DESC);
+
+-- Daily sales for reports, refreshed by the order service if reports.materialized_view is enabled
+CREATE MATERIALIZED VIEW IF NOT EXISTS sales_daily AS
+SELECT
+    date_trunc('day', o.date_created, 'UTC') AS day,
+    COALESCE(o.delivery_service, '') AS delivery_service,
+    COALESCE(p.currency, '') AS currency,
+    COALESCE(d.region, '') AS region,
+    COALESCE(o.locale, '') AS locale,
+    COUNT(*) AS order_count,
+    SUM(s.item_count)::BIGINT AS item_count,
+    SUM(s.goods_total)::BIGINT AS goods_total,
+    SUM(p.delivery_cost)::BIGINT AS delivery_cost,
+    SUM(s.sale_sum)::BIGINT AS sale_sum
+FROM orders o
+JOIN payments p ON p.transaction = o.order_uid
+JOIN deliveries d ON d.id = o.delivery_id
+JOIN (
+    SELECT track_number, COUNT(*) AS item_count, SUM(total_price) AS goods_total, SUM(sale) AS sale_sum
+    FROM items
+    GROUP BY track_number
+) s ON s.track_number = o.track_number
+-- Orders of the latency probe are not sales
+WHERE o.deleted_at IS NULL AND o.customer_id IS DISTINCT FROM 'probe'
+GROUP BY 1, 2, 3, 4, 5;
+
+CREATE MATERIALIZED VIEW IF NOT EXISTS sales_daily_brands AS
+SELECT
+    date_trunc('day', o.date_created, 'UTC') AS day,
+    COALESCE(o.delivery_service, '') AS delivery_service,
+    COALESCE(s.brand, '') AS brand,
+    COALESCE(p.currency, '') AS currency,
+    COALESCE(d.region, '') AS region,
+    COALESCE(o.locale, '') AS locale,
+    COUNT(*) AS order_count,
+    SUM(s.item_count)::BIGINT AS item_count,
+    SUM(s.goods_total)::BIGINT AS goods_total,
+    SUM(p.delivery_cost * s.delivery_share)::BIGINT AS delivery_cost,
+    SUM(s.sale_sum)::BIGINT AS sale_sum
+FROM orders o
+JOIN payments p ON p.transaction = o.order_uid
+JOIN deliveries d ON d.id = o.delivery_id
+JOIN (
+    -- The delivery cost of an order is allocated to its first brand, so it's counted once
+    SELECT track_number, brand, COUNT(*) AS item_count, SUM(total_price) AS goods_total, SUM(sale) AS sale_sum,
+        (row_number() OVER (PARTITION BY track_number ORDER BY brand) = 1)::INT AS delivery_share
+    FROM items
+    GROUP BY track_number, brand
+) s ON s.track_number = o.track_number
+WHERE o.deleted_at IS NULL AND o.customer_id IS DISTINCT FROM 'probe'
+GROUP BY 1, 2, 3, 4, 5, 6;
+
+-- Unique indexes are required to refresh the views concurrently
+CREATE UNIQUE INDEX IF NOT EXISTS idx_sales_daily ON sales_daily (day, delivery_service, currency, region, locale);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_sales_daily_brands ON sales_daily_brands (day, delivery_service, brand, currency, region, locale);
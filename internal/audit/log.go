@@ -0,0 +1,211 @@
+// Package audit implements an asynchronous audit log of actions of API principals
+package audit
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"l0/internal/config"
+	"l0/internal/interfaces"
+	"l0/internal/metrics"
+	"l0/internal/models"
+)
+
+// stopTimeout limits writing of buffered entries when the log is stopped
+const stopTimeout = 10 * time.Second
+
+// A Log buffers recorded entries and writes them to the store in batches in background, so requests
+// don't wait for the database. Written entries are sealed into the hash chain every flush interval.
+// If the store fails, the batch is retried and new entries are dropped once the buffer is full
+type Log struct {
+	store    interfaces.AuditStore
+	config   config.AuditConfig
+	logger   *zerolog.Logger
+	entries  chan models.AuditEntry
+	written  *metrics.Counter
+	dropped  *metrics.Counter
+	failures *metrics.Counter
+
+	mu      sync.Mutex
+	running bool
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+// NewLog creates a new audit log, zero settings are replaced with defaults
+func NewLog(store interfaces.AuditStore, cfg config.AuditConfig, logger *zerolog.Logger) *Log {
+	if cfg.BufferSize <= 0 {
+		cfg.BufferSize = 10000
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 100
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = time.Second
+	}
+
+	return &Log{
+		store:    store,
+		config:   cfg,
+		logger:   logger,
+		entries:  make(chan models.AuditEntry, cfg.BufferSize),
+		written:  metrics.DefaultRegistry.Counter("audit_written_entries_total", "Number of written audit entries"),
+		dropped:  metrics.DefaultRegistry.Counter("audit_dropped_entries_total", "Number of dropped audit entries"),
+		failures: metrics.DefaultRegistry.Counter("audit_write_failures_total", "Number of failed audit log writes"),
+	}
+}
+
+// Record adds the entry to the buffer without blocking, the entry is timestamped if it has no time
+func (l *Log) Record(entry models.AuditEntry) {
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = time.Now().UTC()
+	}
+
+	select {
+	case l.entries <- entry:
+	default:
+		l.dropped.Inc()
+		l.logger.Error().
+			Str("principal", entry.Principal).
+			Str("action", entry.Action).
+			Str("order_uid", entry.OrderUID).
+			Msg("Audit buffer is full, entry dropped")
+	}
+}
+
+// Entries returns a page of written audit entries matching the filter
+func (l *Log) Entries(ctx context.Context, filter models.AuditFilter) ([]models.AuditEntry, error) {
+	return l.store.ListAuditEntries(ctx, filter)
+}
+
+// Verify verifies the hash chain of the written audit entries
+func (l *Log) Verify(ctx context.Context) (*models.AuditVerification, error) {
+	return l.store.VerifyAuditLog(ctx)
+}
+
+// Start starts writing entries in background
+func (l *Log) Start(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.running {
+		return errors.New("audit log is already running")
+	}
+
+	ctx, l.cancel = context.WithCancel(ctx)
+	l.running = true
+
+	l.wg.Add(1)
+	go func() {
+		defer l.wg.Done()
+		l.run(ctx)
+	}()
+
+	return nil
+}
+
+// Stop stops the log after writing buffered entries, entries recorded after Stop are not written
+func (l *Log) Stop() {
+	l.mu.Lock()
+	if !l.running {
+		l.mu.Unlock()
+		return
+	}
+	l.running = false
+	l.cancel()
+	l.mu.Unlock()
+
+	l.wg.Wait()
+}
+
+// run writes entries until the context is done, then writes the rest of the buffer
+func (l *Log) run(ctx context.Context) {
+	ticker := time.NewTicker(l.config.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]models.AuditEntry, 0, l.config.BatchSize)
+	for {
+		// A full batch is kept only while the store fails, new entries wait in the buffer until it's written
+		entries := l.entries
+		if len(batch) >= l.config.BatchSize {
+			entries = nil
+		}
+
+		select {
+		case <-ctx.Done():
+			l.drain(batch)
+			return
+		case entry := <-entries:
+			batch = append(batch, entry)
+			if len(batch) >= l.config.BatchSize {
+				batch = l.write(ctx, batch)
+			}
+		case <-ticker.C:
+			batch = l.write(ctx, batch)
+			l.seal(ctx)
+		}
+	}
+}
+
+// drain writes the batch and the buffered entries with a separate timeout after the log was stopped
+func (l *Log) drain(batch []models.AuditEntry) {
+	ctx, cancel := context.WithTimeout(context.Background(), stopTimeout)
+	defer cancel()
+
+	for {
+		select {
+		case entry := <-l.entries:
+			batch = append(batch, entry)
+			if len(batch) < l.config.BatchSize {
+				continue
+			}
+		default:
+		}
+
+		if batch = l.write(ctx, batch); len(batch) > 0 || len(l.entries) == 0 {
+			break
+		}
+	}
+
+	if len(batch) > 0 || len(l.entries) > 0 {
+		l.logger.Error().
+			Int("entries", len(batch)+len(l.entries)).
+			Msg("Failed to write audit entries before stop")
+	}
+	l.seal(ctx)
+}
+
+// write writes the batch and returns it emptied, or unchanged if the store failed
+func (l *Log) write(ctx context.Context, batch []models.AuditEntry) []models.AuditEntry {
+	if len(batch) == 0 {
+		return batch
+	}
+
+	if err := l.store.InsertAuditEntries(ctx, batch); err != nil {
+		l.failures.Inc()
+		l.logger.Error().Err(err).Int("entries", len(batch)).Msg("Failed to write audit entries")
+		return batch
+	}
+
+	l.written.Add(int64(len(batch)))
+	return batch[:0]
+}
+
+// seal appends written entries to the hash chain while there are full batches
+func (l *Log) seal(ctx context.Context) {
+	for ctx.Err() == nil {
+		sealed, err := l.store.SealAuditLog(ctx, l.config.BatchSize)
+		if err != nil {
+			l.failures.Inc()
+			l.logger.Error().Err(err).Msg("Failed to seal audit log")
+			return
+		}
+		if sealed < l.config.BatchSize {
+			return
+		}
+	}
+}
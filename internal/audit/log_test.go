@@ -0,0 +1,133 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"l0/internal/config"
+	"l0/internal/models"
+)
+
+// A mockStore is a thread-safe mock implementation of AuditStore for testing
+type mockStore struct {
+	mu      sync.Mutex
+	entries []models.AuditEntry
+	sealed  int
+	err     error
+}
+
+func (m *mockStore) InsertAuditEntries(ctx context.Context, entries []models.AuditEntry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.err != nil {
+		return m.err
+	}
+	m.entries = append(m.entries, entries...)
+	return nil
+}
+
+func (m *mockStore) SealAuditLog(ctx context.Context, limit int) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	sealed := min(len(m.entries)-m.sealed, limit)
+	m.sealed += sealed
+	return sealed, nil
+}
+
+func (m *mockStore) ListAuditEntries(ctx context.Context, filter models.AuditFilter) ([]models.AuditEntry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]models.AuditEntry(nil), m.entries...), nil
+}
+
+func (m *mockStore) VerifyAuditLog(ctx context.Context) (*models.AuditVerification, error) {
+	return &models.AuditVerification{Valid: true}, nil
+}
+
+func (m *mockStore) setErr(err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.err = err
+}
+
+func (m *mockStore) counts() (written, sealed int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.entries), m.sealed
+}
+
+func TestLog_WritesAndSeals(t *testing.T) {
+	logger := zerolog.New(os.Stdout)
+	store := &mockStore{}
+	log := NewLog(store, config.AuditConfig{BatchSize: 2, FlushInterval: 10 * time.Millisecond}, &logger)
+	if err := log.Start(context.Background()); err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	for _, uid := range []string{"order1", "order2", "order3"} {
+		log.Record(models.AuditEntry{Principal: "support", Action: models.AuditOrderRead, OrderUID: uid})
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	written, sealed := store.counts()
+	if written != 3 || sealed != 3 {
+		t.Errorf("error: expected 3 written and sealed entries, got %d and %d", written, sealed)
+	}
+	entries, _ := log.Entries(context.Background(), models.AuditFilter{})
+	if len(entries) > 0 && entries[0].CreatedAt.IsZero() {
+		t.Errorf("error: expected recorded entries to be timestamped")
+	}
+	log.Stop()
+}
+
+func TestLog_StopWritesBuffered(t *testing.T) {
+	logger := zerolog.New(os.Stdout)
+	store := &mockStore{}
+	log := NewLog(store, config.AuditConfig{BatchSize: 100, FlushInterval: time.Hour}, &logger)
+	if err := log.Start(context.Background()); err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	for range 5 {
+		log.Record(models.AuditEntry{Principal: "admin", Action: models.AuditConsumerPaused})
+	}
+	log.Stop()
+
+	if written, sealed := store.counts(); written != 5 || sealed != 5 {
+		t.Errorf("error: expected buffered entries to be written on stop, got %d written and %d sealed", written, sealed)
+	}
+}
+
+func TestLog_RetriesAndDrops(t *testing.T) {
+	logger := zerolog.New(os.Stdout)
+	store := &mockStore{}
+	store.setErr(errors.New("database is not available"))
+	log := NewLog(
+		store, config.AuditConfig{BufferSize: 2, BatchSize: 2, FlushInterval: 10 * time.Millisecond}, &logger,
+	)
+	if err := log.Start(context.Background()); err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	dropped := log.dropped.Value()
+	for range 10 {
+		log.Record(models.AuditEntry{Principal: "support", Action: models.AuditOrderRead})
+		time.Sleep(time.Millisecond)
+	}
+	if log.dropped.Value() == dropped {
+		t.Errorf("error: expected entries to be dropped while the store fails")
+	}
+
+	store.setErr(nil)
+	time.Sleep(100 * time.Millisecond)
+	if written, _ := store.counts(); written != 4 {
+		t.Errorf("error: expected the failed batch and the buffer to be written, got %d entries", written)
+	}
+	log.Stop()
+}
@@ -0,0 +1,58 @@
+// Package auth implements authentication and authorization of HTTP API requests
+package auth
+
+import (
+	"context"
+	"errors"
+	"slices"
+)
+
+// Scopes of access to HTTP API
+const (
+	ScopeOrdersRead  = "orders:read"
+	ScopeOrdersWrite = "orders:write"
+	ScopePIIRead     = "pii:read"
+	ScopeAdmin       = "admin"
+)
+
+// Authentication methods
+const (
+	MethodAPIKey = "api_key"
+	MethodJWT    = "jwt"
+)
+
+var (
+	// ErrNoCredentials is returned when a request has no credentials
+	ErrNoCredentials = errors.New("no credentials")
+	// ErrInvalidCredentials is returned when credentials are not valid
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+// A Principal is an authenticated identity of a request
+type Principal struct {
+	Subject string
+	Method  string
+	Scopes  []string
+}
+
+// HasScope checks if the principal has the scope, admin has all scopes
+func (p *Principal) HasScope(scope string) bool {
+	if p == nil {
+		return false
+	}
+	return slices.Contains(p.Scopes, scope) || slices.Contains(p.Scopes, ScopeAdmin)
+}
+
+// principalKey is a context key for Principal
+type principalKey struct{}
+
+// WithPrincipal returns a copy of the context with the principal
+func WithPrincipal(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// PrincipalFromContext returns the principal of the context, nil if the request is anonymous
+func PrincipalFromContext(ctx context.Context) *Principal {
+	principal, _ := ctx.Value(principalKey{}).(*Principal)
+	return principal
+}
@@ -0,0 +1,95 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"l0/internal/config"
+)
+
+// An apiKey is a static API key with its scopes, only a hash of the key is kept in memory
+type apiKey struct {
+	name   string
+	hash   [sha256.Size]byte
+	scopes []string
+}
+
+// An Authenticator authenticates requests by static API keys and JWT
+type Authenticator struct {
+	apiKeys []apiKey
+	jwt     *JWTVerifier
+}
+
+// NewAuthenticator creates a new authenticator based on the configuration
+func NewAuthenticator(cfg config.AuthConfig) (*Authenticator, error) {
+	a := &Authenticator{}
+	for _, key := range cfg.APIKeys {
+		if key.Key == "" {
+			return nil, fmt.Errorf("API key %q is empty", key.Name)
+		}
+		a.apiKeys = append(a.apiKeys, apiKey{name: key.Name, hash: sha256.Sum256([]byte(key.Key)), scopes: key.Scopes})
+	}
+
+	if cfg.JWT.HMACSecret != "" || cfg.JWT.JWKSFile != "" {
+		verifier, err := NewJWTVerifier(
+			cfg.JWT.HMACSecret, cfg.JWT.JWKSFile, cfg.JWT.Issuer, cfg.JWT.Audience, cfg.JWT.Leeway,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize JWT verifier: %w", err)
+		}
+		a.jwt = verifier
+	}
+
+	return a, nil
+}
+
+// Authenticate returns the principal of the request. API keys are read from the X-API-Key header,
+// the Authorization header may contain either a JWT or an API key as a bearer token
+func (a *Authenticator) Authenticate(r *http.Request) (*Principal, error) {
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return a.authenticateAPIKey(key)
+	}
+
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || strings.TrimSpace(token) == "" {
+		return nil, ErrNoCredentials
+	}
+	token = strings.TrimSpace(token)
+
+	if strings.Count(token, ".") == 2 {
+		if a.jwt == nil {
+			return nil, fmt.Errorf("%w: JWT is not configured", ErrInvalidCredentials)
+		}
+		return a.jwt.Verify(token)
+	}
+	return a.authenticateAPIKey(token)
+}
+
+// HasCredentials reports whether the request has an API key or a bearer token, they aren't checked
+func (a *Authenticator) HasCredentials(r *http.Request) bool {
+	if r.Header.Get("X-API-Key") != "" {
+		return true
+	}
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && strings.TrimSpace(token) != ""
+}
+
+// authenticateAPIKey looks for the key comparing hashes in constant time
+func (a *Authenticator) authenticateAPIKey(key string) (*Principal, error) {
+	hash := sha256.Sum256([]byte(key))
+
+	var found *apiKey
+	for i := range a.apiKeys {
+		if subtle.ConstantTimeCompare(a.apiKeys[i].hash[:], hash[:]) == 1 {
+			found = &a.apiKeys[i]
+		}
+	}
+	if found == nil {
+		return nil, fmt.Errorf("%w: unknown API key", ErrInvalidCredentials)
+	}
+
+	return &Principal{Subject: found.name, Method: MethodAPIKey, Scopes: found.scopes}, nil
+}
@@ -0,0 +1,75 @@
+package auth
+
+import (
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"l0/internal/config"
+)
+
+func TestAuthenticator_APIKey(t *testing.T) {
+	a, err := NewAuthenticator(
+		config.AuthConfig{
+			APIKeys: []config.APIKeyConfig{{Name: "dashboard", Key: "key1", Scopes: []string{ScopeOrdersRead}}},
+		},
+	)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	r := httptest.NewRequest("GET", "/order/1", nil)
+	r.Header.Set("X-API-Key", "key1")
+	principal, err := a.Authenticate(r)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if principal.Subject != "dashboard" || !principal.HasScope(ScopeOrdersRead) || principal.HasScope(ScopeAdmin) {
+		t.Errorf("error: unexpected principal %+v", principal)
+	}
+
+	r = httptest.NewRequest("GET", "/order/1", nil)
+	r.Header.Set("Authorization", "Bearer key1")
+	if _, err := a.Authenticate(r); err != nil {
+		t.Errorf("error: expected API key as bearer token to be accepted, got %v", err)
+	}
+
+	r = httptest.NewRequest("GET", "/order/1", nil)
+	r.Header.Set("X-API-Key", "key2")
+	if _, err := a.Authenticate(r); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("error: expected invalid credentials, got %v", err)
+	}
+}
+
+func TestAuthenticator_NoCredentials(t *testing.T) {
+	a, err := NewAuthenticator(config.AuthConfig{})
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	if _, err := a.Authenticate(httptest.NewRequest("GET", "/order/1", nil)); !errors.Is(err, ErrNoCredentials) {
+		t.Errorf("error: expected no credentials, got %v", err)
+	}
+
+	r := httptest.NewRequest("GET", "/order/1", nil)
+	r.Header.Set("Authorization", "Bearer ")
+	if a.HasCredentials(r) {
+		t.Errorf("error: expected an empty bearer token not to be credentials")
+	}
+	r.Header.Set("Authorization", "Bearer key1")
+	if !a.HasCredentials(r) {
+		t.Errorf("error: expected the bearer token to be credentials")
+	}
+}
+
+func TestPrincipal_HasScope(t *testing.T) {
+	admin := &Principal{Subject: "admin", Scopes: []string{ScopeAdmin}}
+	if !admin.HasScope(ScopeOrdersWrite) {
+		t.Errorf("error: expected admin to have all scopes")
+	}
+
+	var anonymous *Principal
+	if anonymous.HasScope(ScopeOrdersRead) {
+		t.Errorf("error: expected anonymous principal to have no scopes")
+	}
+}
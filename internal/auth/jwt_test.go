@@ -0,0 +1,151 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func encodeSegment(t *testing.T, v any) string {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func signHS256(t *testing.T, secret []byte, kid string, claims map[string]any) string {
+	t.Helper()
+	signed := encodeSegment(t, map[string]string{"alg": "HS256", "kid": kid}) + "." + encodeSegment(t, claims)
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(signed))
+	return signed + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func signRS256(t *testing.T, key *rsa.PrivateKey, kid string, claims map[string]any) string {
+	t.Helper()
+	signed := encodeSegment(t, map[string]string{"alg": "RS256", "kid": kid}) + "." + encodeSegment(t, claims)
+	digest := sha256.Sum256([]byte(signed))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func validClaims() map[string]any {
+	return map[string]any{
+		"sub":   "user1",
+		"iss":   "l0-tests",
+		"aud":   []string{"orders-api"},
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"scope": "orders:read pii:read",
+	}
+}
+
+func TestJWTVerifier_HS256(t *testing.T) {
+	v, err := NewJWTVerifier("secret", "", "l0-tests", "orders-api", 0)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	principal, err := v.Verify(signHS256(t, []byte("secret"), "", validClaims()))
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if principal.Subject != "user1" || principal.Method != MethodJWT {
+		t.Errorf("error: unexpected principal %+v", principal)
+	}
+	if !slices.Equal(principal.Scopes, []string{ScopeOrdersRead, ScopePIIRead}) {
+		t.Errorf("error: unexpected scopes %v", principal.Scopes)
+	}
+
+	if _, err := v.Verify(signHS256(t, []byte("other"), "", validClaims())); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("error: expected invalid signature, got %v", err)
+	}
+}
+
+func TestJWTVerifier_RS256WithJWKS(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	jwks := map[string]any{
+		"keys": []map[string]string{
+			{
+				"kty": "RSA",
+				"kid": "rsa1",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			},
+		},
+	}
+	data, _ := json.Marshal(jwks)
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	v, err := NewJWTVerifier("", path, "", "", 0)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	if _, err := v.Verify(signRS256(t, key, "rsa1", validClaims())); err != nil {
+		t.Errorf("error: %v", err)
+	}
+	if _, err := v.Verify(signRS256(t, key, "rsa2", validClaims())); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("error: expected unknown key error, got %v", err)
+	}
+}
+
+func TestJWTVerifier_Claims(t *testing.T) {
+	v, err := NewJWTVerifier("secret", "", "l0-tests", "orders-api", 0)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	expired := validClaims()
+	expired["exp"] = time.Now().Add(-time.Minute).Unix()
+	wrongIssuer := validClaims()
+	wrongIssuer["iss"] = "other"
+	wrongAudience := validClaims()
+	wrongAudience["aud"] = "other"
+	noExpiration := validClaims()
+	delete(noExpiration, "exp")
+
+	for name, claims := range map[string]map[string]any{
+		"expired":        expired,
+		"wrong issuer":   wrongIssuer,
+		"wrong audience": wrongAudience,
+		"no expiration":  noExpiration,
+	} {
+		if _, err := v.Verify(signHS256(t, []byte("secret"), "", claims)); !errors.Is(err, ErrInvalidCredentials) {
+			t.Errorf("error: expected invalid credentials for %s token, got %v", name, err)
+		}
+	}
+}
+
+func TestJWTVerifier_AlgNone(t *testing.T) {
+	v, err := NewJWTVerifier("secret", "", "", "", 0)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	token := encodeSegment(t, map[string]string{"alg": "none"}) + "." + encodeSegment(t, validClaims()) + "."
+	if _, err := v.Verify(token); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("error: expected unsigned token to be rejected, got %v", err)
+	}
+}
@@ -45,15 +45,20 @@ func (c *Manager) WarmCache(ctx context.Context) error {
 	return nil
 }
 
-// Set add an order to the cache and database
-func (c *Manager) Set(ctx context.Context, order *models.Order) {
+// Set add an order to the database and then to the cache. It returns false if the order already exists,
+// then the order isn't cached, so a redelivered order doesn't replace a deleted or erased one
+func (c *Manager) Set(ctx context.Context, order *models.Order) (bool, error) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	err := c.repo.SaveOrder(ctx, order)
+	inserted, err := c.repo.SaveOrder(ctx, order)
 	if err != nil {
 		c.logger.Error().Stack().Err(err).Msg("")
+		return false, err
 	}
-	c.cache.Set(order.OrderUID, order)
+	if inserted {
+		c.cache.Set(order.OrderUID, order)
+	}
+	return inserted, nil
 }
 
 // Get returns order from cache, if it's not there - from database
@@ -88,6 +93,20 @@ func (c *Manager) DeleteCache(orderUID string) {
 	return
 }
 
+// EvictCache removes orders from the cache, orders which aren't cached are skipped
+func (c *Manager) EvictCache(orderUIDs ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, orderUID := range orderUIDs {
+		if !c.cache.Contains(orderUID) {
+			continue
+		}
+		if err := c.cache.Delete(orderUID); err != nil {
+			c.logger.Error().Stack().Err(err).Msg("")
+		}
+	}
+}
+
 // ContainsCache checks if element is present in cache
 func (c *Manager) ContainsCache(orderUID string) bool {
 	c.mu.Lock()
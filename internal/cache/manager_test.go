@@ -9,28 +9,31 @@ import (
 	"l0/internal/models"
 	"maps"
 	"os"
-	"slices"
 	"sync"
 	"testing"
 )
 
 // A mockRepository is a not thread-safe mock implementation of Cache for testing
 type mockRepository struct {
-	orders map[string]models.Order
+	orders map[string]*models.Order
 	err    error // to create artificial errors
 }
 
-func (m *mockRepository) SaveOrder(ctx context.Context, order *models.Order) error {
+// SaveOrder skips orders which already exist, a deleted order is kept as nil
+func (m *mockRepository) SaveOrder(ctx context.Context, order *models.Order) (bool, error) {
 	if m.err != nil {
-		return m.err
+		return false, m.err
 	}
 
 	if m.orders == nil {
-		m.orders = make(map[string]models.Order)
+		m.orders = make(map[string]*models.Order)
+	}
+	if _, ok := m.orders[order.OrderUID]; ok {
+		return false, nil
 	}
 
-	m.orders[order.OrderUID] = *order
-	return nil
+	m.orders[order.OrderUID] = order
+	return true, nil
 }
 
 func (m *mockRepository) GetOrder(ctx context.Context, orderUid string) (*models.Order, error) {
@@ -43,7 +46,7 @@ func (m *mockRepository) GetOrder(ctx context.Context, orderUid string) (*models
 		return nil, nil
 	}
 
-	return &order, nil
+	return order, nil
 }
 
 func (m *mockRepository) GetNOrders(ctx context.Context, n int) ([]models.Order, error) {
@@ -59,7 +62,7 @@ func (m *mockRepository) GetNOrders(ctx context.Context, n int) ([]models.Order,
 
 	i := 0
 	for v := range maps.Values(m.orders) {
-		orders = append(orders, v)
+		orders = append(orders, *v)
 		i += 1
 		if i == n {
 			break
@@ -73,7 +76,11 @@ func (m *mockRepository) GetAllOrders(ctx context.Context) ([]models.Order, erro
 		return nil, m.err
 	}
 
-	return slices.Collect(maps.Values(m.orders)), nil
+	var orders []models.Order
+	for v := range maps.Values(m.orders) {
+		orders = append(orders, *v)
+	}
+	return orders, nil
 
 }
 
@@ -260,6 +267,24 @@ func TestManager_DeleteCache(t *testing.T) {
 	}
 }
 
+func TestManager_EvictCache(t *testing.T) {
+	cache := newMockCache[string, *models.Order](10)
+	repo := mockRepository{}
+	logger := zerolog.New(os.Stdout).With().Timestamp().Logger()
+	m := NewManager(cache, &repo, &logger)
+
+	m.Set(context.Background(), &models.Order{OrderUID: "order1", Entry: "entry1"})
+	m.Set(context.Background(), &models.Order{OrderUID: "order2", Entry: "entry2"})
+	m.EvictCache("order1", "order3")
+
+	if m.ContainsCache("order1") {
+		t.Errorf("error: expected order1 to be evicted from cache")
+	}
+	if !m.ContainsCache("order2") {
+		t.Errorf("error: expected order2 to stay in cache")
+	}
+}
+
 func TestManager_ContainsCache(t *testing.T) {
 	cache := newMockCache[string, *models.Order](10)
 	repo := mockRepository{}
@@ -361,6 +386,59 @@ func TestManager_DBError(t *testing.T) {
 	}
 }
 
+func TestManager_SetDBError(t *testing.T) {
+	cache := newMockCache[string, *models.Order](10)
+	repo := mockRepository{err: errors.New("db mock connection error")}
+	logger := zerolog.New(os.Stdout).With().Timestamp().Logger()
+	m := NewManager(cache, &repo, &logger)
+
+	_, err := m.Set(context.Background(), &models.Order{OrderUID: "order1", Entry: "entry1"})
+	if err == nil {
+		t.Errorf("error: expected database error to be returned")
+	}
+	if m.ContainsCache("order1") {
+		t.Errorf("error: expected order not to be cached when it wasn't saved")
+	}
+}
+
+func TestManager_SetRedelivered(t *testing.T) {
+	cache := newMockCache[string, *models.Order](10)
+	repo := mockRepository{}
+	logger := zerolog.New(os.Stdout).With().Timestamp().Logger()
+	m := NewManager(cache, &repo, &logger)
+
+	newOrder := func(orderUID string) *models.Order {
+		return &models.Order{OrderUID: orderUID, Delivery: models.Delivery{Name: "Test Testov", Phone: "+9720000000"}}
+	}
+	for _, orderUID := range []string{"order1", "order2"} {
+		if inserted, err := m.Set(context.Background(), newOrder(orderUID)); err != nil || !inserted {
+			t.Fatalf("error: expected %s to be inserted, got %t, %v", orderUID, inserted, err)
+		}
+	}
+
+	// order1 is deleted and personal data of order2 are erased, both are evicted from the cache
+	repo.orders["order1"] = nil
+	repo.orders["order2"] = &models.Order{OrderUID: "order2"}
+	m.EvictCache("order1", "order2")
+
+	for _, orderUID := range []string{"order1", "order2"} {
+		inserted, err := m.Set(context.Background(), newOrder(orderUID))
+		if err != nil || inserted {
+			t.Errorf("error: expected redelivered %s not to be inserted, got %t, %v", orderUID, inserted, err)
+		}
+		if m.ContainsCache(orderUID) {
+			t.Errorf("error: expected redelivered %s not to be cached", orderUID)
+		}
+	}
+
+	if order, err := m.Get(context.Background(), "order1"); err != nil || order != nil {
+		t.Errorf("error: expected deleted order not to be served, got %v, %v", order, err)
+	}
+	if order, err := m.Get(context.Background(), "order2"); err != nil || order.Delivery.Name != "" {
+		t.Errorf("error: expected erased delivery to stay erased, got %v, %v", order, err)
+	}
+}
+
 func TestManager_Concurrency(t *testing.T) {
 	cache, err := lru_cache.NewLRUCache[string, *models.Order](1000000)
 	if err != nil {
@@ -0,0 +1,162 @@
+package codec
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/linkedin/goavro/v2"
+	"l0/internal/interfaces"
+	"l0/internal/models"
+	"sync"
+	"time"
+)
+
+// An AvroDecoder decodes orders from Avro messages in the Confluent wire format.
+// Messages are decoded with the writer schema from the registry, so fields missing in older schemas stay empty
+type AvroDecoder struct {
+	registry interfaces.SchemaRegistry
+	mu       sync.RWMutex
+	codecs   map[int]*goavro.Codec
+}
+
+// NewAvroDecoder creates a new Avro decoder resolving schemas through the registry
+func NewAvroDecoder(registry interfaces.SchemaRegistry) *AvroDecoder {
+	return &AvroDecoder{
+		registry: registry,
+		codecs:   make(map[int]*goavro.Codec),
+	}
+}
+
+// Decode decodes the order from the Avro message and records the schema version on it
+func (d *AvroDecoder) Decode(ctx context.Context, value []byte) (*models.Order, error) {
+	schemaID, payload, err := parseWireFormat(value)
+	if err != nil {
+		return nil, err
+	}
+	schema, err := resolveSchema(ctx, d.registry, schemaID, models.SchemaTypeAvro)
+	if err != nil {
+		return nil, err
+	}
+	codec, err := d.codec(schema)
+	if err != nil {
+		return nil, err
+	}
+
+	native, _, err := codec.NativeFromBinary(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode avro message: %w", err)
+	}
+	// The standard JSON form of the record has the same field names as the JSON order
+	text, err := codec.TextualFromNative(nil, native)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode avro message: %w", err)
+	}
+
+	var order models.Order
+	if err := json.Unmarshal(text, &order); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformedFields, err)
+	}
+	order.SchemaVersion = schema.VersionString(FormatAvro)
+
+	return &order, nil
+}
+
+// codec returns the cached codec of the schema
+func (d *AvroDecoder) codec(schema *models.Schema) (*goavro.Codec, error) {
+	d.mu.RLock()
+	codec, ok := d.codecs[schema.ID]
+	d.mu.RUnlock()
+	if ok {
+		return codec, nil
+	}
+
+	codec, err := goavro.NewCodecForStandardJSONFull(schema.Schema)
+	if err != nil {
+		return nil, fmt.Errorf("invalid avro schema %d: %w", schema.ID, err)
+	}
+
+	d.mu.Lock()
+	d.codecs[schema.ID] = codec
+	d.mu.Unlock()
+
+	return codec, nil
+}
+
+var (
+	avroCodecOnce sync.Once
+	avroCodec     *goavro.Codec
+	avroCodecErr  error
+)
+
+// MarshalAvro encodes the order with the current Avro schema into the Confluent wire format.
+// The schemaID must be the ID of AvroSchema in the registry
+func MarshalAvro(schemaID int, order *models.Order) ([]byte, error) {
+	avroCodecOnce.Do(
+		func() {
+			avroCodec, avroCodecErr = goavro.NewCodec(AvroSchema)
+		},
+	)
+	if avroCodecErr != nil {
+		return nil, avroCodecErr
+	}
+
+	return avroCodec.BinaryFromNative(appendWireHeader(nil, schemaID), avroNative(order))
+}
+
+// avroNative converts the order into the native form of the Avro record
+func avroNative(order *models.Order) map[string]any {
+	items := make([]any, 0, len(order.Items))
+	for _, item := range order.Items {
+		items = append(
+			items, map[string]any{
+				"chrt_id":      item.ChrtID,
+				"track_number": item.TrackNumber,
+				"price":        int64(item.Price),
+				"rid":          item.Rid,
+				"name":         item.Name,
+				"sale":         int64(item.Sale),
+				"size":         item.Size,
+				"total_price":  int64(item.TotalPrice),
+				"nm_id":        item.NmID,
+				"brand":        item.Brand,
+				"status":       int64(item.Status),
+			},
+		)
+	}
+
+	return map[string]any{
+		"order_uid":    order.OrderUID,
+		"track_number": order.TrackNumber,
+		"entry":        order.Entry,
+		"delivery": map[string]any{
+			"name":    order.Delivery.Name,
+			"phone":   order.Delivery.Phone,
+			"zip":     order.Delivery.Zip,
+			"city":    order.Delivery.City,
+			"address": order.Delivery.Address,
+			"region":  order.Delivery.Region,
+			"email":   order.Delivery.Email,
+		},
+		"payment": map[string]any{
+			"transaction":   order.Payment.Transaction,
+			"request_id":    order.Payment.RequestID,
+			"currency":      order.Payment.Currency,
+			"provider":      order.Payment.Provider,
+			"amount":        int64(order.Payment.Amount),
+			"payment_dt":    order.Payment.PaymentDt,
+			"bank":          order.Payment.Bank,
+			"delivery_cost": int64(order.Payment.DeliveryCost),
+			"goods_total":   int64(order.Payment.GoodsTotal),
+			"custom_fee":    int64(order.Payment.CustomFee),
+		},
+		"items":              items,
+		"locale":             order.Locale,
+		"internal_signature": order.InternalSignature,
+		"customer_id":        order.CustomerID,
+		"delivery_service":   order.DeliveryService,
+		"shardkey":           order.Shardkey,
+		"sm_id":              int64(order.SmID),
+		"date_created":       order.DateCreated.Format(time.RFC3339Nano),
+		"oof_shard":          order.OofShard,
+	}
+}
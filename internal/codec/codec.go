@@ -0,0 +1,164 @@
+// Package codec implements decoding of orders from Kafka messages in JSON, Avro and Protobuf formats.
+// Avro and Protobuf messages use the Confluent wire format and their schemas are resolved through the schema registry
+package codec
+
+import (
+	"context"
+	_ "embed"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"l0/internal/interfaces"
+	"l0/internal/models"
+	"mime"
+	"strings"
+)
+
+// Formats of order messages
+const (
+	FormatJSON     = "json"
+	FormatAvro     = "avro"
+	FormatProtobuf = "protobuf"
+)
+
+// Subject is the schema registry subject of order schemas
+const Subject = "orders-value"
+
+// ContentTypeHeader is the Kafka message header selecting the message format
+const ContentTypeHeader = "content-type"
+
+// magicByte is the first byte of messages in the Confluent wire format
+const magicByte = 0
+
+var (
+	ErrUnknownFormat   = errors.New("unknown message format")
+	ErrInvalidWire     = errors.New("invalid wire format")
+	ErrNoRegistry      = errors.New("schema registry is not configured")
+	ErrSchemaMismatch  = errors.New("schema type doesn't match the message format")
+	ErrUnknownMessage  = errors.New("unknown message in schema")
+	ErrMalformedFields = errors.New("malformed message fields")
+)
+
+// AvroSchema is the current Avro schema of orders
+//
+//go:embed schemas/order.avsc
+var AvroSchema string
+
+// ProtobufSchema is the current Protobuf schema of orders
+//
+//go:embed schemas/order.proto
+var ProtobufSchema string
+
+// contentTypes maps content types of messages to formats
+var contentTypes = map[string]string{
+	"application/json":                   FormatJSON,
+	"application/vnd.confluent.avro":     FormatAvro,
+	"application/avro":                   FormatAvro,
+	"avro/binary":                        FormatAvro,
+	"application/vnd.confluent.protobuf": FormatProtobuf,
+	"application/protobuf":               FormatProtobuf,
+	"application/x-protobuf":             FormatProtobuf,
+}
+
+// ContentType returns the content type set on produced messages of the format
+func ContentType(format string) string {
+	switch format {
+	case FormatAvro:
+		return "application/vnd.confluent.avro"
+	case FormatProtobuf:
+		return "application/vnd.confluent.protobuf"
+	default:
+		return "application/json"
+	}
+}
+
+// FormatFromContentType returns the format of the content type, parameters of the content type are ignored
+func FormatFromContentType(contentType string) (string, error) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return "", fmt.Errorf("%w: %q", ErrUnknownFormat, contentType)
+	}
+	format, ok := contentTypes[strings.ToLower(mediaType)]
+	if !ok {
+		return "", fmt.Errorf("%w: %q", ErrUnknownFormat, contentType)
+	}
+	return format, nil
+}
+
+// A Decoders is a set of order decoders selected by the message content type or the default format
+type Decoders struct {
+	decoders      map[string]interfaces.OrderDecoder
+	defaultFormat string
+}
+
+// NewDecoders creates decoders of all formats. Avro and Protobuf decoders fail without the schema registry
+func NewDecoders(defaultFormat string, registry interfaces.SchemaRegistry) (*Decoders, error) {
+	if defaultFormat == "" {
+		defaultFormat = FormatJSON
+	}
+
+	d := &Decoders{
+		decoders: map[string]interfaces.OrderDecoder{
+			FormatJSON:     NewJSONDecoder(),
+			FormatAvro:     NewAvroDecoder(registry),
+			FormatProtobuf: NewProtobufDecoder(registry),
+		},
+		defaultFormat: defaultFormat,
+	}
+	if _, ok := d.decoders[defaultFormat]; !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownFormat, defaultFormat)
+	}
+
+	return d, nil
+}
+
+// Format returns the format of the message with the content type, the default format is used for empty content type
+func (d *Decoders) Format(contentType string) (string, error) {
+	if strings.TrimSpace(contentType) == "" {
+		return d.defaultFormat, nil
+	}
+	return FormatFromContentType(contentType)
+}
+
+// Decode decodes the order from the message value in the format of the content type
+func (d *Decoders) Decode(ctx context.Context, contentType string, value []byte) (*models.Order, error) {
+	format, err := d.Format(contentType)
+	if err != nil {
+		return nil, err
+	}
+	return d.decoders[format].Decode(ctx, value)
+}
+
+// parseWireFormat splits the message in the Confluent wire format into the schema ID and the payload
+func parseWireFormat(value []byte) (int, []byte, error) {
+	if len(value) < 5 {
+		return 0, nil, fmt.Errorf("%w: message is too short", ErrInvalidWire)
+	}
+	if value[0] != magicByte {
+		return 0, nil, fmt.Errorf("%w: unexpected magic byte %d", ErrInvalidWire, value[0])
+	}
+	return int(binary.BigEndian.Uint32(value[1:5])), value[5:], nil
+}
+
+// appendWireHeader appends the header of the Confluent wire format with the schema ID
+func appendWireHeader(b []byte, schemaID int) []byte {
+	b = append(b, magicByte)
+	return binary.BigEndian.AppendUint32(b, uint32(schemaID))
+}
+
+// resolveSchema gets the schema from the registry and checks its type
+func resolveSchema(
+	ctx context.Context, registry interfaces.SchemaRegistry, schemaID int, schemaType string,
+) (*models.Schema, error) {
+	if registry == nil {
+		return nil, ErrNoRegistry
+	}
+	schema, err := registry.GetSchemaByID(ctx, schemaID)
+	if err != nil {
+		return nil, err
+	}
+	if schema.Type != schemaType {
+		return nil, fmt.Errorf("%w: schema %d is %s", ErrSchemaMismatch, schemaID, schema.Type)
+	}
+	return schema, nil
+}
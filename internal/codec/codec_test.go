@@ -0,0 +1,202 @@
+package codec
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"l0/internal/models"
+	"l0/internal/schemaregistry"
+)
+
+func testOrder() *models.Order {
+	return &models.Order{
+		OrderUID:    "b563feb7b2b84b6test",
+		TrackNumber: "WBILMTESTTRACK",
+		Entry:       "WBIL",
+		Delivery: models.Delivery{
+			Name: "Test Testov", Phone: "+9720000000", Zip: "2639809", City: "Kiryat Mozkin",
+			Address: "Ploshad Mira 15", Region: "Kraiot", Email: "test@gmail.com",
+		},
+		Payment: models.Payment{
+			Transaction: "b563feb7b2b84b6test", Currency: "USD", Provider: "wbpay", Amount: 1817,
+			PaymentDt: 1637907727, Bank: "alpha", DeliveryCost: 1500, GoodsTotal: 317,
+		},
+		Items: []models.Item{
+			{
+				ChrtID: 9934930, TrackNumber: "WBILMTESTTRACK", Price: 453, Rid: "ab4219087a764ae0btest",
+				Name: "Mascaras", Sale: 30, Size: "0", TotalPrice: 317, NmID: 2389212, Brand: "Vivienne Sabo",
+				Status: 202,
+			},
+		},
+		Locale:          "en",
+		CustomerID:      "test",
+		DeliveryService: "meest",
+		Shardkey:        "9",
+		SmID:            99,
+		DateCreated:     time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC),
+		OofShard:        "1",
+	}
+}
+
+// assertOrder checks the decoded order matches the original one except for the schema version
+func assertOrder(t *testing.T, expected, actual *models.Order) {
+	t.Helper()
+	actualCopy := *actual
+	actualCopy.SchemaVersion = ""
+	actualCopy.DateCreated = actual.DateCreated.UTC()
+	if !reflect.DeepEqual(*expected, actualCopy) {
+		t.Errorf("error: decoded order differs\nexpected %+v\ngot      %+v", *expected, actualCopy)
+	}
+}
+
+func TestFormatFromContentType(t *testing.T) {
+	tests := map[string]string{
+		"application/json":                   FormatJSON,
+		"application/json; charset=utf-8":    FormatJSON,
+		"application/vnd.confluent.avro":     FormatAvro,
+		"avro/binary":                        FormatAvro,
+		"application/x-protobuf":             FormatProtobuf,
+		"Application/Vnd.Confluent.Protobuf": FormatProtobuf,
+	}
+	for contentType, expected := range tests {
+		format, err := FormatFromContentType(contentType)
+		if err != nil || format != expected {
+			t.Errorf("error: expected %s for %q, got %s, %v", expected, contentType, format, err)
+		}
+	}
+
+	if _, err := FormatFromContentType("text/plain"); !errors.Is(err, ErrUnknownFormat) {
+		t.Errorf("error: expected ErrUnknownFormat, got %v", err)
+	}
+}
+
+func TestDecoders_JSON(t *testing.T) {
+	decoders, err := NewDecoders("", nil)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	order := testOrder()
+	value, _ := json.Marshal(order)
+
+	decoded, err := decoders.Decode(context.Background(), "", value)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	assertOrder(t, order, decoded)
+	if decoded.SchemaVersion != "json" {
+		t.Errorf("error: expected schema version json, got %s", decoded.SchemaVersion)
+	}
+
+	if _, err := NewDecoders("xml", nil); !errors.Is(err, ErrUnknownFormat) {
+		t.Errorf("error: expected ErrUnknownFormat, got %v", err)
+	}
+}
+
+func TestDecoders_Avro(t *testing.T) {
+	registry := schemaregistry.NewMemoryRegistry()
+	id, _ := registry.Register(context.Background(), Subject, models.SchemaTypeAvro, AvroSchema)
+
+	order := testOrder()
+	value, err := MarshalAvro(id, order)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	decoders, _ := NewDecoders(FormatJSON, registry)
+	decoded, err := decoders.Decode(context.Background(), ContentType(FormatAvro), value)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	assertOrder(t, order, decoded)
+	if decoded.SchemaVersion != "avro:orders-value:1" {
+		t.Errorf("error: expected schema version avro:orders-value:1, got %s", decoded.SchemaVersion)
+	}
+}
+
+func TestDecoders_AvroOlderSchema(t *testing.T) {
+	registry := schemaregistry.NewMemoryRegistry()
+	ctx := context.Background()
+	id, _ := registry.Register(ctx, Subject, models.SchemaTypeAvro, AvroSchema)
+	// The current schema is registered as the second version, messages of both versions must be decoded
+	oldID, _ := registry.Register(ctx, Subject, models.SchemaTypeAvro, `{
+		"type": "record", "name": "Order", "namespace": "l0.orders",
+		"fields": [
+			{"name": "order_uid", "type": "string"},
+			{"name": "track_number", "type": ["null", "string"], "default": null}
+		]
+	}`)
+
+	oldValue := appendWireHeader(nil, oldID)
+	oldValue = append(oldValue, 2*7, 'o', 'r', 'd', 'e', 'r', '-', '1', 2, 2*5, 'T', 'R', 'A', 'C', 'K')
+
+	decoders, _ := NewDecoders(FormatAvro, registry)
+	decoded, err := decoders.Decode(ctx, "", oldValue)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if decoded.OrderUID != "order-1" || decoded.TrackNumber != "TRACK" {
+		t.Errorf("error: unexpected order %+v", decoded)
+	}
+	if decoded.SchemaVersion != "avro:orders-value:2" {
+		t.Errorf("error: expected schema version avro:orders-value:2, got %s", decoded.SchemaVersion)
+	}
+
+	value, _ := MarshalAvro(id, testOrder())
+	if _, err := decoders.Decode(ctx, "", value); err != nil {
+		t.Errorf("error: %v", err)
+	}
+}
+
+func TestDecoders_Protobuf(t *testing.T) {
+	registry := schemaregistry.NewMemoryRegistry()
+	id, _ := registry.Register(context.Background(), Subject, models.SchemaTypeProtobuf, ProtobufSchema)
+
+	order := testOrder()
+	decoders, _ := NewDecoders(FormatProtobuf, registry)
+	decoded, err := decoders.Decode(context.Background(), "", MarshalProtobuf(id, order))
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	assertOrder(t, order, decoded)
+	if decoded.SchemaVersion != "protobuf:orders-value:1" {
+		t.Errorf("error: expected schema version protobuf:orders-value:1, got %s", decoded.SchemaVersion)
+	}
+}
+
+func TestDecoders_Errors(t *testing.T) {
+	registry := schemaregistry.NewMemoryRegistry()
+	ctx := context.Background()
+	avroID, _ := registry.Register(ctx, Subject, models.SchemaTypeAvro, AvroSchema)
+	protoID, _ := registry.Register(ctx, Subject, models.SchemaTypeProtobuf, ProtobufSchema)
+
+	decoders, _ := NewDecoders(FormatJSON, registry)
+	protobuf := ContentType(FormatProtobuf)
+
+	if _, err := decoders.Decode(ctx, protobuf, []byte{1, 0, 0, 0, 1, 0}); !errors.Is(err, ErrInvalidWire) {
+		t.Errorf("error: expected ErrInvalidWire for wrong magic byte, got %v", err)
+	}
+	if _, err := decoders.Decode(ctx, protobuf, []byte{0, 0}); !errors.Is(err, ErrInvalidWire) {
+		t.Errorf("error: expected ErrInvalidWire for short message, got %v", err)
+	}
+	if _, err := decoders.Decode(ctx, protobuf, MarshalProtobuf(42, testOrder())); !errors.Is(err, schemaregistry.ErrSchemaNotFound) {
+		t.Errorf("error: expected ErrSchemaNotFound, got %v", err)
+	}
+	if _, err := decoders.Decode(ctx, protobuf, MarshalProtobuf(avroID, testOrder())); !errors.Is(err, ErrSchemaMismatch) {
+		t.Errorf("error: expected ErrSchemaMismatch, got %v", err)
+	}
+
+	value := appendWireHeader(nil, protoID)
+	value = append(value, 2, 2)
+	if _, err := decoders.Decode(ctx, protobuf, value); !errors.Is(err, ErrUnknownMessage) {
+		t.Errorf("error: expected ErrUnknownMessage, got %v", err)
+	}
+
+	withoutRegistry, _ := NewDecoders(FormatJSON, nil)
+	if _, err := withoutRegistry.Decode(ctx, protobuf, MarshalProtobuf(protoID, testOrder())); !errors.Is(err, ErrNoRegistry) {
+		t.Errorf("error: expected ErrNoRegistry, got %v", err)
+	}
+}
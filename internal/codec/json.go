@@ -0,0 +1,25 @@
+package codec
+
+import (
+	"context"
+	"encoding/json"
+	"l0/internal/models"
+)
+
+// A JSONDecoder decodes orders from plain JSON messages
+type JSONDecoder struct{}
+
+// NewJSONDecoder creates a new JSON decoder
+func NewJSONDecoder() *JSONDecoder {
+	return &JSONDecoder{}
+}
+
+// Decode decodes the order from JSON, the schema version of JSON orders is "json"
+func (d *JSONDecoder) Decode(_ context.Context, value []byte) (*models.Order, error) {
+	var order models.Order
+	if err := json.Unmarshal(value, &order); err != nil {
+		return nil, err
+	}
+	order.SchemaVersion = FormatJSON
+	return &order, nil
+}
@@ -0,0 +1,395 @@
+package codec
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"google.golang.org/protobuf/encoding/protowire"
+	"l0/internal/interfaces"
+	"l0/internal/models"
+	"time"
+)
+
+// A ProtobufDecoder decodes orders from Protobuf messages in the Confluent wire format.
+// Messages are decoded by field numbers of ProtobufSchema, unknown fields are skipped
+type ProtobufDecoder struct {
+	registry interfaces.SchemaRegistry
+}
+
+// NewProtobufDecoder creates a new Protobuf decoder resolving schemas through the registry
+func NewProtobufDecoder(registry interfaces.SchemaRegistry) *ProtobufDecoder {
+	return &ProtobufDecoder{registry: registry}
+}
+
+// Decode decodes the order from the Protobuf message and records the schema version on it
+func (d *ProtobufDecoder) Decode(ctx context.Context, value []byte) (*models.Order, error) {
+	schemaID, payload, err := parseWireFormat(value)
+	if err != nil {
+		return nil, err
+	}
+	schema, err := resolveSchema(ctx, d.registry, schemaID, models.SchemaTypeProtobuf)
+	if err != nil {
+		return nil, err
+	}
+
+	indexes, n, err := parseMessageIndexes(payload)
+	if err != nil {
+		return nil, err
+	}
+	// Order is the first message of the schema file
+	if len(indexes) != 1 || indexes[0] != 0 {
+		return nil, fmt.Errorf("%w: %v", ErrUnknownMessage, indexes)
+	}
+
+	var order models.Order
+	if err := unmarshalOrder(payload[n:], &order); err != nil {
+		return nil, err
+	}
+	order.SchemaVersion = schema.VersionString(FormatProtobuf)
+
+	return &order, nil
+}
+
+// parseMessageIndexes parses the path of the message in the schema file which follows the schema ID.
+// The single zero byte is a shortcut for the first message
+func parseMessageIndexes(b []byte) ([]int, int, error) {
+	count, n := binary.Varint(b)
+	if n <= 0 || count < 0 || count > int64(len(b)) {
+		return nil, 0, fmt.Errorf("%w: invalid message indexes", ErrInvalidWire)
+	}
+	if count == 0 {
+		return []int{0}, n, nil
+	}
+
+	indexes := make([]int, 0, count)
+	for i := int64(0); i < count; i++ {
+		index, m := binary.Varint(b[n:])
+		if m <= 0 || index < 0 {
+			return nil, 0, fmt.Errorf("%w: invalid message indexes", ErrInvalidWire)
+		}
+		indexes = append(indexes, int(index))
+		n += m
+	}
+
+	return indexes, n, nil
+}
+
+// MarshalProtobuf encodes the order with the current Protobuf schema into the Confluent wire format.
+// The schemaID must be the ID of ProtobufSchema in the registry
+func MarshalProtobuf(schemaID int, order *models.Order) []byte {
+	b := appendWireHeader(nil, schemaID)
+	b = binary.AppendVarint(b, 0)
+	return appendOrder(b, order)
+}
+
+// fieldFunc decodes the field and returns the number of consumed bytes or 0 if the field is unknown
+type fieldFunc func(num protowire.Number, typ protowire.Type, b []byte) (int, error)
+
+// consumeFields calls field for every field of the message and skips unknown fields
+func consumeFields(b []byte, field fieldFunc) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return fmt.Errorf("%w: %v", ErrMalformedFields, protowire.ParseError(n))
+		}
+		b = b[n:]
+
+		m, err := field(num, typ, b)
+		if err != nil {
+			return fmt.Errorf("field %d: %w", num, err)
+		}
+		if m == 0 {
+			m = protowire.ConsumeFieldValue(num, typ, b)
+		}
+		if m < 0 {
+			return fmt.Errorf("%w: %v", ErrMalformedFields, protowire.ParseError(m))
+		}
+		b = b[m:]
+	}
+	return nil
+}
+
+// consumeString decodes the string field into s
+func consumeString(typ protowire.Type, b []byte, s *string) (int, error) {
+	if typ != protowire.BytesType {
+		return 0, ErrMalformedFields
+	}
+	v, n := protowire.ConsumeString(b)
+	if n < 0 {
+		return 0, fmt.Errorf("%w: %v", ErrMalformedFields, protowire.ParseError(n))
+	}
+	*s = v
+	return n, nil
+}
+
+// consumeInt decodes the int64 field and passes it to set
+func consumeInt(typ protowire.Type, b []byte, set func(int64)) (int, error) {
+	if typ != protowire.VarintType {
+		return 0, ErrMalformedFields
+	}
+	v, n := protowire.ConsumeVarint(b)
+	if n < 0 {
+		return 0, fmt.Errorf("%w: %v", ErrMalformedFields, protowire.ParseError(n))
+	}
+	set(int64(v))
+	return n, nil
+}
+
+// consumeMessage decodes the embedded message field with unmarshal
+func consumeMessage(typ protowire.Type, b []byte, unmarshal func([]byte) error) (int, error) {
+	if typ != protowire.BytesType {
+		return 0, ErrMalformedFields
+	}
+	v, n := protowire.ConsumeBytes(b)
+	if n < 0 {
+		return 0, fmt.Errorf("%w: %v", ErrMalformedFields, protowire.ParseError(n))
+	}
+	return n, unmarshal(v)
+}
+
+func unmarshalOrder(b []byte, order *models.Order) error {
+	return consumeFields(
+		b, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+			switch num {
+			case 1:
+				return consumeString(typ, b, &order.OrderUID)
+			case 2:
+				return consumeString(typ, b, &order.TrackNumber)
+			case 3:
+				return consumeString(typ, b, &order.Entry)
+			case 4:
+				return consumeMessage(
+					typ, b, func(b []byte) error {
+						return unmarshalDelivery(b, &order.Delivery)
+					},
+				)
+			case 5:
+				return consumeMessage(
+					typ, b, func(b []byte) error {
+						return unmarshalPayment(b, &order.Payment)
+					},
+				)
+			case 6:
+				return consumeMessage(
+					typ, b, func(b []byte) error {
+						var item models.Item
+						if err := unmarshalItem(b, &item); err != nil {
+							return err
+						}
+						order.Items = append(order.Items, item)
+						return nil
+					},
+				)
+			case 7:
+				return consumeString(typ, b, &order.Locale)
+			case 8:
+				return consumeString(typ, b, &order.InternalSignature)
+			case 9:
+				return consumeString(typ, b, &order.CustomerID)
+			case 10:
+				return consumeString(typ, b, &order.DeliveryService)
+			case 11:
+				return consumeString(typ, b, &order.Shardkey)
+			case 12:
+				return consumeInt(typ, b, func(v int64) { order.SmID = int(v) })
+			case 13:
+				return consumeMessage(
+					typ, b, func(b []byte) error {
+						return unmarshalTimestamp(b, &order.DateCreated)
+					},
+				)
+			case 14:
+				return consumeString(typ, b, &order.OofShard)
+			}
+			return 0, nil
+		},
+	)
+}
+
+func unmarshalDelivery(b []byte, delivery *models.Delivery) error {
+	fields := map[protowire.Number]*string{
+		1: &delivery.Name,
+		2: &delivery.Phone,
+		3: &delivery.Zip,
+		4: &delivery.City,
+		5: &delivery.Address,
+		6: &delivery.Region,
+		7: &delivery.Email,
+	}
+	return consumeFields(
+		b, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+			if s, ok := fields[num]; ok {
+				return consumeString(typ, b, s)
+			}
+			return 0, nil
+		},
+	)
+}
+
+func unmarshalPayment(b []byte, payment *models.Payment) error {
+	return consumeFields(
+		b, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+			switch num {
+			case 1:
+				return consumeString(typ, b, &payment.Transaction)
+			case 2:
+				return consumeString(typ, b, &payment.RequestID)
+			case 3:
+				return consumeString(typ, b, &payment.Currency)
+			case 4:
+				return consumeString(typ, b, &payment.Provider)
+			case 5:
+				return consumeInt(typ, b, func(v int64) { payment.Amount = int(v) })
+			case 6:
+				return consumeInt(typ, b, func(v int64) { payment.PaymentDt = v })
+			case 7:
+				return consumeString(typ, b, &payment.Bank)
+			case 8:
+				return consumeInt(typ, b, func(v int64) { payment.DeliveryCost = int(v) })
+			case 9:
+				return consumeInt(typ, b, func(v int64) { payment.GoodsTotal = int(v) })
+			case 10:
+				return consumeInt(typ, b, func(v int64) { payment.CustomFee = int(v) })
+			}
+			return 0, nil
+		},
+	)
+}
+
+func unmarshalItem(b []byte, item *models.Item) error {
+	return consumeFields(
+		b, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+			switch num {
+			case 1:
+				return consumeInt(typ, b, func(v int64) { item.ChrtID = v })
+			case 2:
+				return consumeString(typ, b, &item.TrackNumber)
+			case 3:
+				return consumeInt(typ, b, func(v int64) { item.Price = int(v) })
+			case 4:
+				return consumeString(typ, b, &item.Rid)
+			case 5:
+				return consumeString(typ, b, &item.Name)
+			case 6:
+				return consumeInt(typ, b, func(v int64) { item.Sale = int(v) })
+			case 7:
+				return consumeString(typ, b, &item.Size)
+			case 8:
+				return consumeInt(typ, b, func(v int64) { item.TotalPrice = int(v) })
+			case 9:
+				return consumeInt(typ, b, func(v int64) { item.NmID = v })
+			case 10:
+				return consumeString(typ, b, &item.Brand)
+			case 11:
+				return consumeInt(typ, b, func(v int64) { item.Status = int(v) })
+			}
+			return 0, nil
+		},
+	)
+}
+
+// unmarshalTimestamp decodes google.protobuf.Timestamp
+func unmarshalTimestamp(b []byte, t *time.Time) error {
+	var seconds, nanos int64
+	err := consumeFields(
+		b, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+			switch num {
+			case 1:
+				return consumeInt(typ, b, func(v int64) { seconds = v })
+			case 2:
+				return consumeInt(typ, b, func(v int64) { nanos = v })
+			}
+			return 0, nil
+		},
+	)
+	if err != nil {
+		return err
+	}
+	*t = time.Unix(seconds, nanos).UTC()
+	return nil
+}
+
+// appendString appends the string field, empty strings are omitted as in proto3
+func appendString(b []byte, num protowire.Number, s string) []byte {
+	if s == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, s)
+}
+
+// appendInt appends the int64 field, zeros are omitted as in proto3
+func appendInt(b []byte, num protowire.Number, v int64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, uint64(v))
+}
+
+// appendMessage appends the embedded message field encoded by appendFields
+func appendMessage(b []byte, num protowire.Number, appendFields func([]byte) []byte) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, appendFields(nil))
+}
+
+func appendOrder(b []byte, order *models.Order) []byte {
+	b = appendString(b, 1, order.OrderUID)
+	b = appendString(b, 2, order.TrackNumber)
+	b = appendString(b, 3, order.Entry)
+	b = appendMessage(
+		b, 4, func(b []byte) []byte {
+			b = appendString(b, 1, order.Delivery.Name)
+			b = appendString(b, 2, order.Delivery.Phone)
+			b = appendString(b, 3, order.Delivery.Zip)
+			b = appendString(b, 4, order.Delivery.City)
+			b = appendString(b, 5, order.Delivery.Address)
+			b = appendString(b, 6, order.Delivery.Region)
+			return appendString(b, 7, order.Delivery.Email)
+		},
+	)
+	b = appendMessage(
+		b, 5, func(b []byte) []byte {
+			b = appendString(b, 1, order.Payment.Transaction)
+			b = appendString(b, 2, order.Payment.RequestID)
+			b = appendString(b, 3, order.Payment.Currency)
+			b = appendString(b, 4, order.Payment.Provider)
+			b = appendInt(b, 5, int64(order.Payment.Amount))
+			b = appendInt(b, 6, order.Payment.PaymentDt)
+			b = appendString(b, 7, order.Payment.Bank)
+			b = appendInt(b, 8, int64(order.Payment.DeliveryCost))
+			b = appendInt(b, 9, int64(order.Payment.GoodsTotal))
+			return appendInt(b, 10, int64(order.Payment.CustomFee))
+		},
+	)
+	for _, item := range order.Items {
+		b = appendMessage(
+			b, 6, func(b []byte) []byte {
+				b = appendInt(b, 1, item.ChrtID)
+				b = appendString(b, 2, item.TrackNumber)
+				b = appendInt(b, 3, int64(item.Price))
+				b = appendString(b, 4, item.Rid)
+				b = appendString(b, 5, item.Name)
+				b = appendInt(b, 6, int64(item.Sale))
+				b = appendString(b, 7, item.Size)
+				b = appendInt(b, 8, int64(item.TotalPrice))
+				b = appendInt(b, 9, item.NmID)
+				b = appendString(b, 10, item.Brand)
+				return appendInt(b, 11, int64(item.Status))
+			},
+		)
+	}
+	b = appendString(b, 7, order.Locale)
+	b = appendString(b, 8, order.InternalSignature)
+	b = appendString(b, 9, order.CustomerID)
+	b = appendString(b, 10, order.DeliveryService)
+	b = appendString(b, 11, order.Shardkey)
+	b = appendInt(b, 12, int64(order.SmID))
+	b = appendMessage(
+		b, 13, func(b []byte) []byte {
+			b = appendInt(b, 1, order.DateCreated.Unix())
+			return appendInt(b, 2, int64(order.DateCreated.Nanosecond()))
+		},
+	)
+	return appendString(b, 14, order.OofShard)
+}
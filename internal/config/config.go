@@ -6,6 +6,7 @@ import (
 	"github.com/joho/godotenv"
 	"gopkg.in/yaml.v3"
 	"os"
+	"strings"
 	"time"
 )
 
@@ -16,6 +17,13 @@ type Config struct {
 	Kafka          KafkaConfig          `yaml:"kafka"`
 	Cache          CacheConfig          `yaml:"cache"`
 	CircuitBreaker CircuitBreakerConfig `yaml:"circuit_breaker"`
+	Currency       CurrencyConfig       `yaml:"currency"`
+	Privacy        PrivacyConfig        `yaml:"privacy"`
+	Auth           AuthConfig           `yaml:"auth"`
+	Outbox         OutboxConfig         `yaml:"outbox"`
+	Reports        ReportsConfig        `yaml:"reports"`
+	Audit          AuditConfig          `yaml:"audit"`
+	Webhooks       WebhooksConfig       `yaml:"webhooks"`
 }
 
 // A ServerConfig contains configurations for HTTP server
@@ -24,6 +32,33 @@ type ServerConfig struct {
 	ReadTimeout  time.Duration `yaml:"read_timeout"`
 	WriteTimeout time.Duration `yaml:"write_timeout"`
 	IdleTimeout  time.Duration `yaml:"idle_timeout"`
+	// MaxInFlight limits the number of concurrently served requests, 0 means no limit
+	MaxInFlight int             `yaml:"max_in_flight"`
+	RateLimit   RateLimitConfig `yaml:"rate_limit"`
+	Stream      StreamConfig    `yaml:"stream"`
+}
+
+// A RateLimitConfig represents settings for per-client rate limiting of HTTP requests.
+// Clients are identified by the authenticated principal or by IP address.
+// Failed authentications are limited per IP address before credentials are checked, 0 burst disables the limit
+type RateLimitConfig struct {
+	Enabled             bool          `yaml:"enabled"`
+	RequestsPerSecond   float64       `yaml:"requests_per_second"`
+	Burst               int           `yaml:"burst"`
+	ClientTTL           time.Duration `yaml:"client_ttl"`
+	TrustForwardedFor   bool          `yaml:"trust_forwarded_for"`
+	FailedAuthPerSecond float64       `yaml:"failed_auth_per_second"`
+	FailedAuthBurst     int           `yaml:"failed_auth_burst"`
+}
+
+// A StreamConfig represents settings for streaming order changes over SSE and WebSocket.
+// A connection is closed when its buffer of undelivered changes overflows, clients are expected to reconnect
+type StreamConfig struct {
+	Enabled           bool          `yaml:"enabled"`
+	MaxSubscribers    int           `yaml:"max_subscribers"`
+	BufferSize        int           `yaml:"buffer_size"`
+	HeartbeatInterval time.Duration `yaml:"heartbeat_interval"`
+	WriteTimeout      time.Duration `yaml:"write_timeout"`
 }
 
 // A DatabaseConfig contains settings for Postgres
@@ -43,10 +78,94 @@ type DatabaseConfig struct {
 
 // A KafkaConfig contains settings for Kafka
 type KafkaConfig struct {
+	// Topic is the orders topic used if Topics are not set
 	Topic               string   `yaml:"topic"`
 	GroupID             string   `yaml:"group_id"`
 	Listeners           string   `yaml:"listeners"`
 	AdvertisedListeners []string `yaml:"advertised_listeners"`
+	// OffsetStorage is either "kafka" to commit offsets to the consumer group
+	// or "postgres" to store them in the same transaction as orders
+	OffsetStorage string `yaml:"offset_storage"`
+	// StartOffset is either "earliest" or "latest", partitions without committed offsets are consumed from it.
+	// By default new groups skip history if offsets are committed to Kafka and read it if they are stored in Postgres
+	StartOffset string `yaml:"start_offset"`
+	// ReplayGroupSuffix is appended to GroupID to name the group of replays started without a group
+	ReplayGroupSuffix string `yaml:"replay_group_suffix"`
+	// Concurrency is the number of workers processing messages, messages are dispatched to workers
+	// by partition or by key hash depending on DispatchBy. It's ignored if offsets are stored in Postgres
+	Concurrency     int    `yaml:"concurrency"`
+	DispatchBy      string `yaml:"dispatch_by"`
+	WorkerQueueSize int    `yaml:"worker_queue_size"`
+	// Format is the format of messages without the content-type header: "json", "avro" or "protobuf"
+	Format         string               `yaml:"format"`
+	SchemaRegistry SchemaRegistryConfig `yaml:"schema_registry"`
+	Security       KafkaSecurityConfig  `yaml:"security"`
+	Topics         []TopicConfig        `yaml:"topics"`
+	Backpressure   BackpressureConfig   `yaml:"backpressure"`
+	RetryTopics    RetryTopicsConfig    `yaml:"retry_topics"`
+}
+
+// A RetryTopicsConfig represents settings for retrying failed messages through delayed retry topics.
+// A failed message is republished to the next tier and processed again after the tier delay,
+// it's sent to the dead letter queue only after the last tier
+type RetryTopicsConfig struct {
+	Enabled bool              `yaml:"enabled"`
+	Tiers   []RetryTierConfig `yaml:"tiers"`
+}
+
+// A RetryTierConfig represents a retry topic with the delay of processing its messages
+type RetryTierConfig struct {
+	Topic string        `yaml:"topic"`
+	Delay time.Duration `yaml:"delay"`
+}
+
+// A BackpressureConfig represents settings for pausing the consumer while downstream is unhealthy.
+// Fetching is paused while the order service circuit breaker is open or the share of acquired
+// database connections is not less than PoolSaturation
+type BackpressureConfig struct {
+	Enabled        bool          `yaml:"enabled"`
+	CheckInterval  time.Duration `yaml:"check_interval"`
+	PoolSaturation float64       `yaml:"pool_saturation"`
+}
+
+// A TopicConfig maps a topic or all existing topics matching the pattern to a message handler.
+// Patterns are resolved when the consumer starts
+type TopicConfig struct {
+	Name    string `yaml:"name"`
+	Pattern string `yaml:"pattern"`
+	Handler string `yaml:"handler"`
+}
+
+// A KafkaSecurityConfig represents settings for connections to Kafka shared by consumers and producers.
+// Protocol is one of "plaintext", "ssl", "sasl_plaintext" or "sasl_ssl"
+type KafkaSecurityConfig struct {
+	Protocol string          `yaml:"protocol"`
+	TLS      KafkaTLSConfig  `yaml:"tls"`
+	SASL     KafkaSASLConfig `yaml:"sasl"`
+}
+
+// A KafkaTLSConfig represents TLS settings, the client certificate and key are used for mutual TLS
+type KafkaTLSConfig struct {
+	CAFile             string `yaml:"ca_file"`
+	CertFile           string `yaml:"cert_file"`
+	KeyFile            string `yaml:"key_file"`
+	ServerName         string `yaml:"server_name"`
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify"`
+}
+
+// A KafkaSASLConfig represents SASL settings. Mechanism is one of "PLAIN", "SCRAM-SHA-256" or "SCRAM-SHA-512".
+// The password may be read from PasswordFile instead of the configuration
+type KafkaSASLConfig struct {
+	Mechanism    string `yaml:"mechanism"`
+	Username     string `yaml:"username"`
+	Password     string `yaml:"password"`
+	PasswordFile string `yaml:"password_file"`
+}
+
+// A SchemaRegistryConfig represents settings for the schema registry client used to decode Avro and Protobuf messages
+type SchemaRegistryConfig struct {
+	URL     string        `yaml:"url"`
+	Timeout time.Duration `yaml:"timeout"`
 }
 
 // A CacheConfig represents settings for cache
@@ -66,6 +185,102 @@ type CircuitBreakerConfig struct {
 	HalfOpenMaxCalls int           `yaml:"half_open_max_calls"`
 }
 
+// A CurrencyConfig represents settings for currency conversion
+type CurrencyConfig struct {
+	BaseCurrency string `yaml:"base_currency"`
+	RatesFile    string `yaml:"rates_file"`
+}
+
+// A PrivacyConfig represents settings for personal data protection
+type PrivacyConfig struct {
+	Encryption EncryptionConfig `yaml:"encryption"`
+	Retention  RetentionConfig  `yaml:"retention"`
+}
+
+// A RetentionConfig represents settings of the retention job. Orders older than max age are deleted
+// or their personal data are anonymized depending on the mode, at most batch size orders at once
+type RetentionConfig struct {
+	Enabled   bool          `yaml:"enabled"`
+	MaxAge    time.Duration `yaml:"max_age"`
+	Mode      string        `yaml:"mode"`
+	Interval  time.Duration `yaml:"interval"`
+	BatchSize int           `yaml:"batch_size"`
+}
+
+// An EncryptionConfig represents settings for field-level encryption, keys are base64 encoded and mapped by IDs
+type EncryptionConfig struct {
+	ActiveKeyID   string            `yaml:"active_key_id"`
+	Keys          map[string]string `yaml:"keys"`
+	RotateOnStart bool              `yaml:"rotate_on_start"`
+}
+
+// An AuthConfig represents settings for authentication of HTTP API requests
+type AuthConfig struct {
+	Enabled bool           `yaml:"enabled"`
+	APIKeys []APIKeyConfig `yaml:"api_keys"`
+	JWT     JWTConfig      `yaml:"jwt"`
+}
+
+// An APIKeyConfig represents a static API key, the key may be read from the environmental variable KeyEnv
+type APIKeyConfig struct {
+	Name   string   `yaml:"name"`
+	Key    string   `yaml:"key"`
+	KeyEnv string   `yaml:"key_env"`
+	Scopes []string `yaml:"scopes"`
+}
+
+// A JWTConfig represents settings for JWT verification
+type JWTConfig struct {
+	HMACSecret string        `yaml:"hmac_secret"`
+	JWKSFile   string        `yaml:"jwks_file"`
+	Issuer     string        `yaml:"issuer"`
+	Audience   string        `yaml:"audience"`
+	Leeway     time.Duration `yaml:"leeway"`
+}
+
+// An OutboxConfig represents settings for publishing events from the transactional outbox
+type OutboxConfig struct {
+	Enabled         bool          `yaml:"enabled"`
+	Topic           string        `yaml:"topic"`
+	PollInterval    time.Duration `yaml:"poll_interval"`
+	BatchSize       int           `yaml:"batch_size"`
+	Retention       time.Duration `yaml:"retention"`
+	CleanupInterval time.Duration `yaml:"cleanup_interval"`
+}
+
+// A ReportsConfig represents settings of sales reports. If the materialized view is enabled,
+// reports are read from views refreshed every refresh interval
+type ReportsConfig struct {
+	MaterializedView bool          `yaml:"materialized_view"`
+	RefreshInterval  time.Duration `yaml:"refresh_interval"`
+}
+
+// An AuditConfig represents settings of the audit log. Entries are buffered in memory and written in batches,
+// entries recorded while the buffer is full are dropped
+type AuditConfig struct {
+	Enabled       bool          `yaml:"enabled"`
+	BufferSize    int           `yaml:"buffer_size"`
+	BatchSize     int           `yaml:"batch_size"`
+	FlushInterval time.Duration `yaml:"flush_interval"`
+}
+
+// A WebhooksConfig represents settings for delivering outbox events to webhook subscriptions.
+// Failed deliveries are retried with exponential backoff until MaxAttempts, the circuit breaker of a subscription
+// opens after BreakerFailures consecutive failures and defers its deliveries for BreakerTimeout.
+// Events are taken from the outbox, so they're lost if they aren't enqueued within the outbox retention
+type WebhooksConfig struct {
+	Enabled         bool          `yaml:"enabled"`
+	PollInterval    time.Duration `yaml:"poll_interval"`
+	BatchSize       int           `yaml:"batch_size"`
+	Concurrency     int           `yaml:"concurrency"`
+	Timeout         time.Duration `yaml:"timeout"`
+	MaxAttempts     int           `yaml:"max_attempts"`
+	InitialBackoff  time.Duration `yaml:"initial_backoff"`
+	MaxBackoff      time.Duration `yaml:"max_backoff"`
+	BreakerFailures int           `yaml:"breaker_failures"`
+	BreakerTimeout  time.Duration `yaml:"breaker_timeout"`
+}
+
 // LoadConfig loads data into Config structure from a file
 func LoadConfig(configPath string) (*Config, error) {
 	data, err := os.ReadFile(configPath)
@@ -91,6 +306,50 @@ func (c *Config) loadEnv() {
 	c.Database.Password = os.Getenv("POSTGRES_PASSWORD")
 	c.Database.Database = os.Getenv("POSTGRES_DB")
 
+	// Privacy env variables, keys are set as "id1:base64key1,id2:base64key2"
+	if keyID := os.Getenv("PII_ACTIVE_KEY_ID"); keyID != "" {
+		c.Privacy.Encryption.ActiveKeyID = keyID
+	}
+	if keys := os.Getenv("PII_ENCRYPTION_KEYS"); keys != "" {
+		c.Privacy.Encryption.Keys = make(map[string]string)
+		for _, pair := range splitList(keys) {
+			id, key, _ := strings.Cut(pair, ":")
+			c.Privacy.Encryption.Keys[id] = key
+		}
+	}
+
+	// Kafka env variables
+	if url := os.Getenv("SCHEMA_REGISTRY_URL"); url != "" {
+		c.Kafka.SchemaRegistry.URL = url
+	}
+	if username := os.Getenv("KAFKA_SASL_USERNAME"); username != "" {
+		c.Kafka.Security.SASL.Username = username
+	}
+	if password := os.Getenv("KAFKA_SASL_PASSWORD"); password != "" {
+		c.Kafka.Security.SASL.Password = password
+	}
+
+	// Auth env variables
+	if secret := os.Getenv("AUTH_JWT_HMAC_SECRET"); secret != "" {
+		c.Auth.JWT.HMACSecret = secret
+	}
+	for i := range c.Auth.APIKeys {
+		if c.Auth.APIKeys[i].KeyEnv != "" {
+			c.Auth.APIKeys[i].Key = os.Getenv(c.Auth.APIKeys[i].KeyEnv)
+		}
+	}
+
+}
+
+// splitList splits a comma-separated list trimming spaces and skipping empty elements
+func splitList(list string) []string {
+	var result []string
+	for _, elem := range strings.Split(list, ",") {
+		if elem = strings.TrimSpace(elem); elem != "" {
+			result = append(result, elem)
+		}
+	}
+	return result
 }
 
 func (c *Config) GetServerAddress() string {
@@ -109,9 +368,102 @@ func (c *Config) Validate() error {
 	if c.Server.Port <= 0 || c.Server.Port > 65535 {
 		return fmt.Errorf("invalid server port: %d: ", c.Server.Port)
 	}
+	if c.Kafka.OffsetStorage != "" && c.Kafka.OffsetStorage != "kafka" && c.Kafka.OffsetStorage != "postgres" {
+		return fmt.Errorf("invalid kafka offset storage: %q", c.Kafka.OffsetStorage)
+	}
+	if c.Kafka.StartOffset != "" && c.Kafka.StartOffset != "earliest" && c.Kafka.StartOffset != "latest" {
+		return fmt.Errorf("invalid kafka start offset: %q", c.Kafka.StartOffset)
+	}
+	if c.Kafka.DispatchBy != "" && c.Kafka.DispatchBy != "partition" && c.Kafka.DispatchBy != "key" {
+		return fmt.Errorf("invalid kafka dispatch mode: %q", c.Kafka.DispatchBy)
+	}
+	if c.Kafka.Format != "" && c.Kafka.Format != "json" && c.Kafka.Format != "avro" && c.Kafka.Format != "protobuf" {
+		return fmt.Errorf("invalid kafka message format: %q", c.Kafka.Format)
+	}
+	if c.Kafka.Format != "" && c.Kafka.Format != "json" && c.Kafka.SchemaRegistry.URL == "" {
+		return fmt.Errorf("schema registry is required for %s messages", c.Kafka.Format)
+	}
+	if c.Kafka.Backpressure.PoolSaturation < 0 || c.Kafka.Backpressure.PoolSaturation > 1 {
+		return fmt.Errorf("invalid pool saturation threshold: %v", c.Kafka.Backpressure.PoolSaturation)
+	}
+	for _, topic := range c.Kafka.Topics {
+		if (topic.Name == "") == (topic.Pattern == "") {
+			return errors.New("either kafka topic name or pattern is required")
+		}
+		if topic.Handler == "" {
+			return fmt.Errorf("handler is required for kafka topic %q", topic.Name+topic.Pattern)
+		}
+	}
+	switch c.Kafka.Security.Protocol {
+	case "", "plaintext", "ssl", "sasl_plaintext", "sasl_ssl":
+	default:
+		return fmt.Errorf("invalid kafka security protocol: %q", c.Kafka.Security.Protocol)
+	}
+	if strings.HasPrefix(c.Kafka.Security.Protocol, "sasl_") {
+		switch c.Kafka.Security.SASL.Mechanism {
+		case "PLAIN", "SCRAM-SHA-256", "SCRAM-SHA-512":
+		default:
+			return fmt.Errorf("invalid kafka SASL mechanism: %q", c.Kafka.Security.SASL.Mechanism)
+		}
+	}
+	if (c.Kafka.Security.TLS.CertFile == "") != (c.Kafka.Security.TLS.KeyFile == "") {
+		return errors.New("both kafka TLS certificate and key files are required")
+	}
+	if c.Kafka.RetryTopics.Enabled {
+		if c.Kafka.GroupID == "" {
+			return errors.New("kafka GroupID is required for retry topics")
+		}
+		if len(c.Kafka.RetryTopics.Tiers) == 0 {
+			return errors.New("at least one kafka retry tier is required")
+		}
+		for _, tier := range c.Kafka.RetryTopics.Tiers {
+			if tier.Topic == "" || tier.Delay <= 0 {
+				return fmt.Errorf("kafka retry tier %q requires topic and positive delay", tier.Topic)
+			}
+		}
+	}
+	if c.Outbox.Enabled && c.Outbox.Topic == "" {
+		return errors.New("outbox topic is required")
+	}
+	if c.Privacy.Retention.Enabled {
+		if c.Privacy.Retention.MaxAge <= 0 {
+			return errors.New("retention max age must be positive")
+		}
+		switch c.Privacy.Retention.Mode {
+		case "delete", "anonymize":
+		default:
+			return fmt.Errorf("invalid retention mode: %q", c.Privacy.Retention.Mode)
+		}
+	}
+	if c.Reports.RefreshInterval < 0 {
+		return errors.New("reports refresh interval cannot be negative")
+	}
+	if c.Server.Stream.MaxSubscribers < 0 || c.Server.Stream.BufferSize < 0 {
+		return errors.New("stream subscribers and buffer size cannot be negative")
+	}
+	if c.Webhooks.Enabled && !c.Outbox.Enabled {
+		return errors.New("webhooks require the outbox to be enabled")
+	}
+	if c.Audit.BufferSize < 0 || c.Audit.BatchSize < 0 {
+		return errors.New("audit buffer and batch sizes cannot be negative")
+	}
+	if c.Server.MaxInFlight < 0 {
+		return errors.New("max in-flight requests cannot be negative")
+	}
+	if c.Server.RateLimit.Enabled && c.Server.RateLimit.RequestsPerSecond <= 0 {
+		return errors.New("rate limit must be positive")
+	}
 	if c.Cache.Capacity <= 0 {
 		return errors.New("cache capacity must be positive")
 	}
+	if c.Currency.RatesFile != "" && len(c.Currency.BaseCurrency) != 3 {
+		return fmt.Errorf("invalid base currency: %q", c.Currency.BaseCurrency)
+	}
+	if len(c.Privacy.Encryption.Keys) > 0 {
+		if _, ok := c.Privacy.Encryption.Keys[c.Privacy.Encryption.ActiveKeyID]; !ok {
+			return fmt.Errorf("active encryption key %q is not configured", c.Privacy.Encryption.ActiveKeyID)
+		}
+	}
 
 	return nil
-}
\ No newline at end of file
+}
@@ -0,0 +1,105 @@
+package currency
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+
+	"l0/internal/interfaces"
+	"l0/internal/models"
+)
+
+// maxRateDigits is the number of decimal places of rates which have no finite decimal form
+const maxRateDigits = 20
+
+// A Converter converts payment amounts using rates from a RateProvider
+type Converter struct {
+	provider interfaces.RateProvider
+	base     string
+}
+
+// NewConverter creates a new converter with the base currency used for analytics
+func NewConverter(provider interfaces.RateProvider, base string) (*Converter, error) {
+	if provider == nil {
+		return nil, errors.New("rate provider is required")
+	}
+	base = strings.ToUpper(strings.TrimSpace(base))
+	if len(base) != 3 {
+		return nil, errors.New("base currency must be a 3-letter currency code")
+	}
+	return &Converter{provider: provider, base: base}, nil
+}
+
+// Base returns the base currency of the converter
+func (c *Converter) Base() string {
+	return c.base
+}
+
+// ToBase converts the payment amounts into the base currency
+func (c *Converter) ToBase(ctx context.Context, payment *models.Payment) (*models.ConvertedPayment, error) {
+	return c.Convert(ctx, payment, c.base)
+}
+
+// Convert converts the payment amounts into the specified currency
+func (c *Converter) Convert(ctx context.Context, payment *models.Payment, currency string) (
+	*models.ConvertedPayment, error,
+) {
+	if payment == nil {
+		return nil, errors.New("payment cannot be nil")
+	}
+	currency = strings.ToUpper(strings.TrimSpace(currency))
+
+	rate, err := c.provider.Rate(ctx, payment.Currency, currency)
+	if err != nil {
+		return nil, err
+	}
+
+	converted := &models.ConvertedPayment{Currency: currency, Rate: decimalRate(rate)}
+	amounts := []struct {
+		from int
+		to   *int
+	}{
+		{payment.Amount, &converted.Amount},
+		{payment.DeliveryCost, &converted.DeliveryCost},
+		{payment.GoodsTotal, &converted.GoodsTotal},
+		{payment.CustomFee, &converted.CustomFee},
+	}
+	for _, amount := range amounts {
+		if *amount.to, err = convertAmount(amount.from, rate); err != nil {
+			return nil, err
+		}
+	}
+	return converted, nil
+}
+
+// decimalRate formats the rate as a decimal number. Rates with a finite decimal form are exact,
+// other rates are rounded to maxRateDigits decimal places
+func decimalRate(rate *big.Rat) json.Number {
+	decimal := strings.TrimRight(rate.FloatString(maxRateDigits), "0")
+	return json.Number(strings.TrimSuffix(decimal, "."))
+}
+
+// convertAmount multiplies the amount by the rate rounding half away from zero. The product is exact,
+// an error is returned if the result doesn't fit into int
+func convertAmount(amount int, rate *big.Rat) (int, error) {
+	product := new(big.Rat).SetInt64(int64(amount))
+	product.Mul(product, rate)
+
+	// round(n/d) = (2n + sign(n)*d) / 2d truncated towards zero
+	numerator := new(big.Int).Lsh(product.Num(), 1)
+	denominator := product.Denom()
+	if product.Sign() < 0 {
+		numerator.Sub(numerator, denominator)
+	} else {
+		numerator.Add(numerator, denominator)
+	}
+	result := numerator.Quo(numerator, new(big.Int).Lsh(denominator, 1))
+	if !result.IsInt64() || result.Int64() > math.MaxInt || result.Int64() < math.MinInt {
+		return 0, fmt.Errorf("converted amount %s overflows", result)
+	}
+	return int(result.Int64()), nil
+}
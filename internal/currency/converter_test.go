@@ -0,0 +1,142 @@
+package currency
+
+import (
+	"context"
+	"math"
+	"math/big"
+	"testing"
+
+	"l0/internal/models"
+)
+
+// A mockRateProvider is a RateProvider with fixed decimal rates quoted against one currency
+type mockRateProvider map[string]string
+
+func (m mockRateProvider) Rate(ctx context.Context, from, to string) (*big.Rat, error) {
+	fromRate, ok := new(big.Rat).SetString(m[from])
+	if !ok {
+		return nil, ErrUnknownCurrency
+	}
+	toRate, ok := new(big.Rat).SetString(m[to])
+	if !ok {
+		return nil, ErrUnknownCurrency
+	}
+	return fromRate.Quo(toRate, fromRate), nil
+}
+
+func TestNewConverter(t *testing.T) {
+	if _, err := NewConverter(nil, "USD"); err == nil {
+		t.Errorf("error: expected error for nil provider")
+	}
+	if _, err := NewConverter(mockRateProvider{}, "US"); err == nil {
+		t.Errorf("error: expected error for invalid base currency")
+	}
+
+	c, err := NewConverter(mockRateProvider{}, "usd")
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if c.Base() != "USD" {
+		t.Errorf("error: expected base USD, got %s", c.Base())
+	}
+}
+
+func TestConverter_ToBase(t *testing.T) {
+	c, err := NewConverter(mockRateProvider{"USD": "1", "RUB": "80"}, "USD")
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	payment := &models.Payment{Currency: "RUB", Amount: 1000, DeliveryCost: 150, GoodsTotal: 850, CustomFee: 0}
+	converted, err := c.ToBase(context.Background(), payment)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	if converted.Currency != "USD" {
+		t.Errorf("error: expected USD, got %s", converted.Currency)
+	}
+	if converted.Rate != "0.0125" {
+		t.Errorf("error: expected the exact rate 0.0125, got %s", converted.Rate)
+	}
+	if converted.Amount != 13 {
+		t.Errorf("error: expected amount 13, got %d", converted.Amount)
+	}
+	if converted.DeliveryCost != 2 {
+		t.Errorf("error: expected delivery cost 2, got %d", converted.DeliveryCost)
+	}
+	if converted.GoodsTotal != 11 {
+		t.Errorf("error: expected goods total 11, got %d", converted.GoodsTotal)
+	}
+}
+
+func TestDecimalRate(t *testing.T) {
+	tests := map[string]string{
+		"0.0125":                       "0.0125",
+		"80":                           "80",
+		"1/3":                          "0.33333333333333333333",
+		"123456789.000000000000000001": "123456789.000000000000000001",
+		"0":                            "0",
+	}
+	for value, expected := range tests {
+		rate, _ := new(big.Rat).SetString(value)
+		if actual := decimalRate(rate); string(actual) != expected {
+			t.Errorf("error: expected rate %s to be formatted as %s, got %s", value, expected, actual)
+		}
+	}
+}
+
+func TestConverter_UnknownCurrency(t *testing.T) {
+	c, err := NewConverter(mockRateProvider{"USD": "1"}, "USD")
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	if _, err := c.ToBase(context.Background(), &models.Payment{Currency: "KZT", Amount: 100}); err == nil {
+		t.Errorf("error: expected error for unknown currency")
+	}
+}
+
+func TestConvertAmount(t *testing.T) {
+	tests := []struct {
+		amount   int
+		rate     string
+		expected int
+	}{
+		{1000, "0.0125", 13},
+		{5, "0.5", 3},
+		{-5, "0.5", -3},
+		{0, "80", 0},
+		// 0.1 isn't representable as float64, 5 * 0.1 as float64 is slightly above 0.5
+		{5, "0.1", 1},
+		{15, "0.1", 2},
+		{1, "1/3", 0},
+		// 2^53 + 1 isn't representable as float64
+		{9007199254740993, "1", 9007199254740993},
+		{9007199254740993, "2", 18014398509481986},
+		{9007199254740993, "0.5", 4503599627370497},
+	}
+
+	for _, tt := range tests {
+		rate, _ := new(big.Rat).SetString(tt.rate)
+		actual, err := convertAmount(tt.amount, rate)
+		if err != nil || actual != tt.expected {
+			t.Errorf("error: expected %d * %s = %d, got %d, %v", tt.amount, tt.rate, tt.expected, actual, err)
+		}
+	}
+
+	if _, err := convertAmount(math.MaxInt, big.NewRat(2, 1)); err == nil {
+		t.Errorf("error: expected an error for the overflowing amount")
+	}
+}
+
+func TestConverter_Overflow(t *testing.T) {
+	c, err := NewConverter(mockRateProvider{"USD": "1", "UZS": "12650"}, "UZS")
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	if _, err := c.ToBase(context.Background(), &models.Payment{Currency: "USD", Amount: math.MaxInt / 100}); err == nil {
+		t.Errorf("error: expected an error for the amount overflowing after conversion")
+	}
+}
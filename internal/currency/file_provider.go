@@ -0,0 +1,160 @@
+// Package currency implements conversion of order payments between currencies
+package currency
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+var (
+	// ErrUnknownCurrency is returned when there is no rate for the requested currency
+	ErrUnknownCurrency = errors.New("unknown currency")
+	// ErrConversionUnavailable is returned when currency conversion is not configured
+	ErrConversionUnavailable = errors.New("currency conversion is not configured")
+)
+
+// A ratesFile is a JSON representation of the rates file, rates are kept as decimal numbers
+type ratesFile struct {
+	Base  string                 `json:"base"`
+	Rates map[string]json.Number `json:"rates"`
+}
+
+// A FileRateProvider is a thread-safe RateProvider that reads rates from a local CSV or JSON file.
+// All rates in the file are quoted against one common currency, so any pair can be derived from them.
+// Rates are parsed from their decimal form exactly, so cross rates are exact too
+type FileRateProvider struct {
+	path  string
+	mu    sync.RWMutex
+	rates map[string]*big.Rat
+}
+
+// NewFileRateProvider creates a new provider and loads rates from the file at path
+func NewFileRateProvider(path string) (*FileRateProvider, error) {
+	p := &FileRateProvider{path: path}
+	if err := p.Reload(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Reload reads the rates file again, the previous rates are kept if the file is invalid
+func (p *FileRateProvider) Reload() error {
+	f, err := os.Open(p.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var rates map[string]*big.Rat
+	switch strings.ToLower(filepath.Ext(p.path)) {
+	case ".json":
+		rates, err = parseJSONRates(f)
+	case ".csv":
+		rates, err = parseCSVRates(f)
+	default:
+		err = fmt.Errorf("unsupported rates file format: %s", p.path)
+	}
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.rates = rates
+	return nil
+}
+
+// Rate returns how many units of currency to are given for one unit of currency from
+func (p *FileRateProvider) Rate(ctx context.Context, from, to string) (*big.Rat, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	from, to = strings.ToUpper(from), strings.ToUpper(to)
+	if from == to {
+		return big.NewRat(1, 1), nil
+	}
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	fromRate, ok := p.rates[from]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownCurrency, from)
+	}
+	toRate, ok := p.rates[to]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownCurrency, to)
+	}
+
+	return new(big.Rat).Quo(toRate, fromRate), nil
+}
+
+// parseJSONRates reads rates in format {"base": "USD", "rates": {"RUB": 90.5}}
+func parseJSONRates(r io.Reader) (map[string]*big.Rat, error) {
+	var file ratesFile
+	if err := json.NewDecoder(r).Decode(&file); err != nil {
+		return nil, fmt.Errorf("failed to decode rates file: %w", err)
+	}
+
+	rates := make(map[string]*big.Rat, len(file.Rates)+1)
+	for code, rate := range file.Rates {
+		if err := addRate(rates, code, rate.String()); err != nil {
+			return nil, err
+		}
+	}
+	if file.Base != "" {
+		rates[strings.ToUpper(file.Base)] = big.NewRat(1, 1)
+	}
+
+	return rates, nil
+}
+
+// parseCSVRates reads rates in format "currency,rate" with an optional header
+func parseCSVRates(r io.Reader) (map[string]*big.Rat, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = 2
+	reader.TrimLeadingSpace = true
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rates file: %w", err)
+	}
+
+	rates := make(map[string]*big.Rat, len(records))
+	for i, record := range records {
+		if i == 0 && strings.EqualFold(record[0], "currency") {
+			continue
+		}
+		if err := addRate(rates, record[0], record[1]); err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+	}
+
+	return rates, nil
+}
+
+// addRate checks the currency code and the decimal rate and adds them to rates
+func addRate(rates map[string]*big.Rat, code, value string) error {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if len(code) != 3 {
+		return fmt.Errorf("invalid currency code: %q", code)
+	}
+	rate, ok := new(big.Rat).SetString(strings.TrimSpace(value))
+	if !ok {
+		return fmt.Errorf("invalid rate for %s: %q", code, value)
+	}
+	if rate.Sign() <= 0 {
+		return fmt.Errorf("rate for %s must be positive, got: %s", code, value)
+	}
+	rates[code] = rate
+	return nil
+}
@@ -0,0 +1,110 @@
+package currency
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeRatesFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	return path
+}
+
+func TestFileRateProvider_JSON(t *testing.T) {
+	path := writeRatesFile(t, "rates.json", `{"base": "USD", "rates": {"RUB": 90, "kzt": 450}}`)
+	p, err := NewFileRateProvider(path)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	rate, err := p.Rate(context.Background(), "USD", "RUB")
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if rate.Cmp(big.NewRat(90, 1)) != 0 {
+		t.Errorf("error: expected rate 90, got %v", rate)
+	}
+
+	rate, err = p.Rate(context.Background(), "RUB", "KZT")
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if rate.Cmp(big.NewRat(5, 1)) != 0 {
+		t.Errorf("error: expected cross rate 5, got %v", rate)
+	}
+}
+
+func TestFileRateProvider_DecimalRates(t *testing.T) {
+	path := writeRatesFile(t, "rates.json", `{"base": "USD", "rates": {"EUR": 0.1, "RUB": 92.5}}`)
+	p, err := NewFileRateProvider(path)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	rate, err := p.Rate(context.Background(), "USD", "EUR")
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if rate.Cmp(big.NewRat(1, 10)) != 0 {
+		t.Errorf("error: expected the exact rate 1/10, got %v", rate)
+	}
+
+	rate, err = p.Rate(context.Background(), "EUR", "RUB")
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if rate.Cmp(big.NewRat(925, 1)) != 0 {
+		t.Errorf("error: expected the exact cross rate 925, got %v", rate)
+	}
+}
+
+func TestFileRateProvider_CSV(t *testing.T) {
+	path := writeRatesFile(t, "rates.csv", "currency,rate\nUSD,1\nRUB,90\n")
+	p, err := NewFileRateProvider(path)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	rate, err := p.Rate(context.Background(), "RUB", "USD")
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if rate.Cmp(big.NewRat(1, 90)) != 0 {
+		t.Errorf("error: expected rate 1/90, got %v", rate)
+	}
+}
+
+func TestFileRateProvider_UnknownCurrency(t *testing.T) {
+	path := writeRatesFile(t, "rates.json", `{"base": "USD", "rates": {"RUB": 90}}`)
+	p, err := NewFileRateProvider(path)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	_, err = p.Rate(context.Background(), "USD", "XXX")
+	if !errors.Is(err, ErrUnknownCurrency) {
+		t.Errorf("error: expected ErrUnknownCurrency, got %v", err)
+	}
+}
+
+func TestFileRateProvider_InvalidFile(t *testing.T) {
+	files := map[string]string{
+		"rates.json": `{"rates": {"RUB": -1}}`,
+		"rates.csv":  "RUB,abc\n",
+		"rates.txt":  "RUB 90",
+	}
+
+	for name, content := range files {
+		if _, err := NewFileRateProvider(writeRatesFile(t, name, content)); err == nil {
+			t.Errorf("error: expected error for %s", name)
+		}
+	}
+}
@@ -0,0 +1,263 @@
+package db
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/jackc/pgx/v5"
+
+	"l0/internal/interfaces"
+	"l0/internal/models"
+)
+
+// auditLockID is a key of the advisory lock held while sealing the audit log, so the hash chain doesn't fork
+const auditLockID = 320_002
+
+// auditVerifyBatch is the number of entries read at once during the hash chain verification
+const auditVerifyBatch = 1000
+
+// auditColumns are columns of audit entries, missing values are read as empty strings
+const auditColumns = `
+	id, principal, action, COALESCE(order_uid, '') AS order_uid, COALESCE(customer_id, '') AS customer_id,
+	COALESCE(ip, '') AS ip, details, created_at, COALESCE(chain_seq, 0) AS chain_seq,
+	COALESCE(prev_hash, '') AS prev_hash, COALESCE(hash, '') AS hash
+`
+
+// insertAudit is a private method to record the audit entry with specified querier,
+// so the entry is committed together with the recorded change. The entry is chained later by SealAuditLog
+func (o *OrderRepo) insertAudit(ctx context.Context, q interfaces.Queryable, entry models.AuditEntry) error {
+	query := `
+		INSERT INTO audit_log (principal, action, order_uid, customer_id, ip, details, created_at)
+		VALUES ($1, $2, NULLIF($3, ''), NULLIF($4, ''), NULLIF($5, ''), $6, COALESCE($7, NOW()))
+	`
+
+	var details any
+	if len(entry.Details) > 0 {
+		details = entry.Details
+	}
+	var createdAt *time.Time
+	if !entry.CreatedAt.IsZero() {
+		createdAt = &entry.CreatedAt
+	}
+	_, err := q.Exec(
+		ctx, query, entry.Principal, entry.Action, entry.OrderUID, entry.CustomerID, entry.IP, details, createdAt,
+	)
+	return err
+}
+
+// InsertAuditEntries records the audit entries in one transaction
+func (o *OrderRepo) InsertAuditEntries(ctx context.Context, entries []models.AuditEntry) error {
+	_, err := o.db.WithTx(
+		ctx, func(tx pgx.Tx) (any, error) {
+			for _, entry := range entries {
+				if err := o.insertAudit(ctx, tx, entry); err != nil {
+					return nil, err
+				}
+			}
+			return nil, nil
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to insert audit entries: %w", err)
+	}
+	return nil
+}
+
+// SealAuditLog appends at most limit unsealed entries to the hash chain in order of insertion and returns
+// the number of sealed entries. It returns 0 if the audit log is being sealed by another process
+func (o *OrderRepo) SealAuditLog(ctx context.Context, limit int) (int, error) {
+	headQuery := `
+		SELECT chain_seq, hash FROM audit_log
+		WHERE chain_seq IS NOT NULL
+		ORDER BY chain_seq DESC
+		LIMIT 1
+	`
+	selectQuery := `SELECT ` + auditColumns + ` FROM audit_log WHERE hash IS NULL ORDER BY id LIMIT $1`
+	updateQuery := `
+		UPDATE audit_log a
+		SET chain_seq = u.chain_seq, prev_hash = u.prev_hash, hash = u.hash
+		FROM unnest($1::BIGINT[], $2::BIGINT[], $3::TEXT[], $4::TEXT[]) AS u(id, chain_seq, prev_hash, hash)
+		WHERE a.id = u.id
+	`
+
+	sealed, err := o.db.WithTx(
+		ctx, func(tx pgx.Tx) (any, error) {
+			var locked bool
+			if err := tx.QueryRow(ctx, `SELECT pg_try_advisory_xact_lock($1)`, auditLockID).Scan(&locked); err != nil {
+				return 0, err
+			}
+			if !locked {
+				return 0, nil
+			}
+
+			var seq int64
+			var prevHash string
+			err := tx.QueryRow(ctx, headQuery).Scan(&seq, &prevHash)
+			if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+				return 0, err
+			}
+
+			var entries []models.AuditEntry
+			if err := pgxscan.Select(ctx, tx, &entries, selectQuery, limit); err != nil {
+				return 0, err
+			}
+			if len(entries) == 0 {
+				return 0, nil
+			}
+
+			ids := make([]int64, len(entries))
+			seqs := make([]int64, len(entries))
+			prevHashes := make([]string, len(entries))
+			hashes := make([]string, len(entries))
+			for i := range entries {
+				seq++
+				entries[i].ChainSeq, entries[i].PrevHash = seq, prevHash
+				prevHash = auditHash(entries[i])
+
+				ids[i], seqs[i], prevHashes[i], hashes[i] = entries[i].ID, seq, entries[i].PrevHash, prevHash
+			}
+			if _, err := tx.Exec(ctx, updateQuery, ids, seqs, prevHashes, hashes); err != nil {
+				return 0, err
+			}
+			return len(entries), nil
+		},
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to seal audit log: %w", err)
+	}
+	return sealed.(int), nil
+}
+
+// ListAuditEntries returns a page of audit entries matching the filter from the newest to the oldest
+func (o *OrderRepo) ListAuditEntries(ctx context.Context, filter models.AuditFilter) ([]models.AuditEntry, error) {
+	var conditions []string
+	var args []any
+	add := func(condition string, arg any) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.Principal != "" {
+		add("principal = $%d", filter.Principal)
+	}
+	if filter.Action != "" {
+		add("action = $%d", filter.Action)
+	}
+	if filter.OrderUID != "" {
+		add("order_uid = $%d", filter.OrderUID)
+	}
+	if filter.CustomerID != "" {
+		add("customer_id = $%d", filter.CustomerID)
+	}
+	if filter.From != nil {
+		add("created_at >= $%d", *filter.From)
+	}
+	if filter.To != nil {
+		add("created_at < $%d", *filter.To)
+	}
+
+	query := `SELECT ` + auditColumns + ` FROM audit_log`
+	if len(conditions) > 0 {
+		query += ` WHERE ` + strings.Join(conditions, " AND ")
+	}
+	args = append(args, filter.Limit, filter.Offset)
+	query += fmt.Sprintf(` ORDER BY id DESC LIMIT $%d OFFSET $%d`, len(args)-1, len(args))
+
+	entries := []models.AuditEntry{}
+	if err := pgxscan.Select(ctx, o.db.pool, &entries, query, args...); err != nil {
+		return nil, fmt.Errorf("failed to list audit entries: %w", err)
+	}
+	return entries, nil
+}
+
+// VerifyAuditLog walks the hash chain of sealed entries and reports the first entry which was changed,
+// removed or inserted after sealing
+func (o *OrderRepo) VerifyAuditLog(ctx context.Context) (*models.AuditVerification, error) {
+	selectQuery := `
+		SELECT ` + auditColumns + ` FROM audit_log
+		WHERE chain_seq > $1
+		ORDER BY chain_seq
+		LIMIT $2
+	`
+
+	result := &models.AuditVerification{Valid: true}
+	err := o.db.WithReadTx(
+		ctx, func(tx pgx.Tx) error {
+			err := tx.QueryRow(ctx, `SELECT COUNT(*) FROM audit_log WHERE hash IS NULL`).Scan(&result.Unsealed)
+			if err != nil {
+				return err
+			}
+
+			var seq int64
+			var prevHash string
+			for {
+				var entries []models.AuditEntry
+				if err := pgxscan.Select(ctx, tx, &entries, selectQuery, seq, auditVerifyBatch); err != nil {
+					return err
+				}
+
+				for _, entry := range entries {
+					if reason := verifyAuditEntry(entry, seq+1, prevHash); reason != "" {
+						result.Valid = false
+						result.BrokenSeq, result.BrokenID, result.Error = seq+1, entry.ID, reason
+						return nil
+					}
+					seq, prevHash = entry.ChainSeq, entry.Hash
+					result.Checked++
+				}
+
+				if len(entries) < auditVerifyBatch {
+					return nil
+				}
+			}
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify audit log: %w", err)
+	}
+	return result, nil
+}
+
+// verifyAuditEntry checks the entry is the expected link of the chain and returns the reason if it isn't
+func verifyAuditEntry(entry models.AuditEntry, seq int64, prevHash string) string {
+	switch {
+	case entry.ChainSeq != seq:
+		return fmt.Sprintf("entry %d is missing", seq)
+	case entry.PrevHash != prevHash:
+		return "previous hash doesn't match"
+	case entry.Hash != auditHash(entry):
+		return "entry hash doesn't match"
+	default:
+		return ""
+	}
+}
+
+// auditHash returns the hex-encoded SHA-256 of the entry fields and the hash of the previous entry
+func auditHash(entry models.AuditEntry) string {
+	fields := []string{
+		strconv.FormatInt(entry.ChainSeq, 10),
+		entry.PrevHash,
+		strconv.FormatInt(entry.ID, 10),
+		entry.Principal,
+		entry.Action,
+		entry.OrderUID,
+		entry.CustomerID,
+		entry.IP,
+		string(entry.Details),
+		entry.CreatedAt.UTC().Format(time.RFC3339Nano),
+	}
+
+	hash := sha256.New()
+	for _, field := range fields {
+		// Fields are prefixed with their length, so moving characters between fields changes the hash
+		hash.Write([]byte(strconv.Itoa(len(field)) + ":" + field))
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
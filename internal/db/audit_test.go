@@ -0,0 +1,50 @@
+package db
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"l0/internal/models"
+)
+
+func TestAuditHashChain(t *testing.T) {
+	created := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	first := models.AuditEntry{
+		ID: 1, ChainSeq: 1, Principal: "support", Action: models.AuditOrderRead, OrderUID: "order1",
+		IP: "10.0.0.1", CreatedAt: created,
+	}
+	first.Hash = auditHash(first)
+	second := models.AuditEntry{
+		ID: 3, ChainSeq: 2, PrevHash: first.Hash, Principal: "admin", Action: models.AuditConsumerSeek,
+		Details: json.RawMessage(`{"status": 200}`), CreatedAt: created.Add(time.Second),
+	}
+	second.Hash = auditHash(second)
+
+	if reason := verifyAuditEntry(first, 1, ""); reason != "" {
+		t.Errorf("error: expected the first entry to be valid, got %s", reason)
+	}
+	if reason := verifyAuditEntry(second, 2, first.Hash); reason != "" {
+		t.Errorf("error: expected the second entry to be valid, got %s", reason)
+	}
+
+	moscow := first
+	moscow.CreatedAt = created.In(time.FixedZone("MSK", 3*60*60))
+	if auditHash(moscow) != first.Hash {
+		t.Errorf("error: expected the hash not to depend on the time zone")
+	}
+
+	tampered := first
+	tampered.OrderUID = "order2"
+	if verifyAuditEntry(tampered, 1, "") == "" {
+		t.Errorf("error: expected the changed entry to be detected")
+	}
+	shifted := first
+	shifted.Principal, shifted.Action = "supportorder.read", ""
+	if auditHash(shifted) == first.Hash {
+		t.Errorf("error: expected moved characters between fields to change the hash")
+	}
+	if verifyAuditEntry(second, 1, "") == "" || verifyAuditEntry(second, 2, "other") == "" {
+		t.Errorf("error: expected removed entries and broken links to be detected")
+	}
+}
@@ -0,0 +1,120 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/jackc/pgx/v5"
+
+	"l0/internal/models"
+)
+
+// GetCustomerOrders returns a page of customer orders from the newest to the oldest using transaction
+func (o *OrderRepo) GetCustomerOrders(ctx context.Context, customerID string, limit, offset int) (
+	[]models.Order, error,
+) {
+	query := `SELECT ` + orderColumns + ` FROM ` + orderTables + `
+		WHERE o.customer_id=$1 AND ` + activeOrders + `
+		ORDER BY o.date_created DESC, o.order_uid
+		LIMIT $2 OFFSET $3
+	`
+
+	orders, err := o.db.WithTx(
+		ctx, func(tx pgx.Tx) (any, error) {
+			var orders []models.Order
+			err := pgxscan.Select(ctx, tx, &orders, query, customerID, limit, offset)
+			if err != nil {
+				return nil, err
+			}
+			return orders, nil
+		},
+	)
+	if err != nil {
+		return []models.Order{}, err
+	}
+	if orders == nil {
+		return []models.Order{}, nil
+	}
+
+	result := orders.([]models.Order)
+	if err := o.decryptOrders(result); err != nil {
+		return []models.Order{}, err
+	}
+	return result, nil
+}
+
+// GetCustomerSummary returns aggregated statistics of customer orders with at most brandsLimit favorite brands
+func (o *OrderRepo) GetCustomerSummary(ctx context.Context, customerID string, brandsLimit int) (
+	*models.CustomerSummary, error,
+) {
+	ordersQuery := `
+		SELECT COUNT(*), MIN(date_created), MAX(date_created)
+		FROM orders o
+		WHERE o.customer_id=$1 AND ` + activeOrders + `
+	`
+	spendQuery := `
+		SELECT p.currency, SUM(p.amount)
+		FROM orders o
+		JOIN payments p ON p.transaction = o.order_uid
+		WHERE o.customer_id=$1 AND ` + activeOrders + `
+		GROUP BY p.currency
+	`
+	brandsQuery := `
+		SELECT i.brand, COUNT(*) AS item_count
+		FROM orders o
+		JOIN items i ON i.track_number = o.track_number
+		WHERE o.customer_id=$1 AND ` + activeOrders + `
+		GROUP BY i.brand
+		ORDER BY item_count DESC, i.brand
+		LIMIT $2
+	`
+
+	summary, err := o.db.WithTx(
+		ctx, func(tx pgx.Tx) (any, error) {
+			summary := &models.CustomerSummary{
+				CustomerID:     customerID,
+				TotalSpend:     make(map[string]int64),
+				FavoriteBrands: []models.BrandCount{},
+			}
+
+			var first, last *time.Time
+			err := tx.QueryRow(ctx, ordersQuery, customerID).Scan(&summary.OrderCount, &first, &last)
+			if err != nil {
+				return nil, err
+			}
+			summary.FirstOrderDate, summary.LastOrderDate = first, last
+			if summary.OrderCount == 0 {
+				return summary, nil
+			}
+
+			rows, err := tx.Query(ctx, spendQuery, customerID)
+			if err != nil {
+				return nil, err
+			}
+			var currency string
+			var total int64
+			_, err = pgx.ForEachRow(
+				rows, []any{&currency, &total}, func() error {
+					summary.TotalSpend[currency] = total
+					return nil
+				},
+			)
+			if err != nil {
+				return nil, err
+			}
+
+			err = pgxscan.Select(ctx, tx, &summary.FavoriteBrands, brandsQuery, customerID, brandsLimit)
+			if err != nil {
+				return nil, err
+			}
+
+			return summary, nil
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return summary.(*models.CustomerSummary), nil
+}
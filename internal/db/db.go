@@ -7,8 +7,10 @@ import (
 	"errors"
 	"fmt"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"l0/internal/config"
+	"strings"
 )
 
 // A DB is a wrapper for database pool
@@ -53,6 +55,15 @@ func (db *DB) Ping(ctx context.Context) error {
 
 }
 
+// PoolSaturation returns the share of acquired connections of the pool, from 0 to 1
+func (db *DB) PoolSaturation() float64 {
+	stat := db.pool.Stat()
+	if stat.MaxConns() <= 0 {
+		return 0
+	}
+	return float64(stat.AcquiredConns()) / float64(stat.MaxConns())
+}
+
 // WithTx wraps the function with database query in a transaction
 func (db *DB) WithTx(ctx context.Context, fn func(tx pgx.Tx) (any, error)) (any, error) {
 	tx, err := db.pool.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.Serializable})
@@ -74,6 +85,30 @@ func (db *DB) WithTx(ctx context.Context, fn func(tx pgx.Tx) (any, error)) (any,
 	return res, nil
 }
 
+// IsDataError reports whether the query failed because of the data, e.g. a violated constraint
+// or an invalid value. Such queries fail again with the same data, unlike serialization failures or lost connections
+func IsDataError(err error) bool {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return false
+	}
+	return strings.HasPrefix(pgErr.Code, "22") || strings.HasPrefix(pgErr.Code, "23")
+}
+
+// WithReadTx wraps the function in a read-only transaction, all queries of the function see one snapshot
+func (db *DB) WithReadTx(ctx context.Context, fn func(tx pgx.Tx) error) error {
+	tx, err := db.pool.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return err
+	}
+
+	defer func() { _ = tx.Rollback(ctx) }()
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
 // Close closes the connection to the pool
 func (db *DB) Close() {
 	db.pool.Close()
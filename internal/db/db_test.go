@@ -0,0 +1,28 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestIsDataError(t *testing.T) {
+	tests := map[string]bool{
+		"23505": true,  // unique_violation
+		"23502": true,  // not_null_violation
+		"22001": true,  // string_data_right_truncation
+		"40001": false, // serialization_failure
+		"57P01": false, // admin_shutdown
+	}
+	for code, expected := range tests {
+		err := fmt.Errorf("order order1: %w", &pgconn.PgError{Code: code})
+		if IsDataError(err) != expected {
+			t.Errorf("error: expected IsDataError %t for %s", expected, code)
+		}
+	}
+	if IsDataError(errors.New("connection reset by peer")) {
+		t.Errorf("error: expected connection errors not to be data errors")
+	}
+}
@@ -0,0 +1,88 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/jackc/pgx/v5"
+
+	"l0/internal/models"
+)
+
+// exportFetchSize is the number of orders fetched from the export cursor at once
+const exportFetchSize = 500
+
+// StreamOrders calls fn for every order matching the filter from the oldest to the newest.
+// Orders are fetched from a cursor in batches, so memory doesn't depend on the number of orders.
+// Streaming stops at the first error of fn
+func (o *OrderRepo) StreamOrders(
+	ctx context.Context, filter models.OrderFilter, fn func(order *models.Order) error,
+) error {
+	conditions, args := orderFilterConditions(filter)
+	query := `SELECT ` + orderColumns + ` FROM ` + orderTables + conditions + ` ORDER BY o.date_created, o.order_uid`
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit)
+		query += fmt.Sprintf(" LIMIT $%d", len(args))
+	}
+
+	return o.db.WithReadTx(
+		ctx, func(tx pgx.Tx) error {
+			if _, err := tx.Exec(ctx, `DECLARE orders_export NO SCROLL CURSOR FOR `+query, args...); err != nil {
+				return fmt.Errorf("failed to open export cursor: %w", err)
+			}
+
+			fetchQuery := fmt.Sprintf(`FETCH %d FROM orders_export`, exportFetchSize)
+			for {
+				var orders []models.Order
+				if err := pgxscan.Select(ctx, tx, &orders, fetchQuery); err != nil {
+					return fmt.Errorf("failed to fetch orders: %w", err)
+				}
+
+				for i := range orders {
+					if err := o.decryptDelivery(&orders[i].Delivery); err != nil {
+						return fmt.Errorf("order %s: %w", orders[i].OrderUID, err)
+					}
+					if err := fn(&orders[i]); err != nil {
+						return err
+					}
+				}
+				if len(orders) < exportFetchSize {
+					return nil
+				}
+			}
+		},
+	)
+}
+
+// orderFilterConditions returns the WHERE clause of the filter with its arguments, deleted orders are excluded
+func orderFilterConditions(filter models.OrderFilter) (string, []any) {
+	conditions := []string{activeOrders}
+	var args []any
+	add := func(condition string, arg any) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.CustomerID != "" {
+		add("o.customer_id = $%d", filter.CustomerID)
+	}
+	if filter.DeliveryService != "" {
+		add("o.delivery_service = $%d", filter.DeliveryService)
+	}
+	if filter.Currency != "" {
+		add("p.currency = $%d", filter.Currency)
+	}
+	if filter.Locale != "" {
+		add("o.locale = $%d", filter.Locale)
+	}
+	if filter.From != nil {
+		add("o.date_created >= $%d", *filter.From)
+	}
+	if filter.To != nil {
+		add("o.date_created < $%d", *filter.To)
+	}
+
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
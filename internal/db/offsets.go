@@ -0,0 +1,87 @@
+package db
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+
+	"l0/internal/interfaces"
+	"l0/internal/offsets"
+)
+
+// GetConsumerOffsets returns offsets to continue consumption from mapped by partitions
+func (o *OrderRepo) GetConsumerOffsets(ctx context.Context, groupID, topic string) (map[int]int64, error) {
+	query := `
+		SELECT partition, next_offset
+		FROM consumer_offsets
+		WHERE group_id=$1 AND topic=$2
+	`
+
+	rows, err := o.db.pool.Query(ctx, query, groupID, topic)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[int]int64)
+	var partition int
+	var offset int64
+	_, err = pgx.ForEachRow(
+		rows, []any{&partition, &offset}, func() error {
+			result[partition] = offset
+			return nil
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// StoreConsumerOffset stores the offset after the message position using transaction
+func (o *OrderRepo) StoreConsumerOffset(ctx context.Context, position offsets.Position) error {
+	_, err := o.db.WithTx(
+		ctx, func(tx pgx.Tx) (any, error) {
+			return nil, o.storeConsumerOffset(ctx, tx, position)
+		},
+	)
+	return err
+}
+
+// storeConsumerOffset is a private method to store the offset with specified querier.
+// Offsets never move backwards, so a reprocessed old message doesn't rewind the consumer
+func (o *OrderRepo) storeConsumerOffset(ctx context.Context, q interfaces.Queryable, position offsets.Position) error {
+	query := `
+		INSERT INTO consumer_offsets (group_id, topic, partition, next_offset, updated_at)
+		VALUES ($1, $2, $3, $4, NOW())
+		ON CONFLICT (group_id, topic, partition) DO UPDATE
+		SET next_offset = EXCLUDED.next_offset, updated_at = EXCLUDED.updated_at
+		WHERE consumer_offsets.next_offset < EXCLUDED.next_offset;
+	`
+
+	_, err := q.Exec(ctx, query, position.GroupID, position.Topic, position.Partition, position.Next())
+	return err
+}
+
+// ResetConsumerOffsets overwrites offsets of partitions of the topic, offsets may move backwards
+func (o *OrderRepo) ResetConsumerOffsets(
+	ctx context.Context, groupID, topic string, partitionOffsets map[int]int64,
+) error {
+	query := `
+		INSERT INTO consumer_offsets (group_id, topic, partition, next_offset, updated_at)
+		VALUES ($1, $2, $3, $4, NOW())
+		ON CONFLICT (group_id, topic, partition) DO UPDATE
+		SET next_offset = EXCLUDED.next_offset, updated_at = EXCLUDED.updated_at;
+	`
+
+	_, err := o.db.WithTx(
+		ctx, func(tx pgx.Tx) (any, error) {
+			for partition, offset := range partitionOffsets {
+				if _, err := tx.Exec(ctx, query, groupID, topic, partition, offset); err != nil {
+					return nil, err
+				}
+			}
+			return nil, nil
+		},
+	)
+	return err
+}
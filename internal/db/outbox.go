@@ -0,0 +1,112 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/jackc/pgx/v5"
+
+	"l0/internal/interfaces"
+	"l0/internal/models"
+)
+
+// outboxLockID is a key of the advisory lock held by the outbox relay, so only one relay publishes at a time
+const outboxLockID = 320_001
+
+// insertOrderSavedEvent is a private method to add the order.saved event to the outbox with specified querier
+func (o *OrderRepo) insertOrderSavedEvent(ctx context.Context, q interfaces.Queryable, order *models.Order) error {
+	query := `
+		INSERT INTO outbox (aggregate_id, event_type, payload)
+		VALUES ($1, $2, $3)
+	`
+
+	payload, err := json.Marshal(models.NewOrderSavedEvent(order, time.Now().UTC()))
+	if err != nil {
+		return err
+	}
+
+	_, err = q.Exec(ctx, query, order.OrderUID, models.EventOrderSaved, payload)
+	return err
+}
+
+// ProcessPendingEvents passes at most limit unpublished events in order of creation to publish
+// and marks them published if it succeeded. It returns 0 if another relay holds the outbox lock
+func (o *OrderRepo) ProcessPendingEvents(
+	ctx context.Context, limit int, publish func([]models.OutboxEvent) error,
+) (int, error) {
+	selectQuery := `
+		SELECT id, aggregate_id, event_type, payload, created_at
+		FROM outbox
+		WHERE published_at IS NULL
+		ORDER BY id
+		LIMIT $1
+	`
+	updateQuery := `
+		UPDATE outbox
+		SET published_at = NOW()
+		WHERE id = ANY($1)
+	`
+
+	processed, err := o.db.WithTx(
+		ctx, func(tx pgx.Tx) (any, error) {
+			var locked bool
+			if err := tx.QueryRow(ctx, `SELECT pg_try_advisory_xact_lock($1)`, outboxLockID).Scan(&locked); err != nil {
+				return 0, err
+			}
+			if !locked {
+				return 0, nil
+			}
+
+			var events []models.OutboxEvent
+			if err := pgxscan.Select(ctx, tx, &events, selectQuery, limit); err != nil {
+				return 0, err
+			}
+			if len(events) == 0 {
+				return 0, nil
+			}
+
+			if err := publish(events); err != nil {
+				return 0, err
+			}
+
+			ids := make([]int64, len(events))
+			for i, event := range events {
+				ids[i] = event.ID
+			}
+			if _, err := tx.Exec(ctx, updateQuery, ids); err != nil {
+				return 0, err
+			}
+			return len(events), nil
+		},
+	)
+	if err != nil {
+		return 0, err
+	}
+	return processed.(int), nil
+}
+
+// DeletePublishedEvents removes events published before the time and returns the number of removed events.
+// If keepPendingWebhooks is set, events not fanned out to webhook deliveries yet are kept
+func (o *OrderRepo) DeletePublishedEvents(
+	ctx context.Context, before time.Time, keepPendingWebhooks bool,
+) (int64, error) {
+	tag, err := o.db.pool.Exec(ctx, deletePublishedEventsQuery(keepPendingWebhooks), before)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+// deletePublishedEventsQuery builds the query removing events published before $1
+func deletePublishedEventsQuery(keepPendingWebhooks bool) string {
+	query := `
+		DELETE FROM outbox
+		WHERE published_at < $1`
+	if keepPendingWebhooks {
+		query += `
+		AND webhooks_enqueued_at IS NOT NULL`
+	}
+	return query
+}
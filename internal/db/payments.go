@@ -0,0 +1,52 @@
+package db
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+
+	"l0/internal/models"
+	"l0/internal/offsets"
+)
+
+// ErrPaymentNotFound is returned when the updated payment doesn't exist
+var ErrPaymentNotFound = errors.New("payment not found")
+
+// UpdatePayment updates the payment of an existing order and its amounts in the base currency.
+// If the context has a position of the consumed message, the consumer offset is stored in the same transaction
+func (o *OrderRepo) UpdatePayment(
+	ctx context.Context, payment *models.Payment, basePayment *models.ConvertedPayment,
+) error {
+	_, err := o.db.WithTx(
+		ctx, func(tx pgx.Tx) (any, error) {
+			tag, err := tx.Exec(
+				ctx, `
+				UPDATE payments SET request_id=$2, currency=$3, provider=$4, amount=$5, payment_dt=$6, bank=$7,
+					delivery_cost=$8, goods_total=$9, custom_fee=$10
+				WHERE transaction=$1`,
+				payment.Transaction, payment.RequestID, payment.Currency, payment.Provider, payment.Amount,
+				payment.PaymentDt, payment.Bank, payment.DeliveryCost, payment.GoodsTotal, payment.CustomFee,
+			)
+			if err != nil {
+				return nil, err
+			}
+			if tag.RowsAffected() == 0 {
+				return nil, ErrPaymentNotFound
+			}
+
+			_, err = tx.Exec(
+				ctx, `UPDATE orders SET base_payment=$2 WHERE order_uid=$1`, payment.Transaction, basePayment,
+			)
+			if err != nil {
+				return nil, err
+			}
+
+			if position, ok := offsets.FromContext(ctx); ok {
+				err = o.storeConsumerOffset(ctx, tx, position)
+			}
+			return nil, err
+		},
+	)
+	return err
+}
@@ -0,0 +1,177 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"l0/internal/interfaces"
+	"l0/internal/models"
+)
+
+// retentionPrincipal is the principal of audit entries of the retention job
+const retentionPrincipal = "retention"
+
+// scrubDelivery is a SET clause clearing personal data of deliveries and marking them erased.
+// Personal data are the same fields which are encrypted at rest
+var scrubDelivery = func() string {
+	var fields []string
+	for field := range deliveryPII(&models.Delivery{}) {
+		fields = append(fields, field+` = ''`)
+	}
+	slices.Sort(fields)
+	return strings.Join(fields, ", ") + `, key_id = NULL, erased_at = NOW()`
+}()
+
+// SoftDeleteOrder marks the order deleted and records the deletion in the audit log in one transaction.
+// It returns false if the order doesn't exist or is already deleted
+func (o *OrderRepo) SoftDeleteOrder(ctx context.Context, orderUID, principal string) (bool, error) {
+	query := `
+		UPDATE orders SET deleted_at = NOW()
+		WHERE order_uid = $1 AND deleted_at IS NULL
+		RETURNING customer_id
+	`
+
+	deleted, err := o.db.WithTx(
+		ctx, func(tx pgx.Tx) (any, error) {
+			var customerID *string
+			err := tx.QueryRow(ctx, query, orderUID).Scan(&customerID)
+			if errors.Is(err, pgx.ErrNoRows) {
+				return false, nil
+			}
+			if err != nil {
+				return nil, err
+			}
+
+			entry := models.AuditEntry{Principal: principal, Action: models.AuditOrderDeleted, OrderUID: orderUID}
+			if customerID != nil {
+				entry.CustomerID = *customerID
+			}
+			return true, o.insertAudit(ctx, tx, entry)
+		},
+	)
+	if err != nil {
+		return false, err
+	}
+	return deleted.(bool), nil
+}
+
+// EraseCustomer scrubs personal data of deliveries of all customer orders including deleted ones
+// and records the erasure in the audit log in one transaction. It returns UIDs of the erased orders
+func (o *OrderRepo) EraseCustomer(ctx context.Context, customerID, principal string) ([]string, error) {
+	query := `
+		UPDATE deliveries d SET ` + scrubDelivery + `
+		FROM orders o
+		WHERE o.delivery_id = d.id AND o.customer_id = $1
+		RETURNING o.order_uid
+	`
+
+	erased, err := o.db.WithTx(
+		ctx, func(tx pgx.Tx) (any, error) {
+			orderUIDs, err := collectOrderUIDs(ctx, tx, query, customerID)
+			if err != nil {
+				return nil, err
+			}
+
+			details, _ := json.Marshal(map[string]any{"orders": len(orderUIDs)})
+			entry := models.AuditEntry{
+				Principal: principal, Action: models.AuditCustomerErased, CustomerID: customerID, Details: details,
+			}
+			return orderUIDs, o.insertAudit(ctx, tx, entry)
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	return erased.([]string), nil
+}
+
+// DeleteOrdersBefore deletes at most limit orders created before the time with their deliveries, items
+// and payments, the deletion is recorded in the audit log. It returns UIDs of the deleted orders
+func (o *OrderRepo) DeleteOrdersBefore(ctx context.Context, before time.Time, limit int) ([]string, error) {
+	query := `
+		WITH expired AS (
+			SELECT order_uid FROM orders
+			WHERE date_created < $1
+			ORDER BY date_created
+			LIMIT $2
+			FOR UPDATE SKIP LOCKED
+		), deleted AS (
+			DELETE FROM orders o USING expired
+			WHERE o.order_uid = expired.order_uid
+			RETURNING o.order_uid, o.delivery_id
+		), deleted_deliveries AS (
+			DELETE FROM deliveries d USING deleted
+			WHERE d.id = deleted.delivery_id
+		)
+		SELECT order_uid FROM deleted
+	`
+	return o.expireOrders(ctx, query, models.AuditRetentionDeleted, before, limit)
+}
+
+// AnonymizeOrdersBefore scrubs personal data of deliveries of at most limit orders created before the time,
+// the anonymization is recorded in the audit log. It returns UIDs of the anonymized orders
+func (o *OrderRepo) AnonymizeOrdersBefore(ctx context.Context, before time.Time, limit int) ([]string, error) {
+	query := `
+		WITH expired AS (
+			SELECT o.order_uid, o.delivery_id FROM orders o
+			JOIN deliveries d ON d.id = o.delivery_id
+			WHERE o.date_created < $1 AND d.erased_at IS NULL
+			ORDER BY o.date_created
+			LIMIT $2
+			FOR UPDATE OF d SKIP LOCKED
+		)
+		UPDATE deliveries d SET ` + scrubDelivery + `
+		FROM expired
+		WHERE d.id = expired.delivery_id
+		RETURNING expired.order_uid
+	`
+	return o.expireOrders(ctx, query, models.AuditRetentionAnonymized, before, limit)
+}
+
+// expireOrders runs the retention query and records its result in the audit log in one transaction
+func (o *OrderRepo) expireOrders(
+	ctx context.Context, query, action string, before time.Time, limit int,
+) ([]string, error) {
+	expired, err := o.db.WithTx(
+		ctx, func(tx pgx.Tx) (any, error) {
+			orderUIDs, err := collectOrderUIDs(ctx, tx, query, before, limit)
+			if err != nil {
+				return nil, err
+			}
+			if len(orderUIDs) == 0 {
+				return orderUIDs, nil
+			}
+
+			details, _ := json.Marshal(map[string]any{"orders": len(orderUIDs), "before": before})
+			entry := models.AuditEntry{Principal: retentionPrincipal, Action: action, Details: details}
+			return orderUIDs, o.insertAudit(ctx, tx, entry)
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to expire orders: %w", err)
+	}
+	return expired.([]string), nil
+}
+
+// collectOrderUIDs runs the query returning order UIDs
+func collectOrderUIDs(ctx context.Context, q interfaces.Queryable, query string, args ...any) ([]string, error) {
+	rows, err := q.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	orderUIDs, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, err
+	}
+	if orderUIDs == nil {
+		orderUIDs = []string{}
+	}
+	return orderUIDs, nil
+}
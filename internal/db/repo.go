@@ -2,6 +2,7 @@ package db
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/georgysavva/scany/v2/pgxscan"
 	"github.com/jackc/pgx/v5"
@@ -9,15 +10,20 @@ import (
 	"l0/internal/config"
 	"l0/internal/interfaces"
 	"l0/internal/models"
+	"l0/internal/offsets"
+	"l0/internal/privacy"
 
 	_ "database/sql"
 
 	_ "github.com/jackc/pgx/v5/pgxpool"
 )
 
-// An OrderRepo is a repository pattern implementation for working with database
+// An OrderRepo is a repository pattern implementation for working with database.
+// Personal data of deliveries is encrypted at rest if a cipher is configured
 type OrderRepo struct {
-	db *DB
+	db            *DB
+	cipher        *privacy.FieldCipher
+	outboxEnabled bool
 }
 
 // NewOrderRepo creates a new instance of OrderRepo with specified configuration
@@ -26,55 +32,117 @@ func NewOrderRepo(ctx context.Context, cfg *config.Config) (*OrderRepo, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &OrderRepo{db}, nil
+	cipher, err := privacy.NewFieldCipherWithConfig(cfg.Privacy.Encryption)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize field cipher: %w", err)
+	}
+	return &OrderRepo{db: db, cipher: cipher, outboxEnabled: cfg.Outbox.Enabled}, nil
 }
 
-// SaveOrder adds an order to the database using transaction
-func (o *OrderRepo) SaveOrder(ctx context.Context, order *models.Order) error {
-	_, err := o.db.WithTx(
-		ctx, func(tx pgx.Tx) (any, error) {
-			dID, err := o.insertDelivery(ctx, tx, &order.Delivery)
-			if err != nil {
-				return nil, err
-			}
+// Close closes the database pool of the repository
+func (o *OrderRepo) Close() {
+	o.db.Close()
+}
 
-			err = o.insertOrder(ctx, tx, order, dID)
-			if err != nil {
-				return nil, err
-			}
+// PoolSaturation returns the share of acquired connections of the database pool
+func (o *OrderRepo) PoolSaturation() float64 {
+	return o.db.PoolSaturation()
+}
 
-			err = o.insertItems(ctx, tx, order.Items)
+// SaveOrder adds an order to the database using transaction. If the outbox is enabled, the order.saved event
+// is added in the same transaction. If the context has a position of the consumed message,
+// the consumer offset is stored in the same transaction too. It returns false if the order already exists,
+// then the stored order is left as is, so a redelivered order doesn't restore a deleted or erased one
+func (o *OrderRepo) SaveOrder(ctx context.Context, order *models.Order) (bool, error) {
+	inserted, err := o.db.WithTx(
+		ctx, func(tx pgx.Tx) (any, error) {
+			inserted, err := o.saveOrder(ctx, tx, order)
 			if err != nil {
 				return nil, err
 			}
 
-			err = o.insertPayment(ctx, tx, &order.Payment)
-			if err != nil {
-				return nil, err
+			if position, ok := offsets.FromContext(ctx); ok {
+				return inserted, o.storeConsumerOffset(ctx, tx, position)
 			}
+			return inserted, nil
+		},
+	)
+	if err != nil {
+		return false, err
+	}
+	return inserted.(bool), nil
+}
 
-			return nil, err
+// SaveOrders adds orders to the database in one transaction, so either all orders are saved or none.
+// Orders which already exist are skipped
+func (o *OrderRepo) SaveOrders(ctx context.Context, orders []*models.Order) error {
+	_, err := o.db.WithTx(
+		ctx, func(tx pgx.Tx) (any, error) {
+			for _, order := range orders {
+				if _, err := o.saveOrder(ctx, tx, order); err != nil {
+					return nil, fmt.Errorf("order %s: %w", order.OrderUID, err)
+				}
+			}
+			return nil, nil
 		},
 	)
 	return err
 }
 
-// insertOrder is a private method to add order to the database with payment, items and delivery already inserted
-func (o *OrderRepo) insertOrder(
-	ctx context.Context, q interfaces.Queryable, order *models.Order, deliveryID int64,
-) error {
+// saveOrder is a private method to add an order with its delivery, items, payment and outbox event.
+// The order is inserted first, so nothing else is written for an order which already exists
+func (o *OrderRepo) saveOrder(ctx context.Context, q interfaces.Queryable, order *models.Order) (bool, error) {
+	inserted, err := o.insertOrder(ctx, q, order)
+	if err != nil || !inserted {
+		return false, err
+	}
+
+	dID, err := o.insertDelivery(ctx, q, &order.Delivery)
+	if err != nil {
+		return false, err
+	}
+	if err := o.linkDelivery(ctx, q, order.OrderUID, dID); err != nil {
+		return false, err
+	}
+
+	if err := o.insertItems(ctx, q, order.Items); err != nil {
+		return false, err
+	}
+
+	if err := o.insertPayment(ctx, q, &order.Payment); err != nil {
+		return false, err
+	}
+
+	if o.outboxEnabled {
+		return true, o.insertOrderSavedEvent(ctx, q, order)
+	}
+	return true, nil
+}
+
+// insertOrder is a private method to add order to the database without delivery.
+// It returns false if the order already exists
+func (o *OrderRepo) insertOrder(ctx context.Context, q interfaces.Queryable, order *models.Order) (bool, error) {
 	query := `
-		INSERT INTO orders (order_uid, track_number, entry, delivery_id, locale, internal_signature, customer_id, 
-			delivery_service, shardkey, sm_id, date_created, oof_shard)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		INSERT INTO orders (order_uid, track_number, entry, locale, internal_signature, customer_id, 
+			delivery_service, shardkey, sm_id, date_created, oof_shard, base_payment, schema_version)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
 		ON CONFLICT (order_uid) DO NOTHING;
 	`
 
-	_, err := q.Exec(
-		ctx, query, order.OrderUID, order.TrackNumber, order.Entry, deliveryID, order.Locale,
+	tag, err := q.Exec(
+		ctx, query, order.OrderUID, order.TrackNumber, order.Entry, order.Locale,
 		order.InternalSignature, order.CustomerID, order.DeliveryService, order.Shardkey, order.SmID,
-		order.DateCreated, order.OofShard,
+		order.DateCreated, order.OofShard, order.BasePayment, order.SchemaVersion,
 	)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// linkDelivery is a private method to set the delivery of the inserted order
+func (o *OrderRepo) linkDelivery(ctx context.Context, q interfaces.Queryable, orderUID string, deliveryID int64) error {
+	_, err := q.Exec(ctx, `UPDATE orders SET delivery_id = $2 WHERE order_uid = $1`, orderUID, deliveryID)
 	return err
 }
 
@@ -116,19 +184,12 @@ func (o *OrderRepo) InsertItems(ctx context.Context, items []models.Item) error
 
 // insertItems is a private method to insert list of items into the database with specified querier
 func (o *OrderRepo) insertItems(ctx context.Context, q interfaces.Queryable, items []models.Item) error {
-	_, err := o.db.WithTx(
-		ctx, func(tx pgx.Tx) (any, error) {
-			for idx := range items {
-				err := o.insertItem(ctx, q, &items[idx])
-				if err != nil {
-					return nil, err
-				}
-
-			}
-			return nil, nil
-		},
-	)
-	return err
+	for idx := range items {
+		if err := o.insertItem(ctx, q, &items[idx]); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 // InsertItem is a public method to insert item into the database using transaction
@@ -172,51 +233,185 @@ func (o *OrderRepo) insertDelivery(ctx context.Context, q interfaces.Queryable,
 	int64, error,
 ) {
 	query := `
-		INSERT INTO deliveries (name, phone, zip, city, address, region, email)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		INSERT INTO deliveries (name, phone, zip, city, address, region, email, key_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
 		ON CONFLICT (id) DO NOTHING
 		RETURNING id
 	`
+	stored, keyID, err := o.encryptDelivery(delivery)
+	if err != nil {
+		return 0, err
+	}
+
 	var id int64 = 0
-	err := q.QueryRow(
-		ctx, query, delivery.Name, delivery.Phone, delivery.Zip, delivery.City, delivery.Address,
-		delivery.Region, delivery.Email,
+	err = q.QueryRow(
+		ctx, query, stored.Name, stored.Phone, stored.Zip, stored.City, stored.Address,
+		stored.Region, stored.Email, keyID,
 	).Scan(&id)
 	return id, err
 }
 
+// encryptDelivery returns a copy of the delivery with encrypted personal data and the ID of the used key.
+// The delivery is returned as is with nil key ID if encryption is not configured
+func (o *OrderRepo) encryptDelivery(delivery *models.Delivery) (models.Delivery, *string, error) {
+	stored := *delivery
+	if o.cipher == nil {
+		return stored, nil, nil
+	}
+
+	for field, value := range deliveryPII(&stored) {
+		encrypted, err := o.cipher.Encrypt(field, *value)
+		if err != nil {
+			return models.Delivery{}, nil, fmt.Errorf("failed to encrypt delivery %s: %w", field, err)
+		}
+		*value = encrypted
+	}
+
+	keyID := o.cipher.ActiveKeyID()
+	return stored, &keyID, nil
+}
+
+// decryptDelivery decrypts personal data of the delivery in place
+func (o *OrderRepo) decryptDelivery(delivery *models.Delivery) error {
+	for field, value := range deliveryPII(delivery) {
+		if _, encrypted := privacy.KeyID(*value); !encrypted {
+			continue
+		}
+		if o.cipher == nil {
+			return fmt.Errorf("delivery %s is encrypted, but encryption is not configured", field)
+		}
+		decrypted, err := o.cipher.Decrypt(field, *value)
+		if err != nil {
+			return err
+		}
+		*value = decrypted
+	}
+	return nil
+}
+
+// decryptOrders decrypts personal data of deliveries for all orders in place
+func (o *OrderRepo) decryptOrders(orders []models.Order) error {
+	for i := range orders {
+		if err := o.decryptDelivery(&orders[i].Delivery); err != nil {
+			return fmt.Errorf("order %s: %w", orders[i].OrderUID, err)
+		}
+	}
+	return nil
+}
+
+// deliveryPII returns pointers to the delivery fields with personal data mapped by column names
+func deliveryPII(delivery *models.Delivery) map[string]*string {
+	return map[string]*string{
+		"name":    &delivery.Name,
+		"phone":   &delivery.Phone,
+		"address": &delivery.Address,
+		"email":   &delivery.Email,
+	}
+}
+
+// RotateDeliveryKeys re-encrypts at most batchSize deliveries that are stored in plaintext
+// or with a key other than the active one. It returns the number of re-encrypted deliveries
+func (o *OrderRepo) RotateDeliveryKeys(ctx context.Context, batchSize int) (int, error) {
+	if o.cipher == nil {
+		return 0, nil
+	}
+
+	selectQuery := `
+		SELECT id, name, phone, address, email
+		FROM deliveries
+		WHERE key_id IS DISTINCT FROM $1
+		LIMIT $2
+		FOR UPDATE SKIP LOCKED
+	`
+	updateQuery := `
+		UPDATE deliveries
+		SET name = $2, phone = $3, address = $4, email = $5, key_id = $6
+		WHERE id = $1
+	`
+
+	rotated, err := o.db.WithTx(
+		ctx, func(tx pgx.Tx) (any, error) {
+			var rows []struct {
+				ID int64 `db:"id"`
+				models.Delivery
+			}
+			if err := pgxscan.Select(ctx, tx, &rows, selectQuery, o.cipher.ActiveKeyID(), batchSize); err != nil {
+				return 0, err
+			}
+
+			for _, row := range rows {
+				if err := o.decryptDelivery(&row.Delivery); err != nil {
+					return 0, fmt.Errorf("delivery %d: %w", row.ID, err)
+				}
+				stored, keyID, err := o.encryptDelivery(&row.Delivery)
+				if err != nil {
+					return 0, fmt.Errorf("delivery %d: %w", row.ID, err)
+				}
+				_, err = tx.Exec(
+					ctx, updateQuery, row.ID, stored.Name, stored.Phone, stored.Address, stored.Email, keyID,
+				)
+				if err != nil {
+					return 0, err
+				}
+			}
+			return len(rows), nil
+		},
+	)
+	if err != nil {
+		return 0, err
+	}
+	return rotated.(int), nil
+}
+
+// orderColumns is a list of columns to select an order with its delivery, payment and items,
+// aliases of nested columns follow the scany naming of nested structures
+const orderColumns = `
+	o.order_uid, o.track_number, o.entry, o.delivery_id, o.locale, o.internal_signature, o.customer_id,
+	o.delivery_service, o.shardkey, o.sm_id, o.date_created, o.oof_shard::TEXT AS oof_shard, o.base_payment,
+	COALESCE(o.schema_version, '') AS schema_version,
+	d.name AS "delivery.name", d.phone AS "delivery.phone", d.zip AS "delivery.zip", d.city AS "delivery.city",
+	d.address AS "delivery.address", d.region AS "delivery.region", d.email AS "delivery.email",
+	p.transaction AS "payment.transaction", p.request_id AS "payment.request_id", p.currency AS "payment.currency",
+	p.provider AS "payment.provider", p.amount AS "payment.amount", p.payment_dt AS "payment.payment_dt",
+	p.bank AS "payment.bank", p.delivery_cost AS "payment.delivery_cost", p.goods_total AS "payment.goods_total",
+	p.custom_fee AS "payment.custom_fee",
+	COALESCE((
+		SELECT jsonb_agg(jsonb_build_object(
+			'chrt_id', i.chrt_id,
+			'track_number', i.track_number,
+			'price', i.price,
+			'rid', i.rid,
+			'name', i.name,
+			'sale', i.sale,
+			'size', i.size,
+			'total_price', i.total_price,
+			'nm_id', i.nm_id,
+			'brand', i.brand,
+			'status', i.status
+		) ORDER BY i.chrt_id)
+		FROM items i
+		WHERE i.track_number = o.track_number
+	), '[]'::JSONB) AS items
+`
+
+// orderTables joins tables needed for orderColumns
+const orderTables = `
+	orders o
+	JOIN deliveries d ON o.delivery_id = d.id
+	JOIN payments p ON o.order_uid = p.transaction
+`
+
+// activeOrders is a condition excluding soft deleted orders
+const activeOrders = `o.deleted_at IS NULL`
+
 // GetOrder returns order by orderUID from the database using transaction
 func (o *OrderRepo) GetOrder(ctx context.Context, orderUid string) (*models.Order, error) {
-	query := `
-		SELECT
-			o.*,
-			d.*,
-			p.*,
-			jsonb_agg(jsonb_build_object(
-				'chrt_id', i.chrt_id,
-				'track_number', i.track_number,
-				'price', i.price,
-				'rid', i.rid,
-				'name', i.name,
-				'sale', i.sale,
-				'size', i.size,
-				'total_price', i.total_price,
-				'nm_id', i.nm_id,
-				'brand', i.brand,
-				'status', i.status
-			)) AS items	
-		FROM orders o
-		LEFT JOIN deliveries d ON o.delivery_id = d.id
-		LEFT JOIN payments p ON o.order_uid = p.transaction
-		LEFT JOIN items i ON i.track_number = o.track_number
-		WHERE o.order_uid=$1
-		GROUP BY o.order_uid, d.id, p.transaction
-	`
+	query := `SELECT ` + orderColumns + ` FROM ` + orderTables + ` WHERE o.order_uid=$1 AND ` + activeOrders
 
 	order, err := o.db.WithTx(
 		ctx, func(tx pgx.Tx) (any, error) {
 			var order models.Order
-			err := pgxscan.Select(ctx, tx, &order, query)
+			err := pgxscan.Get(ctx, tx, &order, query, orderUid)
 			if err != nil {
 				return nil, err
 			}
@@ -231,14 +426,19 @@ func (o *OrderRepo) GetOrder(ctx context.Context, orderUid string) (*models.Orde
 		return nil, pgx.ErrNoRows
 	}
 
-	return order.(*models.Order), err
+	result := order.(*models.Order)
+	if err := o.decryptDelivery(&result.Delivery); err != nil {
+		return nil, err
+	}
+
+	return result, nil
 }
 
 // GetDelivery returns delivery by id from the database using transaction
 func (o *OrderRepo) GetDelivery(ctx context.Context, id string) (*models.Delivery, error) {
 	query := `
 		SELECT
-			d.*
+			d.name, d.phone, d.zip, d.city, d.address, d.region, d.email
 		FROM deliveries d
 		WHERE d.id=$1
 	`
@@ -247,7 +447,7 @@ func (o *OrderRepo) GetDelivery(ctx context.Context, id string) (*models.Deliver
 		ctx, func(tx pgx.Tx) (any, error) {
 			var delivery models.Delivery
 
-			err := pgxscan.Select(ctx, tx, &delivery, query)
+			err := pgxscan.Get(ctx, tx, &delivery, query, id)
 			if err != nil {
 				return nil, err
 			}
@@ -262,7 +462,12 @@ func (o *OrderRepo) GetDelivery(ctx context.Context, id string) (*models.Deliver
 		return nil, pgx.ErrNoRows
 	}
 
-	return delivery.(*models.Delivery), err
+	result := delivery.(*models.Delivery)
+	if err := o.decryptDelivery(result); err != nil {
+		return nil, err
+	}
+
+	return result, nil
 }
 
 // GetItems returns list of items by list of chrtIDs from the database using transaction
@@ -357,11 +562,7 @@ func (o *OrderRepo) GetPayment(ctx context.Context, transaction string) (*models
 
 // GetNOrders returns list of n orders from the database using transaction
 func (o *OrderRepo) GetNOrders(ctx context.Context, n int) ([]models.Order, error) {
-	query := `
-		SELECT *
-		FROM orders
-		LIMIT $1
-	`
+	query := `SELECT ` + orderColumns + ` FROM ` + orderTables + ` WHERE ` + activeOrders + ` ORDER BY o.date_created DESC LIMIT $1`
 
 	orders, err := o.db.WithTx(
 		ctx, func(tx pgx.Tx) (any, error) {
@@ -379,15 +580,17 @@ func (o *OrderRepo) GetNOrders(ctx context.Context, n int) ([]models.Order, erro
 	if orders == nil {
 		return []models.Order{}, nil
 	}
-	return orders.([]models.Order), err
+
+	result := orders.([]models.Order)
+	if err := o.decryptOrders(result); err != nil {
+		return []models.Order{}, err
+	}
+	return result, nil
 }
 
 // GetAllOrders returns list of all orders from the database using transaction
 func (o *OrderRepo) GetAllOrders(ctx context.Context) ([]models.Order, error) {
-	query := `
-		SELECT *
-		FROM orders
-	`
+	query := `SELECT ` + orderColumns + ` FROM ` + orderTables + ` WHERE ` + activeOrders
 
 	orders, err := o.db.WithTx(
 		ctx, func(tx pgx.Tx) (any, error) {
@@ -406,5 +609,10 @@ func (o *OrderRepo) GetAllOrders(ctx context.Context) ([]models.Order, error) {
 	if orders == nil {
 		return []models.Order{}, nil
 	}
-	return orders.([]models.Order), err
+
+	result := orders.([]models.Order)
+	if err := o.decryptOrders(result); err != nil {
+		return []models.Order{}, err
+	}
+	return result, nil
 }
\ No newline at end of file
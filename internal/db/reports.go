@@ -0,0 +1,193 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/georgysavva/scany/v2/pgxscan"
+
+	"l0/internal/models"
+)
+
+// A reportDimension is a grouping of sales reports with its expressions in live queries and in materialized views
+type reportDimension struct {
+	live string
+	view string
+}
+
+// reportDimensions are expressions of groupings, periods are days, weeks and months in UTC
+var reportDimensions = map[string]reportDimension{
+	models.GroupDay:             {`date_trunc('day', o.date_created, 'UTC')`, `day`},
+	models.GroupWeek:            {`date_trunc('week', o.date_created, 'UTC')`, `date_trunc('week', day, 'UTC')`},
+	models.GroupMonth:           {`date_trunc('month', o.date_created, 'UTC')`, `date_trunc('month', day, 'UTC')`},
+	models.GroupDeliveryService: {`COALESCE(o.delivery_service, '')`, `delivery_service`},
+	models.GroupBrand:           {`COALESCE(s.brand, '')`, `brand`},
+	models.GroupCurrency:        {`COALESCE(p.currency, '')`, `currency`},
+	models.GroupRegion:          {`COALESCE(d.region, '')`, `region`},
+	models.GroupLocale:          {`COALESCE(o.locale, '')`, `locale`},
+}
+
+// reportColumns are names of columns of groupings in results
+var reportColumns = map[string]string{
+	models.GroupDay:             "period",
+	models.GroupWeek:            "period",
+	models.GroupMonth:           "period",
+	models.GroupDeliveryService: "delivery_service",
+	models.GroupBrand:           "brand",
+	models.GroupCurrency:        "currency",
+	models.GroupRegion:          "region",
+	models.GroupLocale:          "locale",
+}
+
+// Materialized views of daily sales, brands are kept in a separate view,
+// so orders with items of several brands are counted once if brands aren't grouped
+const (
+	salesView       = "sales_daily"
+	salesBrandsView = "sales_daily_brands"
+)
+
+// A ReportRepo is a repository of aggregated sales. If materialized views are used, reports with day-aligned
+// ranges are read from the views, other reports are aggregated from orders
+type ReportRepo struct {
+	db      *DB
+	useView bool
+}
+
+// NewReportRepo creates a new instance of ReportRepo
+func NewReportRepo(db *DB, useView bool) *ReportRepo {
+	return &ReportRepo{db: db, useView: useView}
+}
+
+// SalesReport returns sales grouped by the query groupings in the order of groups
+func (r *ReportRepo) SalesReport(ctx context.Context, query models.SalesReportQuery) (*models.SalesReport, error) {
+	report := &models.SalesReport{GroupBy: query.GroupBy, From: query.From, To: query.To, Rows: []models.SalesReportRow{}}
+
+	var sql string
+	var args []any
+	if r.useView && dayAligned(query.From) && dayAligned(query.To) {
+		report.Source = models.ReportSourceView
+		sql, args = viewSalesQuery(query)
+	} else {
+		report.Source = models.ReportSourceLive
+		sql, args = liveSalesQuery(query)
+	}
+
+	if err := pgxscan.Select(ctx, r.db.pool, &report.Rows, sql, args...); err != nil {
+		return nil, fmt.Errorf("failed to aggregate sales: %w", err)
+	}
+	return report, nil
+}
+
+// RefreshSalesViews refreshes materialized views of sales without blocking reports
+func (r *ReportRepo) RefreshSalesViews(ctx context.Context) error {
+	for _, view := range []string{salesView, salesBrandsView} {
+		if _, err := r.db.pool.Exec(ctx, `REFRESH MATERIALIZED VIEW CONCURRENTLY `+view); err != nil {
+			return fmt.Errorf("failed to refresh %s: %w", view, err)
+		}
+	}
+	return nil
+}
+
+// reportedOrders is a condition excluding orders of the latency probe from sales reports
+const reportedOrders = `o.customer_id IS DISTINCT FROM '` + models.ProbeCustomerID + `'`
+
+// liveSalesQuery aggregates sales from orders with items, items are aggregated per order
+// and per brand if brands are grouped. The delivery cost of an order is allocated to its first brand
+// in alphabetical order, so it's counted once and delivery costs of brands sum up to the total
+func liveSalesQuery(query models.SalesReportQuery) (string, []any) {
+	items := `SELECT COUNT(*) AS item_count, SUM(i.total_price) AS goods_total, SUM(i.sale) AS sale_sum,
+				1 AS delivery_share
+			FROM items i WHERE i.track_number = o.track_number`
+	if slices.Contains(query.GroupBy, models.GroupBrand) {
+		items = `SELECT i.brand, COUNT(*) AS item_count, SUM(i.total_price) AS goods_total, SUM(i.sale) AS sale_sum,
+				(row_number() OVER (ORDER BY i.brand) = 1)::INT AS delivery_share
+			FROM items i WHERE i.track_number = o.track_number GROUP BY i.brand`
+	}
+
+	conditions := []string{activeOrders, reportedOrders}
+	var args []any
+	if query.From != nil {
+		args = append(args, *query.From)
+		conditions = append(conditions, fmt.Sprintf("o.date_created >= $%d", len(args)))
+	}
+	if query.To != nil {
+		args = append(args, *query.To)
+		conditions = append(conditions, fmt.Sprintf("o.date_created < $%d", len(args)))
+	}
+	where := "WHERE " + strings.Join(conditions, " AND ")
+
+	dimensions, groups := salesDimensions(query, func(d reportDimension) string { return d.live })
+	return fmt.Sprintf(
+		`SELECT %s,
+			COUNT(*) AS order_count,
+			COALESCE(SUM(s.item_count), 0)::BIGINT AS item_count,
+			COALESCE(SUM(s.goods_total), 0)::BIGINT AS goods_total,
+			COALESCE(SUM(p.delivery_cost * s.delivery_share), 0)::BIGINT AS delivery_cost,
+			COALESCE(SUM(s.sale_sum)::FLOAT8 / NULLIF(SUM(s.item_count), 0), 0) AS average_sale
+		FROM orders o
+		JOIN payments p ON p.transaction = o.order_uid
+		JOIN deliveries d ON d.id = o.delivery_id
+		JOIN LATERAL (%s) s ON s.item_count > 0
+		%s
+		GROUP BY %s
+		ORDER BY %s`,
+		dimensions, items, where, groups, groups,
+	), args
+}
+
+// viewSalesQuery aggregates sales from the daily materialized views
+func viewSalesQuery(query models.SalesReportQuery) (string, []any) {
+	view := salesView
+	if slices.Contains(query.GroupBy, models.GroupBrand) {
+		view = salesBrandsView
+	}
+
+	var conditions []string
+	var args []any
+	if query.From != nil {
+		args = append(args, *query.From)
+		conditions = append(conditions, fmt.Sprintf("day >= $%d", len(args)))
+	}
+	if query.To != nil {
+		args = append(args, *query.To)
+		conditions = append(conditions, fmt.Sprintf("day < $%d", len(args)))
+	}
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	dimensions, groups := salesDimensions(query, func(d reportDimension) string { return d.view })
+	return fmt.Sprintf(
+		`SELECT %s,
+			SUM(order_count)::BIGINT AS order_count,
+			SUM(item_count)::BIGINT AS item_count,
+			SUM(goods_total)::BIGINT AS goods_total,
+			SUM(delivery_cost)::BIGINT AS delivery_cost,
+			COALESCE(SUM(sale_sum)::FLOAT8 / NULLIF(SUM(item_count), 0), 0) AS average_sale
+		FROM %s
+		%s
+		GROUP BY %s
+		ORDER BY %s`,
+		dimensions, view, where, groups, groups,
+	), args
+}
+
+// salesDimensions returns selected expressions of groupings and their positions for GROUP BY and ORDER BY
+func salesDimensions(query models.SalesReportQuery, expression func(reportDimension) string) (string, string) {
+	dimensions := make([]string, len(query.GroupBy))
+	positions := make([]string, len(query.GroupBy))
+	for i, group := range query.GroupBy {
+		dimensions[i] = expression(reportDimensions[group]) + " AS " + reportColumns[group]
+		positions[i] = fmt.Sprint(i + 1)
+	}
+	return strings.Join(dimensions, ", "), strings.Join(positions, ", ")
+}
+
+// dayAligned reports whether the bound is not set or is a midnight in UTC, so it can be compared with days of views
+func dayAligned(bound *time.Time) bool {
+	return bound == nil || bound.UTC().Truncate(24*time.Hour).Equal(*bound)
+}
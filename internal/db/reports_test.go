@@ -0,0 +1,54 @@
+package db
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"l0/internal/models"
+)
+
+func TestSalesQueries(t *testing.T) {
+	from := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	query := models.SalesReportQuery{GroupBy: []string{models.GroupWeek, models.GroupBrand}, From: &from}
+
+	live, args := liveSalesQuery(query)
+	if len(args) != 1 || !strings.Contains(live, "o.date_created >= $1") {
+		t.Errorf("error: expected the range condition, got %s with %v", live, args)
+	}
+	if !strings.Contains(live, "o.customer_id IS DISTINCT FROM 'probe'") {
+		t.Errorf("error: expected probe orders to be excluded, got %s", live)
+	}
+	if !strings.Contains(live, "GROUP BY i.brand") || !strings.Contains(live, "GROUP BY 1, 2") {
+		t.Errorf("error: expected items grouped by brand, got %s", live)
+	}
+	if !strings.Contains(live, "row_number() OVER (ORDER BY i.brand) = 1") ||
+		!strings.Contains(live, "SUM(p.delivery_cost * s.delivery_share)") {
+		t.Errorf("error: expected the delivery cost to be allocated to one brand, got %s", live)
+	}
+	if strings.Index(live, "AS period") > strings.Index(live, "AS brand") {
+		t.Errorf("error: expected columns in the order of groupings, got %s", live)
+	}
+
+	view, _ := viewSalesQuery(query)
+	if !strings.Contains(view, "FROM "+salesBrandsView) || !strings.Contains(view, "date_trunc('week', day, 'UTC')") {
+		t.Errorf("error: expected weeks from the brands view, got %s", view)
+	}
+	view, args = viewSalesQuery(models.SalesReportQuery{GroupBy: []string{models.GroupCurrency}})
+	if len(args) != 0 || !strings.Contains(view, "FROM "+salesView+"\n") || strings.Contains(view, "WHERE") {
+		t.Errorf("error: expected the view without brands and conditions, got %s", view)
+	}
+}
+
+func TestDayAligned(t *testing.T) {
+	midnight := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	noon := midnight.Add(12 * time.Hour)
+	moscowMidnight := time.Date(2026, 1, 1, 0, 0, 0, 0, time.FixedZone("MSK", 3*60*60))
+
+	if !dayAligned(nil) || !dayAligned(&midnight) {
+		t.Errorf("error: expected missing bounds and midnights in UTC to be aligned")
+	}
+	if dayAligned(&noon) || dayAligned(&moscowMidnight) {
+		t.Errorf("error: expected noon and midnight in other zones not to be aligned")
+	}
+}
@@ -0,0 +1,309 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/jackc/pgx/v5"
+
+	"l0/internal/models"
+	"l0/internal/privacy"
+)
+
+// webhookLockID is a key of the advisory lock held while outbox events are fanned out to webhook deliveries
+const webhookLockID = 320_003
+
+// webhookSubscriptionColumns are columns of webhook subscriptions without the secret
+const webhookSubscriptionColumns = `
+	id, url, event_types, COALESCE(customer_id, '') AS customer_id,
+	COALESCE(delivery_service, '') AS delivery_service, created_at
+`
+
+// webhookDeliveryColumns are columns of webhook deliveries, missing values are read as zero values
+const webhookDeliveryColumns = `
+	d.id, d.subscription_id, d.event_id, d.event_type, d.payload, d.status, d.attempts, d.next_attempt_at,
+	COALESCE(d.last_status_code, 0) AS last_status_code, COALESCE(d.last_error, '') AS last_error,
+	d.created_at, d.delivered_at
+`
+
+// webhookSecretField is the field name used as additional data of encrypted webhook secrets
+const webhookSecretField = "webhook_secret"
+
+// CreateWebhookSubscription adds the subscription and sets its ID and creation time.
+// The secret is encrypted at rest if a cipher is configured
+func (o *OrderRepo) CreateWebhookSubscription(ctx context.Context, subscription *models.WebhookSubscription) error {
+	query := `
+		INSERT INTO webhook_subscriptions (url, secret, key_id, event_types, customer_id, delivery_service)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), NULLIF($6, ''))
+		RETURNING id, created_at
+	`
+
+	secret, keyID, err := o.encryptWebhookSecret(subscription.Secret)
+	if err != nil {
+		return err
+	}
+
+	err = o.db.pool.QueryRow(
+		ctx, query, subscription.URL, secret, keyID, subscription.EventTypes, subscription.CustomerID,
+		subscription.DeliveryService,
+	).Scan(&subscription.ID, &subscription.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create webhook subscription: %w", err)
+	}
+	return nil
+}
+
+// ListWebhookSubscriptions returns all subscriptions without secrets
+func (o *OrderRepo) ListWebhookSubscriptions(ctx context.Context) ([]models.WebhookSubscription, error) {
+	query := `SELECT ` + webhookSubscriptionColumns + ` FROM webhook_subscriptions ORDER BY id`
+
+	subscriptions := []models.WebhookSubscription{}
+	if err := pgxscan.Select(ctx, o.db.pool, &subscriptions, query); err != nil {
+		return nil, fmt.Errorf("failed to list webhook subscriptions: %w", err)
+	}
+	return subscriptions, nil
+}
+
+// GetWebhookSubscription returns the subscription without the secret or nil if it doesn't exist
+func (o *OrderRepo) GetWebhookSubscription(ctx context.Context, id int64) (*models.WebhookSubscription, error) {
+	query := `SELECT ` + webhookSubscriptionColumns + ` FROM webhook_subscriptions WHERE id = $1`
+
+	var subscription models.WebhookSubscription
+	err := pgxscan.Get(ctx, o.db.pool, &subscription, query, id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhook subscription: %w", err)
+	}
+	return &subscription, nil
+}
+
+// DeleteWebhookSubscription removes the subscription with its deliveries.
+// It returns false if the subscription doesn't exist
+func (o *OrderRepo) DeleteWebhookSubscription(ctx context.Context, id int64) (bool, error) {
+	tag, err := o.db.pool.Exec(ctx, `DELETE FROM webhook_subscriptions WHERE id = $1`, id)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete webhook subscription: %w", err)
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// ListWebhookDeliveries returns a page of deliveries of the subscription from the newest to the oldest
+func (o *OrderRepo) ListWebhookDeliveries(
+	ctx context.Context, subscriptionID int64, limit, offset int,
+) ([]models.WebhookDelivery, error) {
+	query := `
+		SELECT ` + webhookDeliveryColumns + ` FROM webhook_deliveries d
+		WHERE d.subscription_id = $1
+		ORDER BY d.id DESC
+		LIMIT $2 OFFSET $3
+	`
+
+	deliveries := []models.WebhookDelivery{}
+	if err := pgxscan.Select(ctx, o.db.pool, &deliveries, query, subscriptionID, limit, offset); err != nil {
+		return nil, fmt.Errorf("failed to list webhook deliveries: %w", err)
+	}
+	return deliveries, nil
+}
+
+// EnqueueWebhookDeliveries creates deliveries of at most limit outbox events for matching subscriptions
+// and returns the number of processed events. It returns 0 if another process holds the webhook lock
+func (o *OrderRepo) EnqueueWebhookDeliveries(ctx context.Context, limit int) (int, error) {
+	selectQuery := `
+		SELECT id FROM outbox
+		WHERE webhooks_enqueued_at IS NULL
+		ORDER BY id
+		LIMIT $1
+	`
+	insertQuery := `
+		INSERT INTO webhook_deliveries (subscription_id, event_id, event_type, payload)
+		SELECT s.id, e.id, e.event_type, e.payload
+		FROM outbox e
+		JOIN webhook_subscriptions s ON e.event_type = ANY(s.event_types)
+			AND (s.customer_id IS NULL OR s.customer_id = e.payload->>'customer_id')
+			AND (s.delivery_service IS NULL OR s.delivery_service = e.payload->>'delivery_service')
+		WHERE e.id = ANY($1)
+		ORDER BY e.id, s.id
+		ON CONFLICT (subscription_id, event_id) DO NOTHING
+	`
+	updateQuery := `
+		UPDATE outbox
+		SET webhooks_enqueued_at = NOW()
+		WHERE id = ANY($1)
+	`
+
+	processed, err := o.db.WithTx(
+		ctx, func(tx pgx.Tx) (any, error) {
+			var locked bool
+			if err := tx.QueryRow(ctx, `SELECT pg_try_advisory_xact_lock($1)`, webhookLockID).Scan(&locked); err != nil {
+				return 0, err
+			}
+			if !locked {
+				return 0, nil
+			}
+
+			rows, err := tx.Query(ctx, selectQuery, limit)
+			if err != nil {
+				return 0, err
+			}
+			ids, err := pgx.CollectRows(rows, pgx.RowTo[int64])
+			if err != nil {
+				return 0, err
+			}
+			if len(ids) == 0 {
+				return 0, nil
+			}
+
+			if _, err := tx.Exec(ctx, insertQuery, ids); err != nil {
+				return 0, err
+			}
+			if _, err := tx.Exec(ctx, updateQuery, ids); err != nil {
+				return 0, err
+			}
+			return len(ids), nil
+		},
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to enqueue webhook deliveries: %w", err)
+	}
+	return processed.(int), nil
+}
+
+// ClaimWebhookDeliveries returns at most limit due pending deliveries with URLs and secrets of their
+// subscriptions. Claimed deliveries aren't due for the lease, so a crashed worker's deliveries are retried later
+func (o *OrderRepo) ClaimWebhookDeliveries(
+	ctx context.Context, limit int, lease time.Duration,
+) ([]models.WebhookDelivery, error) {
+	query := `
+		WITH due AS (
+			SELECT id FROM webhook_deliveries
+			WHERE status = 'pending' AND next_attempt_at <= NOW()
+			ORDER BY next_attempt_at
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		UPDATE webhook_deliveries d
+		SET next_attempt_at = NOW() + $2::INTERVAL
+		FROM due, webhook_subscriptions s
+		WHERE d.id = due.id AND s.id = d.subscription_id
+		RETURNING ` + webhookDeliveryColumns + `, s.url, s.secret
+	`
+
+	var deliveries []models.WebhookDelivery
+	if err := pgxscan.Select(ctx, o.db.pool, &deliveries, query, limit, lease); err != nil {
+		return nil, fmt.Errorf("failed to claim webhook deliveries: %w", err)
+	}
+	for i := range deliveries {
+		secret, err := o.decryptWebhookSecret(deliveries[i].Secret)
+		if err != nil {
+			return nil, fmt.Errorf("webhook subscription %d: %w", deliveries[i].SubscriptionID, err)
+		}
+		deliveries[i].Secret = secret
+	}
+	return deliveries, nil
+}
+
+// RecordWebhookAttempt updates the delivery with the result of the attempt
+func (o *OrderRepo) RecordWebhookAttempt(ctx context.Context, attempt models.WebhookAttempt) error {
+	query := `
+		UPDATE webhook_deliveries
+		SET status = $2,
+			attempts = attempts + CASE WHEN $3 THEN 1 ELSE 0 END,
+			next_attempt_at = $4,
+			last_status_code = COALESCE(NULLIF($5, 0), last_status_code),
+			last_error = NULLIF($6, ''),
+			delivered_at = CASE WHEN $2 = 'delivered' THEN NOW() END
+		WHERE id = $1
+	`
+
+	_, err := o.db.pool.Exec(
+		ctx, query, attempt.DeliveryID, attempt.Status, attempt.Counted, attempt.NextAttemptAt, attempt.StatusCode,
+		attempt.Error,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record webhook attempt: %w", err)
+	}
+	return nil
+}
+
+// RotateWebhookSecretKeys re-encrypts at most batchSize webhook secrets that are stored in plaintext
+// or with a key other than the active one. It returns the number of re-encrypted secrets
+func (o *OrderRepo) RotateWebhookSecretKeys(ctx context.Context, batchSize int) (int, error) {
+	if o.cipher == nil {
+		return 0, nil
+	}
+
+	selectQuery := `
+		SELECT id, secret
+		FROM webhook_subscriptions
+		WHERE key_id IS DISTINCT FROM $1
+		LIMIT $2
+		FOR UPDATE SKIP LOCKED
+	`
+	updateQuery := `
+		UPDATE webhook_subscriptions
+		SET secret = $2, key_id = $3
+		WHERE id = $1
+	`
+
+	rotated, err := o.db.WithTx(
+		ctx, func(tx pgx.Tx) (any, error) {
+			var rows []struct {
+				ID     int64  `db:"id"`
+				Secret string `db:"secret"`
+			}
+			if err := pgxscan.Select(ctx, tx, &rows, selectQuery, o.cipher.ActiveKeyID(), batchSize); err != nil {
+				return 0, err
+			}
+
+			for _, row := range rows {
+				secret, err := o.decryptWebhookSecret(row.Secret)
+				if err != nil {
+					return 0, fmt.Errorf("webhook subscription %d: %w", row.ID, err)
+				}
+				secret, keyID, err := o.encryptWebhookSecret(secret)
+				if err != nil {
+					return 0, fmt.Errorf("webhook subscription %d: %w", row.ID, err)
+				}
+				if _, err := tx.Exec(ctx, updateQuery, row.ID, secret, keyID); err != nil {
+					return 0, err
+				}
+			}
+			return len(rows), nil
+		},
+	)
+	if err != nil {
+		return 0, err
+	}
+	return rotated.(int), nil
+}
+
+// encryptWebhookSecret returns the encrypted secret and the ID of the used key.
+// The secret is returned as is with nil key ID if encryption is not configured
+func (o *OrderRepo) encryptWebhookSecret(secret string) (string, *string, error) {
+	if o.cipher == nil {
+		return secret, nil, nil
+	}
+
+	encrypted, err := o.cipher.Encrypt(webhookSecretField, secret)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to encrypt webhook secret: %w", err)
+	}
+	keyID := o.cipher.ActiveKeyID()
+	return encrypted, &keyID, nil
+}
+
+// decryptWebhookSecret decrypts the secret, plaintext secrets are returned as is
+func (o *OrderRepo) decryptWebhookSecret(secret string) (string, error) {
+	if _, encrypted := privacy.KeyID(secret); !encrypted {
+		return secret, nil
+	}
+	if o.cipher == nil {
+		return "", errors.New("webhook secret is encrypted, but encryption is not configured")
+	}
+	return o.cipher.Decrypt(webhookSecretField, secret)
+}
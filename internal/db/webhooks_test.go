@@ -0,0 +1,48 @@
+package db
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"l0/internal/privacy"
+)
+
+func TestWebhookSecretEncryption(t *testing.T) {
+	cipher, err := privacy.NewFieldCipher(map[string][]byte{"k1": bytes.Repeat([]byte{1}, 32)}, "k1")
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	repo := &OrderRepo{cipher: cipher}
+
+	encrypted, keyID, err := repo.encryptWebhookSecret("partner-secret")
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if strings.Contains(encrypted, "partner-secret") || keyID == nil || *keyID != "k1" {
+		t.Errorf("error: expected the secret to be encrypted with the active key, got %s", encrypted)
+	}
+	if secret, err := repo.decryptWebhookSecret(encrypted); err != nil || secret != "partner-secret" {
+		t.Errorf("error: expected the secret to be decrypted, got %q, %v", secret, err)
+	}
+	if secret, err := repo.decryptWebhookSecret("plain-secret"); err != nil || secret != "plain-secret" {
+		t.Errorf("error: expected the plaintext secret to be returned as is, got %q, %v", secret, err)
+	}
+
+	if _, err := (&OrderRepo{}).decryptWebhookSecret(encrypted); err == nil {
+		t.Errorf("error: expected an error for the encrypted secret without a cipher")
+	}
+}
+
+func TestDeletePublishedEventsQuery(t *testing.T) {
+	query := deletePublishedEventsQuery(false)
+	if !strings.Contains(query, "published_at < $1") || strings.Contains(query, "webhooks_enqueued_at") {
+		t.Errorf("error: expected events to be removed by publish time only, got %s", query)
+	}
+
+	query = deletePublishedEventsQuery(true)
+	if !strings.Contains(query, "published_at < $1") ||
+		!strings.Contains(query, "AND webhooks_enqueued_at IS NOT NULL") {
+		t.Errorf("error: expected events not enqueued for webhooks to be kept, got %s", query)
+	}
+}
@@ -0,0 +1,99 @@
+// Package envelope implements versioned JSON payloads of orders. Payloads of older versions are upcast
+// to the current version by the chain of upcasters
+package envelope
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"l0/internal/models"
+)
+
+// Versions of the JSON order payload. Messages without an envelope carry the legacy payload,
+// version 2 renamed shardkey into shard_key
+const (
+	LegacyPayloadVersion  = 1
+	CurrentPayloadVersion = 2
+)
+
+var ErrUnknownPayloadVersion = errors.New("unknown payload version")
+
+// An Envelope is a JSON message wrapping the order payload with the version of its schema
+type Envelope struct {
+	SchemaVersion int             `json:"schema_version"`
+	Payload       json.RawMessage `json:"payload"`
+}
+
+// New wraps the order into the envelope of the current payload version
+func New(order *models.Order) (*Envelope, error) {
+	payload, err := json.Marshal(OrderPayload{Order: order})
+	if err != nil {
+		return nil, err
+	}
+	return &Envelope{SchemaVersion: CurrentPayloadVersion, Payload: payload}, nil
+}
+
+// An OrderPayload is the order in the JSON payload of the current version, the target of upcasters.
+// The payload differs from the API form of the order only by shard_key, so the API keeps shardkey
+type OrderPayload struct {
+	Order *models.Order
+}
+
+// MarshalJSON encodes the order into the payload of the current version
+func (p OrderPayload) MarshalJSON() ([]byte, error) {
+	data, err := json.Marshal(p.Order)
+	if err != nil {
+		return nil, err
+	}
+	return renameField(data, "shardkey", "shard_key")
+}
+
+// UnmarshalJSON decodes the order from the payload of the current version
+func (p *OrderPayload) UnmarshalJSON(data []byte) error {
+	data, err := renameField(data, "shard_key", "shardkey")
+	if err != nil {
+		return err
+	}
+	var order models.Order
+	if err := json.Unmarshal(data, &order); err != nil {
+		return err
+	}
+	p.Order = &order
+	return nil
+}
+
+// renameField renames the field of the JSON object, the field which already has the new name is kept
+func renameField(data []byte, from, to string) ([]byte, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	value, ok := fields[from]
+	if !ok {
+		return data, nil
+	}
+	if _, exists := fields[to]; !exists {
+		fields[to] = value
+	}
+	delete(fields, from)
+	return json.Marshal(fields)
+}
+
+// Parse unwraps the message value. Values without the payload field are legacy payloads,
+// values which are not valid JSON are returned as is to be reported by the decoder
+func Parse(value []byte) (*Envelope, error) {
+	var probe struct {
+		SchemaVersion json.RawMessage `json:"schema_version"`
+		Payload       json.RawMessage `json:"payload"`
+	}
+	if err := json.Unmarshal(value, &probe); err != nil || len(probe.Payload) == 0 {
+		return &Envelope{SchemaVersion: LegacyPayloadVersion, Payload: value}, nil
+	}
+
+	var version int
+	if err := json.Unmarshal(probe.SchemaVersion, &version); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownPayloadVersion, probe.SchemaVersion)
+	}
+
+	return &Envelope{SchemaVersion: version, Payload: probe.Payload}, nil
+}
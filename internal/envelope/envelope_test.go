@@ -0,0 +1,58 @@
+package envelope
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"l0/internal/models"
+)
+
+func TestParse(t *testing.T) {
+	legacy := []byte(`{"order_uid":"order1","schema_version":"json:1"}`)
+	envelope, err := Parse(legacy)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if envelope.SchemaVersion != LegacyPayloadVersion || string(envelope.Payload) != string(legacy) {
+		t.Errorf("error: expected legacy payload, got %+v", envelope)
+	}
+
+	envelope, err = Parse([]byte(`{"schema_version":2,"payload":{"order_uid":"order1"}}`))
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if envelope.SchemaVersion != 2 || string(envelope.Payload) != `{"order_uid":"order1"}` {
+		t.Errorf("error: unexpected envelope %+v", envelope)
+	}
+
+	if _, err := Parse([]byte(`{"schema_version":"v2","payload":{}}`)); !errors.Is(err, ErrUnknownPayloadVersion) {
+		t.Errorf("error: expected ErrUnknownPayloadVersion, got %v", err)
+	}
+	if envelope, _ := Parse([]byte(`not json`)); envelope.SchemaVersion != LegacyPayloadVersion {
+		t.Errorf("error: expected malformed value to be passed to the decoder")
+	}
+}
+
+func TestOrderPayload(t *testing.T) {
+	order := &models.Order{OrderUID: "order1", Shardkey: "9"}
+	envelope, err := New(order)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	payload := string(envelope.Payload)
+	if !strings.Contains(payload, `"shard_key":"9"`) || strings.Contains(payload, "shardkey") {
+		t.Errorf("error: expected the payload to have shard_key, got %s", envelope.Payload)
+	}
+
+	var decoded OrderPayload
+	if err := json.Unmarshal(envelope.Payload, &decoded); err != nil || decoded.Order.Shardkey != "9" {
+		t.Errorf("error: expected the payload to decode into the order, got %+v, %v", decoded.Order, err)
+	}
+
+	api, _ := json.Marshal(order)
+	if !strings.Contains(string(api), `"shardkey":"9"`) {
+		t.Errorf("error: expected the API form of the order to keep shardkey, got %s", api)
+	}
+}
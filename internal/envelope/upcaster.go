@@ -0,0 +1,98 @@
+package envelope
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// An Upcaster transforms the payload of one version into the payload of the next version
+type Upcaster func(payload map[string]any) (map[string]any, error)
+
+// An UpcasterChain is a chain of upcasters transforming payloads of older versions into the current version.
+// To change the payload, increase the current version and register an upcaster from the previous one
+type UpcasterChain struct {
+	current   int
+	upcasters map[int]Upcaster
+}
+
+// NewUpcasterChain creates a new chain upcasting payloads to the current version
+func NewUpcasterChain(current int) *UpcasterChain {
+	return &UpcasterChain{
+		current:   current,
+		upcasters: make(map[int]Upcaster),
+	}
+}
+
+// DefaultUpcasters returns the chain of upcasters of order payloads
+func DefaultUpcasters() *UpcasterChain {
+	chain := NewUpcasterChain(CurrentPayloadVersion)
+	if err := chain.Register(1, renameShardKey); err != nil {
+		panic(err)
+	}
+	return chain
+}
+
+// renameShardKey upcasts the payload from version 1 to version 2. Payloads produced from the current model
+// without an envelope already have shard_key, it's kept
+func renameShardKey(payload map[string]any) (map[string]any, error) {
+	shardKey, ok := payload["shardkey"]
+	if !ok {
+		return payload, nil
+	}
+	if _, exists := payload["shard_key"]; !exists {
+		payload["shard_key"] = shardKey
+	}
+	delete(payload, "shardkey")
+	return payload, nil
+}
+
+// Register registers the upcaster from the version to the next one
+func (c *UpcasterChain) Register(from int, upcaster Upcaster) error {
+	if from < LegacyPayloadVersion || from >= c.current {
+		return fmt.Errorf("invalid upcaster version %d, current version is %d", from, c.current)
+	}
+	if _, ok := c.upcasters[from]; ok {
+		return fmt.Errorf("upcaster from version %d is already registered", from)
+	}
+	c.upcasters[from] = upcaster
+	return nil
+}
+
+// Current returns the current payload version
+func (c *UpcasterChain) Current() int {
+	return c.current
+}
+
+// Upcast transforms the payload of the version into the current version.
+// Versions newer than the current one or without the full chain of upcasters are unknown
+func (c *UpcasterChain) Upcast(version int, payload json.RawMessage) (json.RawMessage, error) {
+	if version == c.current {
+		return payload, nil
+	}
+	if version < LegacyPayloadVersion || version > c.current {
+		return nil, fmt.Errorf("%w: %d", ErrUnknownPayloadVersion, version)
+	}
+	for v := version; v < c.current; v++ {
+		if _, ok := c.upcasters[v]; !ok {
+			return nil, fmt.Errorf("%w: %d, no upcaster from version %d", ErrUnknownPayloadVersion, version, v)
+		}
+	}
+
+	// Numbers are kept as json.Number to not lose precision of int64 IDs
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	decoder.UseNumber()
+	var fields map[string]any
+	if err := decoder.Decode(&fields); err != nil {
+		return nil, err
+	}
+
+	for v := version; v < c.current; v++ {
+		var err error
+		if fields, err = c.upcasters[v](fields); err != nil {
+			return nil, fmt.Errorf("failed to upcast payload from version %d: %w", v, err)
+		}
+	}
+
+	return json.Marshal(fields)
+}
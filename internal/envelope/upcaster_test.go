@@ -0,0 +1,119 @@
+package envelope
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// testUpcasters returns a chain of version 3 where version 2 split delivery.name
+// and version 3 renamed shardkey into shard_key
+func testUpcasters(t *testing.T) *UpcasterChain {
+	chain := NewUpcasterChain(3)
+	err := chain.Register(
+		1, func(payload map[string]any) (map[string]any, error) {
+			delivery, ok := payload["delivery"].(map[string]any)
+			if !ok {
+				return nil, errors.New("delivery is missing")
+			}
+			name, _ := delivery["name"].(string)
+			first, last, _ := strings.Cut(name, " ")
+			delivery["first_name"], delivery["last_name"] = first, last
+			delete(delivery, "name")
+			return payload, nil
+		},
+	)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	err = chain.Register(
+		2, func(payload map[string]any) (map[string]any, error) {
+			payload["shard_key"] = payload["shardkey"]
+			delete(payload, "shardkey")
+			return payload, nil
+		},
+	)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	return chain
+}
+
+func TestUpcasterChain_Upcast(t *testing.T) {
+	chain := testUpcasters(t)
+
+	payload, err := chain.Upcast(1, json.RawMessage(`{"delivery":{"name":"Test Testov"},"shardkey":"9","nm_id":9007199254740993}`))
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	var result map[string]any
+	_ = json.Unmarshal(payload, &result)
+	delivery := result["delivery"].(map[string]any)
+	if delivery["first_name"] != "Test" || delivery["last_name"] != "Testov" || delivery["name"] != nil {
+		t.Errorf("error: unexpected delivery %v", delivery)
+	}
+	if result["shard_key"] != "9" || result["shardkey"] != nil {
+		t.Errorf("error: expected shardkey to be renamed, got %v", result)
+	}
+	if !strings.Contains(string(payload), "9007199254740993") {
+		t.Errorf("error: expected large numbers to keep precision, got %s", payload)
+	}
+
+	current := json.RawMessage(`{"shard_key":"9"}`)
+	if payload, err := chain.Upcast(3, current); err != nil || string(payload) != string(current) {
+		t.Errorf("error: expected current payload to stay unchanged, got %s, %v", payload, err)
+	}
+
+	for _, version := range []int{0, 4} {
+		if _, err := chain.Upcast(version, current); !errors.Is(err, ErrUnknownPayloadVersion) {
+			t.Errorf("error: expected ErrUnknownPayloadVersion for version %d, got %v", version, err)
+		}
+	}
+}
+
+func TestUpcasterChain_Register(t *testing.T) {
+	chain := NewUpcasterChain(3)
+	noop := func(payload map[string]any) (map[string]any, error) { return payload, nil }
+
+	if err := chain.Register(3, noop); err == nil {
+		t.Errorf("error: expected error for upcaster from the current version")
+	}
+	if err := chain.Register(0, noop); err == nil {
+		t.Errorf("error: expected error for upcaster from version 0")
+	}
+	if err := chain.Register(2, noop); err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if err := chain.Register(2, noop); err == nil {
+		t.Errorf("error: expected error for duplicated upcaster")
+	}
+
+	// Version 1 has no upcaster to version 2
+	if _, err := chain.Upcast(1, json.RawMessage(`{}`)); !errors.Is(err, ErrUnknownPayloadVersion) {
+		t.Errorf("error: expected ErrUnknownPayloadVersion for broken chain, got %v", err)
+	}
+}
+
+func TestDefaultUpcasters(t *testing.T) {
+	chain := DefaultUpcasters()
+	if chain.Current() != CurrentPayloadVersion {
+		t.Fatalf("error: expected the chain to upcast to version %d, got %d", CurrentPayloadVersion, chain.Current())
+	}
+
+	payload, err := chain.Upcast(LegacyPayloadVersion, json.RawMessage(`{"order_uid":"order1","shardkey":"9"}`))
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	var decoded OrderPayload
+	err = json.Unmarshal(payload, &decoded)
+	if err != nil || decoded.Order.Shardkey != "9" || decoded.Order.OrderUID != "order1" {
+		t.Errorf("error: expected the legacy payload to decode into the current model, got %s, %v", payload, err)
+	}
+
+	payload, err = chain.Upcast(LegacyPayloadVersion, json.RawMessage(`{"shard_key":"3","shardkey":"9"}`))
+	if err != nil || strings.Contains(string(payload), "shardkey") || !strings.Contains(string(payload), `"3"`) {
+		t.Errorf("error: expected shard_key of the current model to be kept, got %s, %v", payload, err)
+	}
+}
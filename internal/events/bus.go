@@ -0,0 +1,157 @@
+// Package events implements an in-process bus streaming order changes to subscribers
+package events
+
+import (
+	"errors"
+	"sync"
+
+	"l0/internal/config"
+	"l0/internal/metrics"
+	"l0/internal/models"
+)
+
+var (
+	// ErrTooManySubscribers is returned when the bus already has the maximum number of subscribers
+	ErrTooManySubscribers = errors.New("too many subscribers")
+	// ErrSlowSubscriber is the reason of closing a subscription which buffer overflowed
+	ErrSlowSubscriber = errors.New("subscriber is too slow")
+	// ErrClosed is the reason of closing a subscription by its owner
+	ErrClosed = errors.New("subscription is closed")
+)
+
+// A Bus delivers published order changes to matching subscribers. Publishing never blocks: a subscriber
+// which buffer is full is closed with ErrSlowSubscriber, so slow clients can't hold order processing
+type Bus struct {
+	config    config.StreamConfig
+	published *metrics.Counter
+	dropped   *metrics.Counter
+
+	mu          sync.RWMutex
+	subscribers map[*Subscription]struct{}
+}
+
+// NewBus creates a new bus, zero settings are replaced with defaults
+func NewBus(cfg config.StreamConfig) *Bus {
+	if cfg.MaxSubscribers <= 0 {
+		cfg.MaxSubscribers = 100
+	}
+	if cfg.BufferSize <= 0 {
+		cfg.BufferSize = 64
+	}
+
+	bus := &Bus{
+		config:      cfg,
+		published:   metrics.DefaultRegistry.Counter("stream_published_changes_total", "Number of published order changes"),
+		dropped:     metrics.DefaultRegistry.Counter("stream_dropped_subscribers_total", "Number of closed slow subscribers"),
+		subscribers: make(map[*Subscription]struct{}),
+	}
+	metrics.DefaultRegistry.GaugeFunc(
+		"stream_subscribers", "Number of order change subscribers",
+		func() int64 { return int64(bus.Subscribers()) },
+	)
+	return bus
+}
+
+// Subscribe adds a subscriber receiving changes matching the filter, the subscription has to be closed
+func (b *Bus) Subscribe(filter models.OrderChangeFilter) (*Subscription, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(b.subscribers) >= b.config.MaxSubscribers {
+		return nil, ErrTooManySubscribers
+	}
+
+	subscription := &Subscription{
+		bus:     b,
+		filter:  filter,
+		changes: make(chan models.OrderChange, b.config.BufferSize),
+		done:    make(chan struct{}),
+	}
+	b.subscribers[subscription] = struct{}{}
+	return subscription, nil
+}
+
+// Publish delivers the change to matching subscribers and closes those which can't take it
+func (b *Bus) Publish(change models.OrderChange) {
+	var slow []*Subscription
+
+	b.mu.RLock()
+	for subscription := range b.subscribers {
+		if !subscription.filter.Match(change) {
+			continue
+		}
+		select {
+		case subscription.changes <- change:
+		default:
+			slow = append(slow, subscription)
+		}
+	}
+	b.mu.RUnlock()
+
+	b.published.Inc()
+	for _, subscription := range slow {
+		b.dropped.Inc()
+		subscription.close(ErrSlowSubscriber)
+	}
+}
+
+// HasSubscribers reports whether the bus has subscribers, so publishers can skip preparing changes
+func (b *Bus) HasSubscribers() bool {
+	return b.Subscribers() > 0
+}
+
+// Subscribers returns the number of subscribers
+func (b *Bus) Subscribers() int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return len(b.subscribers)
+}
+
+// A Subscription is a buffered stream of changes matching the filter of the subscriber
+type Subscription struct {
+	bus     *Bus
+	filter  models.OrderChangeFilter
+	changes chan models.OrderChange
+	done    chan struct{}
+	once    sync.Once
+	err     error
+}
+
+// Changes returns the channel of changes, it's never closed, so Done has to be watched as well
+func (s *Subscription) Changes() <-chan models.OrderChange {
+	return s.changes
+}
+
+// Done returns the channel closed when the subscription is closed
+func (s *Subscription) Done() <-chan struct{} {
+	return s.done
+}
+
+// Err returns the reason of closing the subscription or nil if it's open
+func (s *Subscription) Err() error {
+	select {
+	case <-s.done:
+		return s.err
+	default:
+		return nil
+	}
+}
+
+// Close removes the subscriber from the bus
+func (s *Subscription) Close() {
+	s.close(ErrClosed)
+}
+
+// close removes the subscriber from the bus and records the reason, only the first reason is kept
+func (s *Subscription) close(reason error) {
+	s.once.Do(
+		func() {
+			s.bus.mu.Lock()
+			delete(s.bus.subscribers, s)
+			s.bus.mu.Unlock()
+
+			s.err = reason
+			close(s.done)
+		},
+	)
+}
@@ -0,0 +1,86 @@
+package events
+
+import (
+	"errors"
+	"testing"
+
+	"l0/internal/config"
+	"l0/internal/models"
+)
+
+func newChange(orderUID, customerID, deliveryService string) models.OrderChange {
+	return models.OrderChange{
+		Type:            models.ChangeOrderProcessed,
+		OrderUID:        orderUID,
+		CustomerID:      customerID,
+		DeliveryService: deliveryService,
+	}
+}
+
+func TestBus_PublishFiltered(t *testing.T) {
+	bus := NewBus(config.StreamConfig{})
+	all, _ := bus.Subscribe(models.OrderChangeFilter{})
+	defer all.Close()
+	customer, _ := bus.Subscribe(models.OrderChangeFilter{CustomerID: "customer1", DeliveryService: "meest"})
+	defer customer.Close()
+
+	bus.Publish(newChange("order1", "customer1", "meest"))
+	bus.Publish(newChange("order2", "customer1", "dhl"))
+	bus.Publish(newChange("order3", "customer2", "meest"))
+
+	if len(all.Changes()) != 3 {
+		t.Errorf("error: expected all changes without filter, got %d", len(all.Changes()))
+	}
+	if len(customer.Changes()) != 1 {
+		t.Fatalf("error: expected one change matching the filter, got %d", len(customer.Changes()))
+	}
+	if change := <-customer.Changes(); change.OrderUID != "order1" {
+		t.Errorf("error: expected order1, got %s", change.OrderUID)
+	}
+}
+
+func TestBus_SlowSubscriber(t *testing.T) {
+	bus := NewBus(config.StreamConfig{BufferSize: 2})
+	slow, _ := bus.Subscribe(models.OrderChangeFilter{})
+	fast, _ := bus.Subscribe(models.OrderChangeFilter{})
+	defer fast.Close()
+
+	for _, orderUID := range []string{"order1", "order2", "order3"} {
+		bus.Publish(newChange(orderUID, "customer1", "meest"))
+		<-fast.Changes()
+	}
+
+	select {
+	case <-slow.Done():
+	default:
+		t.Fatalf("error: expected the slow subscriber to be closed")
+	}
+	if !errors.Is(slow.Err(), ErrSlowSubscriber) {
+		t.Errorf("error: expected ErrSlowSubscriber, got %v", slow.Err())
+	}
+	if fast.Err() != nil || bus.Subscribers() != 1 {
+		t.Errorf("error: expected only the fast subscriber to stay, got %d subscribers", bus.Subscribers())
+	}
+}
+
+func TestBus_MaxSubscribers(t *testing.T) {
+	bus := NewBus(config.StreamConfig{MaxSubscribers: 1})
+	first, err := bus.Subscribe(models.OrderChangeFilter{})
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if _, err := bus.Subscribe(models.OrderChangeFilter{}); !errors.Is(err, ErrTooManySubscribers) {
+		t.Errorf("error: expected ErrTooManySubscribers, got %v", err)
+	}
+
+	first.Close()
+	first.Close()
+	if !errors.Is(first.Err(), ErrClosed) || bus.HasSubscribers() {
+		t.Errorf("error: expected the closed subscription to be removed")
+	}
+	second, err := bus.Subscribe(models.OrderChangeFilter{})
+	if err != nil {
+		t.Errorf("error: expected a free slot after close, got %v", err)
+	}
+	second.Close()
+}
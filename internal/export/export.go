@@ -0,0 +1,250 @@
+// Package export implements writing orders as CSV and Parquet with one row per item or as NDJSON
+// and reading them back from CSV and NDJSON
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+
+	"l0/internal/models"
+)
+
+// Export formats
+const (
+	FormatCSV     = "csv"
+	FormatNDJSON  = "ndjson"
+	FormatParquet = "parquet"
+)
+
+// parquetRowGroupSize is the number of rows buffered before a Parquet row group is written
+const parquetRowGroupSize = 10000
+
+var ErrUnknownFormat = errors.New("unknown export format")
+
+// A Row is an order flattened with one of its items, orders without items have one row with empty item fields
+type Row struct {
+	OrderUID          string    `parquet:"order_uid"`
+	TrackNumber       string    `parquet:"track_number"`
+	Entry             string    `parquet:"entry"`
+	Locale            string    `parquet:"locale"`
+	InternalSignature string    `parquet:"internal_signature"`
+	CustomerID        string    `parquet:"customer_id"`
+	DeliveryService   string    `parquet:"delivery_service"`
+	Shardkey          string    `parquet:"shardkey"`
+	SmID              int64     `parquet:"sm_id"`
+	DateCreated       time.Time `parquet:"date_created,timestamp(millisecond)"`
+	OofShard          string    `parquet:"oof_shard"`
+
+	DeliveryName    string `parquet:"delivery_name"`
+	DeliveryPhone   string `parquet:"delivery_phone"`
+	DeliveryZip     string `parquet:"delivery_zip"`
+	DeliveryCity    string `parquet:"delivery_city"`
+	DeliveryAddress string `parquet:"delivery_address"`
+	DeliveryRegion  string `parquet:"delivery_region"`
+	DeliveryEmail   string `parquet:"delivery_email"`
+
+	PaymentTransaction  string `parquet:"payment_transaction"`
+	PaymentRequestID    string `parquet:"payment_request_id"`
+	PaymentCurrency     string `parquet:"payment_currency"`
+	PaymentProvider     string `parquet:"payment_provider"`
+	PaymentAmount       int64  `parquet:"payment_amount"`
+	PaymentPaymentDt    int64  `parquet:"payment_payment_dt"`
+	PaymentBank         string `parquet:"payment_bank"`
+	PaymentDeliveryCost int64  `parquet:"payment_delivery_cost"`
+	PaymentGoodsTotal   int64  `parquet:"payment_goods_total"`
+	PaymentCustomFee    int64  `parquet:"payment_custom_fee"`
+
+	ItemChrtID      int64  `parquet:"item_chrt_id"`
+	ItemTrackNumber string `parquet:"item_track_number"`
+	ItemPrice       int64  `parquet:"item_price"`
+	ItemRid         string `parquet:"item_rid"`
+	ItemName        string `parquet:"item_name"`
+	ItemSale        int64  `parquet:"item_sale"`
+	ItemSize        string `parquet:"item_size"`
+	ItemTotalPrice  int64  `parquet:"item_total_price"`
+	ItemNmID        int64  `parquet:"item_nm_id"`
+	ItemBrand       string `parquet:"item_brand"`
+	ItemStatus      int64  `parquet:"item_status"`
+}
+
+// Columns are names of CSV columns in the order of Row fields
+var Columns = []string{
+	"order_uid", "track_number", "entry", "locale", "internal_signature", "customer_id", "delivery_service",
+	"shardkey", "sm_id", "date_created", "oof_shard",
+	"delivery_name", "delivery_phone", "delivery_zip", "delivery_city", "delivery_address", "delivery_region",
+	"delivery_email",
+	"payment_transaction", "payment_request_id", "payment_currency", "payment_provider", "payment_amount",
+	"payment_payment_dt", "payment_bank", "payment_delivery_cost", "payment_goods_total", "payment_custom_fee",
+	"item_chrt_id", "item_track_number", "item_price", "item_rid", "item_name", "item_sale", "item_size",
+	"item_total_price", "item_nm_id", "item_brand", "item_status",
+}
+
+// Rows flattens the order into one row per item
+func Rows(order *models.Order) []Row {
+	base := Row{
+		OrderUID:          order.OrderUID,
+		TrackNumber:       order.TrackNumber,
+		Entry:             order.Entry,
+		Locale:            order.Locale,
+		InternalSignature: order.InternalSignature,
+		CustomerID:        order.CustomerID,
+		DeliveryService:   order.DeliveryService,
+		Shardkey:          order.Shardkey,
+		SmID:              int64(order.SmID),
+		DateCreated:       order.DateCreated.UTC(),
+		OofShard:          order.OofShard,
+
+		DeliveryName:    order.Delivery.Name,
+		DeliveryPhone:   order.Delivery.Phone,
+		DeliveryZip:     order.Delivery.Zip,
+		DeliveryCity:    order.Delivery.City,
+		DeliveryAddress: order.Delivery.Address,
+		DeliveryRegion:  order.Delivery.Region,
+		DeliveryEmail:   order.Delivery.Email,
+
+		PaymentTransaction:  order.Payment.Transaction,
+		PaymentRequestID:    order.Payment.RequestID,
+		PaymentCurrency:     order.Payment.Currency,
+		PaymentProvider:     order.Payment.Provider,
+		PaymentAmount:       int64(order.Payment.Amount),
+		PaymentPaymentDt:    order.Payment.PaymentDt,
+		PaymentBank:         order.Payment.Bank,
+		PaymentDeliveryCost: int64(order.Payment.DeliveryCost),
+		PaymentGoodsTotal:   int64(order.Payment.GoodsTotal),
+		PaymentCustomFee:    int64(order.Payment.CustomFee),
+	}
+	if len(order.Items) == 0 {
+		return []Row{base}
+	}
+
+	rows := make([]Row, len(order.Items))
+	for i, item := range order.Items {
+		row := base
+		row.ItemChrtID = item.ChrtID
+		row.ItemTrackNumber = item.TrackNumber
+		row.ItemPrice = int64(item.Price)
+		row.ItemRid = item.Rid
+		row.ItemName = item.Name
+		row.ItemSale = int64(item.Sale)
+		row.ItemSize = item.Size
+		row.ItemTotalPrice = int64(item.TotalPrice)
+		row.ItemNmID = item.NmID
+		row.ItemBrand = item.Brand
+		row.ItemStatus = int64(item.Status)
+		rows[i] = row
+	}
+	return rows
+}
+
+// Strings returns values of the row in the order of Columns
+func (r Row) Strings() []string {
+	i := strconv.FormatInt
+	return []string{
+		r.OrderUID, r.TrackNumber, r.Entry, r.Locale, r.InternalSignature, r.CustomerID, r.DeliveryService,
+		r.Shardkey, i(r.SmID, 10), r.DateCreated.Format(time.RFC3339), r.OofShard,
+		r.DeliveryName, r.DeliveryPhone, r.DeliveryZip, r.DeliveryCity, r.DeliveryAddress, r.DeliveryRegion,
+		r.DeliveryEmail,
+		r.PaymentTransaction, r.PaymentRequestID, r.PaymentCurrency, r.PaymentProvider, i(r.PaymentAmount, 10),
+		i(r.PaymentPaymentDt, 10), r.PaymentBank, i(r.PaymentDeliveryCost, 10), i(r.PaymentGoodsTotal, 10),
+		i(r.PaymentCustomFee, 10),
+		i(r.ItemChrtID, 10), r.ItemTrackNumber, i(r.ItemPrice, 10), r.ItemRid, r.ItemName, i(r.ItemSale, 10),
+		r.ItemSize, i(r.ItemTotalPrice, 10), i(r.ItemNmID, 10), r.ItemBrand, i(r.ItemStatus, 10),
+	}
+}
+
+// A Writer writes orders in an export format, Close has to be called to write buffered data
+type Writer interface {
+	Write(order *models.Order) error
+	Close() error
+}
+
+// NewWriter creates a writer of the format
+func NewWriter(format string, w io.Writer) (Writer, error) {
+	switch format {
+	case FormatCSV:
+		writer := csv.NewWriter(w)
+		if err := writer.Write(Columns); err != nil {
+			return nil, err
+		}
+		return &csvWriter{writer: writer}, nil
+	case FormatNDJSON:
+		return &ndjsonWriter{encoder: json.NewEncoder(w)}, nil
+	case FormatParquet:
+		return &parquetWriter{
+			writer: parquet.NewGenericWriter[Row](w, parquet.MaxRowsPerRowGroup(parquetRowGroupSize)),
+		}, nil
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownFormat, format)
+	}
+}
+
+// ContentType returns the MIME type of the format
+func ContentType(format string) string {
+	switch format {
+	case FormatCSV:
+		return "text/csv"
+	case FormatNDJSON:
+		return "application/x-ndjson"
+	default:
+		return "application/vnd.apache.parquet"
+	}
+}
+
+// A csvWriter writes orders as CSV rows with the header
+type csvWriter struct {
+	writer *csv.Writer
+}
+
+// Write writes rows of the order
+func (w *csvWriter) Write(order *models.Order) error {
+	for _, row := range Rows(order) {
+		if err := w.writer.Write(row.Strings()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close flushes buffered rows
+func (w *csvWriter) Close() error {
+	w.writer.Flush()
+	return w.writer.Error()
+}
+
+// An ndjsonWriter writes orders as JSON lines
+type ndjsonWriter struct {
+	encoder *json.Encoder
+}
+
+// Write writes the order line
+func (w *ndjsonWriter) Write(order *models.Order) error {
+	return w.encoder.Encode(order)
+}
+
+// Close is a no-op, lines are written immediately
+func (w *ndjsonWriter) Close() error {
+	return nil
+}
+
+// A parquetWriter writes orders as Parquet rows, rows are buffered until the row group is full
+type parquetWriter struct {
+	writer *parquet.GenericWriter[Row]
+}
+
+// Write writes rows of the order
+func (w *parquetWriter) Write(order *models.Order) error {
+	_, err := w.writer.Write(Rows(order))
+	return err
+}
+
+// Close writes buffered rows and the file footer
+func (w *parquetWriter) Close() error {
+	return w.writer.Close()
+}
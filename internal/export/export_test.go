@@ -0,0 +1,228 @@
+package export
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+
+	"l0/internal/models"
+)
+
+func testOrders() []*models.Order {
+	created := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	return []*models.Order{
+		{
+			OrderUID:    "order1",
+			CustomerID:  "customer1",
+			DateCreated: created,
+			Delivery:    models.Delivery{Name: "Test Testov", Region: "Kraiot"},
+			Payment:     models.Payment{Transaction: "order1", Currency: "USD", Amount: 1817, GoodsTotal: 317},
+			Items: []models.Item{
+				{ChrtID: 1, Price: 453, Name: "Mascaras", Brand: "Vivienne Sabo"},
+				{ChrtID: 2, Price: 100, Name: "Lipstick", Brand: "Vivienne Sabo"},
+			},
+		},
+		{OrderUID: "order2", DateCreated: created},
+	}
+}
+
+func writeOrders(t *testing.T, format string) []byte {
+	var buf bytes.Buffer
+	writer, err := NewWriter(format, &buf)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	for _, order := range testOrders() {
+		if err := writer.Write(order); err != nil {
+			t.Fatalf("error: %v", err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestRows(t *testing.T) {
+	orders := testOrders()
+
+	rows := Rows(orders[0])
+	if len(rows) != 2 {
+		t.Fatalf("error: expected a row per item, got %d", len(rows))
+	}
+	if rows[1].OrderUID != "order1" || rows[1].ItemChrtID != 2 || rows[1].PaymentAmount != 1817 {
+		t.Errorf("error: unexpected row %+v", rows[1])
+	}
+	if rows := Rows(orders[1]); len(rows) != 1 || rows[0].ItemChrtID != 0 {
+		t.Errorf("error: expected one row without item for an order without items, got %+v", rows)
+	}
+	if len(rows[0].Strings()) != len(Columns) {
+		t.Errorf("error: expected %d values, got %d", len(Columns), len(rows[0].Strings()))
+	}
+}
+
+func TestWriter_CSV(t *testing.T) {
+	records, err := csv.NewReader(bytes.NewReader(writeOrders(t, FormatCSV))).ReadAll()
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if len(records) != 4 {
+		t.Fatalf("error: expected header and 3 rows, got %d records", len(records))
+	}
+	if strings.Join(records[0], ",") != strings.Join(Columns, ",") {
+		t.Errorf("error: unexpected header %v", records[0])
+	}
+	if records[2][0] != "order1" || records[2][32] != "Lipstick" || records[3][0] != "order2" {
+		t.Errorf("error: unexpected rows %v", records[1:])
+	}
+}
+
+func TestWriter_NDJSON(t *testing.T) {
+	lines := strings.Split(strings.TrimSpace(string(writeOrders(t, FormatNDJSON))), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("error: expected a line per order, got %d", len(lines))
+	}
+	var order models.Order
+	if err := json.Unmarshal([]byte(lines[0]), &order); err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if order.OrderUID != "order1" || len(order.Items) != 2 {
+		t.Errorf("error: unexpected order %+v", order)
+	}
+}
+
+func TestWriter_Parquet(t *testing.T) {
+	data := writeOrders(t, FormatParquet)
+
+	reader := parquet.NewGenericReader[Row](bytes.NewReader(data))
+	defer reader.Close()
+	rows := make([]Row, 10)
+	n, err := reader.Read(rows)
+	if err != nil && n != 3 {
+		t.Fatalf("error: %v", err)
+	}
+	if n != 3 {
+		t.Fatalf("error: expected 3 rows, got %d", n)
+	}
+	if rows[1].ItemName != "Lipstick" || rows[2].OrderUID != "order2" {
+		t.Errorf("error: unexpected rows %+v", rows[:n])
+	}
+	if !rows[0].DateCreated.Equal(testOrders()[0].DateCreated) {
+		t.Errorf("error: expected date %v, got %v", testOrders()[0].DateCreated, rows[0].DateCreated)
+	}
+}
+
+func TestNewWriter_UnknownFormat(t *testing.T) {
+	if _, err := NewWriter("xlsx", &bytes.Buffer{}); !errors.Is(err, ErrUnknownFormat) {
+		t.Errorf("error: expected unknown format error, got %v", err)
+	}
+}
+
+func TestReader_RoundTrip(t *testing.T) {
+	for _, format := range []string{FormatCSV, FormatNDJSON} {
+		reader, err := NewReader(format, bytes.NewReader(writeOrders(t, format)))
+		if err != nil {
+			t.Fatalf("error: %v", err)
+		}
+
+		var records []Record
+		for {
+			record, err := reader.Read()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				t.Fatalf("error: %v", err)
+			}
+			records = append(records, record)
+		}
+		if len(records) != 2 {
+			t.Fatalf("error: expected 2 %s records, got %d", format, len(records))
+		}
+		first := records[0]
+		if first.Err != nil || first.Order.OrderUID != "order1" || len(first.Order.Items) != 2 {
+			t.Errorf("error: unexpected %s record %+v", format, first)
+		}
+		if first.Order.Items[1].Name != "Lipstick" || !first.Order.DateCreated.Equal(testOrders()[0].DateCreated) {
+			t.Errorf("error: unexpected %s order %+v", format, first.Order)
+		}
+		if records[1].Order.OrderUID != "order2" {
+			t.Errorf("error: unexpected %s record %+v", format, records[1])
+		}
+	}
+}
+
+func TestReader_CSVErrors(t *testing.T) {
+	data := "order_uid,sm_id,item_chrt_id\norder1,1,1\norder1,1,2\norder2,one,3\norder3,1\norder1,1,4\n"
+	reader, err := NewReader(FormatCSV, strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	expected := []struct {
+		line  int
+		uid   string
+		items int
+		err   bool
+	}{
+		{3, "order1", 2, false},
+		{4, "order2", 1, true},
+		{5, "", 0, true},
+		{6, "order1", 1, false},
+	}
+	for _, e := range expected {
+		record, err := reader.Read()
+		if err != nil {
+			t.Fatalf("error: %v", err)
+		}
+		if record.Line != e.line || (record.Err != nil) != e.err {
+			t.Errorf("error: expected line %d with error %t, got %+v", e.line, e.err, record)
+		}
+		if record.Order != nil && (record.Order.OrderUID != e.uid || len(record.Order.Items) != e.items) {
+			t.Errorf("error: expected %s with %d items at line %d, got %+v", e.uid, e.items, e.line, record.Order)
+		}
+	}
+	if _, err := reader.Read(); !errors.Is(err, io.EOF) {
+		t.Errorf("error: expected EOF, got %v", err)
+	}
+
+	if _, err := NewReader(FormatCSV, strings.NewReader("order_uid,colour\n")); !errors.Is(err, ErrUnknownColumn) {
+		t.Errorf("error: expected unknown column error, got %v", err)
+	}
+}
+
+func TestReader_ShardKey(t *testing.T) {
+	inputs := map[string]string{
+		FormatCSV: "order_uid,shardkey\norder1,9\n",
+		FormatNDJSON: `{"order_uid":"order1","shardkey":"9"}` + "\n" +
+			`{"schema_version":2,"payload":{"order_uid":"order2","shard_key":"3"}}` + "\n",
+	}
+	for format, data := range inputs {
+		reader, err := NewReader(format, strings.NewReader(data))
+		if err != nil {
+			t.Fatalf("error: %v", err)
+		}
+		record, err := reader.Read()
+		if err != nil || record.Err != nil || record.Order.Shardkey != "9" {
+			t.Errorf("error: expected the %s shardkey to be read, got %+v, %v", format, record, err)
+		}
+	}
+
+	reader, _ := NewReader(FormatNDJSON, strings.NewReader(inputs[FormatNDJSON]))
+	reader.Read()
+	if record, err := reader.Read(); err != nil || record.Err != nil || record.Order.Shardkey != "3" {
+		t.Errorf("error: expected the enveloped order to be read, got %+v, %v", record, err)
+	}
+
+	reader, _ = NewReader(FormatNDJSON, strings.NewReader(`{"schema_version":7,"payload":{}}`))
+	if record, err := reader.Read(); err != nil || record.Err == nil {
+		t.Errorf("error: expected an error for the unknown payload version, got %+v, %v", record, err)
+	}
+}
@@ -0,0 +1,278 @@
+package export
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"l0/internal/envelope"
+	"l0/internal/models"
+)
+
+// maxLineSize is the maximum size of an NDJSON line
+const maxLineSize = 16 * 1024 * 1024
+
+var ErrUnknownColumn = errors.New("unknown CSV column")
+
+// A Record is an order read from a file. Line is the last line of the order in the file.
+// Records which can't be parsed have an error, the order of such records is nil or partially set
+type Record struct {
+	Line  int
+	Order *models.Order
+	Err   error
+}
+
+// A Reader reads orders one by one, io.EOF is returned after the last order
+type Reader interface {
+	Read() (Record, error)
+}
+
+// NewReader creates a reader of the format, Parquet files can't be read
+func NewReader(format string, r io.Reader) (Reader, error) {
+	switch format {
+	case FormatCSV:
+		return newCSVReader(r)
+	case FormatNDJSON:
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+		return &ndjsonReader{scanner: scanner, upcasters: envelope.DefaultUpcasters()}, nil
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownFormat, format)
+	}
+}
+
+// An ndjsonReader reads orders one per line, empty lines are skipped.
+// Orders of older payload versions are upcast like consumed messages
+type ndjsonReader struct {
+	scanner   *bufio.Scanner
+	upcasters *envelope.UpcasterChain
+	line      int
+}
+
+// Read reads the next order
+func (r *ndjsonReader) Read() (Record, error) {
+	for r.scanner.Scan() {
+		r.line++
+		data := bytes.TrimSpace(r.scanner.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+
+		wrapped, err := envelope.Parse(data)
+		if err != nil {
+			return Record{Line: r.line, Err: err}, nil
+		}
+		payload, err := r.upcasters.Upcast(wrapped.SchemaVersion, wrapped.Payload)
+		if err != nil {
+			return Record{Line: r.line, Err: err}, nil
+		}
+
+		var decoded envelope.OrderPayload
+		if err := json.Unmarshal(payload, &decoded); err != nil {
+			return Record{Line: r.line, Err: err}, nil
+		}
+		return Record{Line: r.line, Order: decoded.Order}, nil
+	}
+	if err := r.scanner.Err(); err != nil {
+		return Record{}, fmt.Errorf("line %d: %w", r.line+1, err)
+	}
+	return Record{}, io.EOF
+}
+
+// A csvReader reads orders flattened with one row per item. Rows of one order have to follow each other,
+// order, delivery and payment fields are taken from the first row of the order
+type csvReader struct {
+	reader    *csv.Reader
+	header    []string
+	uidColumn int
+	row       int
+	next      []string
+	nextErr   error
+}
+
+// newCSVReader reads the header and checks columns
+func newCSVReader(r io.Reader) (*csvReader, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV header: %w", err)
+	}
+	uidColumn := -1
+	for i, column := range header {
+		header[i] = strings.TrimSpace(column)
+		if !KnownColumn(header[i]) {
+			return nil, fmt.Errorf("%w %q", ErrUnknownColumn, header[i])
+		}
+		if header[i] == "order_uid" {
+			uidColumn = i
+		}
+	}
+	if uidColumn < 0 {
+		return nil, errors.New("CSV column order_uid is required")
+	}
+
+	csvReader := &csvReader{reader: reader, header: header, uidColumn: uidColumn, row: 1}
+	csvReader.advance()
+	return csvReader, nil
+}
+
+// advance reads the row following the current one
+func (r *csvReader) advance() {
+	r.next, r.nextErr = r.reader.Read()
+	if !errors.Is(r.nextErr, io.EOF) {
+		r.row++
+	}
+}
+
+// Read reads rows of the next order
+func (r *csvReader) Read() (Record, error) {
+	if errors.Is(r.nextErr, io.EOF) {
+		return Record{}, io.EOF
+	}
+	if r.nextErr != nil {
+		record := Record{Line: r.row, Err: r.nextErr}
+		r.advance()
+		return record, nil
+	}
+	if len(r.next) != len(r.header) {
+		record := Record{
+			Line: r.row, Err: fmt.Errorf("expected %d columns, got %d", len(r.header), len(r.next)),
+		}
+		r.advance()
+		return record, nil
+	}
+
+	record := Record{Order: &models.Order{}}
+	uid := r.next[r.uidColumn]
+	for first := true; ; first = false {
+		record.Line = r.row
+
+		var item models.Item
+		for i, value := range r.next {
+			// Order fields are taken from the first row, so rows of one order don't have to repeat them
+			if !first && !strings.HasPrefix(r.header[i], "item_") {
+				continue
+			}
+			if err := SetColumn(record.Order, &item, r.header[i], strings.TrimSpace(value)); err != nil {
+				record.Err = errors.Join(record.Err, fmt.Errorf("row %d: column %s: %w", r.row, r.header[i], err))
+			}
+		}
+		record.Order.Items = append(record.Order.Items, item)
+
+		r.advance()
+		if r.nextErr != nil || len(r.next) != len(r.header) || r.next[r.uidColumn] != uid {
+			break
+		}
+	}
+	return record, nil
+}
+
+// KnownColumn reports whether the CSV column is one of Columns
+func KnownColumn(column string) bool {
+	_, ok := columnSetters[column]
+	return ok
+}
+
+// SetColumn sets the field of the order or the item by the CSV column
+func SetColumn(order *models.Order, item *models.Item, column, value string) error {
+	setter, ok := columnSetters[column]
+	if !ok {
+		return fmt.Errorf("%w %q", ErrUnknownColumn, column)
+	}
+	return setter(order, item, value)
+}
+
+// columnSetters set fields of orders and items by CSV columns
+var columnSetters = map[string]func(order *models.Order, item *models.Item, value string) error{
+	"order_uid":          setString(func(o *models.Order, _ *models.Item) *string { return &o.OrderUID }),
+	"track_number":       setString(func(o *models.Order, _ *models.Item) *string { return &o.TrackNumber }),
+	"entry":              setString(func(o *models.Order, _ *models.Item) *string { return &o.Entry }),
+	"locale":             setString(func(o *models.Order, _ *models.Item) *string { return &o.Locale }),
+	"internal_signature": setString(func(o *models.Order, _ *models.Item) *string { return &o.InternalSignature }),
+	"customer_id":        setString(func(o *models.Order, _ *models.Item) *string { return &o.CustomerID }),
+	"delivery_service":   setString(func(o *models.Order, _ *models.Item) *string { return &o.DeliveryService }),
+	"shardkey":           setString(func(o *models.Order, _ *models.Item) *string { return &o.Shardkey }),
+	"sm_id":              setInt(func(o *models.Order, _ *models.Item) *int { return &o.SmID }),
+	"oof_shard":          setString(func(o *models.Order, _ *models.Item) *string { return &o.OofShard }),
+	"date_created": func(order *models.Order, _ *models.Item, value string) error {
+		if value == "" {
+			return nil
+		}
+		var err error
+		order.DateCreated, err = time.Parse(time.RFC3339, value)
+		return err
+	},
+
+	"delivery_name":    setString(func(o *models.Order, _ *models.Item) *string { return &o.Delivery.Name }),
+	"delivery_phone":   setString(func(o *models.Order, _ *models.Item) *string { return &o.Delivery.Phone }),
+	"delivery_zip":     setString(func(o *models.Order, _ *models.Item) *string { return &o.Delivery.Zip }),
+	"delivery_city":    setString(func(o *models.Order, _ *models.Item) *string { return &o.Delivery.City }),
+	"delivery_address": setString(func(o *models.Order, _ *models.Item) *string { return &o.Delivery.Address }),
+	"delivery_region":  setString(func(o *models.Order, _ *models.Item) *string { return &o.Delivery.Region }),
+	"delivery_email":   setString(func(o *models.Order, _ *models.Item) *string { return &o.Delivery.Email }),
+
+	"payment_transaction":   setString(func(o *models.Order, _ *models.Item) *string { return &o.Payment.Transaction }),
+	"payment_request_id":    setString(func(o *models.Order, _ *models.Item) *string { return &o.Payment.RequestID }),
+	"payment_currency":      setString(func(o *models.Order, _ *models.Item) *string { return &o.Payment.Currency }),
+	"payment_provider":      setString(func(o *models.Order, _ *models.Item) *string { return &o.Payment.Provider }),
+	"payment_amount":        setInt(func(o *models.Order, _ *models.Item) *int { return &o.Payment.Amount }),
+	"payment_payment_dt":    setInt64(func(o *models.Order, _ *models.Item) *int64 { return &o.Payment.PaymentDt }),
+	"payment_bank":          setString(func(o *models.Order, _ *models.Item) *string { return &o.Payment.Bank }),
+	"payment_delivery_cost": setInt(func(o *models.Order, _ *models.Item) *int { return &o.Payment.DeliveryCost }),
+	"payment_goods_total":   setInt(func(o *models.Order, _ *models.Item) *int { return &o.Payment.GoodsTotal }),
+	"payment_custom_fee":    setInt(func(o *models.Order, _ *models.Item) *int { return &o.Payment.CustomFee }),
+
+	"item_chrt_id":      setInt64(func(_ *models.Order, i *models.Item) *int64 { return &i.ChrtID }),
+	"item_track_number": setString(func(_ *models.Order, i *models.Item) *string { return &i.TrackNumber }),
+	"item_price":        setInt(func(_ *models.Order, i *models.Item) *int { return &i.Price }),
+	"item_rid":          setString(func(_ *models.Order, i *models.Item) *string { return &i.Rid }),
+	"item_name":         setString(func(_ *models.Order, i *models.Item) *string { return &i.Name }),
+	"item_sale":         setInt(func(_ *models.Order, i *models.Item) *int { return &i.Sale }),
+	"item_size":         setString(func(_ *models.Order, i *models.Item) *string { return &i.Size }),
+	"item_total_price":  setInt(func(_ *models.Order, i *models.Item) *int { return &i.TotalPrice }),
+	"item_nm_id":        setInt64(func(_ *models.Order, i *models.Item) *int64 { return &i.NmID }),
+	"item_brand":        setString(func(_ *models.Order, i *models.Item) *string { return &i.Brand }),
+	"item_status":       setInt(func(_ *models.Order, i *models.Item) *int { return &i.Status }),
+}
+
+// setString returns a setter of the string field
+func setString(field func(*models.Order, *models.Item) *string) func(*models.Order, *models.Item, string) error {
+	return func(order *models.Order, item *models.Item, value string) error {
+		*field(order, item) = value
+		return nil
+	}
+}
+
+// setInt returns a setter of the int field, empty values are zeros
+func setInt(field func(*models.Order, *models.Item) *int) func(*models.Order, *models.Item, string) error {
+	return func(order *models.Order, item *models.Item, value string) error {
+		if value == "" {
+			return nil
+		}
+		n, err := strconv.Atoi(value)
+		*field(order, item) = n
+		return err
+	}
+}
+
+// setInt64 returns a setter of the int64 field, empty values are zeros
+func setInt64(field func(*models.Order, *models.Item) *int64) func(*models.Order, *models.Item, string) error {
+	return func(order *models.Order, item *models.Item, value string) error {
+		if value == "" {
+			return nil
+		}
+		n, err := strconv.ParseInt(value, 10, 64)
+		*field(order, item) = n
+		return err
+	}
+}
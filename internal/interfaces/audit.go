@@ -0,0 +1,19 @@
+package interfaces
+
+import (
+	"context"
+	"l0/internal/models"
+)
+
+type AuditStore interface {
+	InsertAuditEntries(ctx context.Context, entries []models.AuditEntry) error
+	SealAuditLog(ctx context.Context, limit int) (int, error)
+	ListAuditEntries(ctx context.Context, filter models.AuditFilter) ([]models.AuditEntry, error)
+	VerifyAuditLog(ctx context.Context) (*models.AuditVerification, error)
+}
+
+type AuditLog interface {
+	Record(entry models.AuditEntry)
+	Entries(ctx context.Context, filter models.AuditFilter) ([]models.AuditEntry, error)
+	Verify(ctx context.Context) (*models.AuditVerification, error)
+}
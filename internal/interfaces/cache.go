@@ -9,4 +9,8 @@ type Cache[K comparable, V any] interface {
 	Size() int
 	Capacity() int
 	Empty() bool
+}
+
+type CacheEvicter interface {
+	EvictCache(orderUIDs ...string)
 }
\ No newline at end of file
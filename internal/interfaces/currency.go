@@ -0,0 +1,10 @@
+package interfaces
+
+import (
+	"context"
+	"math/big"
+)
+
+type RateProvider interface {
+	Rate(ctx context.Context, from, to string) (*big.Rat, error)
+}
@@ -0,0 +1,8 @@
+package interfaces
+
+import "l0/internal/models"
+
+type OrderChangePublisher interface {
+	Publish(change models.OrderChange)
+	HasSubscribers() bool
+}
@@ -3,6 +3,7 @@ package interfaces
 import (
 	"context"
 	"l0/internal/models"
+	"l0/internal/offsets"
 	"time"
 )
 
@@ -26,4 +27,23 @@ type DeadLetterQueue interface {
 
 type OrderProcessor interface {
 	ProcessOrder(ctx context.Context, order *models.Order) error
-}
\ No newline at end of file
+}
+
+type PaymentProcessor interface {
+	UpdatePayment(ctx context.Context, payment *models.Payment) error
+}
+
+type OffsetStore interface {
+	GetConsumerOffsets(ctx context.Context, groupID, topic string) (map[int]int64, error)
+	StoreConsumerOffset(ctx context.Context, position offsets.Position) error
+	ResetConsumerOffsets(ctx context.Context, groupID, topic string, partitionOffsets map[int]int64) error
+}
+
+type ConsumerController interface {
+	Pause()
+	Resume()
+	Seek(ctx context.Context, topic string, partition int, offset int64) error
+	ResetGroup(ctx context.Context, reset models.OffsetReset) (map[int]int64, error)
+	StartReplay(ctx context.Context, replay models.Replay) (models.ReplayStatus, error)
+	Status() models.ConsumerStatus
+}
@@ -0,0 +1,16 @@
+package interfaces
+
+import (
+	"context"
+	"l0/internal/models"
+	"time"
+)
+
+type OutboxStore interface {
+	ProcessPendingEvents(ctx context.Context, limit int, publish func([]models.OutboxEvent) error) (int, error)
+	DeletePublishedEvents(ctx context.Context, before time.Time, keepPendingWebhooks bool) (int64, error)
+}
+
+type EventPublisher interface {
+	Publish(ctx context.Context, events []models.OutboxEvent) error
+}
@@ -3,11 +3,40 @@ package interfaces
 import (
 	"context"
 	"l0/internal/models"
+	"time"
 )
 
 type Repository interface {
-	SaveOrder(ctx context.Context, order *models.Order) error
+	SaveOrder(ctx context.Context, order *models.Order) (bool, error)
 	GetOrder(ctx context.Context, orderUid string) (*models.Order, error)
 	GetNOrders(ctx context.Context, n int) ([]models.Order, error)
 	GetAllOrders(ctx context.Context) ([]models.Order, error)
+}
+
+type CustomerRepository interface {
+	GetCustomerOrders(ctx context.Context, customerID string, limit, offset int) ([]models.Order, error)
+	GetCustomerSummary(ctx context.Context, customerID string, brandsLimit int) (*models.CustomerSummary, error)
+}
+
+type PaymentRepository interface {
+	UpdatePayment(ctx context.Context, payment *models.Payment, basePayment *models.ConvertedPayment) error
+}
+
+type ExportRepository interface {
+	StreamOrders(ctx context.Context, filter models.OrderFilter, fn func(order *models.Order) error) error
+}
+
+type ReportRepository interface {
+	SalesReport(ctx context.Context, query models.SalesReportQuery) (*models.SalesReport, error)
+	RefreshSalesViews(ctx context.Context) error
+}
+
+type PrivacyRepository interface {
+	SoftDeleteOrder(ctx context.Context, orderUID, principal string) (bool, error)
+	EraseCustomer(ctx context.Context, customerID, principal string) ([]string, error)
+}
+
+type RetentionStore interface {
+	DeleteOrdersBefore(ctx context.Context, before time.Time, limit int) ([]string, error)
+	AnonymizeOrdersBefore(ctx context.Context, before time.Time, limit int) ([]string, error)
 }
\ No newline at end of file
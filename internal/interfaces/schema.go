@@ -0,0 +1,14 @@
+package interfaces
+
+import (
+	"context"
+	"l0/internal/models"
+)
+
+type SchemaRegistry interface {
+	GetSchemaByID(ctx context.Context, id int) (*models.Schema, error)
+}
+
+type OrderDecoder interface {
+	Decode(ctx context.Context, value []byte) (*models.Order, error)
+}
@@ -9,4 +9,14 @@ type OrderService interface {
 	ProcessOrder(ctx context.Context, order *models.Order) error
 	GetOrder(ctx context.Context, OrderUID string) (*models.Order, error)
 	WarmCache(ctx context.Context) error
+	GetCustomerOrders(ctx context.Context, customerID string, limit, offset int) ([]models.Order, error)
+	GetCustomerSummary(ctx context.Context, customerID string) (*models.CustomerSummary, error)
+	ConvertPayment(ctx context.Context, payment *models.Payment, currency string) (*models.ConvertedPayment, error)
+	ExportOrders(ctx context.Context, filter models.OrderFilter, fn func(order *models.Order) error) error
+	DeleteOrder(ctx context.Context, orderUID, principal string) (bool, error)
+	EraseCustomer(ctx context.Context, customerID, principal string) (*models.ErasureResult, error)
+}
+
+type ReportService interface {
+	SalesReport(ctx context.Context, query models.SalesReportQuery) (*models.SalesReport, error)
 }
\ No newline at end of file
@@ -0,0 +1,29 @@
+package interfaces
+
+import (
+	"context"
+	"l0/internal/models"
+	"time"
+)
+
+type WebhookStore interface {
+	CreateWebhookSubscription(ctx context.Context, subscription *models.WebhookSubscription) error
+	ListWebhookSubscriptions(ctx context.Context) ([]models.WebhookSubscription, error)
+	GetWebhookSubscription(ctx context.Context, id int64) (*models.WebhookSubscription, error)
+	DeleteWebhookSubscription(ctx context.Context, id int64) (bool, error)
+	ListWebhookDeliveries(ctx context.Context, subscriptionID int64, limit, offset int) ([]models.WebhookDelivery, error)
+}
+
+type WebhookQueue interface {
+	EnqueueWebhookDeliveries(ctx context.Context, limit int) (int, error)
+	ClaimWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) ([]models.WebhookDelivery, error)
+	RecordWebhookAttempt(ctx context.Context, attempt models.WebhookAttempt) error
+}
+
+type WebhookService interface {
+	CreateSubscription(ctx context.Context, subscription *models.WebhookSubscription) error
+	Subscriptions(ctx context.Context) ([]models.WebhookSubscription, error)
+	Subscription(ctx context.Context, id int64) (*models.WebhookSubscription, error)
+	DeleteSubscription(ctx context.Context, id int64) (bool, error)
+	Deliveries(ctx context.Context, subscriptionID int64, limit, offset int) ([]models.WebhookDelivery, error)
+}
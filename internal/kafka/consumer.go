@@ -2,33 +2,81 @@ package kafka
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/avast/retry-go/v4"
 	"github.com/rs/zerolog"
 	"github.com/segmentio/kafka-go"
 	"github.com/sony/gobreaker"
+	"l0/internal/codec"
 	"l0/internal/config"
 	"l0/internal/interfaces"
+	"l0/internal/kafka/security"
+	"l0/internal/metrics"
 	"l0/internal/models"
+	"l0/internal/schemaregistry"
 	"strings"
 	"sync"
 	"time"
 )
 
+// Reasons of sending messages to the dead letter queue
+const (
+	ReasonProcessingError      = "processing_error"
+	ReasonValidationError      = "validation_error"
+	ReasonJSONUnmarshalError   = "json_unmarshal_error"
+	ReasonDecodeError          = "decode_error"
+	ReasonUnknownFormat        = "unknown_format"
+	ReasonUnknownSchemaVersion = "unknown_schema_version"
+	ReasonUnknownTopic         = "unknown_topic"
+)
+
+// Offsets to start consumption of partitions without committed offsets from
+const (
+	StartOffsetEarliest = "earliest"
+	StartOffsetLatest   = "latest"
+)
+
+// Storages of consumer offsets
+const (
+	OffsetStorageKafka    = "kafka"
+	OffsetStoragePostgres = "postgres"
+)
+
 type Consumer struct {
 	reader          *kafka.Reader
+	group           *kafka.ConsumerGroup
+	offsetStore     interfaces.OffsetStore
 	config          config.KafkaConfig
 	mu              sync.RWMutex
 	running         bool
-	processor       interfaces.OrderProcessor
 	logger          *zerolog.Logger
 	circuitBreaker  *gobreaker.CircuitBreaker
 	deadLetterQueue interfaces.DeadLetterQueue
+	handlers        map[string]Handler
+	routes          map[string]Handler
+	topics          []string
+	dialer          *kafka.Dialer
 	brokers         []string
+	flow            *flowControl
+	replayFlow      *flowControl
+	monitorOnce     sync.Once
+	startCtx        context.Context
+	stop            chan struct{}
+	done            chan struct{}
+	resetMu         sync.Mutex
+	replayMu        sync.Mutex
+	replays         map[string]*models.ReplayStatus
+	retryWriter     messageWriter
+	retryReaders    []*kafka.Reader
 }
 
-func NewConsumer(config config.Config, processor interfaces.OrderProcessor, logger *zerolog.Logger) *Consumer {
+// NewConsumer creates a new consumer with the orders handler registered. Handlers of other topics are registered
+// with RegisterHandler. The offset store is used only if offsets are stored in Postgres
+func NewConsumer(
+	config config.Config, processor interfaces.OrderProcessor, offsetStore interfaces.OffsetStore,
+	logger *zerolog.Logger,
+) *Consumer {
 	deadLetterQueue := NewInMemoryDeadLetterQueue(logger)
 	cb := gobreaker.NewCircuitBreaker(
 		gobreaker.Settings{
@@ -42,13 +90,32 @@ func NewConsumer(config config.Config, processor interfaces.OrderProcessor, logg
 		},
 	)
 
-	return &Consumer{
+	var registry interfaces.SchemaRegistry
+	if config.Kafka.SchemaRegistry.URL != "" {
+		registry = schemaregistry.NewClient(config.Kafka.SchemaRegistry.URL, config.Kafka.SchemaRegistry.Timeout)
+	}
+	decoders, err := codec.NewDecoders(config.Kafka.Format, registry)
+	if err != nil {
+		logger.Warn().Err(err).Msg("Invalid Kafka message format, falling back to JSON")
+		decoders, _ = codec.NewDecoders(codec.FormatJSON, registry)
+	}
+
+	consumer := &Consumer{
 		config:          config.Kafka,
-		processor:       processor,
 		logger:          logger,
 		circuitBreaker:  cb,
 		deadLetterQueue: deadLetterQueue,
+		handlers: map[string]Handler{
+			HandlerOrders: NewOrderHandler(processor, decoders, logger),
+		},
+		offsetStore: offsetStore,
+		flow:        newFlowControl(),
+		replayFlow:  newFlowControl(),
+		replays:     make(map[string]*models.ReplayStatus),
 	}
+	consumer.registerFlowMetrics()
+
+	return consumer
 }
 
 func (c *Consumer) Start(ctx context.Context) error {
@@ -59,30 +126,55 @@ func (c *Consumer) Start(ctx context.Context) error {
 		return fmt.Errorf("consumer is already running")
 	}
 
-	c.brokers = strings.Split(c.config.Listeners, ",")
-	for i, broker := range c.brokers {
-		c.brokers[i] = strings.TrimSpace(broker)
+	if err := c.connect(ctx); err != nil {
+		return err
 	}
+	c.logger.Info().Strs("topics", c.topics).Msg("Kafka consumer subscribed")
 
-	c.reader = kafka.NewReader(
-		kafka.ReaderConfig{
-			Brokers:     c.brokers,
-			Topic:       c.config.Topic,
-			GroupID:     c.config.GroupID,
-			StartOffset: kafka.LastOffset,
-			MinBytes:    10e3,
-			MaxBytes:    10e6,
-			MaxWait:     time.Second,
-			ErrorLogger: kafka.LoggerFunc(
-				func(msg string, args ...interface{}) {
-					c.logger.Error().
-						Str("kafka_error", fmt.Sprintf(msg, args...)).
-						Msg("kafka reader error")
+	if c.config.Backpressure.Enabled {
+		c.monitorOnce.Do(
+			func() {
+				go c.monitorBackpressure(ctx)
+			},
+		)
+	}
+	c.startCtx = ctx
+	c.stop = make(chan struct{})
+	c.done = make(chan struct{})
 
-				},
-			),
-		},
-	)
+	if c.config.OffsetStorage == OffsetStoragePostgres {
+		if err := c.startGroup(ctx); err != nil {
+			return err
+		}
+		return c.startRetries(ctx)
+	}
+
+	readerConfig := kafka.ReaderConfig{
+		Brokers:     c.brokers,
+		Dialer:      c.dialer,
+		GroupID:     c.config.GroupID,
+		StartOffset: c.startOffset(kafka.LastOffset),
+		MinBytes:    10e3,
+		MaxBytes:    10e6,
+		MaxWait:     time.Second,
+		ErrorLogger: kafka.LoggerFunc(
+			func(msg string, args ...interface{}) {
+				c.logger.Error().
+					Str("kafka_error", fmt.Sprintf(msg, args...)).
+					Msg("kafka reader error")
+
+			},
+		),
+	}
+	// A reader without a group reads only one topic
+	if len(c.topics) == 1 {
+		readerConfig.Topic = c.topics[0]
+	} else if strings.TrimSpace(c.config.GroupID) == "" {
+		return errors.New("Kafka GroupID is required to consume multiple topics")
+	} else {
+		readerConfig.GroupTopics = c.topics
+	}
+	c.reader = kafka.NewReader(readerConfig)
 
 	if strings.TrimSpace(c.config.GroupID) == "" {
 		c.logger.Warn().Msg("Kafka GroupID is empty — offsets will NOT be committed. Set GroupID to enable consumer-group offset commits.")
@@ -90,20 +182,86 @@ func (c *Consumer) Start(ctx context.Context) error {
 
 	c.running = true
 
-	go c.consume(ctx)
+	go func(done chan struct{}) {
+		defer close(done)
+		c.consume(ctx)
+	}(c.done)
 
-	return nil
+	return c.startRetries(ctx)
+}
+
+// connect configures connections to brokers and resolves subscribed topics, the caller has to hold the lock
+func (c *Consumer) connect(ctx context.Context) error {
+	c.brokers = strings.Split(c.config.Listeners, ",")
+	for i, broker := range c.brokers {
+		c.brokers[i] = strings.TrimSpace(broker)
+	}
+
+	dialer, err := security.NewDialer(c.config.Security)
+	if err != nil {
+		return fmt.Errorf("failed to configure Kafka connection: %w", err)
+	}
+	c.dialer = dialer
+
+	return c.resolveTopics(ctx, c.listTopics)
+}
+
+// startOffset returns the offset to start consumption of partitions without committed offsets from
+func (c *Consumer) startOffset(defaultOffset int64) int64 {
+	switch c.config.StartOffset {
+	case StartOffsetEarliest:
+		return kafka.FirstOffset
+	case StartOffsetLatest:
+		return kafka.LastOffset
+	default:
+		return defaultOffset
+	}
 }
 
 func (c *Consumer) Stop(ctx context.Context) error {
 	c.mu.Lock()
-	defer c.mu.Unlock()
-
 	if !c.running {
+		c.mu.Unlock()
 		return nil
 	}
 
 	c.running = false
+	close(c.stop)
+	done := c.done
+
+	if err := c.stopRetryReaders(); err != nil {
+		c.logger.Error().Err(err).Msg("Error stopping Kafka retries")
+	}
+	err := c.closeReaders()
+	c.mu.Unlock()
+
+	// Messages failing while workers drain are republished to retry tiers, so the writer is closed after them
+	select {
+	case <-done:
+	case <-ctx.Done():
+		err = errors.Join(err, fmt.Errorf("consumption didn't finish: %w", ctx.Err()))
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// The consumer started again keeps the writer
+	if !c.running {
+		if closeErr := c.stopRetryWriter(); closeErr != nil {
+			c.logger.Error().Err(closeErr).Msg("Error stopping Kafka retries")
+		}
+	}
+	return err
+}
+
+// closeReaders closes the consumer group and the reader, the caller has to hold the lock
+func (c *Consumer) closeReaders() error {
+	if c.group != nil {
+		if err := c.group.Close(); err != nil {
+			c.logger.Error().Err(err).Msg("Error closing Kafka consumer group")
+			return fmt.Errorf("failed to close Kafka consumer group: %w", err)
+		}
+		c.group = nil
+	}
 
 	if c.reader != nil {
 		if err := c.reader.Close(); err != nil {
@@ -116,17 +274,31 @@ func (c *Consumer) Stop(ctx context.Context) error {
 	return nil
 }
 
+// consume fetches messages of the reader until the consumer is stopped or the reader is replaced
 func (c *Consumer) consume(ctx context.Context) {
+	c.mu.RLock()
+	reader, stop := c.reader, c.stop
+	c.mu.RUnlock()
+
+	var pool *workerPool
+	if c.config.Concurrency > 1 {
+		pool = c.startWorkerPool(ctx, reader)
+		defer pool.stop()
+	}
+
 	for {
 		c.mu.RLock()
-		running := c.running
-		reader := c.reader
+		running := c.running && c.reader == reader
 		c.mu.RUnlock()
 
 		if !running || reader == nil {
 			break
 		}
 
+		if !c.flow.wait(ctx, stop) {
+			break
+		}
+
 		fetchCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
 		result, err := c.circuitBreaker.Execute(
 			func() (any, error) {
@@ -167,134 +339,105 @@ func (c *Consumer) consume(ctx context.Context) {
 		}
 		message := result.(kafka.Message)
 
-		processErr := c.processMessage(ctx, message)
-		if processErr != nil {
-			c.logger.Error().
-				Err(processErr).
-				Str("topic", message.Topic).
-				Int("partition", message.Partition).
-				Int64("offset", message.Offset).
-				Msg("Error processing message, sending to dead letter queue")
-
-			dlqErr := c.deadLetterQueue.Send(
-				message.Value,
-				message.Topic,
-				message.Partition,
-				message.Offset,
-				"processing_error",
-				processErr,
-			)
-			if dlqErr != nil {
-				c.logger.Error().
-					Err(dlqErr).
-					Str("topic", message.Topic).
-					Int("partition", message.Partition).
-					Int64("offset", message.Offset).
-					Msg("Failed to send message to dead letter queue")
-			}
+		if pool != nil {
+			pool.dispatch(ctx, message)
+			continue
 		}
 
-		if strings.TrimSpace(c.config.GroupID) != "" {
-			commitErr := retry.Do(
-				func() error {
-					return reader.CommitMessages(ctx, message)
-				},
-				retry.Attempts(5),
-				retry.Delay(500*time.Millisecond),
-				retry.DelayType(retry.BackOffDelay),
-				retry.Context(ctx),
-			)
-
-			if commitErr != nil {
-				c.logger.Error().
-					Err(commitErr).
-					Str("topic", message.Topic).
-					Int("partition", message.Partition).
-					Int64("offset", message.Offset).
-					Msg("Failed to commit message after retries")
-			}
-		}
+		c.handleMessage(ctx, message)
+		c.commitMessage(ctx, reader, message)
 	}
 }
 
-func (c *Consumer) processMessage(ctx context.Context, message kafka.Message) error {
-	start := time.Now()
-
-	var order models.Order
-	if err := json.Unmarshal(message.Value, &order); err != nil {
-		c.logger.Error().
-			Err(err).
-			Str("topic", message.Topic).
-			Int64("offset", message.Offset).
-			Str("raw_message", string(message.Value)).
-			Msg("Failed to unmarshal order JSON")
-
-		dlqErr := c.deadLetterQueue.Send(
-			message.Value,
-			message.Topic,
-			message.Partition,
-			message.Offset,
-			"json_unmarshal_error",
-			err,
-		)
-		if dlqErr != nil {
-			c.logger.Error().Err(dlqErr).Msg("Failed to send malformed JSON to dead letter queue")
-		}
-
-		return fmt.Errorf("failed to unmarshal order JSON: %w", err)
+// commitMessage commits the offset after the message to the consumer group with retries
+func (c *Consumer) commitMessage(ctx context.Context, reader *kafka.Reader, message kafka.Message) {
+	if strings.TrimSpace(c.config.GroupID) == "" {
+		return
 	}
 
-	if err := c.validateOrder(&order); err != nil {
+	commitErr := retry.Do(
+		func() error {
+			return reader.CommitMessages(ctx, message)
+		},
+		retry.Attempts(5),
+		retry.Delay(500*time.Millisecond),
+		retry.DelayType(retry.BackOffDelay),
+		retry.Context(ctx),
+	)
+
+	if commitErr != nil {
 		c.logger.Error().
-			Err(err).
-			Str("order_uid", order.OrderUID).
+			Err(commitErr).
 			Str("topic", message.Topic).
+			Int("partition", message.Partition).
 			Int64("offset", message.Offset).
-			Msg("Order validation failed")
-
-		dlqErr := c.deadLetterQueue.Send(
-			message.Value,
-			message.Topic,
-			message.Partition,
-			message.Offset,
-			"validation_error",
-			err,
-		)
+			Msg("Failed to commit message after retries")
+	}
+}
 
-		if dlqErr != nil {
-			c.logger.Error().Err(dlqErr).Msg("Failed to send invalid order to dead letter queue")
-		}
+// handleMessage processes the message by the handler of its topic. If processing failed, the message is
+// republished to the next retry tier or sent to the dead letter queue when tiers are exhausted
+func (c *Consumer) handleMessage(ctx context.Context, message kafka.Message) error {
+	var processErr error
+	if handler, ok := c.routes[message.Topic]; ok {
+		processErr = handler.Handle(ctx, message)
+	} else {
+		processErr = NewDeadLetterError(ReasonUnknownTopic, fmt.Errorf("no handler for topic %q", message.Topic))
+	}
 
-		return fmt.Errorf("order validation failed: %w", err)
+	if processErr == nil {
+		metrics.DefaultRegistry.Counter(
+			"kafka_consumed_messages_total", "Number of consumed Kafka messages",
+			"topic", message.Topic, "result", "processed",
+		).Inc()
+		return nil
 	}
 
-	processCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
-	defer cancel()
+	reason := deadLetterReason(processErr)
+	// Only transient processing errors are retried, invalid messages fail on every attempt
+	if reason == ReasonProcessingError && c.scheduleRetry(ctx, message, processErr) {
+		metrics.DefaultRegistry.Counter(
+			"kafka_consumed_messages_total", "Number of consumed Kafka messages",
+			"topic", message.Topic, "result", "retried",
+		).Inc()
+		return processErr
+	}
 
-	if err := c.processor.ProcessOrder(processCtx, &order); err != nil {
+	metrics.DefaultRegistry.Counter(
+		"kafka_consumed_messages_total", "Number of consumed Kafka messages",
+		"topic", message.Topic, "result", "failed",
+	).Inc()
+	metrics.DefaultRegistry.Counter(
+		"kafka_dead_letter_messages_total", "Number of messages sent to the dead letter queue",
+		"topic", message.Topic, "reason", reason,
+	).Inc()
+
+	c.logger.Error().
+		Err(processErr).
+		Str("topic", message.Topic).
+		Int("partition", message.Partition).
+		Int64("offset", message.Offset).
+		Str("reason", reason).
+		Msg("Error processing message, sending to dead letter queue")
+
+	dlqErr := c.deadLetterQueue.Send(
+		message.Value,
+		message.Topic,
+		message.Partition,
+		message.Offset,
+		reason,
+		processErr,
+	)
+	if dlqErr != nil {
 		c.logger.Error().
-			Err(err).
-			Str("order_uid", order.OrderUID).
+			Err(dlqErr).
 			Str("topic", message.Topic).
+			Int("partition", message.Partition).
 			Int64("offset", message.Offset).
-			Dur("duration", time.Since(start)).
-			Msg("Failed to process order")
-
-		return fmt.Errorf("failed to process order: %w", err)
+			Msg("Failed to send message to dead letter queue")
 	}
 
-	return nil
-}
-
-func (c *Consumer) validateOrder(order *models.Order) error {
-	if err := order.Validate(); err != nil {
-		c.logger.Error().
-			Err(err).
-			Str("order_uid", order.OrderUID).
-			Msg("Kafka consumer: order validation failed")
-		return err
-	}
-	return nil
+	return processErr
 }
 
 func (c *Consumer) GetDeadLetterQueue() interfaces.DeadLetterQueue {
@@ -0,0 +1,193 @@
+package kafka
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"l0/internal/metrics"
+	"l0/internal/models"
+)
+
+// defaultCheckInterval is the interval of checking pause conditions if it's not configured
+const defaultCheckInterval = time.Second
+
+// A flowControl pauses fetching of messages manually or while any pause condition holds.
+// Messages which are already fetched are processed while fetching is paused
+type flowControl struct {
+	mu         sync.Mutex
+	manual     bool
+	reasons    []string
+	conditions map[string]func() bool
+	resumed    chan struct{}
+}
+
+// newFlowControl creates a new flow control in the running state
+func newFlowControl() *flowControl {
+	resumed := make(chan struct{})
+	close(resumed)
+	return &flowControl{
+		conditions: make(map[string]func() bool),
+		resumed:    resumed,
+	}
+}
+
+// paused reports if fetching is paused, the caller has to hold the lock
+func (f *flowControl) paused() bool {
+	return f.manual || len(f.reasons) > 0
+}
+
+// update changes the state and wakes waiters up if fetching is resumed, the caller has to hold the lock
+func (f *flowControl) update(manual bool, reasons []string) (changed bool) {
+	wasPaused := f.paused()
+	f.manual, f.reasons = manual, reasons
+	isPaused := f.paused()
+
+	switch {
+	case !wasPaused && isPaused:
+		f.resumed = make(chan struct{})
+	case wasPaused && !isPaused:
+		close(f.resumed)
+	}
+	return wasPaused != isPaused
+}
+
+// wait blocks while fetching is paused, it returns false if the context is done or the consumer is stopped
+func (f *flowControl) wait(ctx context.Context, stop <-chan struct{}) bool {
+	f.mu.Lock()
+	resumed := f.resumed
+	f.mu.Unlock()
+
+	select {
+	case <-resumed:
+		return true
+	case <-ctx.Done():
+		return false
+	case <-stop:
+		return false
+	}
+}
+
+// check evaluates pause conditions and returns the names of the held ones
+func (f *flowControl) check() []string {
+	f.mu.Lock()
+	conditions := make(map[string]func() bool, len(f.conditions))
+	for name, condition := range f.conditions {
+		conditions[name] = condition
+	}
+	f.mu.Unlock()
+
+	var reasons []string
+	for name, condition := range conditions {
+		if condition() {
+			reasons = append(reasons, name)
+		}
+	}
+	sort.Strings(reasons)
+	return reasons
+}
+
+// AddPauseCondition registers the condition pausing fetching of messages while it holds,
+// e.g. while the downstream circuit breaker is open. Conditions are checked only if backpressure is enabled
+func (c *Consumer) AddPauseCondition(name string, condition func() bool) {
+	c.flow.mu.Lock()
+	defer c.flow.mu.Unlock()
+
+	c.flow.conditions[name] = condition
+}
+
+// Pause pauses fetching of messages until Resume is called
+func (c *Consumer) Pause() {
+	c.flow.mu.Lock()
+	defer c.flow.mu.Unlock()
+
+	if c.flow.update(true, c.flow.reasons) {
+		c.logger.Warn().Msg("Kafka consumer paused manually")
+	}
+}
+
+// Resume resumes fetching paused by Pause. Fetching stays paused while any pause condition holds
+func (c *Consumer) Resume() {
+	c.flow.mu.Lock()
+	defer c.flow.mu.Unlock()
+
+	if c.flow.update(false, c.flow.reasons) {
+		c.logger.Info().Msg("Kafka consumer resumed manually")
+	}
+}
+
+// Status returns the state of the consumer
+func (c *Consumer) Status() models.ConsumerStatus {
+	c.mu.RLock()
+	status := models.ConsumerStatus{
+		Running:       c.running,
+		Topics:        append([]string(nil), c.topics...),
+		GroupID:       c.config.GroupID,
+		OffsetStorage: c.config.OffsetStorage,
+	}
+	c.mu.RUnlock()
+
+	c.flow.mu.Lock()
+	status.Paused = c.flow.paused()
+	status.PausedManually = c.flow.manual
+	status.PauseReasons = append([]string(nil), c.flow.reasons...)
+	c.flow.mu.Unlock()
+
+	status.Replays = c.replaySnapshots()
+
+	return status
+}
+
+// monitorBackpressure checks pause conditions until the context is done,
+// fetching is paused while any condition holds and resumed automatically when all of them clear.
+// Conditions pause replays too, Pause and Resume affect only live consumption
+func (c *Consumer) monitorBackpressure(ctx context.Context) {
+	interval := c.config.Backpressure.CheckInterval
+	if interval <= 0 {
+		interval = defaultCheckInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		reasons := c.flow.check()
+
+		c.flow.mu.Lock()
+		previous := c.flow.reasons
+		changed := c.flow.update(c.flow.manual, reasons)
+		c.flow.mu.Unlock()
+
+		c.replayFlow.mu.Lock()
+		c.replayFlow.update(false, reasons)
+		c.replayFlow.mu.Unlock()
+
+		switch {
+		case len(reasons) > 0 && len(previous) == 0:
+			c.logger.Warn().Strs("reasons", reasons).Msg("Kafka consumer paused by backpressure")
+		case len(reasons) == 0 && len(previous) > 0:
+			c.logger.Info().Bool("fetching", changed).Msg("Backpressure cleared, Kafka consumer resumed")
+		}
+	}
+}
+
+// registerFlowMetrics registers the gauge of the paused state
+func (c *Consumer) registerFlowMetrics() {
+	metrics.DefaultRegistry.GaugeFunc(
+		"kafka_consumer_paused", "Whether fetching of Kafka messages is paused",
+		func() int64 {
+			c.flow.mu.Lock()
+			defer c.flow.mu.Unlock()
+			if c.flow.paused() {
+				return 1
+			}
+			return 0
+		},
+	)
+}
@@ -0,0 +1,170 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"l0/internal/config"
+)
+
+func TestFlowControl_Wait(t *testing.T) {
+	flow := newFlowControl()
+	if !flow.wait(context.Background(), nil) {
+		t.Errorf("error: expected wait to return immediately while running")
+	}
+
+	flow.mu.Lock()
+	flow.update(true, nil)
+	flow.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if flow.wait(ctx, nil) {
+		t.Errorf("error: expected wait to block while paused")
+	}
+
+	stop := make(chan struct{})
+	close(stop)
+	if flow.wait(context.Background(), stop) {
+		t.Errorf("error: expected wait to return false when the consumer is stopped")
+	}
+
+	result := make(chan bool)
+	go func() {
+		result <- flow.wait(context.Background(), nil)
+	}()
+	flow.mu.Lock()
+	flow.update(false, nil)
+	flow.mu.Unlock()
+
+	select {
+	case ok := <-result:
+		if !ok {
+			t.Errorf("error: expected wait to return true after resume")
+		}
+	case <-time.After(time.Second):
+		t.Errorf("error: expected wait to be woken up after resume")
+	}
+}
+
+func TestConsumer_PauseResume(t *testing.T) {
+	consumer := newTestConsumer(t, config.KafkaConfig{GroupID: "orders-service"}, &mockProcessor{})
+
+	consumer.Pause()
+	status := consumer.Status()
+	if !status.Paused || !status.PausedManually {
+		t.Errorf("error: expected consumer to be paused manually, got %+v", status)
+	}
+	if !consumer.replayFlow.wait(context.Background(), nil) {
+		t.Errorf("error: expected replays to keep running while the consumer is paused manually")
+	}
+
+	consumer.Resume()
+	status = consumer.Status()
+	if status.Paused || status.PausedManually {
+		t.Errorf("error: expected consumer to be resumed, got %+v", status)
+	}
+	if status.GroupID != "orders-service" {
+		t.Errorf("error: expected group orders-service, got %q", status.GroupID)
+	}
+}
+
+func TestConsumer_MonitorBackpressure(t *testing.T) {
+	cfg := config.KafkaConfig{
+		Backpressure: config.BackpressureConfig{Enabled: true, CheckInterval: 10 * time.Millisecond},
+	}
+	consumer := newTestConsumer(t, cfg, &mockProcessor{})
+
+	var open atomic.Bool
+	open.Store(true)
+	consumer.AddPauseCondition("circuit_open", open.Load)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go consumer.monitorBackpressure(ctx)
+
+	waitStatus := func(paused bool) {
+		deadline := time.Now().Add(time.Second)
+		for time.Now().Before(deadline) {
+			if consumer.Status().Paused == paused {
+				return
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+		t.Errorf("error: expected paused to be %v", paused)
+	}
+
+	waitStatus(true)
+	if reasons := consumer.Status().PauseReasons; len(reasons) != 1 || reasons[0] != "circuit_open" {
+		t.Errorf("error: expected pause reason circuit_open, got %v", reasons)
+	}
+	consumer.replayFlow.mu.Lock()
+	replayPaused := consumer.replayFlow.paused()
+	consumer.replayFlow.mu.Unlock()
+	if !replayPaused {
+		t.Errorf("error: expected replays to be paused while the condition holds")
+	}
+
+	// Manual resume doesn't override a held condition
+	consumer.Resume()
+	if !consumer.Status().Paused {
+		t.Errorf("error: expected consumer to stay paused while the condition holds")
+	}
+
+	open.Store(false)
+	waitStatus(false)
+}
+
+func TestConsumer_SeekWithoutGroup(t *testing.T) {
+	consumer := newTestConsumer(t, config.KafkaConfig{}, &mockProcessor{})
+
+	err := consumer.Seek(context.Background(), "orders", 0, 10)
+	if !errors.Is(err, ErrNoConsumerGroup) {
+		t.Errorf("error: expected ErrNoConsumerGroup, got %v", err)
+	}
+}
+
+func TestConsumer_ResetOffsetsRestartsAfterCancel(t *testing.T) {
+	cfg := config.KafkaConfig{
+		Listeners: "127.0.0.1:1",
+		GroupID:   "orders-service",
+		Topics:    []config.TopicConfig{{Name: "orders", Handler: HandlerOrders}},
+	}
+	consumer := newTestConsumer(t, cfg, &mockProcessor{})
+	if err := consumer.resolveTopics(context.Background(), nil); err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	// The consumer looks running with consumption which doesn't finish until done is closed
+	startCtx, stopConsumer := context.WithCancel(context.Background())
+	defer stopConsumer()
+	done := make(chan struct{})
+	consumer.running, consumer.startCtx = true, startCtx
+	consumer.stop, consumer.done = make(chan struct{}), done
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := consumer.ResetOffsets(ctx, "orders", map[int]int64{0: 10}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("error: expected the reset to be cancelled, got %v", err)
+	}
+	if consumer.Status().Running {
+		t.Fatalf("error: expected the consumer to be stopped while consumption finishes")
+	}
+
+	close(done)
+	deadline := time.Now().Add(2 * time.Second)
+	for !consumer.Status().Running && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !consumer.Status().Running {
+		t.Errorf("error: expected the consumer to be restarted after the cancelled reset")
+	}
+
+	stopConsumer()
+	if err := consumer.Stop(context.Background()); err != nil {
+		t.Errorf("error: %v", err)
+	}
+}
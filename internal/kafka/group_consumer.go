@@ -0,0 +1,262 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/avast/retry-go/v4"
+	"github.com/segmentio/kafka-go"
+
+	"l0/internal/offsets"
+)
+
+// Delays between retries of failed reads, they double with every failure up to the maximum
+const (
+	offsetsRetryDelay    = 500 * time.Millisecond
+	maxOffsetsRetryDelay = 30 * time.Second
+	fetchRetryDelay      = 100 * time.Millisecond
+	maxFetchRetryDelay   = 10 * time.Second
+)
+
+// startGroup starts consumption with offsets stored in Postgres. Kafka is used only to assign partitions
+// to group members, every assigned partition is read from the offset stored with the last processed order
+func (c *Consumer) startGroup(ctx context.Context) error {
+	if c.offsetStore == nil {
+		return errors.New("offset store is required to keep offsets in Postgres")
+	}
+	if c.config.GroupID == "" {
+		return errors.New("Kafka GroupID is required to keep offsets in Postgres")
+	}
+
+	group, err := kafka.NewConsumerGroup(
+		kafka.ConsumerGroupConfig{
+			ID:          c.config.GroupID,
+			Brokers:     c.brokers,
+			Dialer:      c.dialer,
+			Topics:      c.topics,
+			StartOffset: c.startOffset(kafka.FirstOffset),
+			ErrorLogger: kafka.LoggerFunc(
+				func(msg string, args ...interface{}) {
+					c.logger.Error().
+						Str("kafka_error", fmt.Sprintf(msg, args...)).
+						Msg("kafka consumer group error")
+				},
+			),
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create consumer group: %w", err)
+	}
+
+	c.group = group
+	c.running = true
+
+	go func(done chan struct{}) {
+		defer close(done)
+		c.consumeGroup(ctx, group)
+	}(c.done)
+
+	return nil
+}
+
+// consumeGroup starts partition consumers for every generation of the consumer group
+func (c *Consumer) consumeGroup(ctx context.Context, group *kafka.ConsumerGroup) {
+	for {
+		gen, err := group.Next(ctx)
+		if err != nil {
+			if ctx.Err() != nil || errors.Is(err, kafka.ErrGroupClosed) {
+				return
+			}
+			c.logger.Error().Err(err).Msg("Failed to join Kafka consumer group")
+			time.Sleep(time.Second)
+			continue
+		}
+
+		for topic, assignments := range gen.Assignments {
+			gen.Start(
+				func(genCtx context.Context) {
+					topicCtx, cancel := context.WithCancel(ctx)
+					defer cancel()
+					stop := context.AfterFunc(genCtx, cancel)
+					defer stop()
+
+					c.consumeAssignments(topicCtx, gen.ID, gen.GroupID, topic, assignments)
+				},
+			)
+		}
+	}
+}
+
+// consumeAssignments consumes assigned partitions of the topic until the generation ends.
+// Partitions are started only after stored offsets are loaded, so none of them is read from a wrong offset
+func (c *Consumer) consumeAssignments(
+	ctx context.Context, generationID int32, groupID, topic string, assignments []kafka.PartitionAssignment,
+) {
+	partitionOffsets, err := c.assignedOffsets(ctx, topic, assignments)
+	if err != nil {
+		return
+	}
+
+	var wg sync.WaitGroup
+	for partition, offset := range partitionOffsets {
+		c.logger.Info().
+			Int32("generation", generationID).
+			Str("topic", topic).
+			Int("partition", partition).
+			Int64("offset", offset).
+			Msg("Partition assigned")
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.consumePartition(ctx, groupID, topic, partition, offset)
+		}()
+	}
+	wg.Wait()
+}
+
+// assignedOffsets returns start offsets of assigned partitions, stored offsets take precedence over
+// offsets committed to Kafka. Loading is retried until it succeeds or the context is done
+func (c *Consumer) assignedOffsets(
+	ctx context.Context, topic string, assignments []kafka.PartitionAssignment,
+) (map[int]int64, error) {
+	var stored map[int]int64
+	err := retry.Do(
+		func() error {
+			var err error
+			stored, err = c.offsetStore.GetConsumerOffsets(ctx, c.config.GroupID, topic)
+			return err
+		},
+		retry.Attempts(0),
+		retry.Delay(offsetsRetryDelay),
+		retry.MaxDelay(maxOffsetsRetryDelay),
+		retry.DelayType(retry.BackOffDelay),
+		retry.LastErrorOnly(true),
+		retry.Context(ctx),
+		retry.OnRetry(
+			func(attempt uint, err error) {
+				c.logger.Error().
+					Err(err).
+					Str("topic", topic).
+					Uint("attempt", attempt+1).
+					Msg("Failed to load stored consumer offsets, partitions aren't consumed until they are loaded")
+			},
+		),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	partitionOffsets := make(map[int]int64, len(assignments))
+	for _, assignment := range assignments {
+		partitionOffsets[assignment.ID] = assignment.Offset
+		if offset, ok := stored[assignment.ID]; ok {
+			partitionOffsets[assignment.ID] = offset
+		}
+	}
+	return partitionOffsets, nil
+}
+
+// consumePartition reads one partition of the topic from the offset until the context is done.
+// Offsets of processed orders are stored by the repository in the order transaction,
+// offsets of failed messages are stored after they are sent to the dead letter queue
+func (c *Consumer) consumePartition(ctx context.Context, groupID, topic string, partition int, offset int64) {
+	reader := kafka.NewReader(
+		kafka.ReaderConfig{
+			Brokers:   c.brokers,
+			Dialer:    c.dialer,
+			Topic:     topic,
+			Partition: partition,
+			MinBytes:  10e3,
+			MaxBytes:  10e6,
+			MaxWait:   time.Second,
+			ErrorLogger: kafka.LoggerFunc(
+				func(msg string, args ...interface{}) {
+					c.logger.Error().
+						Str("kafka_error", fmt.Sprintf(msg, args...)).
+						Int("partition", partition).
+						Msg("kafka reader error")
+				},
+			),
+		},
+	)
+	defer func() {
+		if err := reader.Close(); err != nil {
+			c.logger.Error().Err(err).Int("partition", partition).Msg("Error closing Kafka partition reader")
+		}
+	}()
+
+	if err := reader.SetOffset(offset); err != nil {
+		c.logger.Error().Err(err).Int("partition", partition).Msg("Failed to seek partition")
+		return
+	}
+
+	backoff := fetchRetryDelay
+	for {
+		if !c.flow.wait(ctx, nil) {
+			return
+		}
+
+		message, err := reader.FetchMessage(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			c.logger.Error().
+				Err(err).
+				Int("partition", partition).
+				Dur("backoff", backoff).
+				Msg("Error fetching Kafka message")
+			if !sleep(ctx, backoff) {
+				return
+			}
+			backoff = min(2*backoff, maxFetchRetryDelay)
+			continue
+		}
+		backoff = fetchRetryDelay
+
+		position := offsets.Position{
+			GroupID:   groupID,
+			Topic:     message.Topic,
+			Partition: message.Partition,
+			Offset:    message.Offset,
+		}
+		if err := c.handleMessage(offsets.WithPosition(ctx, position), message); err == nil {
+			continue
+		}
+
+		storeErr := retry.Do(
+			func() error {
+				return c.offsetStore.StoreConsumerOffset(ctx, position)
+			},
+			retry.Attempts(5),
+			retry.Delay(500*time.Millisecond),
+			retry.DelayType(retry.BackOffDelay),
+			retry.Context(ctx),
+		)
+		if storeErr != nil {
+			c.logger.Error().
+				Err(storeErr).
+				Str("topic", message.Topic).
+				Int("partition", message.Partition).
+				Int64("offset", message.Offset).
+				Msg("Failed to store consumer offset after retries")
+		}
+	}
+}
+
+// sleep waits for the duration, it returns false if the context is done first
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
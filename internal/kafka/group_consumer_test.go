@@ -0,0 +1,82 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+
+	"l0/internal/config"
+	"l0/internal/offsets"
+)
+
+// A mockOffsetStore is a mock implementation of OffsetStore failing the first reads for testing
+type mockOffsetStore struct {
+	mu       sync.Mutex
+	offsets  map[int]int64
+	failures int
+	reads    int
+}
+
+func (m *mockOffsetStore) GetConsumerOffsets(ctx context.Context, groupID, topic string) (map[int]int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.reads++
+	if m.failures < 0 || m.reads <= m.failures {
+		return nil, errors.New("database is unavailable")
+	}
+	return m.offsets, nil
+}
+
+func (m *mockOffsetStore) StoreConsumerOffset(ctx context.Context, position offsets.Position) error {
+	return nil
+}
+
+func (m *mockOffsetStore) ResetConsumerOffsets(
+	ctx context.Context, groupID, topic string, partitionOffsets map[int]int64,
+) error {
+	return nil
+}
+
+func TestConsumer_AssignedOffsets(t *testing.T) {
+	store := &mockOffsetStore{offsets: map[int]int64{0: 42, 5: 7}, failures: 1}
+	consumer := newTestConsumer(t, config.KafkaConfig{GroupID: "orders-service"}, &mockProcessor{})
+	consumer.offsetStore = store
+
+	assignments := []kafka.PartitionAssignment{{ID: 0, Offset: 10}, {ID: 1, Offset: kafka.FirstOffset}}
+	partitionOffsets, err := consumer.assignedOffsets(context.Background(), "orders", assignments)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	if store.reads != 2 {
+		t.Errorf("error: expected the failed read to be retried, got %d reads", store.reads)
+	}
+	if len(partitionOffsets) != 2 || partitionOffsets[0] != 42 || partitionOffsets[1] != kafka.FirstOffset {
+		t.Errorf("error: expected stored offsets to take precedence for assigned partitions, got %v", partitionOffsets)
+	}
+}
+
+func TestConsumer_AssignedOffsetsUnavailable(t *testing.T) {
+	store := &mockOffsetStore{failures: -1}
+	consumer := newTestConsumer(t, config.KafkaConfig{GroupID: "orders-service"}, &mockProcessor{})
+	consumer.offsetStore = store
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	partitionOffsets, err := consumer.assignedOffsets(ctx, "orders", []kafka.PartitionAssignment{{ID: 0}})
+	if err == nil || partitionOffsets != nil {
+		t.Errorf("error: expected no partitions to start without stored offsets, got %v", partitionOffsets)
+	}
+	if ctx.Err() == nil {
+		t.Errorf("error: expected loading to be retried until the context is done")
+	}
+	if store.reads < 2 {
+		t.Errorf("error: expected the failed read to be retried, got %d reads", store.reads)
+	}
+}
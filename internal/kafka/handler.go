@@ -0,0 +1,180 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/segmentio/kafka-go"
+
+	"l0/internal/config"
+)
+
+// Names of message handlers used in the topic configuration
+const (
+	HandlerOrders         = "orders"
+	HandlerPaymentUpdates = "payment_updates"
+)
+
+// A Handler handles messages of the topics it's subscribed to. If offsets are stored in Postgres,
+// the handler has to store the position from the context in the same transaction as its changes
+type Handler interface {
+	Handle(ctx context.Context, message kafka.Message) error
+}
+
+// A HandlerFunc is an adapter to use functions as handlers
+type HandlerFunc func(ctx context.Context, message kafka.Message) error
+
+// Handle calls f(ctx, message)
+func (f HandlerFunc) Handle(ctx context.Context, message kafka.Message) error {
+	return f(ctx, message)
+}
+
+// A DeadLetterError is a handler error with the reason the message is sent to the dead letter queue.
+// Other handler errors are sent with the processing_error reason
+type DeadLetterError struct {
+	Reason string
+	Err    error
+}
+
+// NewDeadLetterError creates a new error with the dead letter queue reason
+func NewDeadLetterError(reason string, err error) *DeadLetterError {
+	return &DeadLetterError{Reason: reason, Err: err}
+}
+
+func (e *DeadLetterError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Reason, e.Err)
+}
+
+func (e *DeadLetterError) Unwrap() error {
+	return e.Err
+}
+
+// deadLetterReason returns the dead letter queue reason of the handler error
+func deadLetterReason(err error) string {
+	var deadLetterErr *DeadLetterError
+	if errors.As(err, &deadLetterErr) {
+		return deadLetterErr.Reason
+	}
+	return ReasonProcessingError
+}
+
+// RegisterHandler registers the handler by the name used in the topic configuration.
+// Handlers have to be registered before the consumer starts
+func (c *Consumer) RegisterHandler(name string, handler Handler) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.handlers[name] = handler
+}
+
+// subscriptions returns configured topics, the orders topic is used if topics are not configured
+func (c *Consumer) subscriptions() []config.TopicConfig {
+	if len(c.config.Topics) > 0 {
+		return c.config.Topics
+	}
+	return []config.TopicConfig{{Name: c.config.Topic, Handler: HandlerOrders}}
+}
+
+// resolveTopics maps topics to their handlers. Patterns are matched against existing topics,
+// a topic configured by name takes precedence over patterns, the first matching pattern wins
+func (c *Consumer) resolveTopics(ctx context.Context, listTopics func(context.Context) ([]string, error)) error {
+	routes := make(map[string]Handler)
+	var existing []string
+	listed := false
+
+	subscriptions := c.subscriptions()
+	for _, subscription := range subscriptions {
+		if subscription.Name == "" {
+			continue
+		}
+		handler, ok := c.handlers[subscription.Handler]
+		if !ok {
+			return fmt.Errorf("unknown handler %q for topic %q", subscription.Handler, subscription.Name)
+		}
+		routes[subscription.Name] = handler
+	}
+
+	for _, subscription := range subscriptions {
+		if subscription.Pattern == "" {
+			continue
+		}
+		handler, ok := c.handlers[subscription.Handler]
+		if !ok {
+			return fmt.Errorf("unknown handler %q for topic pattern %q", subscription.Handler, subscription.Pattern)
+		}
+		pattern, err := regexp.Compile(subscription.Pattern)
+		if err != nil {
+			return fmt.Errorf("invalid topic pattern %q: %w", subscription.Pattern, err)
+		}
+
+		if !listed {
+			if existing, err = listTopics(ctx); err != nil {
+				return fmt.Errorf("failed to list topics: %w", err)
+			}
+			listed = true
+		}
+
+		matched := 0
+		for _, topic := range existing {
+			if _, ok := routes[topic]; !ok && pattern.MatchString(topic) {
+				routes[topic] = handler
+				matched++
+			}
+		}
+		if matched == 0 {
+			c.logger.Warn().Str("pattern", subscription.Pattern).Msg("No topics match the subscription pattern")
+		}
+	}
+
+	if len(routes) == 0 {
+		return errors.New("no topics to consume")
+	}
+
+	c.routes = routes
+	c.topics = make([]string, 0, len(routes))
+	for topic := range routes {
+		c.topics = append(c.topics, topic)
+	}
+	sort.Strings(c.topics)
+
+	return nil
+}
+
+// listTopics returns names of existing topics except internal ones
+func (c *Consumer) listTopics(ctx context.Context) ([]string, error) {
+	dialer := c.dialer
+	if dialer == nil {
+		dialer = kafka.DefaultDialer
+	}
+
+	var lastErr error
+	for _, broker := range c.brokers {
+		conn, err := dialer.DialContext(ctx, "tcp", broker)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		partitions, err := conn.ReadPartitions()
+		_ = conn.Close()
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		seen := make(map[string]bool)
+		var topics []string
+		for _, partition := range partitions {
+			if !seen[partition.Topic] && !strings.HasPrefix(partition.Topic, "__") {
+				seen[partition.Topic] = true
+				topics = append(topics, partition.Topic)
+			}
+		}
+		return topics, nil
+	}
+
+	return nil, lastErr
+}
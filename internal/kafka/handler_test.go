@@ -0,0 +1,230 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/segmentio/kafka-go"
+
+	"l0/internal/codec"
+	"l0/internal/config"
+	"l0/internal/models"
+	"l0/internal/schemaregistry"
+)
+
+// A mockProcessor records processed orders and payments
+type mockProcessor struct {
+	orders   []*models.Order
+	payments []*models.Payment
+	err      error
+}
+
+func (m *mockProcessor) ProcessOrder(ctx context.Context, order *models.Order) error {
+	m.orders = append(m.orders, order)
+	return m.err
+}
+
+func (m *mockProcessor) UpdatePayment(ctx context.Context, payment *models.Payment) error {
+	m.payments = append(m.payments, payment)
+	return m.err
+}
+
+func newTestConsumer(t *testing.T, cfg config.KafkaConfig, processor *mockProcessor) *Consumer {
+	logger := zerolog.New(os.Stdout)
+	consumer := NewConsumer(config.Config{Kafka: cfg}, processor, nil, &logger)
+	consumer.RegisterHandler(HandlerPaymentUpdates, NewPaymentUpdateHandler(processor, &logger))
+	return consumer
+}
+
+// deadLetters returns the number of dead letter messages with the reason
+func deadLetters(consumer *Consumer, reason string) int {
+	messages, _ := consumer.deadLetterQueue.(*InMemoryDeadLetterQueue).GetByReason(reason, 100)
+	return len(messages)
+}
+
+func TestConsumer_ResolveTopics(t *testing.T) {
+	cfg := config.KafkaConfig{
+		Topics: []config.TopicConfig{
+			{Name: "orders", Handler: HandlerOrders},
+			{Pattern: `^orders\..+$`, Handler: HandlerPaymentUpdates},
+			{Pattern: `^payment-updates(-.+)?$`, Handler: HandlerPaymentUpdates},
+		},
+	}
+	consumer := newTestConsumer(t, cfg, &mockProcessor{})
+
+	listTopics := func(context.Context) ([]string, error) {
+		return []string{"orders", "orders.eu", "payment-updates", "payment-updates-eu", "other"}, nil
+	}
+	if err := consumer.resolveTopics(context.Background(), listTopics); err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	expected := []string{"orders", "orders.eu", "payment-updates", "payment-updates-eu"}
+	if len(consumer.topics) != len(expected) {
+		t.Fatalf("error: expected topics %v, got %v", expected, consumer.topics)
+	}
+	for i, topic := range expected {
+		if consumer.topics[i] != topic {
+			t.Errorf("error: expected topics %v, got %v", expected, consumer.topics)
+		}
+	}
+	if _, ok := consumer.routes["orders"].(*OrderHandler); !ok {
+		t.Errorf("error: expected orders topic to be handled by the orders handler")
+	}
+	if _, ok := consumer.routes["orders.eu"].(*PaymentUpdateHandler); !ok {
+		t.Errorf("error: expected orders.eu topic to be handled by the pattern handler")
+	}
+}
+
+func TestConsumer_ResolveTopicsErrors(t *testing.T) {
+	listTopics := func(context.Context) ([]string, error) {
+		return nil, errors.New("broker is not available")
+	}
+
+	consumer := newTestConsumer(t, config.KafkaConfig{Topic: "orders"}, &mockProcessor{})
+	if err := consumer.resolveTopics(context.Background(), listTopics); err != nil {
+		t.Errorf("error: expected topics configured by name not to be listed, got %v", err)
+	}
+	if len(consumer.topics) != 1 || consumer.topics[0] != "orders" {
+		t.Errorf("error: expected the orders topic by default, got %v", consumer.topics)
+	}
+
+	consumer = newTestConsumer(
+		t, config.KafkaConfig{Topics: []config.TopicConfig{{Name: "orders", Handler: "cancellations"}}},
+		&mockProcessor{},
+	)
+	if err := consumer.resolveTopics(context.Background(), listTopics); err == nil {
+		t.Errorf("error: expected error for unknown handler")
+	}
+
+	consumer = newTestConsumer(
+		t, config.KafkaConfig{Topics: []config.TopicConfig{{Pattern: "^orders", Handler: HandlerOrders}}},
+		&mockProcessor{},
+	)
+	if err := consumer.resolveTopics(context.Background(), listTopics); err == nil {
+		t.Errorf("error: expected error when topics can't be listed")
+	}
+}
+
+func TestConsumer_HandleMessage(t *testing.T) {
+	cfg := config.KafkaConfig{
+		Topics: []config.TopicConfig{
+			{Name: "orders", Handler: HandlerOrders},
+			{Name: "payment-updates", Handler: HandlerPaymentUpdates},
+		},
+	}
+	processor := &mockProcessor{}
+	consumer := newTestConsumer(t, cfg, processor)
+	if err := consumer.resolveTopics(context.Background(), nil); err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	ctx := context.Background()
+
+	payment := []byte(`{"transaction":"order1","currency":"USD","provider":"wbpay","amount":100,"goods_total":100}`)
+	if err := consumer.handleMessage(ctx, kafka.Message{Topic: "payment-updates", Value: payment}); err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if len(processor.payments) != 1 || processor.payments[0].Transaction != "order1" {
+		t.Errorf("error: expected payment update to be processed, got %v", processor.payments)
+	}
+
+	tests := []struct {
+		reason  string
+		message kafka.Message
+	}{
+		{ReasonUnknownTopic, kafka.Message{Topic: "unknown", Value: payment}},
+		{ReasonUnknownSchemaVersion, kafka.Message{Topic: "orders", Value: []byte(`{"schema_version":7,"payload":{}}`)}},
+		{ReasonJSONUnmarshalError, kafka.Message{Topic: "orders", Value: []byte(`{"order_uid":`)}},
+		{ReasonValidationError, kafka.Message{Topic: "orders", Value: []byte(`{"order_uid":"order1"}`)}},
+		{ReasonValidationError, kafka.Message{Topic: "payment-updates", Value: []byte(`{"transaction":"order1"}`)}},
+		{
+			ReasonUnknownFormat, kafka.Message{
+				Topic: "orders", Value: []byte(`order1`),
+				Headers: []kafka.Header{{Key: codec.ContentTypeHeader, Value: []byte("text/plain")}},
+			},
+		},
+	}
+	for _, test := range tests {
+		before := deadLetters(consumer, test.reason)
+		if err := consumer.handleMessage(ctx, test.message); err == nil {
+			t.Errorf("error: expected error for %s", test.reason)
+		}
+		if deadLetters(consumer, test.reason) != before+1 {
+			t.Errorf("error: expected message to be sent to the dead letter queue once with reason %s", test.reason)
+		}
+	}
+	if deadLetters(consumer, ReasonProcessingError) != 0 {
+		t.Errorf("error: expected no processing errors")
+	}
+
+	processor.err = errors.New("database is not available")
+	if err := consumer.handleMessage(ctx, kafka.Message{Topic: "payment-updates", Value: payment}); err == nil {
+		t.Errorf("error: expected processing error")
+	}
+	if deadLetters(consumer, ReasonProcessingError) != 1 {
+		t.Errorf("error: expected processing error in the dead letter queue")
+	}
+}
+
+func TestOrderHandler_DecodeEnvelope(t *testing.T) {
+	logger := zerolog.New(os.Stdout)
+	decoders, _ := codec.NewDecoders(codec.FormatJSON, nil)
+	handler := NewOrderHandler(&mockProcessor{}, decoders, &logger)
+
+	value := []byte(`{"schema_version":1,"payload":{"order_uid":"order1","track_number":"TRACK1"}}`)
+	order, err := handler.decodeMessage(context.Background(), kafka.Message{Value: value})
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if order.OrderUID != "order1" || order.SchemaVersion != "json:1" {
+		t.Errorf("error: unexpected order %+v", order)
+	}
+
+	legacy := []byte(`{"order_uid":"order2","shardkey":"9"}`)
+	order, err = handler.decodeMessage(context.Background(), kafka.Message{Value: legacy})
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if order.Shardkey != "9" || order.SchemaVersion != "json:1" {
+		t.Errorf("error: expected the legacy payload to be upcast, got %+v", order)
+	}
+}
+
+func TestOrderHandler_RegistryUnavailable(t *testing.T) {
+	var status atomic.Int32
+	status.Store(http.StatusServiceUnavailable)
+	server := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(int(status.Load()))
+			},
+		),
+	)
+	defer server.Close()
+
+	logger := zerolog.New(os.Stdout)
+	decoders, _ := codec.NewDecoders(codec.FormatJSON, schemaregistry.NewClient(server.URL, time.Second))
+	handler := NewOrderHandler(&mockProcessor{}, decoders, &logger)
+	message := kafka.Message{
+		Value:   []byte{0, 0, 0, 0, 1, 2},
+		Headers: []kafka.Header{{Key: codec.ContentTypeHeader, Value: []byte(codec.ContentType(codec.FormatAvro))}},
+	}
+
+	_, err := handler.decodeMessage(context.Background(), message)
+	if err == nil || deadLetterReason(err) != ReasonProcessingError {
+		t.Errorf("error: expected the message to be retried while the registry is not available, got %v", err)
+	}
+
+	status.Store(http.StatusNotFound)
+	_, err = handler.decodeMessage(context.Background(), message)
+	if deadLetterReason(err) != ReasonDecodeError {
+		t.Errorf("error: expected decode error for the unknown schema, got %v", err)
+	}
+}
@@ -0,0 +1,155 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/segmentio/kafka-go"
+
+	"l0/internal/codec"
+	"l0/internal/envelope"
+	"l0/internal/interfaces"
+	"l0/internal/models"
+	"l0/internal/schemaregistry"
+)
+
+// An OrderHandler decodes, validates and processes orders
+type OrderHandler struct {
+	processor interfaces.OrderProcessor
+	decoders  *codec.Decoders
+	upcasters *envelope.UpcasterChain
+	logger    *zerolog.Logger
+}
+
+// NewOrderHandler creates a new handler of orders decoded by decoders
+func NewOrderHandler(processor interfaces.OrderProcessor, decoders *codec.Decoders, logger *zerolog.Logger) *OrderHandler {
+	return &OrderHandler{
+		processor: processor,
+		decoders:  decoders,
+		upcasters: envelope.DefaultUpcasters(),
+		logger:    logger,
+	}
+}
+
+// Handle decodes the order in the format selected by the content-type header, validates and processes it
+func (h *OrderHandler) Handle(ctx context.Context, message kafka.Message) error {
+	start := time.Now()
+
+	order, err := h.decodeMessage(ctx, message)
+	if err != nil {
+		return err
+	}
+
+	if err := order.Validate(); err != nil {
+		h.logger.Error().
+			Err(err).
+			Str("order_uid", order.OrderUID).
+			Str("topic", message.Topic).
+			Int64("offset", message.Offset).
+			Msg("Order validation failed")
+		return NewDeadLetterError(ReasonValidationError, fmt.Errorf("order validation failed: %w", err))
+	}
+
+	processCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	if err := h.processor.ProcessOrder(processCtx, order); err != nil {
+		h.logger.Error().
+			Err(err).
+			Str("order_uid", order.OrderUID).
+			Str("topic", message.Topic).
+			Int64("offset", message.Offset).
+			Dur("duration", time.Since(start)).
+			Msg("Failed to process order")
+
+		return fmt.Errorf("failed to process order: %w", err)
+	}
+
+	return nil
+}
+
+// decodeMessage decodes the order and logs undecodable messages
+func (h *OrderHandler) decodeMessage(ctx context.Context, message kafka.Message) (*models.Order, error) {
+	contentType := messageHeader(message, codec.ContentTypeHeader)
+
+	order, reason, err := h.decode(ctx, contentType, message.Value)
+	if err == nil {
+		return order, nil
+	}
+
+	event := h.logger.Error().
+		Err(err).
+		Str("topic", message.Topic).
+		Int64("offset", message.Offset).
+		Str("content_type", contentType).
+		Str("reason", reason)
+	if reason == ReasonJSONUnmarshalError {
+		event = event.Str("raw_message", string(message.Value))
+	}
+	event.Msg("Failed to decode order")
+
+	err = fmt.Errorf("failed to decode order: %w", err)
+	if reason == ReasonProcessingError {
+		// The message is retried, it can be decoded when the schema registry is available again
+		return nil, err
+	}
+	return nil, NewDeadLetterError(reason, err)
+}
+
+// decode decodes the order and returns the dead letter queue reason if it failed.
+// JSON payloads are unwrapped from the envelope and upcasted to the current version.
+// Failures of the schema registry are processing errors, the message itself may be valid
+func (h *OrderHandler) decode(ctx context.Context, contentType string, value []byte) (*models.Order, string, error) {
+	format, err := h.decoders.Format(contentType)
+	if err != nil {
+		return nil, ReasonUnknownFormat, err
+	}
+
+	if format != codec.FormatJSON {
+		order, err := h.decoders.Decode(ctx, contentType, value)
+		var unavailable *schemaregistry.UnavailableError
+		if errors.As(err, &unavailable) {
+			return nil, ReasonProcessingError, err
+		}
+		if err != nil {
+			return nil, ReasonDecodeError, err
+		}
+		return order, "", nil
+	}
+
+	wrapped, err := envelope.Parse(value)
+	if err != nil {
+		return nil, ReasonUnknownSchemaVersion, err
+	}
+	payload, err := h.upcasters.Upcast(wrapped.SchemaVersion, wrapped.Payload)
+	if err != nil {
+		if errors.Is(err, envelope.ErrUnknownPayloadVersion) {
+			return nil, ReasonUnknownSchemaVersion, err
+		}
+		return nil, ReasonJSONUnmarshalError, err
+	}
+
+	var decoded envelope.OrderPayload
+	if err := json.Unmarshal(payload, &decoded); err != nil {
+		return nil, ReasonJSONUnmarshalError, err
+	}
+	order := decoded.Order
+	order.SchemaVersion = fmt.Sprintf("%s:%d", codec.FormatJSON, wrapped.SchemaVersion)
+
+	return order, "", nil
+}
+
+// messageHeader returns the value of the message header, header keys are case-insensitive
+func messageHeader(message kafka.Message, key string) string {
+	for _, header := range message.Headers {
+		if strings.EqualFold(header.Key, key) {
+			return string(header.Value)
+		}
+	}
+	return ""
+}
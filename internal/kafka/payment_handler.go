@@ -0,0 +1,58 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/segmentio/kafka-go"
+
+	"l0/internal/interfaces"
+	"l0/internal/models"
+)
+
+// A PaymentUpdateHandler handles JSON payment updates of existing orders, payments are found by transaction
+type PaymentUpdateHandler struct {
+	processor interfaces.PaymentProcessor
+	logger    *zerolog.Logger
+}
+
+// NewPaymentUpdateHandler creates a new handler of payment updates
+func NewPaymentUpdateHandler(processor interfaces.PaymentProcessor, logger *zerolog.Logger) *PaymentUpdateHandler {
+	return &PaymentUpdateHandler{processor: processor, logger: logger}
+}
+
+// Handle decodes, validates and applies the payment update
+func (h *PaymentUpdateHandler) Handle(ctx context.Context, message kafka.Message) error {
+	var payment models.Payment
+	if err := json.Unmarshal(message.Value, &payment); err != nil {
+		h.logger.Error().
+			Err(err).
+			Str("topic", message.Topic).
+			Int64("offset", message.Offset).
+			Str("raw_message", string(message.Value)).
+			Msg("Failed to unmarshal payment update JSON")
+		return NewDeadLetterError(ReasonJSONUnmarshalError, fmt.Errorf("failed to unmarshal payment update: %w", err))
+	}
+
+	if err := payment.Validate(); err != nil {
+		h.logger.Error().
+			Err(err).
+			Str("transaction", payment.Transaction).
+			Str("topic", message.Topic).
+			Int64("offset", message.Offset).
+			Msg("Payment update validation failed")
+		return NewDeadLetterError(ReasonValidationError, fmt.Errorf("payment validation failed: %w", err))
+	}
+
+	processCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	if err := h.processor.UpdatePayment(processCtx, &payment); err != nil {
+		return fmt.Errorf("failed to update payment: %w", err)
+	}
+
+	return nil
+}
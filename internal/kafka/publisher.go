@@ -0,0 +1,69 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+
+	"l0/internal/config"
+	"l0/internal/kafka/security"
+	"l0/internal/models"
+)
+
+// An EventPublisher publishes outbox events to a Kafka topic. Events are keyed by the aggregate ID,
+// so events of one order get into one partition and keep their order
+type EventPublisher struct {
+	writer *kafka.Writer
+}
+
+// NewEventPublisher creates a new publisher for the topic on brokers of the Kafka configuration
+func NewEventPublisher(cfg config.KafkaConfig, topic string) (*EventPublisher, error) {
+	addrs := strings.Split(cfg.Listeners, ",")
+	for i, addr := range addrs {
+		addrs[i] = strings.TrimSpace(addr)
+	}
+
+	transport, err := security.NewTransport(cfg.Security)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure Kafka connection: %w", err)
+	}
+
+	return &EventPublisher{
+		writer: &kafka.Writer{
+			Addr:         kafka.TCP(addrs...),
+			Transport:    transport,
+			Topic:        topic,
+			Balancer:     &kafka.Hash{},
+			RequiredAcks: kafka.RequireAll,
+			MaxAttempts:  5,
+			BatchTimeout: 10 * time.Millisecond,
+		},
+	}, nil
+}
+
+// Publish writes events synchronously, it returns an error if any event wasn't written
+func (p *EventPublisher) Publish(ctx context.Context, events []models.OutboxEvent) error {
+	messages := make([]kafka.Message, len(events))
+	for i, event := range events {
+		messages[i] = kafka.Message{
+			Key:   []byte(event.AggregateID),
+			Value: event.Payload,
+			Time:  event.CreatedAt,
+			Headers: []kafka.Header{
+				{Key: "event_type", Value: []byte(event.EventType)},
+				{Key: "event_id", Value: []byte(strconv.FormatInt(event.ID, 10))},
+			},
+		}
+	}
+
+	return p.writer.WriteMessages(ctx, messages...)
+}
+
+// Close flushes pending messages and closes the publisher
+func (p *EventPublisher) Close() error {
+	return p.writer.Close()
+}
@@ -0,0 +1,361 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+
+	"l0/internal/models"
+)
+
+var (
+	ErrReplayRunning    = errors.New("replay is already running in the group")
+	ErrReplayIncomplete = errors.New("replay stopped before the end of the range")
+)
+
+// replayIdleTimeout is the time without fetched messages after which the partition is checked for messages left.
+// If the partition still has messages in the range, the replay of the partition fails
+const replayIdleTimeout = 10 * time.Second
+
+// Replay reprocesses the range of messages of the topic in a separate consumer group and waits until it's done.
+// Live consumption isn't affected: partitions are read directly and offsets are committed only to the replay group
+func (c *Consumer) Replay(ctx context.Context, replay models.Replay) (models.ReplayStatus, error) {
+	status, err := c.planReplay(ctx, replay)
+	if err != nil {
+		return models.ReplayStatus{}, err
+	}
+
+	err = c.runReplay(ctx, status)
+	return c.replaySnapshot(status), err
+}
+
+// StartReplay plans the replay like Replay and runs it in background, the progress is reported by Status.
+// The replay is canceled when the context of the running consumer is done
+func (c *Consumer) StartReplay(ctx context.Context, replay models.Replay) (models.ReplayStatus, error) {
+	status, err := c.planReplay(ctx, replay)
+	if err != nil {
+		return models.ReplayStatus{}, err
+	}
+
+	c.mu.RLock()
+	runCtx := c.startCtx
+	c.mu.RUnlock()
+	if runCtx == nil {
+		runCtx = context.WithoutCancel(ctx)
+	}
+
+	go func() {
+		_ = c.runReplay(runCtx, status)
+	}()
+
+	return c.replaySnapshot(status), nil
+}
+
+// planReplay resolves the group and the ranges of partitions of the replay and registers its status
+func (c *Consumer) planReplay(ctx context.Context, replay models.Replay) (*models.ReplayStatus, error) {
+	if replay.Topic == "" {
+		return nil, errors.New("topic is required")
+	}
+	groupID := replay.GroupID
+	if groupID == "" && c.config.GroupID != "" {
+		groupID = c.config.GroupID + c.config.ReplayGroupSuffix
+	}
+	if groupID == "" || groupID == c.config.GroupID {
+		return nil, errors.New("replay group is required and must differ from the consumer group")
+	}
+
+	c.mu.Lock()
+	var err error
+	if c.dialer == nil {
+		err = c.connect(ctx)
+	}
+	_, subscribed := c.routes[replay.Topic]
+	c.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	if !subscribed {
+		return nil, fmt.Errorf("consumer is not subscribed to topic %q", replay.Topic)
+	}
+
+	from, err := c.replayBound(ctx, replay.Topic, kafka.FirstOffset, replay.FromTime, replay.FromOffsets)
+	if err != nil {
+		return nil, err
+	}
+	to, err := c.replayBound(ctx, replay.Topic, kafka.LastOffset, replay.ToTime, replay.ToOffsets)
+	if err != nil {
+		return nil, err
+	}
+
+	// Partitions are limited to the listed ones if offsets are set
+	listed := make(map[int]bool)
+	for partition := range replay.FromOffsets {
+		listed[partition] = true
+	}
+	for partition := range replay.ToOffsets {
+		listed[partition] = true
+	}
+
+	status := &models.ReplayStatus{
+		GroupID:   groupID,
+		Topic:     replay.Topic,
+		State:     models.ReplayRunning,
+		StartedAt: time.Now(),
+	}
+	for partition, start := range from {
+		end, ok := to[partition]
+		if !ok || start >= end || (len(listed) > 0 && !listed[partition]) {
+			continue
+		}
+		status.Partitions = append(
+			status.Partitions, models.PartitionReplay{Partition: partition, From: start, To: end},
+		)
+	}
+	sort.Slice(
+		status.Partitions, func(i, j int) bool {
+			return status.Partitions[i].Partition < status.Partitions[j].Partition
+		},
+	)
+
+	c.replayMu.Lock()
+	defer c.replayMu.Unlock()
+	if previous, ok := c.replays[groupID]; ok && previous.State == models.ReplayRunning {
+		return nil, fmt.Errorf("%w %q", ErrReplayRunning, groupID)
+	}
+	c.replays[groupID] = status
+
+	return status, nil
+}
+
+// replayBound returns offsets of partitions of the topic at the time or at the default offset,
+// offsets of partitions are overwritten by the set ones
+func (c *Consumer) replayBound(
+	ctx context.Context, topic string, defaultOffset int64, at *time.Time, partitionOffsets map[int]int64,
+) (map[int]int64, error) {
+	offset := defaultOffset
+	if at != nil {
+		offset = at.UnixMilli()
+	}
+
+	result, err := c.topicOffsets(ctx, topic, offset)
+	if err != nil {
+		return nil, err
+	}
+	for partition, offset := range partitionOffsets {
+		if _, ok := result[partition]; !ok {
+			return nil, fmt.Errorf("topic %q has no partition %d", topic, partition)
+		}
+		if offset < 0 {
+			return nil, fmt.Errorf("invalid position %d:%d", partition, offset)
+		}
+		result[partition] = offset
+	}
+	return result, nil
+}
+
+// runReplay reads partitions of the replay in parallel and updates its status
+func (c *Consumer) runReplay(ctx context.Context, status *models.ReplayStatus) error {
+	c.logger.Info().
+		Str("group_id", status.GroupID).
+		Str("topic", status.Topic).
+		Int("partitions", len(status.Partitions)).
+		Msg("Replay started")
+
+	var wg sync.WaitGroup
+	errs := make([]error, len(status.Partitions))
+	for i := range status.Partitions {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = c.replayPartition(ctx, status, i)
+		}(i)
+	}
+	wg.Wait()
+
+	err := errors.Join(errs...)
+
+	c.replayMu.Lock()
+	finishedAt := time.Now()
+	status.FinishedAt = &finishedAt
+	status.State = models.ReplayCompleted
+	if err != nil {
+		status.State = models.ReplayFailed
+		status.Error = err.Error()
+	}
+	c.replayMu.Unlock()
+
+	event := c.logger.Info()
+	if err != nil {
+		event = c.logger.Error().Err(err)
+	}
+	event.
+		Str("group_id", status.GroupID).
+		Str("topic", status.Topic).
+		Dur("duration", finishedAt.Sub(status.StartedAt)).
+		Msg("Replay finished")
+
+	return err
+}
+
+// replayPartition processes messages of the partition range and commits the offset after the last processed one
+// to the replay group. Failed messages are sent to the dead letter queue like in live consumption.
+// The range ends at the high watermark if it's lower than the end offset, so replays never wait for new messages
+func (c *Consumer) replayPartition(ctx context.Context, status *models.ReplayStatus, i int) error {
+	c.replayMu.Lock()
+	partition, from, to := status.Partitions[i].Partition, status.Partitions[i].From, status.Partitions[i].To
+	c.replayMu.Unlock()
+
+	reader := kafka.NewReader(
+		kafka.ReaderConfig{
+			Brokers:   c.brokers,
+			Dialer:    c.dialer,
+			Topic:     status.Topic,
+			Partition: partition,
+			MinBytes:  10e3,
+			MaxBytes:  10e6,
+			MaxWait:   time.Second,
+		},
+	)
+	defer func() {
+		if err := reader.Close(); err != nil {
+			c.logger.Error().Err(err).Int("partition", partition).Msg("Error closing Kafka replay reader")
+		}
+	}()
+
+	if err := reader.SetOffset(from); err != nil {
+		return fmt.Errorf("partition %d: failed to seek: %w", partition, err)
+	}
+
+	highWatermark, err := c.highWatermark(ctx, status.Topic, partition)
+	if err != nil {
+		return fmt.Errorf("partition %d: %w", partition, err)
+	}
+	to = min(to, highWatermark)
+
+	next := from
+	var readErr error
+	for next < to {
+		if !c.replayFlow.wait(ctx, nil) {
+			readErr = ctx.Err()
+			break
+		}
+
+		fetchCtx, cancel := context.WithTimeout(ctx, replayIdleTimeout)
+		message, err := reader.FetchMessage(fetchCtx)
+		cancel()
+		if err != nil {
+			if ctx.Err() == nil && errors.Is(err, context.DeadlineExceeded) {
+				readErr = c.checkReplayIdle(ctx, status.Topic, partition, next, to)
+				if readErr == nil {
+					next = to
+				}
+				break
+			}
+			readErr = err
+			break
+		}
+		if message.Offset >= to {
+			next = to
+			break
+		}
+
+		handleErr := c.handleMessage(ctx, message)
+		next = message.Offset + 1
+
+		c.replayMu.Lock()
+		if handleErr != nil {
+			status.Partitions[i].Failed++
+		} else {
+			status.Partitions[i].Processed++
+		}
+		c.replayMu.Unlock()
+	}
+
+	var commitErr error
+	if next > from {
+		commitErr = c.commitOffsets(
+			context.WithoutCancel(ctx), status.GroupID, status.Topic, map[int]int64{partition: next},
+		)
+	}
+
+	if readErr != nil {
+		readErr = fmt.Errorf("partition %d: stopped at offset %d: %w", partition, next, readErr)
+	}
+	if commitErr != nil {
+		commitErr = fmt.Errorf("partition %d: failed to commit replay offset: %w", partition, commitErr)
+	}
+	return errors.Join(readErr, commitErr)
+}
+
+// checkReplayIdle checks the partition after no messages were fetched from the offset for replayIdleTimeout.
+// It returns nil if the partition has no messages left before the end offset of the range, e.g. they were
+// removed by retention, otherwise fetching stalled and ErrReplayIncomplete is returned
+func (c *Consumer) checkReplayIdle(ctx context.Context, topic string, partition int, next, to int64) error {
+	highWatermark, err := c.highWatermark(ctx, topic, partition)
+	if err != nil {
+		return errors.Join(ErrReplayIncomplete, err)
+	}
+	if next < min(to, highWatermark) {
+		return fmt.Errorf(
+			"%w: no messages fetched for %s, partition has messages up to offset %d",
+			ErrReplayIncomplete, replayIdleTimeout, highWatermark,
+		)
+	}
+
+	c.logger.Info().
+		Int("partition", partition).
+		Int64("offset", next).
+		Int64("to", to).
+		Msg("No messages left in the replay range")
+	return nil
+}
+
+// highWatermark returns the offset after the last message of the partition
+func (c *Consumer) highWatermark(ctx context.Context, topic string, partition int) (int64, error) {
+	highWatermarks, err := c.topicOffsets(ctx, topic, kafka.LastOffset)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read high watermark: %w", err)
+	}
+	highWatermark, ok := highWatermarks[partition]
+	if !ok {
+		return 0, fmt.Errorf("failed to read high watermark: topic %q has no partition %d", topic, partition)
+	}
+	return highWatermark, nil
+}
+
+// replaySnapshot returns a copy of the replay status
+func (c *Consumer) replaySnapshot(status *models.ReplayStatus) models.ReplayStatus {
+	c.replayMu.Lock()
+	defer c.replayMu.Unlock()
+
+	snapshot := *status
+	snapshot.Partitions = append([]models.PartitionReplay(nil), status.Partitions...)
+	return snapshot
+}
+
+// replaySnapshots returns copies of statuses of replays ordered by start time
+func (c *Consumer) replaySnapshots() []models.ReplayStatus {
+	c.replayMu.Lock()
+	statuses := make([]*models.ReplayStatus, 0, len(c.replays))
+	for _, status := range c.replays {
+		statuses = append(statuses, status)
+	}
+	c.replayMu.Unlock()
+
+	sort.Slice(
+		statuses, func(i, j int) bool {
+			return statuses[i].StartedAt.Before(statuses[j].StartedAt)
+		},
+	)
+
+	snapshots := make([]models.ReplayStatus, 0, len(statuses))
+	for _, status := range statuses {
+		snapshots = append(snapshots, c.replaySnapshot(status))
+	}
+	return snapshots
+}
@@ -0,0 +1,64 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"l0/internal/config"
+	"l0/internal/models"
+)
+
+func TestConsumer_ResetGroupValidation(t *testing.T) {
+	consumer := newTestConsumer(t, config.KafkaConfig{GroupID: "orders-service"}, &mockProcessor{})
+
+	_, err := consumer.ResetGroup(context.Background(), models.OffsetReset{Topic: "orders", To: "yesterday"})
+	if !errors.Is(err, ErrUnknownResetStrategy) {
+		t.Errorf("error: expected ErrUnknownResetStrategy, got %v", err)
+	}
+
+	_, err = consumer.ResetGroup(context.Background(), models.OffsetReset{Topic: "orders", To: models.OffsetResetOffsets})
+	if err == nil {
+		t.Errorf("error: expected error for the offsets strategy without offsets")
+	}
+
+	_, err = consumer.ResetGroup(
+		context.Background(), models.OffsetReset{Topic: "orders", To: models.OffsetResetTimestamp},
+	)
+	if err == nil {
+		t.Errorf("error: expected error for the timestamp strategy without timestamp")
+	}
+
+	_, err = consumer.ResetGroup(
+		context.Background(),
+		models.OffsetReset{Topic: "orders", To: models.OffsetResetOffsets, Offsets: map[int]int64{0: -5}},
+	)
+	if err == nil {
+		t.Errorf("error: expected error for a negative offset")
+	}
+
+	consumer = newTestConsumer(t, config.KafkaConfig{}, &mockProcessor{})
+	_, err = consumer.ResetGroup(context.Background(), models.OffsetReset{Topic: "orders", To: models.OffsetResetEarliest})
+	if !errors.Is(err, ErrNoConsumerGroup) {
+		t.Errorf("error: expected ErrNoConsumerGroup, got %v", err)
+	}
+}
+
+func TestConsumer_ReplayGroup(t *testing.T) {
+	consumer := newTestConsumer(t, config.KafkaConfig{GroupID: "orders-service"}, &mockProcessor{})
+
+	_, err := consumer.Replay(context.Background(), models.Replay{Topic: "orders", GroupID: "orders-service"})
+	if err == nil {
+		t.Errorf("error: expected error for the replay in the consumer group")
+	}
+
+	_, err = consumer.Replay(context.Background(), models.Replay{Topic: "orders"})
+	if err == nil {
+		t.Errorf("error: expected error for the replay without a group")
+	}
+
+	_, err = consumer.Replay(context.Background(), models.Replay{GroupID: "orders-replay"})
+	if err == nil {
+		t.Errorf("error: expected error for the replay without a topic")
+	}
+}
@@ -0,0 +1,256 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+
+	"l0/internal/kafka/security"
+	"l0/internal/metrics"
+)
+
+// Headers of messages republished to retry topics
+const (
+	HeaderRetryAttempt      = "retry-attempt"
+	HeaderRetryNotBefore    = "retry-not-before"
+	HeaderRetryError        = "retry-error"
+	HeaderOriginalTopic     = "original-topic"
+	HeaderOriginalPartition = "original-partition"
+	HeaderOriginalOffset    = "original-offset"
+)
+
+// A messageWriter writes messages to Kafka topics set in messages
+type messageWriter interface {
+	WriteMessages(ctx context.Context, messages ...kafka.Message) error
+	Close() error
+}
+
+// startRetries creates the writer of retry topics and starts consumers of retry tiers,
+// the caller has to hold the lock
+func (c *Consumer) startRetries(ctx context.Context) error {
+	if !c.config.RetryTopics.Enabled {
+		return nil
+	}
+
+	if c.retryWriter == nil {
+		transport, err := security.NewTransport(c.config.Security)
+		if err != nil {
+			return fmt.Errorf("failed to configure Kafka connection: %w", err)
+		}
+		c.retryWriter = &kafka.Writer{
+			Addr:         kafka.TCP(c.brokers...),
+			Transport:    transport,
+			Balancer:     &kafka.Hash{},
+			RequiredAcks: kafka.RequireAll,
+			MaxAttempts:  5,
+			BatchTimeout: 10 * time.Millisecond,
+		}
+	}
+
+	for _, tier := range c.config.RetryTopics.Tiers {
+		reader := kafka.NewReader(
+			kafka.ReaderConfig{
+				Brokers:     c.brokers,
+				Dialer:      c.dialer,
+				GroupID:     c.config.GroupID + "-" + tier.Topic,
+				Topic:       tier.Topic,
+				StartOffset: kafka.FirstOffset,
+				MinBytes:    1,
+				MaxBytes:    10e6,
+				MaxWait:     time.Second,
+				ErrorLogger: kafka.LoggerFunc(
+					func(msg string, args ...interface{}) {
+						c.logger.Error().
+							Str("kafka_error", fmt.Sprintf(msg, args...)).
+							Str("topic", tier.Topic).
+							Msg("kafka retry reader error")
+					},
+				),
+			},
+		)
+		c.retryReaders = append(c.retryReaders, reader)
+		go c.consumeRetries(ctx, reader, c.stop)
+	}
+	return nil
+}
+
+// stopRetryReaders closes consumers of retry tiers, the caller has to hold the lock
+func (c *Consumer) stopRetryReaders() error {
+	var errs []error
+	for _, reader := range c.retryReaders {
+		if err := reader.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("failed to close Kafka retry reader: %w", err))
+		}
+	}
+	c.retryReaders = nil
+	return errors.Join(errs...)
+}
+
+// stopRetryWriter closes the writer of retry topics once messages can't fail anymore,
+// the caller has to hold the lock
+func (c *Consumer) stopRetryWriter() error {
+	if c.retryWriter == nil {
+		return nil
+	}
+	err := c.retryWriter.Close()
+	c.retryWriter = nil
+	if err != nil {
+		return fmt.Errorf("failed to close Kafka retry writer: %w", err)
+	}
+	return nil
+}
+
+// consumeRetries processes messages of the retry tier after their not-before time until the consumer is stopped.
+// Messages of a tier have the same delay, so waiting for the first message doesn't delay the next ones
+func (c *Consumer) consumeRetries(ctx context.Context, reader *kafka.Reader, stop <-chan struct{}) {
+	for {
+		if !c.flow.wait(ctx, stop) {
+			return
+		}
+
+		message, err := reader.FetchMessage(ctx)
+		if err != nil {
+			if ctx.Err() != nil || errors.Is(err, io.EOF) {
+				return
+			}
+			c.logger.Error().Err(err).Str("topic", reader.Config().Topic).Msg("Error fetching Kafka retry message")
+			time.Sleep(time.Second)
+			continue
+		}
+
+		if delay := time.Until(retryNotBefore(message)); delay > 0 {
+			timer := time.NewTimer(delay)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-stop:
+				timer.Stop()
+				return
+			}
+		}
+
+		_ = c.handleMessage(ctx, originalMessage(message))
+		c.commitMessage(ctx, reader, message)
+	}
+}
+
+// scheduleRetry republishes the failed message to the next retry tier,
+// it returns false if tiers are exhausted or the message wasn't republished
+func (c *Consumer) scheduleRetry(ctx context.Context, message kafka.Message, processErr error) bool {
+	c.mu.RLock()
+	writer := c.retryWriter
+	c.mu.RUnlock()
+
+	attempt := retryAttempt(message)
+	tiers := c.config.RetryTopics.Tiers
+	if writer == nil || attempt >= len(tiers) {
+		return false
+	}
+	tier := tiers[attempt]
+
+	retryMessage := kafka.Message{
+		Topic:   tier.Topic,
+		Key:     message.Key,
+		Value:   message.Value,
+		Headers: retryHeaders(message, attempt+1, time.Now().Add(tier.Delay), processErr),
+	}
+	if err := writer.WriteMessages(ctx, retryMessage); err != nil {
+		c.logger.Error().
+			Err(err).
+			Str("topic", message.Topic).
+			Str("retry_topic", tier.Topic).
+			Msg("Failed to republish message to retry topic")
+		return false
+	}
+
+	metrics.DefaultRegistry.Counter(
+		"kafka_retried_messages_total", "Number of messages republished to retry topics",
+		"topic", message.Topic, "retry_topic", tier.Topic,
+	).Inc()
+	c.logger.Warn().
+		Err(processErr).
+		Str("topic", message.Topic).
+		Int("partition", message.Partition).
+		Int64("offset", message.Offset).
+		Str("retry_topic", tier.Topic).
+		Int("attempt", attempt+1).
+		Msg("Error processing message, scheduled retry")
+	return true
+}
+
+// retryHeaders returns headers of the message republished to a retry topic. Headers of the message are kept,
+// retry headers are replaced and the original position is taken from the message
+func retryHeaders(message kafka.Message, attempt int, notBefore time.Time, processErr error) []kafka.Header {
+	headers := make([]kafka.Header, 0, len(message.Headers)+6)
+	for _, header := range message.Headers {
+		if !isRetryHeader(header.Key) {
+			headers = append(headers, header)
+		}
+	}
+
+	errorMessage := ""
+	if processErr != nil {
+		errorMessage = processErr.Error()
+	}
+	return append(
+		headers,
+		kafka.Header{Key: HeaderRetryAttempt, Value: []byte(strconv.Itoa(attempt))},
+		kafka.Header{Key: HeaderRetryNotBefore, Value: []byte(strconv.FormatInt(notBefore.UnixMilli(), 10))},
+		kafka.Header{Key: HeaderRetryError, Value: []byte(errorMessage)},
+		kafka.Header{Key: HeaderOriginalTopic, Value: []byte(message.Topic)},
+		kafka.Header{Key: HeaderOriginalPartition, Value: []byte(strconv.Itoa(message.Partition))},
+		kafka.Header{Key: HeaderOriginalOffset, Value: []byte(strconv.FormatInt(message.Offset, 10))},
+	)
+}
+
+// isRetryHeader reports if the header is set by retries
+func isRetryHeader(key string) bool {
+	switch strings.ToLower(key) {
+	case HeaderRetryAttempt, HeaderRetryNotBefore, HeaderRetryError,
+		HeaderOriginalTopic, HeaderOriginalPartition, HeaderOriginalOffset:
+		return true
+	}
+	return false
+}
+
+// retryAttempt returns the number of retries of the message, 0 for messages which weren't retried
+func retryAttempt(message kafka.Message) int {
+	attempt, err := strconv.Atoi(messageHeader(message, HeaderRetryAttempt))
+	if err != nil || attempt < 0 {
+		return 0
+	}
+	return attempt
+}
+
+// retryNotBefore returns the time the retry message may be processed after
+func retryNotBefore(message kafka.Message) time.Time {
+	millis, err := strconv.ParseInt(messageHeader(message, HeaderRetryNotBefore), 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	return time.UnixMilli(millis)
+}
+
+// originalMessage returns a copy of the retry message with the original position,
+// so it's routed to the handler of the original topic
+func originalMessage(message kafka.Message) kafka.Message {
+	original := message
+	if topic := messageHeader(message, HeaderOriginalTopic); topic != "" {
+		original.Topic = topic
+	}
+	if partition, err := strconv.Atoi(messageHeader(message, HeaderOriginalPartition)); err == nil {
+		original.Partition = partition
+	}
+	if offset, err := strconv.ParseInt(messageHeader(message, HeaderOriginalOffset), 10, 64); err == nil {
+		original.Offset = offset
+	}
+	return original
+}
@@ -0,0 +1,188 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+
+	"l0/internal/codec"
+	"l0/internal/config"
+)
+
+// A mockWriter records written messages
+type mockWriter struct {
+	messages []kafka.Message
+	err      error
+	closed   bool
+}
+
+func (m *mockWriter) WriteMessages(ctx context.Context, messages ...kafka.Message) error {
+	m.messages = append(m.messages, messages...)
+	return m.err
+}
+
+func (m *mockWriter) Close() error {
+	m.closed = true
+	return nil
+}
+
+func TestRetryHeaders(t *testing.T) {
+	notBefore := time.Now().Add(time.Minute)
+	message := kafka.Message{
+		Topic:     "orders",
+		Partition: 2,
+		Offset:    42,
+		Headers: []kafka.Header{
+			{Key: codec.ContentTypeHeader, Value: []byte("application/json")},
+			{Key: HeaderRetryAttempt, Value: []byte("1")},
+		},
+	}
+
+	retry := kafka.Message{
+		Topic:   "orders.retry.10m",
+		Headers: retryHeaders(message, 2, notBefore, errors.New("database is not available")),
+	}
+	if attempt := retryAttempt(retry); attempt != 2 {
+		t.Errorf("error: expected attempt 2, got %d", attempt)
+	}
+	if at := retryNotBefore(retry); at.UnixMilli() != notBefore.UnixMilli() {
+		t.Errorf("error: expected not-before %v, got %v", notBefore, at)
+	}
+	if contentType := messageHeader(retry, codec.ContentTypeHeader); contentType != "application/json" {
+		t.Errorf("error: expected content type to be kept, got %q", contentType)
+	}
+
+	attempts := 0
+	for _, header := range retry.Headers {
+		if header.Key == HeaderRetryAttempt {
+			attempts++
+		}
+	}
+	if attempts != 1 {
+		t.Errorf("error: expected retry headers to be replaced, got %d attempt headers", attempts)
+	}
+
+	original := originalMessage(retry)
+	if original.Topic != "orders" || original.Partition != 2 || original.Offset != 42 {
+		t.Errorf("error: expected original position orders/2/42, got %s/%d/%d",
+			original.Topic, original.Partition, original.Offset)
+	}
+}
+
+func TestConsumer_ScheduleRetry(t *testing.T) {
+	cfg := config.KafkaConfig{
+		Topics: []config.TopicConfig{{Name: "payment-updates", Handler: HandlerPaymentUpdates}},
+		RetryTopics: config.RetryTopicsConfig{
+			Enabled: true,
+			Tiers: []config.RetryTierConfig{
+				{Topic: "orders.retry.1m", Delay: time.Minute},
+				{Topic: "orders.retry.10m", Delay: 10 * time.Minute},
+			},
+		},
+	}
+	processor := &mockProcessor{err: errors.New("database is not available")}
+	consumer := newTestConsumer(t, cfg, processor)
+	if err := consumer.resolveTopics(context.Background(), nil); err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	writer := &mockWriter{}
+	consumer.retryWriter = writer
+	ctx := context.Background()
+
+	payment := []byte(`{"transaction":"order1","currency":"USD","provider":"wbpay","amount":100,"goods_total":100}`)
+	message := kafka.Message{Topic: "payment-updates", Key: []byte("order1"), Value: payment}
+
+	if err := consumer.handleMessage(ctx, message); err == nil {
+		t.Errorf("error: expected processing error")
+	}
+	if len(writer.messages) != 1 || writer.messages[0].Topic != "orders.retry.1m" {
+		t.Fatalf("error: expected message to be republished to the first tier, got %v", writer.messages)
+	}
+	if string(writer.messages[0].Key) != "order1" {
+		t.Errorf("error: expected key to be kept, got %q", writer.messages[0].Key)
+	}
+	if deadLetters(consumer, ReasonProcessingError) != 0 {
+		t.Errorf("error: expected no dead letters before tiers are exhausted")
+	}
+
+	// The retry consumer restores the original topic, the next failure goes to the next tier
+	if err := consumer.handleMessage(ctx, originalMessage(writer.messages[0])); err == nil {
+		t.Errorf("error: expected processing error")
+	}
+	if len(writer.messages) != 2 || writer.messages[1].Topic != "orders.retry.10m" {
+		t.Fatalf("error: expected message to be republished to the second tier, got %v", writer.messages)
+	}
+	if attempt := retryAttempt(writer.messages[1]); attempt != 2 {
+		t.Errorf("error: expected attempt 2, got %d", attempt)
+	}
+
+	if err := consumer.handleMessage(ctx, originalMessage(writer.messages[1])); err == nil {
+		t.Errorf("error: expected processing error")
+	}
+	if len(writer.messages) != 2 || deadLetters(consumer, ReasonProcessingError) != 1 {
+		t.Errorf("error: expected message to be sent to the dead letter queue after the last tier")
+	}
+
+	invalid := kafka.Message{Topic: "payment-updates", Value: []byte(`{"transaction":"order1"}`)}
+	if err := consumer.handleMessage(ctx, invalid); err == nil {
+		t.Errorf("error: expected validation error")
+	}
+	if len(writer.messages) != 2 || deadLetters(consumer, ReasonValidationError) != 1 {
+		t.Errorf("error: expected invalid message to be sent to the dead letter queue without retries")
+	}
+
+	writer.err = errors.New("broker is not available")
+	if err := consumer.handleMessage(ctx, message); err == nil {
+		t.Errorf("error: expected processing error")
+	}
+	if deadLetters(consumer, ReasonProcessingError) != 2 {
+		t.Errorf("error: expected message to be sent to the dead letter queue if retry wasn't published")
+	}
+}
+
+func TestConsumer_StopDrainsBeforeClosingRetries(t *testing.T) {
+	cfg := config.KafkaConfig{
+		Topics: []config.TopicConfig{{Name: "payment-updates", Handler: HandlerPaymentUpdates}},
+		RetryTopics: config.RetryTopicsConfig{
+			Enabled: true,
+			Tiers:   []config.RetryTierConfig{{Topic: "orders.retry.1m", Delay: time.Minute}},
+		},
+	}
+	processor := &mockProcessor{err: errors.New("database is not available")}
+	consumer := newTestConsumer(t, cfg, processor)
+	if err := consumer.resolveTopics(context.Background(), nil); err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	writer := &mockWriter{}
+	consumer.retryWriter = writer
+
+	// A worker still processes a message when the consumer is stopped
+	stop, done := make(chan struct{}), make(chan struct{})
+	consumer.running, consumer.stop, consumer.done = true, stop, done
+	go func() {
+		defer close(done)
+		<-stop
+		payment := []byte(`{"transaction":"order1","currency":"USD","provider":"wbpay","amount":100,"goods_total":100}`)
+		_ = consumer.handleMessage(context.Background(), kafka.Message{Topic: "payment-updates", Value: payment})
+	}()
+
+	if err := consumer.Stop(context.Background()); err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if len(writer.messages) != 1 || deadLetters(consumer, ReasonProcessingError) != 0 {
+		t.Errorf("error: expected the message failed during shutdown to be republished to the retry tier")
+	}
+	if !writer.closed || consumer.retryWriter != nil {
+		t.Errorf("error: expected the retry writer to be closed after the workers drained")
+	}
+}
+
+func TestRetryAttempt_Invalid(t *testing.T) {
+	message := kafka.Message{Headers: []kafka.Header{{Key: HeaderRetryAttempt, Value: []byte("-1")}}}
+	if attempt := retryAttempt(message); attempt != 0 {
+		t.Errorf("error: expected attempt 0 for an invalid header, got %d", attempt)
+	}
+}
@@ -0,0 +1,136 @@
+// Package security implements TLS and SASL settings of Kafka connections shared by consumers and producers
+package security
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/sasl"
+	"github.com/segmentio/kafka-go/sasl/plain"
+	"github.com/segmentio/kafka-go/sasl/scram"
+
+	"l0/internal/config"
+)
+
+// Security protocols
+const (
+	ProtocolPlaintext     = "plaintext"
+	ProtocolSSL           = "ssl"
+	ProtocolSASLPlaintext = "sasl_plaintext"
+	ProtocolSASLSSL       = "sasl_ssl"
+)
+
+// SASL mechanisms
+const (
+	MechanismPlain       = "PLAIN"
+	MechanismSCRAMSHA256 = "SCRAM-SHA-256"
+	MechanismSCRAMSHA512 = "SCRAM-SHA-512"
+)
+
+// TLSConfig returns the TLS configuration or nil if the protocol doesn't use TLS
+func TLSConfig(cfg config.KafkaSecurityConfig) (*tls.Config, error) {
+	if cfg.Protocol != ProtocolSSL && cfg.Protocol != ProtocolSASLSSL {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         cfg.TLS.ServerName,
+		InsecureSkipVerify: cfg.TLS.InsecureSkipVerify,
+	}
+
+	if cfg.TLS.CAFile != "" {
+		ca, err := os.ReadFile(cfg.TLS.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no certificates found in CA file %s", cfg.TLS.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if cfg.TLS.CertFile != "" || cfg.TLS.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.TLS.CertFile, cfg.TLS.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
+
+// Mechanism returns the SASL mechanism or nil if the protocol doesn't use SASL
+func Mechanism(cfg config.KafkaSecurityConfig) (sasl.Mechanism, error) {
+	if cfg.Protocol != ProtocolSASLPlaintext && cfg.Protocol != ProtocolSASLSSL {
+		return nil, nil
+	}
+
+	password := cfg.SASL.Password
+	if cfg.SASL.PasswordFile != "" {
+		data, err := os.ReadFile(cfg.SASL.PasswordFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read SASL password file: %w", err)
+		}
+		password = strings.TrimSpace(string(data))
+	}
+	if cfg.SASL.Username == "" || password == "" {
+		return nil, errors.New("SASL username and password are required")
+	}
+
+	switch cfg.SASL.Mechanism {
+	case MechanismPlain:
+		return plain.Mechanism{Username: cfg.SASL.Username, Password: password}, nil
+	case MechanismSCRAMSHA256:
+		return scram.Mechanism(scram.SHA256, cfg.SASL.Username, password)
+	case MechanismSCRAMSHA512:
+		return scram.Mechanism(scram.SHA512, cfg.SASL.Username, password)
+	default:
+		return nil, fmt.Errorf("unsupported SASL mechanism: %q", cfg.SASL.Mechanism)
+	}
+}
+
+// NewDialer creates a dialer for readers and consumer groups
+func NewDialer(cfg config.KafkaSecurityConfig) (*kafka.Dialer, error) {
+	tlsConfig, err := TLSConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	mechanism, err := Mechanism(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &kafka.Dialer{
+		Timeout:       10 * time.Second,
+		DualStack:     true,
+		TLS:           tlsConfig,
+		SASLMechanism: mechanism,
+	}, nil
+}
+
+// NewTransport creates a transport for writers
+func NewTransport(cfg config.KafkaSecurityConfig) (*kafka.Transport, error) {
+	tlsConfig, err := TLSConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	mechanism, err := Mechanism(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &kafka.Transport{
+		DialTimeout: 10 * time.Second,
+		TLS:         tlsConfig,
+		SASL:        mechanism,
+	}, nil
+}
@@ -0,0 +1,137 @@
+package security
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go/sasl/plain"
+
+	"l0/internal/config"
+)
+
+// writeCertificate writes a self-signed certificate and its key into the directory
+func writeCertificate(t *testing.T, dir string) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "kafka-client"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	certFile := filepath.Join(dir, "client.crt")
+	keyFile := filepath.Join(dir, "client.key")
+	_ = os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600)
+	_ = os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600)
+	return certFile, keyFile
+}
+
+func TestTLSConfig(t *testing.T) {
+	if tlsConfig, err := TLSConfig(config.KafkaSecurityConfig{Protocol: ProtocolSASLPlaintext}); tlsConfig != nil || err != nil {
+		t.Errorf("error: expected no TLS for sasl_plaintext, got %v, %v", tlsConfig, err)
+	}
+
+	certFile, keyFile := writeCertificate(t, t.TempDir())
+	cfg := config.KafkaSecurityConfig{
+		Protocol: ProtocolSSL,
+		TLS:      config.KafkaTLSConfig{CAFile: certFile, CertFile: certFile, KeyFile: keyFile, ServerName: "kafka"},
+	}
+	tlsConfig, err := TLSConfig(cfg)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if tlsConfig.RootCAs == nil || len(tlsConfig.Certificates) != 1 || tlsConfig.ServerName != "kafka" {
+		t.Errorf("error: unexpected TLS config %+v", tlsConfig)
+	}
+
+	cfg.TLS.CAFile = keyFile
+	if _, err := TLSConfig(cfg); err == nil {
+		t.Errorf("error: expected error for CA file without certificates")
+	}
+	cfg.TLS.CAFile = filepath.Join(t.TempDir(), "missing.crt")
+	if _, err := TLSConfig(cfg); err == nil {
+		t.Errorf("error: expected error for missing CA file")
+	}
+}
+
+func TestMechanism(t *testing.T) {
+	if mechanism, err := Mechanism(config.KafkaSecurityConfig{Protocol: ProtocolSSL}); mechanism != nil || err != nil {
+		t.Errorf("error: expected no SASL for ssl, got %v, %v", mechanism, err)
+	}
+
+	for _, name := range []string{MechanismPlain, MechanismSCRAMSHA256, MechanismSCRAMSHA512} {
+		cfg := config.KafkaSecurityConfig{
+			Protocol: ProtocolSASLSSL,
+			SASL:     config.KafkaSASLConfig{Mechanism: name, Username: "service", Password: "secret"},
+		}
+		mechanism, err := Mechanism(cfg)
+		if err != nil {
+			t.Fatalf("error: %v", err)
+		}
+		if mechanism.Name() != name {
+			t.Errorf("error: expected mechanism %s, got %s", name, mechanism.Name())
+		}
+	}
+
+	cfg := config.KafkaSecurityConfig{
+		Protocol: ProtocolSASLPlaintext,
+		SASL:     config.KafkaSASLConfig{Mechanism: "GSSAPI", Username: "service", Password: "secret"},
+	}
+	if _, err := Mechanism(cfg); err == nil {
+		t.Errorf("error: expected error for unsupported mechanism")
+	}
+
+	cfg.SASL = config.KafkaSASLConfig{Mechanism: MechanismPlain, Username: "service"}
+	if _, err := Mechanism(cfg); err == nil {
+		t.Errorf("error: expected error for missing password")
+	}
+}
+
+func TestMechanism_PasswordFile(t *testing.T) {
+	passwordFile := filepath.Join(t.TempDir(), "password")
+	_ = os.WriteFile(passwordFile, []byte("from-file\n"), 0o600)
+
+	cfg := config.KafkaSecurityConfig{
+		Protocol: ProtocolSASLSSL,
+		SASL: config.KafkaSASLConfig{
+			Mechanism: MechanismPlain, Username: "service", Password: "from-config", PasswordFile: passwordFile,
+		},
+	}
+	dialer, err := NewDialer(cfg)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if dialer.TLS == nil || dialer.SASLMechanism == nil {
+		t.Fatalf("error: expected TLS and SASL to be configured")
+	}
+	if plainMechanism, ok := dialer.SASLMechanism.(plain.Mechanism); !ok || plainMechanism.Password != "from-file" {
+		t.Errorf("error: expected password from file")
+	}
+
+	cfg.SASL.PasswordFile = filepath.Join(t.TempDir(), "missing")
+	if _, err := NewTransport(cfg); err == nil {
+		t.Errorf("error: expected error for missing password file")
+	}
+}
@@ -0,0 +1,305 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/segmentio/kafka-go"
+
+	"l0/internal/kafka/security"
+	"l0/internal/models"
+)
+
+var (
+	ErrNoConsumerGroup      = errors.New("offsets can't be reset without a consumer group")
+	ErrUnknownResetStrategy = errors.New("unknown offset reset strategy")
+)
+
+// Seek moves the consumer group to the offset of the topic partition, see ResetOffsets
+func (c *Consumer) Seek(ctx context.Context, topic string, partition int, offset int64) error {
+	if partition < 0 || offset < 0 {
+		return fmt.Errorf("invalid position %d:%d", partition, offset)
+	}
+	return c.ResetOffsets(ctx, topic, map[int]int64{partition: offset})
+}
+
+// ResetGroup moves the consumer group in the topic according to the reset strategy and returns the new offsets,
+// see ResetOffsets
+func (c *Consumer) ResetGroup(ctx context.Context, reset models.OffsetReset) (map[int]int64, error) {
+	if strings.TrimSpace(c.config.GroupID) == "" {
+		return nil, ErrNoConsumerGroup
+	}
+	if reset.Topic == "" {
+		return nil, errors.New("topic is required")
+	}
+
+	var partitionOffsets map[int]int64
+	switch reset.To {
+	case models.OffsetResetOffsets:
+		if len(reset.Offsets) == 0 {
+			return nil, errors.New("offsets are required")
+		}
+		for partition, offset := range reset.Offsets {
+			if partition < 0 || offset < 0 {
+				return nil, fmt.Errorf("invalid position %d:%d", partition, offset)
+			}
+		}
+		partitionOffsets = reset.Offsets
+	case models.OffsetResetEarliest, models.OffsetResetLatest, models.OffsetResetTimestamp:
+		at := int64(kafka.FirstOffset)
+		if reset.To == models.OffsetResetLatest {
+			at = kafka.LastOffset
+		} else if reset.To == models.OffsetResetTimestamp {
+			if reset.Timestamp == nil {
+				return nil, errors.New("timestamp is required")
+			}
+			at = reset.Timestamp.UnixMilli()
+		}
+
+		var err error
+		partitionOffsets, err = c.topicOffsets(ctx, reset.Topic, at)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownResetStrategy, reset.To)
+	}
+
+	if err := c.ResetOffsets(ctx, reset.Topic, partitionOffsets); err != nil {
+		return nil, err
+	}
+	return partitionOffsets, nil
+}
+
+// ResetOffsets sets offsets of the consumer group for partitions of the topic. A running consumer is stopped
+// while offsets are reset and started again, messages fetched before are processed first.
+// The consumer is started again even if the reset failed or ctx is done before it stopped.
+// Offsets in Kafka can be reset only if other members of the group are stopped
+func (c *Consumer) ResetOffsets(ctx context.Context, topic string, partitionOffsets map[int]int64) (err error) {
+	if strings.TrimSpace(c.config.GroupID) == "" {
+		return ErrNoConsumerGroup
+	}
+
+	c.resetMu.Lock()
+	defer c.resetMu.Unlock()
+
+	c.mu.RLock()
+	running, startCtx, done := c.running, c.startCtx, c.done
+	_, subscribed := c.routes[topic]
+	c.mu.RUnlock()
+
+	if running && !subscribed {
+		return fmt.Errorf("consumer is not subscribed to topic %q", topic)
+	}
+
+	if running {
+		defer func() {
+			if startErr := c.restartAfterReset(startCtx, done); startErr != nil {
+				err = errors.Join(err, fmt.Errorf("failed to restart consumer: %w", startErr))
+			}
+		}()
+
+		if err := c.Stop(ctx); err != nil {
+			return err
+		}
+		select {
+		case <-done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	err = c.commitOffsets(ctx, c.config.GroupID, topic, partitionOffsets)
+	if err == nil {
+		c.logger.Info().
+			Str("topic", topic).
+			Interface("offsets", partitionOffsets).
+			Msg("Consumer group offsets reset")
+	}
+
+	return err
+}
+
+// restartAfterReset starts the consumer stopped by ResetOffsets again with its original context.
+// If consumption hasn't finished yet, the consumer is started in background once it finishes,
+// the next reset waits for it. The caller has to hold the reset lock
+func (c *Consumer) restartAfterReset(ctx context.Context, done <-chan struct{}) error {
+	select {
+	case <-done:
+		return c.startStopped(ctx)
+	default:
+	}
+
+	go func() {
+		c.resetMu.Lock()
+		defer c.resetMu.Unlock()
+
+		<-done
+		if err := c.startStopped(ctx); err != nil {
+			c.logger.Error().Err(err).Msg("Failed to restart consumer after offset reset")
+		}
+	}()
+	return nil
+}
+
+// startStopped starts the consumer unless it's running already or its context is done by shutdown
+func (c *Consumer) startStopped(ctx context.Context) error {
+	c.mu.RLock()
+	running := c.running
+	c.mu.RUnlock()
+
+	if running || ctx.Err() != nil {
+		return nil
+	}
+	return c.Start(ctx)
+}
+
+// commitOffsets overwrites offsets of the consumer group in the configured offset storage
+func (c *Consumer) commitOffsets(
+	ctx context.Context, groupID, topic string, partitionOffsets map[int]int64,
+) error {
+	if c.config.OffsetStorage == OffsetStoragePostgres {
+		if c.offsetStore == nil {
+			return errors.New("offset store is required to keep offsets in Postgres")
+		}
+		return c.offsetStore.ResetConsumerOffsets(ctx, groupID, topic, partitionOffsets)
+	}
+
+	client, err := c.client()
+	if err != nil {
+		return err
+	}
+
+	commits := make([]kafka.OffsetCommit, 0, len(partitionOffsets))
+	for partition, offset := range partitionOffsets {
+		commits = append(commits, kafka.OffsetCommit{Partition: partition, Offset: offset})
+	}
+
+	// Offsets are committed outside of a group generation, which is allowed only for an empty group
+	response, err := client.OffsetCommit(
+		ctx, &kafka.OffsetCommitRequest{
+			GroupID:      groupID,
+			GenerationID: -1,
+			Topics:       map[string][]kafka.OffsetCommit{topic: commits},
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to commit offsets: %w", err)
+	}
+
+	var errs []error
+	for _, partition := range response.Topics[topic] {
+		if partition.Error != nil {
+			errs = append(errs, fmt.Errorf("partition %d: %w", partition.Partition, partition.Error))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// client creates a Kafka client for requests outside of readers
+func (c *Consumer) client() (*kafka.Client, error) {
+	transport, err := security.NewTransport(c.config.Security)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure Kafka connection: %w", err)
+	}
+
+	brokers := strings.Split(c.config.Listeners, ",")
+	for i, broker := range brokers {
+		brokers[i] = strings.TrimSpace(broker)
+	}
+
+	return &kafka.Client{Addr: kafka.TCP(brokers...), Transport: transport}, nil
+}
+
+// topicOffsets returns offsets of all partitions of the topic at the time in milliseconds or at kafka.FirstOffset
+// or kafka.LastOffset. Partitions without messages after the time get the latest offsets
+func (c *Consumer) topicOffsets(ctx context.Context, topic string, at int64) (map[int]int64, error) {
+	client, err := c.client()
+	if err != nil {
+		return nil, err
+	}
+
+	metadata, err := client.Metadata(ctx, &kafka.MetadataRequest{Topics: []string{topic}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read topic metadata: %w", err)
+	}
+	var partitions []int
+	for _, t := range metadata.Topics {
+		if t.Name != topic {
+			continue
+		}
+		if t.Error != nil {
+			return nil, fmt.Errorf("failed to read topic %q metadata: %w", topic, t.Error)
+		}
+		for _, partition := range t.Partitions {
+			partitions = append(partitions, partition.ID)
+		}
+	}
+	if len(partitions) == 0 {
+		return nil, fmt.Errorf("topic %q has no partitions", topic)
+	}
+
+	result, err := listOffsets(ctx, client, topic, partitions, at)
+	if err != nil || at == kafka.FirstOffset || at == kafka.LastOffset {
+		return result, err
+	}
+
+	var missing []int
+	for _, partition := range partitions {
+		if _, ok := result[partition]; !ok {
+			missing = append(missing, partition)
+		}
+	}
+	if len(missing) == 0 {
+		return result, nil
+	}
+	latest, err := listOffsets(ctx, client, topic, missing, kafka.LastOffset)
+	if err != nil {
+		return nil, err
+	}
+	for partition, offset := range latest {
+		result[partition] = offset
+	}
+	return result, nil
+}
+
+// listOffsets requests offsets of partitions of the topic at the time in milliseconds
+// or at kafka.FirstOffset or kafka.LastOffset
+func listOffsets(
+	ctx context.Context, client *kafka.Client, topic string, partitions []int, at int64,
+) (map[int]int64, error) {
+	requests := make([]kafka.OffsetRequest, 0, len(partitions))
+	for _, partition := range partitions {
+		requests = append(requests, kafka.OffsetRequest{Partition: partition, Timestamp: at})
+	}
+
+	response, err := client.ListOffsets(
+		ctx, &kafka.ListOffsetsRequest{Topics: map[string][]kafka.OffsetRequest{topic: requests}},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list offsets: %w", err)
+	}
+
+	result := make(map[int]int64, len(partitions))
+	for _, partition := range response.Topics[topic] {
+		if partition.Error != nil {
+			return nil, fmt.Errorf("partition %d: %w", partition.Partition, partition.Error)
+		}
+		switch at {
+		case kafka.FirstOffset:
+			result[partition.Partition] = partition.FirstOffset
+		case kafka.LastOffset:
+			result[partition.Partition] = partition.LastOffset
+		default:
+			// Kafka returns -1 if there are no messages after the time
+			for offset := range partition.Offsets {
+				if offset >= 0 {
+					result[partition.Partition] = offset
+				}
+			}
+		}
+	}
+	return result, nil
+}
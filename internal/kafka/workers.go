@@ -0,0 +1,207 @@
+package kafka
+
+import (
+	"context"
+	"hash/fnv"
+	"slices"
+	"sync"
+
+	"github.com/segmentio/kafka-go"
+
+	"l0/internal/metrics"
+)
+
+// Dispatch modes of messages to workers
+const (
+	DispatchByPartition = "partition"
+	DispatchByKey       = "key"
+)
+
+// A partitionTracker keeps offsets of one partition in fetch order to find processed contiguous offsets
+type partitionTracker struct {
+	pending []int64
+	done    map[int64]bool
+}
+
+// A topicPartition identifies a partition of a topic, partition numbers are only unique within a topic
+type topicPartition struct {
+	topic     string
+	partition int
+}
+
+// An offsetTracker is a thread-safe tracker of processed offsets by topic partitions.
+// Messages of a partition may complete out of order, but an offset is committable only
+// when all messages fetched before it are processed
+type offsetTracker struct {
+	mu         sync.Mutex
+	partitions map[topicPartition]*partitionTracker
+}
+
+// newOffsetTracker creates a new empty tracker
+func newOffsetTracker() *offsetTracker {
+	return &offsetTracker{partitions: make(map[topicPartition]*partitionTracker)}
+}
+
+// add registers a fetched message, messages of a partition have to be added in fetch order
+func (t *offsetTracker) add(topic string, partition int, offset int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	key := topicPartition{topic: topic, partition: partition}
+	p, ok := t.partitions[key]
+	if !ok {
+		p = &partitionTracker{done: make(map[int64]bool)}
+		t.partitions[key] = p
+	}
+	p.pending = append(p.pending, offset)
+}
+
+// remove unregisters a fetched message which was never dispatched
+func (t *offsetTracker) remove(topic string, partition int, offset int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	p, ok := t.partitions[topicPartition{topic: topic, partition: partition}]
+	if !ok {
+		return
+	}
+	if i := slices.Index(p.pending, offset); i >= 0 {
+		p.pending = slices.Delete(p.pending, i, i+1)
+	}
+}
+
+// markDone marks the message processed and returns the highest contiguous processed offset
+// if it moved forward, ok is false if there is nothing new to commit
+func (t *offsetTracker) markDone(topic string, partition int, offset int64) (committable int64, ok bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	p, exists := t.partitions[topicPartition{topic: topic, partition: partition}]
+	if !exists {
+		return 0, false
+	}
+	p.done[offset] = true
+
+	advanced := 0
+	for advanced < len(p.pending) && p.done[p.pending[advanced]] {
+		delete(p.done, p.pending[advanced])
+		committable = p.pending[advanced]
+		advanced++
+	}
+	if advanced == 0 {
+		return 0, false
+	}
+
+	p.pending = p.pending[advanced:]
+	return committable, true
+}
+
+// A workerPool processes messages with a fixed number of workers. Messages of one partition or one key
+// are always dispatched to the same worker, so their processing order is preserved
+type workerPool struct {
+	consumer    *Consumer
+	commitFn    func(ctx context.Context, message kafka.Message)
+	tracker     *offsetTracker
+	queues      []chan kafka.Message
+	completions chan kafka.Message
+	dispatchBy  string
+	inFlight    *metrics.Gauge
+	workersWg   sync.WaitGroup
+	committerWg sync.WaitGroup
+}
+
+// startWorkerPool starts Concurrency workers and a committer of processed offsets
+func (c *Consumer) startWorkerPool(ctx context.Context, reader *kafka.Reader) *workerPool {
+	queueSize := c.config.WorkerQueueSize
+	if queueSize <= 0 {
+		queueSize = 100
+	}
+
+	p := &workerPool{
+		consumer: c,
+		commitFn: func(ctx context.Context, message kafka.Message) {
+			c.commitMessage(ctx, reader, message)
+		},
+		tracker:     newOffsetTracker(),
+		queues:      make([]chan kafka.Message, c.config.Concurrency),
+		completions: make(chan kafka.Message, queueSize*c.config.Concurrency),
+		dispatchBy:  c.config.DispatchBy,
+		inFlight: metrics.DefaultRegistry.Gauge(
+			"kafka_consumer_in_flight_messages", "Number of messages dispatched to workers and not processed yet",
+		),
+	}
+
+	for i := range p.queues {
+		p.queues[i] = make(chan kafka.Message, queueSize)
+		p.workersWg.Add(1)
+		go p.work(ctx, p.queues[i])
+	}
+
+	p.committerWg.Add(1)
+	go p.commit(ctx)
+
+	c.logger.Info().
+		Int("workers", c.config.Concurrency).
+		Str("dispatch_by", p.dispatchBy).
+		Msg("Kafka consumer worker pool started")
+
+	return p
+}
+
+// dispatch sends the message to its worker, it blocks while the worker queue is full.
+// The message is tracked before it's sent, so its completion can't be reported before it's tracked
+func (p *workerPool) dispatch(ctx context.Context, message kafka.Message) {
+	p.tracker.add(message.Topic, message.Partition, message.Offset)
+	p.inFlight.Inc()
+
+	select {
+	case p.queues[p.workerIndex(message)] <- message:
+	case <-ctx.Done():
+		p.tracker.remove(message.Topic, message.Partition, message.Offset)
+		p.inFlight.Dec()
+	}
+}
+
+// workerIndex selects the worker for the message
+func (p *workerPool) workerIndex(message kafka.Message) int {
+	if p.dispatchBy == DispatchByKey && len(message.Key) > 0 {
+		h := fnv.New32a()
+		h.Write(message.Key)
+		return int(h.Sum32() % uint32(len(p.queues)))
+	}
+	return message.Partition % len(p.queues)
+}
+
+// work processes messages of the queue until it's closed
+func (p *workerPool) work(ctx context.Context, queue <-chan kafka.Message) {
+	defer p.workersWg.Done()
+
+	for message := range queue {
+		p.consumer.handleMessage(ctx, message)
+		p.inFlight.Dec()
+		p.completions <- message
+	}
+}
+
+// commit commits the highest contiguous processed offsets of topic partitions
+func (p *workerPool) commit(ctx context.Context) {
+	defer p.committerWg.Done()
+
+	for message := range p.completions {
+		offset, ok := p.tracker.markDone(message.Topic, message.Partition, message.Offset)
+		if !ok {
+			continue
+		}
+		p.commitFn(ctx, kafka.Message{Topic: message.Topic, Partition: message.Partition, Offset: offset})
+	}
+}
+
+// stop waits for the dispatched messages to be processed and committed
+func (p *workerPool) stop() {
+	for _, queue := range p.queues {
+		close(queue)
+	}
+	p.workersWg.Wait()
+	close(p.completions)
+	p.committerWg.Wait()
+}
@@ -0,0 +1,144 @@
+package kafka
+
+import (
+	"context"
+	"maps"
+	"sync"
+	"testing"
+
+	"github.com/segmentio/kafka-go"
+
+	"l0/internal/config"
+	"l0/internal/metrics"
+)
+
+func TestOffsetTracker_MarkDone(t *testing.T) {
+	tracker := newOffsetTracker()
+	for _, offset := range []int64{10, 11, 13, 14} {
+		tracker.add("orders", 0, offset)
+	}
+
+	if _, ok := tracker.markDone("orders", 0, 11); ok {
+		t.Errorf("error: expected no committable offset while 10 is not processed")
+	}
+	if _, ok := tracker.markDone("orders", 0, 14); ok {
+		t.Errorf("error: expected no committable offset while 10 is not processed")
+	}
+
+	offset, ok := tracker.markDone("orders", 0, 10)
+	if !ok || offset != 11 {
+		t.Errorf("error: expected committable offset 11, got %d, %v", offset, ok)
+	}
+
+	offset, ok = tracker.markDone("orders", 0, 13)
+	if !ok || offset != 14 {
+		t.Errorf("error: expected committable offset 14 skipping the gap, got %d, %v", offset, ok)
+	}
+}
+
+func TestOffsetTracker_Partitions(t *testing.T) {
+	tracker := newOffsetTracker()
+	tracker.add("orders", 0, 1)
+	tracker.add("orders", 1, 1)
+	tracker.add("orders", 0, 2)
+
+	if offset, ok := tracker.markDone("orders", 1, 1); !ok || offset != 1 {
+		t.Errorf("error: expected partitions to be tracked independently, got %d, %v", offset, ok)
+	}
+	if _, ok := tracker.markDone("orders", 0, 2); ok {
+		t.Errorf("error: expected no committable offset in partition 0")
+	}
+	if _, ok := tracker.markDone("orders", 2, 1); ok {
+		t.Errorf("error: expected no committable offset for unknown partition")
+	}
+	if _, ok := tracker.markDone("retry", 0, 2); ok {
+		t.Errorf("error: expected no committable offset for the partition of another topic")
+	}
+}
+
+func TestWorkerPool_WorkerIndex(t *testing.T) {
+	p := &workerPool{queues: make([]chan kafka.Message, 4), dispatchBy: DispatchByKey}
+
+	first := p.workerIndex(kafka.Message{Key: []byte("order1"), Partition: 0})
+	for partition := 0; partition < 8; partition++ {
+		if idx := p.workerIndex(kafka.Message{Key: []byte("order1"), Partition: partition}); idx != first {
+			t.Errorf("error: expected messages with the same key to go to one worker")
+		}
+	}
+
+	p.dispatchBy = DispatchByPartition
+	if idx := p.workerIndex(kafka.Message{Key: []byte("order1"), Partition: 5}); idx != 1 {
+		t.Errorf("error: expected worker 1 for partition 5, got %d", idx)
+	}
+}
+
+func TestWorkerPool_DispatchCancelled(t *testing.T) {
+	p := &workerPool{
+		tracker:  newOffsetTracker(),
+		queues:   []chan kafka.Message{make(chan kafka.Message)},
+		inFlight: &metrics.Gauge{},
+	}
+	p.tracker.add("orders", 0, 10)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	p.dispatch(ctx, kafka.Message{Topic: "orders", Partition: 0, Offset: 11})
+
+	if p.inFlight.Value() != 0 {
+		t.Errorf("error: expected the in-flight gauge to be released, got %d", p.inFlight.Value())
+	}
+	if offset, ok := p.tracker.markDone("orders", 0, 10); !ok || offset != 10 {
+		t.Errorf("error: expected the undispatched message not to block commits, got %d, %v", offset, ok)
+	}
+	if len(p.tracker.partitions[topicPartition{topic: "orders"}].pending) != 0 {
+		t.Errorf("error: expected no pending offsets, got %v", p.tracker.partitions[topicPartition{topic: "orders"}].pending)
+	}
+}
+
+func TestWorkerPool_CommitByTopic(t *testing.T) {
+	consumer := newTestConsumer(t, config.KafkaConfig{}, &mockProcessor{})
+
+	var mu sync.Mutex
+	committed := make(map[topicPartition]int64)
+	p := &workerPool{
+		consumer: consumer,
+		commitFn: func(ctx context.Context, message kafka.Message) {
+			mu.Lock()
+			defer mu.Unlock()
+			committed[topicPartition{topic: message.Topic, partition: message.Partition}] = message.Offset
+		},
+		tracker:     newOffsetTracker(),
+		queues:      []chan kafka.Message{make(chan kafka.Message, 10), make(chan kafka.Message, 10)},
+		completions: make(chan kafka.Message, 20),
+		dispatchBy:  DispatchByPartition,
+		inFlight:    &metrics.Gauge{},
+	}
+	ctx := context.Background()
+	for i := range p.queues {
+		p.workersWg.Add(1)
+		go p.work(ctx, p.queues[i])
+	}
+	p.committerWg.Add(1)
+	go p.commit(ctx)
+
+	messages := []kafka.Message{
+		{Topic: "orders", Partition: 0, Offset: 100},
+		{Topic: "retry", Partition: 0, Offset: 5},
+		{Topic: "orders", Partition: 0, Offset: 101},
+		{Topic: "retry", Partition: 0, Offset: 6},
+		{Topic: "retry", Partition: 1, Offset: 7},
+	}
+	for _, message := range messages {
+		p.dispatch(ctx, message)
+	}
+	p.stop()
+
+	expected := map[topicPartition]int64{
+		{topic: "orders", partition: 0}: 101,
+		{topic: "retry", partition: 0}:  6,
+		{topic: "retry", partition: 1}:  7,
+	}
+	if !maps.Equal(committed, expected) {
+		t.Errorf("error: expected offsets %v to be committed by topic partitions, got %v", expected, committed)
+	}
+}
@@ -0,0 +1,195 @@
+// Package metrics implements thread-safe counters and gauges exposed in the Prometheus text format
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// A Counter is a metric that only increases
+type Counter struct {
+	value atomic.Int64
+}
+
+// Inc increases the counter by 1
+func (c *Counter) Inc() {
+	c.value.Add(1)
+}
+
+// Add increases the counter by n
+func (c *Counter) Add(n int64) {
+	c.value.Add(n)
+}
+
+// Value returns the current value of the counter
+func (c *Counter) Value() int64 {
+	return c.value.Load()
+}
+
+// A Gauge is a metric that may increase and decrease
+type Gauge struct {
+	value atomic.Int64
+}
+
+// Set sets the value of the gauge
+func (g *Gauge) Set(n int64) {
+	g.value.Store(n)
+}
+
+// Inc increases the gauge by 1
+func (g *Gauge) Inc() {
+	g.value.Add(1)
+}
+
+// Dec decreases the gauge by 1
+func (g *Gauge) Dec() {
+	g.value.Add(-1)
+}
+
+// Add changes the gauge by n
+func (g *Gauge) Add(n int64) {
+	g.value.Add(n)
+}
+
+// Value returns the current value of the gauge
+func (g *Gauge) Value() int64 {
+	return g.value.Load()
+}
+
+// A family keeps all series of one metric name
+type family struct {
+	kind   string
+	help   string
+	series map[string]func() int64
+}
+
+// A Registry keeps metrics by names and labels
+type Registry struct {
+	mu       sync.Mutex
+	families map[string]*family
+	counters map[string]*Counter
+	gauges   map[string]*Gauge
+}
+
+// DefaultRegistry is the registry used by the service components
+var DefaultRegistry = NewRegistry()
+
+// NewRegistry creates a new empty registry
+func NewRegistry() *Registry {
+	return &Registry{
+		families: make(map[string]*family),
+		counters: make(map[string]*Counter),
+		gauges:   make(map[string]*Gauge),
+	}
+}
+
+// Counter returns the counter with the name and labels, labels are set as key-value pairs.
+// The counter is created on the first call
+func (r *Registry) Counter(name, help string, labels ...string) *Counter {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := name + formatLabels(labels)
+	if counter, ok := r.counters[key]; ok {
+		return counter
+	}
+	counter := &Counter{}
+	r.counters[key] = counter
+	r.register(name, "counter", help, formatLabels(labels), counter.Value)
+	return counter
+}
+
+// Gauge returns the gauge with the name and labels, labels are set as key-value pairs.
+// The gauge is created on the first call
+func (r *Registry) Gauge(name, help string, labels ...string) *Gauge {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := name + formatLabels(labels)
+	if gauge, ok := r.gauges[key]; ok {
+		return gauge
+	}
+	gauge := &Gauge{}
+	r.gauges[key] = gauge
+	r.register(name, "gauge", help, formatLabels(labels), gauge.Value)
+	return gauge
+}
+
+// GaugeFunc registers a gauge which value is calculated by fn on every collection
+func (r *Registry) GaugeFunc(name, help string, fn func() int64, labels ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.register(name, "gauge", help, formatLabels(labels), fn)
+}
+
+// register adds a series into the family of the metric
+func (r *Registry) register(name, kind, help, labels string, value func() int64) {
+	f, ok := r.families[name]
+	if !ok {
+		f = &family{kind: kind, help: help, series: make(map[string]func() int64)}
+		r.families[name] = f
+	}
+	f.series[labels] = value
+}
+
+// WriteTo writes all metrics in the Prometheus text format
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.Lock()
+	var b strings.Builder
+	names := make([]string, 0, len(r.families))
+	for name := range r.families {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	for _, name := range names {
+		f := r.families[name]
+		if f.help != "" {
+			fmt.Fprintf(&b, "# HELP %s %s\n", name, f.help)
+		}
+		fmt.Fprintf(&b, "# TYPE %s %s\n", name, f.kind)
+
+		labels := make([]string, 0, len(f.series))
+		for l := range f.series {
+			labels = append(labels, l)
+		}
+		slices.Sort(labels)
+		for _, l := range labels {
+			fmt.Fprintf(&b, "%s%s %d\n", name, l, f.series[l]())
+		}
+	}
+	r.mu.Unlock()
+
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+// Handler returns an HTTP handler exposing the metrics
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, _ *http.Request) {
+			w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+			_, _ = r.WriteTo(w)
+		},
+	)
+}
+
+// formatLabels formats key-value pairs as {key1="value1",key2="value2"}
+func formatLabels(labels []string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+
+	pairs := make([]string, 0, len(labels)/2)
+	for i := 0; i+1 < len(labels); i += 2 {
+		value := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(labels[i+1])
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, labels[i], value))
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
@@ -0,0 +1,58 @@
+package metrics
+
+import (
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestRegistry_Counter(t *testing.T) {
+	r := NewRegistry()
+
+	c := r.Counter("requests_total", "Total requests", "status", "200")
+	if r.Counter("requests_total", "Total requests", "status", "200") != c {
+		t.Errorf("error: expected the same counter for the same labels")
+	}
+	if r.Counter("requests_total", "Total requests", "status", "500") == c {
+		t.Errorf("error: expected another counter for other labels")
+	}
+
+	wg := sync.WaitGroup{}
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 100 {
+				c.Inc()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if c.Value() != 1000 {
+		t.Errorf("error: expected 1000, got %d", c.Value())
+	}
+}
+
+func TestRegistry_WriteTo(t *testing.T) {
+	r := NewRegistry()
+	r.Counter("requests_total", "Total requests", "status", "200").Add(3)
+	r.Gauge("in_flight", "Requests in flight").Set(2)
+	r.GaugeFunc("cache_size", "", func() int64 { return 7 })
+
+	var b strings.Builder
+	if _, err := r.WriteTo(&b); err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	expected := []string{
+		"# TYPE cache_size gauge\ncache_size 7\n",
+		"# HELP in_flight Requests in flight\n# TYPE in_flight gauge\nin_flight 2\n",
+		`requests_total{status="200"} 3`,
+	}
+	for _, e := range expected {
+		if !strings.Contains(b.String(), e) {
+			t.Errorf("error: expected output to contain %q, got:\n%s", e, b.String())
+		}
+	}
+}
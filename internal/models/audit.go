@@ -0,0 +1,76 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Audit actions
+const (
+	AuditOrderDeleted        = "order.deleted"
+	AuditCustomerErased      = "customer.erased"
+	AuditRetentionDeleted    = "retention.deleted"
+	AuditRetentionAnonymized = "retention.anonymized"
+	AuditOrderRead           = "order.read"
+	AuditCustomerOrdersRead  = "customer.orders.read"
+	AuditCustomerSummaryRead = "customer.summary.read"
+	AuditOrdersExported      = "orders.exported"
+	AuditOrdersStreamed      = "orders.streamed"
+	AuditErasureRequested    = "admin.customer.erasure"
+	AuditConsumerStatus      = "admin.consumer.status"
+	AuditConsumerPaused      = "admin.consumer.paused"
+	AuditConsumerResumed     = "admin.consumer.resumed"
+	AuditConsumerSeek        = "admin.consumer.seek"
+	AuditConsumerReset       = "admin.consumer.reset"
+	AuditConsumerReplay      = "admin.consumer.replay"
+	AuditLogRead             = "admin.audit.read"
+	AuditLogVerified         = "admin.audit.verified"
+	AuditWebhooksRead        = "admin.webhooks.read"
+	AuditWebhookCreated      = "admin.webhook.created"
+	AuditWebhookDeleted      = "admin.webhook.deleted"
+)
+
+// An AuditEntry is a structure to keep a recorded action of a principal, empty fields are not recorded.
+// Sealed entries form a hash chain: every entry has a sequence number and the hash of the previous entry
+type AuditEntry struct {
+	ID         int64           `json:"id" db:"id"`
+	Principal  string          `json:"principal" db:"principal"`
+	Action     string          `json:"action" db:"action"`
+	OrderUID   string          `json:"order_uid,omitempty" db:"order_uid"`
+	CustomerID string          `json:"customer_id,omitempty" db:"customer_id"`
+	IP         string          `json:"ip,omitempty" db:"ip"`
+	Details    json.RawMessage `json:"details,omitempty" db:"details"`
+	CreatedAt  time.Time       `json:"created_at" db:"created_at"`
+	ChainSeq   int64           `json:"chain_seq,omitempty" db:"chain_seq"`
+	PrevHash   string          `json:"prev_hash,omitempty" db:"prev_hash"`
+	Hash       string          `json:"hash,omitempty" db:"hash"`
+}
+
+// An AuditFilter is a structure to keep conditions of audit log queries, empty conditions are not applied
+type AuditFilter struct {
+	Principal  string
+	Action     string
+	OrderUID   string
+	CustomerID string
+	From       *time.Time
+	To         *time.Time
+	Limit      int
+	Offset     int
+}
+
+// An AuditVerification is a structure to keep the result of the audit log hash chain verification.
+// Unsealed entries are not chained yet and aren't verified
+type AuditVerification struct {
+	Valid     bool   `json:"valid"`
+	Checked   int64  `json:"checked"`
+	Unsealed  int64  `json:"unsealed"`
+	BrokenSeq int64  `json:"broken_seq,omitempty"`
+	BrokenID  int64  `json:"broken_id,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// An ErasureResult is a structure to keep the result of a customer erasure request
+type ErasureResult struct {
+	CustomerID string `json:"customer_id"`
+	Orders     int    `json:"orders"`
+}
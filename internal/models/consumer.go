@@ -0,0 +1,71 @@
+package models
+
+import "time"
+
+// Strategies of resetting offsets of the consumer group
+const (
+	OffsetResetEarliest  = "earliest"
+	OffsetResetLatest    = "latest"
+	OffsetResetTimestamp = "timestamp"
+	OffsetResetOffsets   = "offsets"
+)
+
+// States of replays
+const (
+	ReplayRunning   = "running"
+	ReplayCompleted = "completed"
+	ReplayFailed    = "failed"
+)
+
+// A ConsumerStatus is a structure to keep the state of the Kafka consumer
+type ConsumerStatus struct {
+	Running        bool           `json:"running"`
+	Paused         bool           `json:"paused"`
+	PausedManually bool           `json:"paused_manually"`
+	PauseReasons   []string       `json:"pause_reasons,omitempty"`
+	Topics         []string       `json:"topics"`
+	GroupID        string         `json:"group_id"`
+	OffsetStorage  string         `json:"offset_storage"`
+	Replays        []ReplayStatus `json:"replays,omitempty"`
+}
+
+// An OffsetReset is a request to move the consumer group in the topic to the earliest or the latest offsets,
+// to the first offsets after Timestamp or to Offsets of partitions
+type OffsetReset struct {
+	Topic     string        `json:"topic"`
+	To        string        `json:"to"`
+	Timestamp *time.Time    `json:"timestamp,omitempty"`
+	Offsets   map[int]int64 `json:"offsets,omitempty"`
+}
+
+// A Replay is a request to reprocess messages of the topic in a separate consumer group.
+// The range starts from the earliest offsets or the first offsets after FromTime or FromOffsets
+// and ends before the latest offsets at the start or the first offsets after ToTime or ToOffsets
+type Replay struct {
+	Topic       string        `json:"topic"`
+	GroupID     string        `json:"group_id,omitempty"`
+	FromTime    *time.Time    `json:"from_time,omitempty"`
+	ToTime      *time.Time    `json:"to_time,omitempty"`
+	FromOffsets map[int]int64 `json:"from_offsets,omitempty"`
+	ToOffsets   map[int]int64 `json:"to_offsets,omitempty"`
+}
+
+// A ReplayStatus is a structure to keep the progress of a replay
+type ReplayStatus struct {
+	GroupID    string            `json:"group_id"`
+	Topic      string            `json:"topic"`
+	State      string            `json:"state"`
+	Error      string            `json:"error,omitempty"`
+	StartedAt  time.Time         `json:"started_at"`
+	FinishedAt *time.Time        `json:"finished_at,omitempty"`
+	Partitions []PartitionReplay `json:"partitions"`
+}
+
+// A PartitionReplay is the range of offsets [From, To) of the replayed partition
+type PartitionReplay struct {
+	Partition int   `json:"partition"`
+	From      int64 `json:"from"`
+	To        int64 `json:"to"`
+	Processed int64 `json:"processed"`
+	Failed    int64 `json:"failed"`
+}
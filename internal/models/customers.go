@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// A CustomerSummary is a structure to keep aggregated statistics of customer orders
+type CustomerSummary struct {
+	CustomerID     string           `json:"customer_id"`
+	OrderCount     int              `json:"order_count"`
+	TotalSpend     map[string]int64 `json:"total_spend"`
+	FirstOrderDate *time.Time       `json:"first_order_date,omitempty"`
+	LastOrderDate  *time.Time       `json:"last_order_date,omitempty"`
+	FavoriteBrands []BrandCount     `json:"favorite_brands"`
+}
+
+// A BrandCount is a structure to keep the number of items bought of a brand
+type BrandCount struct {
+	Brand     string `json:"brand" db:"brand"`
+	ItemCount int    `json:"item_count" db:"item_count"`
+}
@@ -0,0 +1,90 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Event types
+const (
+	EventOrderSaved = "order.saved"
+)
+
+// Order change types
+const (
+	ChangeOrderProcessed = "order.processed"
+	ChangePaymentUpdated = "payment.updated"
+)
+
+// An OrderChange is a structure to keep a change of an order streamed to clients
+type OrderChange struct {
+	Type            string    `json:"type"`
+	OrderUID        string    `json:"order_uid"`
+	CustomerID      string    `json:"customer_id,omitempty"`
+	DeliveryService string    `json:"delivery_service,omitempty"`
+	Order           *Order    `json:"order,omitempty"`
+	Time            time.Time `json:"time"`
+}
+
+// NewOrderChange creates a new change of the order
+func NewOrderChange(changeType string, order *Order, changedAt time.Time) OrderChange {
+	return OrderChange{
+		Type:            changeType,
+		OrderUID:        order.OrderUID,
+		CustomerID:      order.CustomerID,
+		DeliveryService: order.DeliveryService,
+		Order:           order,
+		Time:            changedAt,
+	}
+}
+
+// An OrderChangeFilter is a structure to keep conditions of streamed changes, empty conditions are not applied
+type OrderChangeFilter struct {
+	CustomerID      string `json:"customer_id,omitempty"`
+	DeliveryService string `json:"delivery_service,omitempty"`
+}
+
+// Match reports whether the change satisfies the filter
+func (f OrderChangeFilter) Match(change OrderChange) bool {
+	return (f.CustomerID == "" || f.CustomerID == change.CustomerID) &&
+		(f.DeliveryService == "" || f.DeliveryService == change.DeliveryService)
+}
+
+// An OutboxEvent is a structure to keep an event stored in the outbox until it's published
+type OutboxEvent struct {
+	ID          int64           `json:"id" db:"id"`
+	AggregateID string          `json:"aggregate_id" db:"aggregate_id"`
+	EventType   string          `json:"event_type" db:"event_type"`
+	Payload     json.RawMessage `json:"payload" db:"payload"`
+	CreatedAt   time.Time       `json:"created_at" db:"created_at"`
+}
+
+// An OrderSavedEvent is a payload of the order.saved event. It doesn't contain personal data of the delivery
+type OrderSavedEvent struct {
+	EventType       string    `json:"event_type"`
+	OrderUID        string    `json:"order_uid"`
+	TrackNumber     string    `json:"track_number"`
+	CustomerID      string    `json:"customer_id"`
+	DeliveryService string    `json:"delivery_service"`
+	Currency        string    `json:"currency"`
+	Amount          int       `json:"amount"`
+	ItemCount       int       `json:"item_count"`
+	DateCreated     time.Time `json:"date_created"`
+	SavedAt         time.Time `json:"saved_at"`
+}
+
+// NewOrderSavedEvent creates a new order.saved event payload for the order
+func NewOrderSavedEvent(order *Order, savedAt time.Time) OrderSavedEvent {
+	return OrderSavedEvent{
+		EventType:       EventOrderSaved,
+		OrderUID:        order.OrderUID,
+		TrackNumber:     order.TrackNumber,
+		CustomerID:      order.CustomerID,
+		DeliveryService: order.DeliveryService,
+		Currency:        order.Payment.Currency,
+		Amount:          order.Payment.Amount,
+		ItemCount:       len(order.Items),
+		DateCreated:     order.DateCreated,
+		SavedAt:         savedAt,
+	}
+}
@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// An OrderFilter is a structure to keep filters of listed orders, empty fields don't filter.
+// Orders are created in the range [From, To)
+type OrderFilter struct {
+	CustomerID      string
+	DeliveryService string
+	Currency        string
+	Locale          string
+	From            *time.Time
+	To              *time.Time
+	Limit           int
+}
@@ -2,6 +2,7 @@
 package models
 
 import (
+	"encoding/json"
 	"fmt"
 	"regexp"
 	"strings"
@@ -10,27 +11,29 @@ import (
 
 // An Order is a structure to keep an order with its payment, delivery and items
 type Order struct {
-	OrderUID          string    `json:"order_uid" db:"order_uid"`
-	TrackNumber       string    `json:"track_number" db:"track_number"`
-	Entry             string    `json:"entry" db:"entry"`
-	DeliveryID        int64     `db:"delivery_id"`
-	Delivery          Delivery  `json:"delivery"`
-	Payment           Payment   `json:"payment"`
-	Items             []Item    `json:"items"`
-	Locale            string    `json:"locale" db:"locale"`
-	InternalSignature string    `json:"internal_signature" db:"internal_signature"`
-	CustomerID        string    `json:"customer_id" db:"customer_id"`
-	DeliveryService   string    `json:"delivery_service" db:"delivery_service"`
-	Shardkey          string    `json:"shardkey" db:"shardkey"`
-	SmID              int       `json:"sm_id" db:"sm_id"`
-	DateCreated       time.Time `json:"date_created" db:"date_created"`
-	OofShard          string    `json:"oof_shard" db:"oof_shard"`
+	OrderUID          string            `json:"order_uid" db:"order_uid"`
+	TrackNumber       string            `json:"track_number" db:"track_number"`
+	Entry             string            `json:"entry" db:"entry"`
+	DeliveryID        int64             `db:"delivery_id"`
+	Delivery          Delivery          `json:"delivery"`
+	Payment           Payment           `json:"payment"`
+	Items             []Item            `json:"items"`
+	Locale            string            `json:"locale" db:"locale"`
+	InternalSignature string            `json:"internal_signature" db:"internal_signature"`
+	CustomerID        string            `json:"customer_id" db:"customer_id"`
+	DeliveryService   string            `json:"delivery_service" db:"delivery_service"`
+	Shardkey          string            `json:"shardkey" db:"shardkey"`
+	SmID              int               `json:"sm_id" db:"sm_id"`
+	DateCreated       time.Time         `json:"date_created" db:"date_created"`
+	OofShard          string            `json:"oof_shard" db:"oof_shard"`
+	BasePayment       *ConvertedPayment `json:"base_payment,omitempty" db:"base_payment"`
+	SchemaVersion     string            `json:"schema_version,omitempty" db:"schema_version"`
 }
 
 // A Delivery is a structure to keep information about order delivery
 type Delivery struct {
 	Name    string `json:"name" db:"name"`
-	Phone   string `json:"phone" db:"phne"`
+	Phone   string `json:"phone" db:"phone"`
 	Zip     string `json:"zip" db:"zip"`
 	City    string `json:"city" db:"city"`
 	Address string `json:"address" db:"address"`
@@ -52,6 +55,17 @@ type Payment struct {
 	CustomFee    int    `json:"custom_fee" db:"custom_fee"`
 }
 
+// A ConvertedPayment is a structure to keep payment amounts converted into another currency.
+// The rate is a decimal number kept as is to not lose its precision
+type ConvertedPayment struct {
+	Currency     string      `json:"currency"`
+	Rate         json.Number `json:"rate"`
+	Amount       int         `json:"amount"`
+	DeliveryCost int         `json:"delivery_cost"`
+	GoodsTotal   int         `json:"goods_total"`
+	CustomFee    int         `json:"custom_fee"`
+}
+
 // An Item is a structure to keep information about one order item
 type Item struct {
 	ChrtID      int64  `json:"chrt_id" db:"chrt_id"`
@@ -360,9 +374,9 @@ func (i *Item) validateLogic() error {
 	if i.TotalPrice != expectedPrice {
 		return NewItemValidationError(
 			"total_price",
-			fmt.Sprintf("total price %d doesn't match price %d with sale %d %", i.TotalPrice, i.Price, i.Sale),
+			fmt.Sprintf("total price %d doesn't match price %d with sale %d%%", i.TotalPrice, i.Price, i.Sale),
 		)
 	}
 
 	return nil
-}
\ No newline at end of file
+}
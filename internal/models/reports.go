@@ -0,0 +1,96 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Groupings of sales reports
+const (
+	GroupDay             = "day"
+	GroupWeek            = "week"
+	GroupMonth           = "month"
+	GroupDeliveryService = "delivery_service"
+	GroupBrand           = "brand"
+	GroupCurrency        = "currency"
+	GroupRegion          = "region"
+	GroupLocale          = "locale"
+)
+
+// ErrInvalidReportQuery is returned when groupings or the range of a report are invalid
+var ErrInvalidReportQuery = errors.New("invalid report query")
+
+// ProbeCustomerID is the customer of orders published by the latency probe, they are excluded from sales reports
+const ProbeCustomerID = "probe"
+
+// Sources of sales reports
+const (
+	ReportSourceLive = "live"
+	ReportSourceView = "materialized_view"
+)
+
+// A SalesReportQuery is a structure to keep groupings and the range [From, To) of orders of a sales report.
+// At most one of day, week and month groupings can be used
+type SalesReportQuery struct {
+	GroupBy []string
+	From    *time.Time
+	To      *time.Time
+}
+
+// A SalesReportRow is a structure to keep sales of a group, only grouped fields are set.
+// Orders are counted in every brand group they have items of, goods total is the sum of item total prices.
+// The delivery cost of an order is counted in the group of its first brand in alphabetical order
+type SalesReportRow struct {
+	Period          *time.Time `json:"period,omitempty" db:"period"`
+	DeliveryService *string    `json:"delivery_service,omitempty" db:"delivery_service"`
+	Brand           *string    `json:"brand,omitempty" db:"brand"`
+	Currency        *string    `json:"currency,omitempty" db:"currency"`
+	Region          *string    `json:"region,omitempty" db:"region"`
+	Locale          *string    `json:"locale,omitempty" db:"locale"`
+	OrderCount      int64      `json:"order_count" db:"order_count"`
+	ItemCount       int64      `json:"item_count" db:"item_count"`
+	GoodsTotal      int64      `json:"goods_total" db:"goods_total"`
+	DeliveryCost    int64      `json:"delivery_cost" db:"delivery_cost"`
+	AverageSale     float64    `json:"average_sale" db:"average_sale"`
+}
+
+// A SalesReport is a structure to keep rows of a sales report with the source they are read from
+type SalesReport struct {
+	GroupBy []string         `json:"group_by"`
+	From    *time.Time       `json:"from,omitempty"`
+	To      *time.Time       `json:"to,omitempty"`
+	Source  string           `json:"source"`
+	Rows    []SalesReportRow `json:"rows"`
+}
+
+// Validate checks if groupings of the query are known and the range isn't empty
+func (q *SalesReportQuery) Validate() error {
+	if len(q.GroupBy) == 0 {
+		return errors.New("at least one grouping is required")
+	}
+
+	seen := make(map[string]bool)
+	periods := 0
+	for _, group := range q.GroupBy {
+		switch group {
+		case GroupDay, GroupWeek, GroupMonth:
+			periods++
+		case GroupDeliveryService, GroupBrand, GroupCurrency, GroupRegion, GroupLocale:
+		default:
+			return fmt.Errorf("unknown grouping %q", group)
+		}
+		if seen[group] {
+			return fmt.Errorf("duplicate grouping %q", group)
+		}
+		seen[group] = true
+	}
+	if periods > 1 {
+		return errors.New("only one of day, week and month groupings can be used")
+	}
+
+	if q.From != nil && q.To != nil && !q.From.Before(*q.To) {
+		return errors.New("from must be before to")
+	}
+	return nil
+}
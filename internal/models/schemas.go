@@ -0,0 +1,24 @@
+package models
+
+import "fmt"
+
+// Types of schemas in the schema registry
+const (
+	SchemaTypeAvro     = "AVRO"
+	SchemaTypeProtobuf = "PROTOBUF"
+	SchemaTypeJSON     = "JSON"
+)
+
+// A Schema is a structure to keep a schema registered in the schema registry
+type Schema struct {
+	ID      int    `json:"id"`
+	Type    string `json:"schemaType"`
+	Schema  string `json:"schema"`
+	Subject string `json:"subject"`
+	Version int    `json:"version"`
+}
+
+// VersionString returns the schema version in the form recorded on stored orders, e.g. "avro:orders-value:3"
+func (s *Schema) VersionString(format string) string {
+	return fmt.Sprintf("%s:%s:%d", format, s.Subject, s.Version)
+}
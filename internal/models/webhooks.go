@@ -0,0 +1,117 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/netip"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Webhook delivery statuses
+const (
+	WebhookPending   = "pending"
+	WebhookDelivered = "delivered"
+	WebhookFailed    = "failed"
+)
+
+// WebhookEventTypes are event types which can be delivered by webhooks
+var WebhookEventTypes = map[string]bool{
+	EventOrderSaved: true,
+}
+
+// blockedWebhookPrefixes are non-public networks not covered by methods of netip.Addr
+var blockedWebhookPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+}
+
+// WebhookAddrAllowed reports whether webhooks can be delivered to the address. Loopback, private, link-local
+// (including cloud metadata endpoints) and other non-public addresses are rejected, so partners
+// can't reach internal services through webhooks
+func WebhookAddrAllowed(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsValid() || addr.IsUnspecified() || addr.IsLoopback() || addr.IsPrivate() ||
+		addr.IsLinkLocalUnicast() || addr.IsMulticast() {
+		return false
+	}
+	for _, prefix := range blockedWebhookPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// A WebhookSubscription is a structure to keep a partner endpoint receiving events of the types.
+// Events are delivered only if they match the customer and delivery service filters, empty filters match all.
+// The secret signs deliveries, it's returned only when the subscription is created
+type WebhookSubscription struct {
+	ID              int64     `json:"id" db:"id"`
+	URL             string    `json:"url" db:"url"`
+	Secret          string    `json:"secret,omitempty" db:"secret"`
+	EventTypes      []string  `json:"event_types" db:"event_types"`
+	CustomerID      string    `json:"customer_id,omitempty" db:"customer_id"`
+	DeliveryService string    `json:"delivery_service,omitempty" db:"delivery_service"`
+	CreatedAt       time.Time `json:"created_at" db:"created_at"`
+}
+
+// Validate checks the subscription has an absolute HTTP URL of a public host and known event types.
+// Host names are resolved only when deliveries are sent, so their addresses are checked at dial time
+func (s *WebhookSubscription) Validate() error {
+	endpoint, err := url.Parse(s.URL)
+	if err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Hostname() == "" {
+		return errors.New("url must be an absolute http or https URL")
+	}
+	host := strings.ToLower(strings.TrimSuffix(endpoint.Hostname(), "."))
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return errors.New("url must not point to a local host")
+	}
+	if addr, err := netip.ParseAddr(host); err == nil && !WebhookAddrAllowed(addr) {
+		return errors.New("url must not point to a private, loopback or link-local address")
+	}
+	if len(s.EventTypes) == 0 {
+		return errors.New("event types are required")
+	}
+	for _, eventType := range s.EventTypes {
+		if !WebhookEventTypes[eventType] {
+			return fmt.Errorf("unknown event type %q", eventType)
+		}
+	}
+	return nil
+}
+
+// A WebhookDelivery is a structure to keep an event delivered to the subscription and the state of delivery.
+// Pending deliveries are attempted after the next attempt time
+type WebhookDelivery struct {
+	ID             int64           `json:"id" db:"id"`
+	SubscriptionID int64           `json:"subscription_id" db:"subscription_id"`
+	EventID        int64           `json:"event_id" db:"event_id"`
+	EventType      string          `json:"event_type" db:"event_type"`
+	Payload        json.RawMessage `json:"payload" db:"payload"`
+	Status         string          `json:"status" db:"status"`
+	Attempts       int             `json:"attempts" db:"attempts"`
+	NextAttemptAt  time.Time       `json:"next_attempt_at" db:"next_attempt_at"`
+	LastStatusCode int             `json:"last_status_code,omitempty" db:"last_status_code"`
+	LastError      string          `json:"last_error,omitempty" db:"last_error"`
+	CreatedAt      time.Time       `json:"created_at" db:"created_at"`
+	DeliveredAt    *time.Time      `json:"delivered_at,omitempty" db:"delivered_at"`
+	URL            string          `json:"-" db:"url"`
+	Secret         string          `json:"-" db:"secret"`
+}
+
+// A WebhookAttempt is a structure to keep the result of a delivery attempt. Attempts deferred
+// by the circuit breaker aren't counted
+type WebhookAttempt struct {
+	DeliveryID    int64
+	Status        string
+	Counted       bool
+	StatusCode    int
+	Error         string
+	NextAttemptAt time.Time
+}
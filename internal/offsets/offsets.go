@@ -0,0 +1,32 @@
+// Package offsets implements passing positions of consumed messages through context,
+// so they can be stored in the same transaction as the processing result
+package offsets
+
+import "context"
+
+// A Position is a position of a consumed message in a topic partition
+type Position struct {
+	GroupID   string
+	Topic     string
+	Partition int
+	Offset    int64
+}
+
+// Next returns the offset to continue consumption from after the message is processed
+func (p Position) Next() int64 {
+	return p.Offset + 1
+}
+
+// positionKey is a context key for Position
+type positionKey struct{}
+
+// WithPosition returns a copy of the context with the message position
+func WithPosition(ctx context.Context, position Position) context.Context {
+	return context.WithValue(ctx, positionKey{}, position)
+}
+
+// FromContext returns the message position of the context
+func FromContext(ctx context.Context) (Position, bool) {
+	position, ok := ctx.Value(positionKey{}).(Position)
+	return position, ok
+}
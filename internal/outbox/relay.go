@@ -0,0 +1,167 @@
+// Package outbox implements a relay publishing events from the transactional outbox
+package outbox
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"l0/internal/config"
+	"l0/internal/interfaces"
+	"l0/internal/metrics"
+	"l0/internal/models"
+)
+
+// A Relay periodically publishes pending outbox events and removes old published ones.
+// Events are marked published only after the publisher confirmed them, so delivery is at-least-once
+type Relay struct {
+	store     interfaces.OutboxStore
+	publisher interfaces.EventPublisher
+	config    config.OutboxConfig
+	logger    *zerolog.Logger
+	published *metrics.Counter
+	failures  *metrics.Counter
+	// keepPendingWebhooks keeps published events until they are enqueued for webhook deliveries
+	keepPendingWebhooks bool
+
+	mu      sync.Mutex
+	running bool
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+// NewRelay creates a new relay, zero settings are replaced with defaults
+func NewRelay(
+	store interfaces.OutboxStore, publisher interfaces.EventPublisher, cfg config.OutboxConfig,
+	logger *zerolog.Logger,
+) *Relay {
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = time.Second
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 100
+	}
+	if cfg.CleanupInterval <= 0 {
+		cfg.CleanupInterval = time.Hour
+	}
+
+	return &Relay{
+		store:     store,
+		publisher: publisher,
+		config:    cfg,
+		logger:    logger,
+		published: metrics.DefaultRegistry.Counter("outbox_published_events_total", "Number of published outbox events"),
+		failures:  metrics.DefaultRegistry.Counter("outbox_publish_failures_total", "Number of failed outbox publishes"),
+	}
+}
+
+// KeepPendingWebhooks makes the cleanup keep published events not enqueued for webhook deliveries yet,
+// so a lagging webhook worker doesn't lose them. It has to be called before Start
+func (r *Relay) KeepPendingWebhooks() {
+	r.keepPendingWebhooks = true
+}
+
+// Start starts the relay in background
+func (r *Relay) Start(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.running {
+		return errors.New("outbox relay is already running")
+	}
+
+	ctx, r.cancel = context.WithCancel(ctx)
+	r.running = true
+
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		r.run(ctx)
+	}()
+
+	return nil
+}
+
+// Stop stops the relay and waits for the current batch to finish
+func (r *Relay) Stop() {
+	r.mu.Lock()
+	if !r.running {
+		r.mu.Unlock()
+		return
+	}
+	r.running = false
+	r.cancel()
+	r.mu.Unlock()
+
+	r.wg.Wait()
+}
+
+// run publishes events until the context is done
+func (r *Relay) run(ctx context.Context) {
+	pollTicker := time.NewTicker(r.config.PollInterval)
+	defer pollTicker.Stop()
+	cleanupTicker := time.NewTicker(r.config.CleanupInterval)
+	defer cleanupTicker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-pollTicker.C:
+			r.publishPending(ctx)
+		case <-cleanupTicker.C:
+			r.cleanup(ctx)
+		}
+	}
+}
+
+// publishPending publishes batches of pending events while there are full batches
+func (r *Relay) publishPending(ctx context.Context) {
+	for ctx.Err() == nil {
+		processed, err := r.PublishBatch(ctx)
+		if err != nil {
+			r.failures.Inc()
+			r.logger.Error().Err(err).Msg("Failed to publish outbox events")
+			return
+		}
+		if processed < r.config.BatchSize {
+			return
+		}
+	}
+}
+
+// PublishBatch publishes one batch of pending events and returns the number of published events
+func (r *Relay) PublishBatch(ctx context.Context) (int, error) {
+	processed, err := r.store.ProcessPendingEvents(
+		ctx, r.config.BatchSize, func(events []models.OutboxEvent) error {
+			publishCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+			defer cancel()
+			return r.publisher.Publish(publishCtx, events)
+		},
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	r.published.Add(int64(processed))
+	return processed, nil
+}
+
+// cleanup removes events published before the retention period
+func (r *Relay) cleanup(ctx context.Context) {
+	if r.config.Retention <= 0 {
+		return
+	}
+
+	deleted, err := r.store.DeletePublishedEvents(ctx, time.Now().Add(-r.config.Retention), r.keepPendingWebhooks)
+	if err != nil {
+		r.logger.Error().Err(err).Msg("Failed to clean up published outbox events")
+		return
+	}
+	if deleted > 0 {
+		r.logger.Info().Int64("deleted", deleted).Msg("Published outbox events cleaned up")
+	}
+}
@@ -0,0 +1,142 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"os"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"l0/internal/config"
+	"l0/internal/models"
+)
+
+// A mockStore is a not thread-safe mock implementation of OutboxStore for testing
+type mockStore struct {
+	events    []models.OutboxEvent
+	published []int64
+	// keptPendingWebhooks records the flag of the last cleanup
+	keptPendingWebhooks bool
+	cleanups            int
+}
+
+func (m *mockStore) ProcessPendingEvents(
+	ctx context.Context, limit int, publish func([]models.OutboxEvent) error,
+) (int, error) {
+	var pending []models.OutboxEvent
+	for _, event := range m.events {
+		if !slices.Contains(m.published, event.ID) && len(pending) < limit {
+			pending = append(pending, event)
+		}
+	}
+	if len(pending) == 0 {
+		return 0, nil
+	}
+	if err := publish(pending); err != nil {
+		return 0, err
+	}
+	for _, event := range pending {
+		m.published = append(m.published, event.ID)
+	}
+	return len(pending), nil
+}
+
+func (m *mockStore) DeletePublishedEvents(
+	ctx context.Context, before time.Time, keepPendingWebhooks bool,
+) (int64, error) {
+	m.cleanups++
+	m.keptPendingWebhooks = keepPendingWebhooks
+	return 0, nil
+}
+
+// A mockPublisher is a mock implementation of EventPublisher recording published events
+type mockPublisher struct {
+	events []models.OutboxEvent
+	err    error
+}
+
+func (m *mockPublisher) Publish(ctx context.Context, events []models.OutboxEvent) error {
+	if m.err != nil {
+		return m.err
+	}
+	m.events = append(m.events, events...)
+	return nil
+}
+
+func newEvents(n int) []models.OutboxEvent {
+	events := make([]models.OutboxEvent, n)
+	for i := range events {
+		events[i] = models.OutboxEvent{ID: int64(i + 1), AggregateID: "order", EventType: models.EventOrderSaved}
+	}
+	return events
+}
+
+func TestRelay_PublishBatch(t *testing.T) {
+	store := &mockStore{events: newEvents(3)}
+	publisher := &mockPublisher{}
+	logger := zerolog.New(os.Stdout)
+	r := NewRelay(store, publisher, config.OutboxConfig{BatchSize: 2}, &logger)
+
+	processed, err := r.PublishBatch(context.Background())
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if processed != 2 {
+		t.Errorf("error: expected 2 events in batch, got %d", processed)
+	}
+
+	r.publishPending(context.Background())
+	if len(publisher.events) != 3 {
+		t.Fatalf("error: expected 3 published events, got %d", len(publisher.events))
+	}
+	for i, event := range publisher.events {
+		if event.ID != int64(i+1) {
+			t.Errorf("error: expected events in order of creation, got %d at %d", event.ID, i)
+		}
+	}
+}
+
+func TestRelay_PublishFailure(t *testing.T) {
+	store := &mockStore{events: newEvents(2)}
+	publisher := &mockPublisher{err: errors.New("kafka mock error")}
+	logger := zerolog.New(os.Stdout)
+	r := NewRelay(store, publisher, config.OutboxConfig{BatchSize: 10}, &logger)
+
+	if _, err := r.PublishBatch(context.Background()); err == nil {
+		t.Errorf("error: expected publish error")
+	}
+	if len(store.published) != 0 {
+		t.Errorf("error: expected events to stay pending after failure")
+	}
+
+	publisher.err = nil
+	if processed, err := r.PublishBatch(context.Background()); err != nil || processed != 2 {
+		t.Errorf("error: expected pending events to be published on retry, got %d, %v", processed, err)
+	}
+}
+
+func TestRelay_CleanupKeepsPendingWebhooks(t *testing.T) {
+	store := &mockStore{}
+	logger := zerolog.New(os.Stdout)
+	r := NewRelay(store, &mockPublisher{}, config.OutboxConfig{Retention: time.Hour}, &logger)
+
+	r.cleanup(context.Background())
+	if store.cleanups != 1 || store.keptPendingWebhooks {
+		t.Errorf("error: expected published events to be removed regardless of webhooks")
+	}
+
+	r.KeepPendingWebhooks()
+	r.cleanup(context.Background())
+	if store.cleanups != 2 || !store.keptPendingWebhooks {
+		t.Errorf("error: expected events pending for webhooks to be kept")
+	}
+
+	r = NewRelay(store, &mockPublisher{}, config.OutboxConfig{}, &logger)
+	r.cleanup(context.Background())
+	if store.cleanups != 2 {
+		t.Errorf("error: expected no cleanup without retention")
+	}
+}
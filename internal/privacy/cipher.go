@@ -0,0 +1,137 @@
+// Package privacy implements protection of personal data: field-level encryption and masking
+package privacy
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"l0/internal/config"
+)
+
+// encryptedPrefix marks values encrypted by FieldCipher, other values are treated as plaintext
+const encryptedPrefix = "enc:"
+
+// ErrUnknownKey is returned when a value is encrypted with a key that is not in the key ring
+var ErrUnknownKey = errors.New("unknown encryption key")
+
+// A FieldCipher encrypts single fields with AES-GCM. Every value keeps the ID of the key it was encrypted with,
+// so old keys stay usable for decryption while new values are encrypted with the active key
+type FieldCipher struct {
+	aeads       map[string]cipher.AEAD
+	activeKeyID string
+}
+
+// NewFieldCipher creates a new cipher from 128, 192 or 256-bit keys mapped by their IDs
+func NewFieldCipher(keys map[string][]byte, activeKeyID string) (*FieldCipher, error) {
+	if _, ok := keys[activeKeyID]; !ok {
+		return nil, fmt.Errorf("%w: active key %q", ErrUnknownKey, activeKeyID)
+	}
+
+	aeads := make(map[string]cipher.AEAD, len(keys))
+	for id, key := range keys {
+		if id == "" || strings.Contains(id, ":") {
+			return nil, fmt.Errorf("invalid key id: %q", id)
+		}
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid key %q: %w", id, err)
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+		aeads[id] = aead
+	}
+
+	return &FieldCipher{aeads: aeads, activeKeyID: activeKeyID}, nil
+}
+
+// NewFieldCipherWithConfig creates a new cipher from base64 encoded keys in configuration.
+// It returns nil without an error if encryption is not configured
+func NewFieldCipherWithConfig(cfg config.EncryptionConfig) (*FieldCipher, error) {
+	if cfg.ActiveKeyID == "" && len(cfg.Keys) == 0 {
+		return nil, nil
+	}
+
+	keys := make(map[string][]byte, len(cfg.Keys))
+	for id, encoded := range cfg.Keys {
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode key %q: %w", id, err)
+		}
+		keys[id] = key
+	}
+
+	return NewFieldCipher(keys, cfg.ActiveKeyID)
+}
+
+// ActiveKeyID returns the ID of the key used for encryption
+func (c *FieldCipher) ActiveKeyID() string {
+	return c.activeKeyID
+}
+
+// Encrypt encrypts the value with the active key, field is used as additional data
+// so an encrypted value cannot be moved into another field
+func (c *FieldCipher) Encrypt(field, value string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+
+	aead := c.aeads[c.activeKeyID]
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	sealed := aead.Seal(nonce, nonce, []byte(value), []byte(field))
+	return encryptedPrefix + c.activeKeyID + ":" + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt decrypts the value with the key it was encrypted with, plaintext values are returned as is
+func (c *FieldCipher) Decrypt(field, value string) (string, error) {
+	keyID, payload, ok := parseEncrypted(value)
+	if !ok {
+		return value, nil
+	}
+
+	aead, ok := c.aeads[keyID]
+	if !ok {
+		return "", fmt.Errorf("%w: %q", ErrUnknownKey, keyID)
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(payload)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode encrypted %s: %w", field, err)
+	}
+	if len(sealed) < aead.NonceSize() {
+		return "", fmt.Errorf("encrypted %s is too short", field)
+	}
+
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, []byte(field))
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt %s: %w", field, err)
+	}
+
+	return string(plaintext), nil
+}
+
+// KeyID returns the ID of the key the value was encrypted with, ok is false for plaintext values
+func KeyID(value string) (keyID string, ok bool) {
+	keyID, _, ok = parseEncrypted(value)
+	return keyID, ok
+}
+
+// parseEncrypted splits the encrypted value into key ID and payload
+func parseEncrypted(value string) (keyID, payload string, ok bool) {
+	rest, ok := strings.CutPrefix(value, encryptedPrefix)
+	if !ok {
+		return "", "", false
+	}
+	return strings.Cut(rest, ":")
+}
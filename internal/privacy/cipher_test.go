@@ -0,0 +1,106 @@
+package privacy
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func newTestCipher(t *testing.T, activeKeyID string, keyIDs ...string) *FieldCipher {
+	t.Helper()
+	keys := make(map[string][]byte, len(keyIDs))
+	for i, id := range keyIDs {
+		keys[id] = bytes.Repeat([]byte{byte(i + 1)}, 32)
+	}
+	c, err := NewFieldCipher(keys, activeKeyID)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	return c
+}
+
+func TestFieldCipher_EncryptDecrypt(t *testing.T) {
+	c := newTestCipher(t, "k1", "k1")
+
+	encrypted, err := c.Encrypt("phone", "+7(999)777-32-32")
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if strings.Contains(encrypted, "777") {
+		t.Errorf("error: expected value to be encrypted, got %s", encrypted)
+	}
+	if keyID, ok := KeyID(encrypted); !ok || keyID != "k1" {
+		t.Errorf("error: expected key id k1, got %s", keyID)
+	}
+
+	decrypted, err := c.Decrypt("phone", encrypted)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if decrypted != "+7(999)777-32-32" {
+		t.Errorf("error: expected original value, got %s", decrypted)
+	}
+}
+
+func TestFieldCipher_WrongField(t *testing.T) {
+	c := newTestCipher(t, "k1", "k1")
+
+	encrypted, err := c.Encrypt("phone", "+7(999)777-32-32")
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if _, err := c.Decrypt("email", encrypted); err == nil {
+		t.Errorf("error: expected error for value moved into another field")
+	}
+}
+
+func TestFieldCipher_Plaintext(t *testing.T) {
+	c := newTestCipher(t, "k1", "k1")
+
+	decrypted, err := c.Decrypt("name", "Test User")
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if decrypted != "Test User" {
+		t.Errorf("error: expected plaintext value as is, got %s", decrypted)
+	}
+}
+
+func TestFieldCipher_Rotation(t *testing.T) {
+	old := newTestCipher(t, "k1", "k1")
+	encrypted, err := old.Encrypt("name", "Test User")
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	rotated := newTestCipher(t, "k2", "k1", "k2")
+	decrypted, err := rotated.Decrypt("name", encrypted)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if decrypted != "Test User" {
+		t.Errorf("error: expected value encrypted with old key to be decrypted, got %s", decrypted)
+	}
+
+	reencrypted, err := rotated.Encrypt("name", decrypted)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if keyID, _ := KeyID(reencrypted); keyID != "k2" {
+		t.Errorf("error: expected active key k2, got %s", keyID)
+	}
+
+	if _, err := old.Decrypt("name", reencrypted); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("error: expected ErrUnknownKey, got %v", err)
+	}
+}
+
+func TestNewFieldCipher_InvalidKeys(t *testing.T) {
+	if _, err := NewFieldCipher(map[string][]byte{"k1": make([]byte, 32)}, "k2"); err == nil {
+		t.Errorf("error: expected error for missing active key")
+	}
+	if _, err := NewFieldCipher(map[string][]byte{"k1": make([]byte, 10)}, "k1"); err == nil {
+		t.Errorf("error: expected error for invalid key size")
+	}
+}
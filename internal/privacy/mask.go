@@ -0,0 +1,116 @@
+package privacy
+
+import (
+	"strings"
+	"unicode"
+
+	"l0/internal/models"
+)
+
+// An Access is a level of access to personal data
+type Access int
+
+const (
+	// AccessPublic hides personal data completely
+	AccessPublic Access = iota
+	// AccessSupport shows only parts of personal data, enough to identify a customer
+	AccessSupport
+	// AccessPrivileged shows personal data as is
+	AccessPrivileged
+)
+
+// hidden replaces completely masked values
+const hidden = "***"
+
+// MaskDelivery returns a copy of the delivery with personal data masked according to the access level
+func MaskDelivery(d models.Delivery, access Access) models.Delivery {
+	switch access {
+	case AccessPrivileged:
+		return d
+	case AccessSupport:
+		d.Name = MaskName(d.Name)
+		d.Phone = MaskPhone(d.Phone)
+		d.Address = MaskAddress(d.Address)
+		d.Email = MaskEmail(d.Email)
+		return d
+	default:
+		d.Name = hide(d.Name)
+		d.Phone = hide(d.Phone)
+		d.Address = hide(d.Address)
+		d.Email = hide(d.Email)
+		d.Zip = hide(d.Zip)
+		return d
+	}
+}
+
+// MaskPhone keeps the country code and the last 4 digits, e.g. +7(999)777-32-32 becomes +7(***)***-32-32
+func MaskPhone(phone string) string {
+	digits := 0
+	for _, r := range phone {
+		if unicode.IsDigit(r) {
+			digits++
+		}
+	}
+
+	keepFirst, keepLast := 1, 4
+	if digits <= keepFirst+keepLast {
+		keepFirst, keepLast = 0, min(2, digits)
+	}
+
+	var b strings.Builder
+	idx := 0
+	for _, r := range phone {
+		if !unicode.IsDigit(r) {
+			b.WriteRune(r)
+			continue
+		}
+		if idx < keepFirst || idx >= digits-keepLast {
+			b.WriteRune(r)
+		} else {
+			b.WriteRune('*')
+		}
+		idx++
+	}
+
+	return b.String()
+}
+
+// MaskEmail keeps the first letter of the local part and the domain, e.g. t***@example.com
+func MaskEmail(email string) string {
+	local, domain, ok := strings.Cut(email, "@")
+	if !ok {
+		return hide(email)
+	}
+	if local == "" {
+		return hidden + "@" + domain
+	}
+	return string([]rune(local)[0]) + hidden + "@" + domain
+}
+
+// MaskName keeps the first letter of every word, e.g. T*** U***
+func MaskName(name string) string {
+	words := strings.Fields(name)
+	for i, word := range words {
+		words[i] = string([]rune(word)[0]) + hidden
+	}
+	return strings.Join(words, " ")
+}
+
+// MaskAddress hides house and apartment numbers keeping the street, e.g. *** Test St
+func MaskAddress(address string) string {
+	words := strings.Fields(address)
+	for i, word := range words {
+		if strings.ContainsFunc(word, unicode.IsDigit) {
+			words[i] = hidden
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+// hide replaces a non-empty value completely
+func hide(value string) string {
+	if value == "" {
+		return ""
+	}
+	return hidden
+}
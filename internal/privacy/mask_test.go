@@ -0,0 +1,61 @@
+package privacy
+
+import (
+	"testing"
+
+	"l0/internal/models"
+)
+
+func TestMaskPhone(t *testing.T) {
+	cases := map[string]string{
+		"+7(999)777-32-32": "+7(***)***-32-32",
+		"89997773232":      "8******3232",
+		"1234":             "**34",
+	}
+	for phone, expected := range cases {
+		if masked := MaskPhone(phone); masked != expected {
+			t.Errorf("error: expected %s, got %s", expected, masked)
+		}
+	}
+}
+
+func TestMaskEmail(t *testing.T) {
+	if masked := MaskEmail("test@example.com"); masked != "t***@example.com" {
+		t.Errorf("error: expected t***@example.com, got %s", masked)
+	}
+	if masked := MaskEmail("invalid"); masked != "***" {
+		t.Errorf("error: expected ***, got %s", masked)
+	}
+}
+
+func TestMaskDelivery(t *testing.T) {
+	delivery := models.Delivery{
+		Name:    "Test User",
+		Phone:   "+7(999)777-32-32",
+		Zip:     "12345",
+		City:    "Test City",
+		Address: "123 Test St",
+		Region:  "NY",
+		Email:   "test@example.com",
+	}
+
+	if masked := MaskDelivery(delivery, AccessPrivileged); masked != delivery {
+		t.Errorf("error: expected delivery as is for privileged access")
+	}
+
+	masked := MaskDelivery(delivery, AccessSupport)
+	if masked.Name != "T*** U***" || masked.Address != "*** Test St" || masked.Phone != "+7(***)***-32-32" {
+		t.Errorf("error: unexpected partial masking: %+v", masked)
+	}
+	if masked.City != delivery.City {
+		t.Errorf("error: expected city not to be masked")
+	}
+
+	masked = MaskDelivery(delivery, AccessPublic)
+	if masked.Name != "***" || masked.Phone != "***" || masked.Email != "***" || masked.Zip != "***" {
+		t.Errorf("error: unexpected full masking: %+v", masked)
+	}
+	if delivery.Name != "Test User" {
+		t.Errorf("error: expected original delivery not to be changed")
+	}
+}
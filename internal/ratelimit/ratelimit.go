@@ -0,0 +1,142 @@
+// Package ratelimit implements token bucket rate limiting per client key
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// A TokenBucket is a not thread-safe token bucket refilled with rate tokens per second up to burst tokens
+type TokenBucket struct {
+	rate     float64
+	burst    float64
+	tokens   float64
+	lastSeen time.Time
+}
+
+// NewTokenBucket creates a new full bucket
+func NewTokenBucket(rate float64, burst int, now time.Time) *TokenBucket {
+	return &TokenBucket{rate: rate, burst: float64(burst), tokens: float64(burst), lastSeen: now}
+}
+
+// Allow takes a token from the bucket. If there are no tokens it returns false
+// and the time until the next token is available
+func (b *TokenBucket) Allow(now time.Time) (bool, time.Duration) {
+	b.refill(now)
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	return false, b.untilToken()
+}
+
+// Delay returns the time until a token is available without taking it, 0 means a token is available now
+func (b *TokenBucket) Delay(now time.Time) time.Duration {
+	b.refill(now)
+
+	if b.tokens >= 1 {
+		return 0
+	}
+	return b.untilToken()
+}
+
+// refill adds tokens for the time elapsed since the bucket was last seen
+func (b *TokenBucket) refill(now time.Time) {
+	if elapsed := now.Sub(b.lastSeen).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(b.burst, b.tokens+elapsed*b.rate)
+	}
+	b.lastSeen = now
+}
+
+// untilToken returns the time until the bucket has a whole token
+func (b *TokenBucket) untilToken() time.Duration {
+	if b.rate <= 0 {
+		return time.Duration(math.MaxInt64)
+	}
+	return time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+}
+
+// A KeyedLimiter is a thread-safe rate limiter with a separate token bucket for every key.
+// Buckets that were not used for ttl are removed
+type KeyedLimiter struct {
+	mu        sync.Mutex
+	rate      float64
+	burst     int
+	ttl       time.Duration
+	buckets   map[string]*TokenBucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// NewKeyedLimiter creates a new limiter allowing rate requests per second with bursts up to burst requests per key
+func NewKeyedLimiter(rate float64, burst int, ttl time.Duration) *KeyedLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	if ttl <= 0 {
+		ttl = 10 * time.Minute
+	}
+	return &KeyedLimiter{
+		rate:      rate,
+		burst:     burst,
+		ttl:       ttl,
+		buckets:   make(map[string]*TokenBucket),
+		lastSweep: time.Now(),
+		now:       time.Now,
+	}
+}
+
+// Allow takes a token from the bucket of the key, see TokenBucket.Allow
+func (l *KeyedLimiter) Allow(key string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	bucket, ok := l.buckets[key]
+	if !ok {
+		bucket = NewTokenBucket(l.rate, l.burst, now)
+		l.buckets[key] = bucket
+	}
+	return bucket.Allow(now)
+}
+
+// Delay returns the time until the bucket of the key has a token without taking it, see TokenBucket.Delay.
+// Keys without a bucket have a full one
+func (l *KeyedLimiter) Delay(key string) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	bucket, ok := l.buckets[key]
+	if !ok {
+		return 0
+	}
+	return bucket.Delay(now)
+}
+
+// Size returns the number of tracked keys
+func (l *KeyedLimiter) Size() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return len(l.buckets)
+}
+
+// sweep removes idle buckets not more often than once per ttl
+func (l *KeyedLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < l.ttl {
+		return
+	}
+	for key, bucket := range l.buckets {
+		if now.Sub(bucket.lastSeen) >= l.ttl {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastSweep = now
+}
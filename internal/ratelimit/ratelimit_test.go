@@ -0,0 +1,90 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTokenBucket_Allow(t *testing.T) {
+	now := time.Now()
+	b := NewTokenBucket(2, 3, now)
+
+	for i := 0; i < 3; i++ {
+		if ok, _ := b.Allow(now); !ok {
+			t.Fatalf("error: expected burst request %d to be allowed", i+1)
+		}
+	}
+
+	ok, retryAfter := b.Allow(now)
+	if ok {
+		t.Errorf("error: expected request over burst to be limited")
+	}
+	if retryAfter != 500*time.Millisecond {
+		t.Errorf("error: expected retry after 500ms, got %v", retryAfter)
+	}
+
+	if ok, _ := b.Allow(now.Add(500 * time.Millisecond)); !ok {
+		t.Errorf("error: expected request to be allowed after refill")
+	}
+}
+
+func TestKeyedLimiter_Allow(t *testing.T) {
+	l := NewKeyedLimiter(1, 1, time.Minute)
+	now := time.Now()
+	l.now = func() time.Time { return now }
+
+	if ok, _ := l.Allow("client1"); !ok {
+		t.Errorf("error: expected first request to be allowed")
+	}
+	if ok, _ := l.Allow("client1"); ok {
+		t.Errorf("error: expected second request of the same client to be limited")
+	}
+	if ok, _ := l.Allow("client2"); !ok {
+		t.Errorf("error: expected request of another client to be allowed")
+	}
+}
+
+func TestKeyedLimiter_Sweep(t *testing.T) {
+	l := NewKeyedLimiter(1, 1, time.Minute)
+	now := time.Now()
+	l.now = func() time.Time { return now }
+	l.lastSweep = now
+
+	l.Allow("client1")
+	l.Allow("client2")
+	if l.Size() != 2 {
+		t.Fatalf("error: expected 2 keys, got %d", l.Size())
+	}
+
+	now = now.Add(2 * time.Minute)
+	l.Allow("client3")
+	if l.Size() != 1 {
+		t.Errorf("error: expected idle keys to be removed, got %d keys", l.Size())
+	}
+}
+
+func TestKeyedLimiter_Delay(t *testing.T) {
+	l := NewKeyedLimiter(1, 2, time.Minute)
+	now := time.Now()
+	l.now = func() time.Time { return now }
+
+	if delay := l.Delay("client1"); delay != 0 || l.Size() != 0 {
+		t.Errorf("error: expected an unknown client to have a token without tracking it, got %v", delay)
+	}
+	l.Allow("client1")
+	if delay := l.Delay("client1"); delay != 0 {
+		t.Errorf("error: expected a token left, got %v", delay)
+	}
+	l.Allow("client1")
+	if delay := l.Delay("client1"); delay != time.Second {
+		t.Errorf("error: expected a token in 1s, got %v", delay)
+	}
+	if delay := l.Delay("client1"); delay != time.Second {
+		t.Errorf("error: expected Delay not to take tokens, got %v", delay)
+	}
+
+	now = now.Add(time.Second)
+	if delay := l.Delay("client1"); delay != 0 {
+		t.Errorf("error: expected a token after refill, got %v", delay)
+	}
+}
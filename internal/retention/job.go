@@ -0,0 +1,153 @@
+// Package retention implements a job deleting or anonymizing orders older than the retention period
+package retention
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"l0/internal/config"
+	"l0/internal/interfaces"
+	"l0/internal/metrics"
+)
+
+// Retention modes
+const (
+	ModeDelete    = "delete"
+	ModeAnonymize = "anonymize"
+)
+
+// A Job periodically deletes or anonymizes expired orders in batches and evicts them from the cache
+type Job struct {
+	store   interfaces.RetentionStore
+	cache   interfaces.CacheEvicter
+	config  config.RetentionConfig
+	logger  *zerolog.Logger
+	expired *metrics.Counter
+
+	mu      sync.Mutex
+	running bool
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+// NewJob creates a new retention job, zero settings are replaced with defaults
+func NewJob(
+	store interfaces.RetentionStore, cache interfaces.CacheEvicter, cfg config.RetentionConfig,
+	logger *zerolog.Logger,
+) *Job {
+	if cfg.Interval <= 0 {
+		cfg.Interval = time.Hour
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 500
+	}
+	if cfg.Mode == "" {
+		cfg.Mode = ModeAnonymize
+	}
+
+	return &Job{
+		store:  store,
+		cache:  cache,
+		config: cfg,
+		logger: logger,
+		expired: metrics.DefaultRegistry.Counter(
+			"retention_expired_orders_total", "Number of orders deleted or anonymized by retention", "mode", cfg.Mode,
+		),
+	}
+}
+
+// Start starts the job in background, the first run is done immediately
+func (j *Job) Start(ctx context.Context) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.running {
+		return errors.New("retention job is already running")
+	}
+
+	ctx, j.cancel = context.WithCancel(ctx)
+	j.running = true
+
+	j.wg.Add(1)
+	go func() {
+		defer j.wg.Done()
+		j.run(ctx)
+	}()
+
+	return nil
+}
+
+// Stop stops the job and waits for the current batch to finish
+func (j *Job) Stop() {
+	j.mu.Lock()
+	if !j.running {
+		j.mu.Unlock()
+		return
+	}
+	j.running = false
+	j.cancel()
+	j.mu.Unlock()
+
+	j.wg.Wait()
+}
+
+// run expires orders until the context is done
+func (j *Job) run(ctx context.Context) {
+	ticker := time.NewTicker(j.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := j.RunOnce(ctx); err != nil && ctx.Err() == nil {
+			j.logger.Error().Err(err).Msg("Failed to expire orders")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce expires batches of orders older than max age until there are no full batches left.
+// It returns the number of expired orders
+func (j *Job) RunOnce(ctx context.Context) (int, error) {
+	expire := j.store.AnonymizeOrdersBefore
+	switch j.config.Mode {
+	case ModeAnonymize:
+	case ModeDelete:
+		expire = j.store.DeleteOrdersBefore
+	default:
+		return 0, fmt.Errorf("unknown retention mode %q", j.config.Mode)
+	}
+
+	before := time.Now().Add(-j.config.MaxAge)
+	total := 0
+	for ctx.Err() == nil {
+		orderUIDs, err := expire(ctx, before, j.config.BatchSize)
+		if err != nil {
+			return total, err
+		}
+		j.cache.EvictCache(orderUIDs...)
+		j.expired.Add(int64(len(orderUIDs)))
+		total += len(orderUIDs)
+
+		if len(orderUIDs) < j.config.BatchSize {
+			break
+		}
+	}
+
+	if total > 0 {
+		j.logger.Info().
+			Int("orders", total).
+			Str("mode", j.config.Mode).
+			Time("before", before).
+			Msg("Expired orders processed")
+	}
+	return total, nil
+}
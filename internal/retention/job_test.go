@@ -0,0 +1,112 @@
+package retention
+
+import (
+	"context"
+	"errors"
+	"os"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"l0/internal/config"
+)
+
+// A mockStore is a not thread-safe mock implementation of RetentionStore for testing
+type mockStore struct {
+	created    map[string]time.Time
+	deleted    []string
+	anonymized []string
+	err        error
+}
+
+func (m *mockStore) expire(before time.Time, limit int, done *[]string) ([]string, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	var expired []string
+	for orderUID, created := range m.created {
+		if created.Before(before) && !slices.Contains(*done, orderUID) && len(expired) < limit {
+			expired = append(expired, orderUID)
+		}
+	}
+	*done = append(*done, expired...)
+	return expired, nil
+}
+
+func (m *mockStore) DeleteOrdersBefore(ctx context.Context, before time.Time, limit int) ([]string, error) {
+	return m.expire(before, limit, &m.deleted)
+}
+
+func (m *mockStore) AnonymizeOrdersBefore(ctx context.Context, before time.Time, limit int) ([]string, error) {
+	return m.expire(before, limit, &m.anonymized)
+}
+
+// A mockCache records evicted orders
+type mockCache struct {
+	evicted []string
+}
+
+func (m *mockCache) EvictCache(orderUIDs ...string) {
+	m.evicted = append(m.evicted, orderUIDs...)
+}
+
+func newTestStore() *mockStore {
+	now := time.Now()
+	return &mockStore{
+		created: map[string]time.Time{
+			"order1": now.Add(-48 * time.Hour),
+			"order2": now.Add(-72 * time.Hour),
+			"order3": now.Add(-96 * time.Hour),
+			"order4": now.Add(-time.Hour),
+		},
+	}
+}
+
+func TestJob_RunOnce(t *testing.T) {
+	logger := zerolog.New(os.Stdout)
+	for _, mode := range []string{ModeAnonymize, ModeDelete} {
+		store := newTestStore()
+		cache := &mockCache{}
+		job := NewJob(store, cache, config.RetentionConfig{MaxAge: 24 * time.Hour, Mode: mode, BatchSize: 2}, &logger)
+
+		expired, err := job.RunOnce(context.Background())
+		if err != nil {
+			t.Fatalf("error: %v", err)
+		}
+		if expired != 3 {
+			t.Errorf("error: expected 3 %s orders, got %d", mode, expired)
+		}
+
+		processed := store.anonymized
+		if mode == ModeDelete {
+			processed = store.deleted
+			if len(store.anonymized) != 0 {
+				t.Errorf("error: expected no anonymized orders in the delete mode")
+			}
+		}
+		if len(processed) != 3 || slices.Contains(processed, "order4") {
+			t.Errorf("error: expected orders older than max age to be %s, got %v", mode, processed)
+		}
+		if len(cache.evicted) != 3 {
+			t.Errorf("error: expected expired orders to be evicted from cache, got %v", cache.evicted)
+		}
+	}
+}
+
+func TestJob_RunOnceErrors(t *testing.T) {
+	logger := zerolog.New(os.Stdout)
+
+	store := newTestStore()
+	store.err = errors.New("database is not available")
+	job := NewJob(store, &mockCache{}, config.RetentionConfig{MaxAge: time.Hour}, &logger)
+	if _, err := job.RunOnce(context.Background()); err == nil {
+		t.Errorf("error: expected store error")
+	}
+
+	job = NewJob(newTestStore(), &mockCache{}, config.RetentionConfig{MaxAge: time.Hour, Mode: "archive"}, &logger)
+	if _, err := job.RunOnce(context.Background()); err == nil {
+		t.Errorf("error: expected error for unknown mode")
+	}
+}
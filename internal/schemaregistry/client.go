@@ -0,0 +1,167 @@
+// Package schemaregistry implements a client of the Confluent schema registry REST API
+package schemaregistry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"l0/internal/models"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const contentType = "application/vnd.schemaregistry.v1+json"
+
+var ErrSchemaNotFound = errors.New("schema not found")
+
+// An UnavailableError is an error of the registry which can't be reached or fails to serve the request.
+// Unlike unknown schemas, the request may succeed later
+type UnavailableError struct {
+	StatusCode int
+	Err        error
+}
+
+func (e *UnavailableError) Error() string {
+	return fmt.Sprintf("schema registry is not available: %v", e.Err)
+}
+
+func (e *UnavailableError) Unwrap() error {
+	return e.Err
+}
+
+// A Client is a client of the schema registry. Schemas are immutable, so they are cached by ID
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	mu         sync.RWMutex
+	schemas    map[int]*models.Schema
+}
+
+// A subjectVersion is a subject and a version the schema is registered under
+type subjectVersion struct {
+	Subject string `json:"subject"`
+	Version int    `json:"version"`
+}
+
+// An errorResponse is a body of the registry response with an error
+type errorResponse struct {
+	ErrorCode int    `json:"error_code"`
+	Message   string `json:"message"`
+}
+
+// NewClient creates a new client of the schema registry available by baseURL
+func NewClient(baseURL string, timeout time.Duration) *Client {
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	return &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: &http.Client{Timeout: timeout},
+		schemas:    make(map[int]*models.Schema),
+	}
+}
+
+// GetSchemaByID returns the schema with its subject and version by the schema ID
+func (c *Client) GetSchemaByID(ctx context.Context, id int) (*models.Schema, error) {
+	c.mu.RLock()
+	schema, ok := c.schemas[id]
+	c.mu.RUnlock()
+	if ok {
+		return schema, nil
+	}
+
+	schema = &models.Schema{ID: id}
+	if err := c.do(ctx, http.MethodGet, fmt.Sprintf("/schemas/ids/%d", id), nil, schema); err != nil {
+		return nil, fmt.Errorf("failed to get schema %d: %w", id, err)
+	}
+	if schema.Type == "" {
+		schema.Type = models.SchemaTypeAvro
+	}
+
+	var versions []subjectVersion
+	if err := c.do(ctx, http.MethodGet, fmt.Sprintf("/schemas/ids/%d/versions", id), nil, &versions); err != nil {
+		return nil, fmt.Errorf("failed to get versions of schema %d: %w", id, err)
+	}
+	for _, version := range versions {
+		if version.Version > schema.Version {
+			schema.Subject = version.Subject
+			schema.Version = version.Version
+		}
+	}
+
+	c.mu.Lock()
+	c.schemas[id] = schema
+	c.mu.Unlock()
+
+	return schema, nil
+}
+
+// Register registers the schema under the subject and returns its ID.
+// Registration of the already registered schema returns the existing ID
+func (c *Client) Register(ctx context.Context, subject, schemaType, schema string) (int, error) {
+	request := struct {
+		Schema     string `json:"schema"`
+		SchemaType string `json:"schemaType,omitempty"`
+	}{Schema: schema}
+	if schemaType != models.SchemaTypeAvro {
+		request.SchemaType = schemaType
+	}
+
+	var response struct {
+		ID int `json:"id"`
+	}
+	path := "/subjects/" + url.PathEscape(subject) + "/versions"
+	if err := c.do(ctx, http.MethodPost, path, request, &response); err != nil {
+		return 0, fmt.Errorf("failed to register schema for subject %q: %w", subject, err)
+	}
+
+	return response.ID, nil
+}
+
+// do sends the request to the registry and decodes the response into result
+func (c *Client) do(ctx context.Context, method, path string, body, result any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	request, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Accept", contentType)
+	if body != nil {
+		request.Header.Set("Content-Type", contentType)
+	}
+
+	response, err := c.httpClient.Do(request)
+	if err != nil {
+		return &UnavailableError{Err: err}
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode == http.StatusNotFound {
+		return ErrSchemaNotFound
+	}
+	if response.StatusCode != http.StatusOK {
+		var registryErr errorResponse
+		_ = json.NewDecoder(response.Body).Decode(&registryErr)
+		err := fmt.Errorf("schema registry returned %d: %s", response.StatusCode, registryErr.Message)
+		if response.StatusCode >= http.StatusInternalServerError || response.StatusCode == http.StatusTooManyRequests {
+			return &UnavailableError{StatusCode: response.StatusCode, Err: err}
+		}
+		return err
+	}
+
+	return json.NewDecoder(response.Body).Decode(result)
+}
@@ -0,0 +1,115 @@
+package schemaregistry
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"l0/internal/models"
+)
+
+func TestClient_RegisterAndGet(t *testing.T) {
+	registry := NewMemoryRegistry()
+	server := httptest.NewServer(registry.Handler())
+	defer server.Close()
+
+	client := NewClient(server.URL, time.Second)
+	ctx := context.Background()
+
+	id, err := client.Register(ctx, "orders-value", models.SchemaTypeAvro, `{"type":"string"}`)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	secondID, err := client.Register(ctx, "orders-value", models.SchemaTypeProtobuf, `syntax = "proto3";`)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if id == secondID {
+		t.Errorf("error: expected different IDs for different schemas")
+	}
+	if sameID, _ := client.Register(ctx, "orders-value", models.SchemaTypeAvro, `{"type":"string"}`); sameID != id {
+		t.Errorf("error: expected existing ID %d, got %d", id, sameID)
+	}
+
+	schema, err := client.GetSchemaByID(ctx, secondID)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if schema.Type != models.SchemaTypeProtobuf || schema.Subject != "orders-value" || schema.Version != 2 {
+		t.Errorf("error: unexpected schema %+v", schema)
+	}
+
+	schema, err = client.GetSchemaByID(ctx, id)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if schema.Type != models.SchemaTypeAvro || schema.Version != 1 {
+		t.Errorf("error: unexpected schema %+v", schema)
+	}
+	if schema.VersionString("avro") != "avro:orders-value:1" {
+		t.Errorf("error: unexpected version string %s", schema.VersionString("avro"))
+	}
+}
+
+func TestClient_NotFound(t *testing.T) {
+	server := httptest.NewServer(NewMemoryRegistry().Handler())
+	defer server.Close()
+
+	_, err := NewClient(server.URL, time.Second).GetSchemaByID(context.Background(), 42)
+	if !errors.Is(err, ErrSchemaNotFound) {
+		t.Errorf("error: expected ErrSchemaNotFound, got %v", err)
+	}
+}
+
+func TestClient_Cache(t *testing.T) {
+	registry := NewMemoryRegistry()
+	id, _ := registry.Register(context.Background(), "orders-value", "", `{"type":"string"}`)
+
+	var requests atomic.Int32
+	handler := registry.Handler()
+	server := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				requests.Add(1)
+				handler.ServeHTTP(w, r)
+			},
+		),
+	)
+	defer server.Close()
+
+	client := NewClient(server.URL, time.Second)
+	for i := 0; i < 3; i++ {
+		if _, err := client.GetSchemaByID(context.Background(), id); err != nil {
+			t.Fatalf("error: %v", err)
+		}
+	}
+	if requests.Load() != 2 {
+		t.Errorf("error: expected 2 requests, got %d", requests.Load())
+	}
+}
+
+func TestClient_Unavailable(t *testing.T) {
+	server := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			},
+		),
+	)
+
+	client := NewClient(server.URL, time.Second)
+	_, err := client.GetSchemaByID(context.Background(), 42)
+	var unavailable *UnavailableError
+	if !errors.As(err, &unavailable) || unavailable.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("error: expected UnavailableError with 503, got %v", err)
+	}
+
+	server.Close()
+	if _, err := client.GetSchemaByID(context.Background(), 42); !errors.As(err, &unavailable) {
+		t.Errorf("error: expected UnavailableError for the unreachable registry, got %v", err)
+	}
+}
@@ -0,0 +1,136 @@
+package schemaregistry
+
+import (
+	"context"
+	"encoding/json"
+	"l0/internal/models"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// A MemoryRegistry is an in-process schema registry. It's used in tests and local development
+// and serves the subset of the registry REST API used by Client
+type MemoryRegistry struct {
+	mu       sync.RWMutex
+	schemas  map[int]*models.Schema
+	subjects map[string][]int
+	nextID   int
+}
+
+// NewMemoryRegistry creates a new empty in-process schema registry
+func NewMemoryRegistry() *MemoryRegistry {
+	return &MemoryRegistry{
+		schemas:  make(map[int]*models.Schema),
+		subjects: make(map[string][]int),
+		nextID:   1,
+	}
+}
+
+// Register registers the schema under the subject and returns its ID.
+// Registration of the already registered schema returns the existing ID
+func (m *MemoryRegistry) Register(_ context.Context, subject, schemaType, schema string) (int, error) {
+	if schemaType == "" {
+		schemaType = models.SchemaTypeAvro
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, id := range m.subjects[subject] {
+		if existing := m.schemas[id]; existing.Schema == schema && existing.Type == schemaType {
+			return id, nil
+		}
+	}
+
+	id := m.nextID
+	m.nextID++
+	m.schemas[id] = &models.Schema{
+		ID:      id,
+		Type:    schemaType,
+		Schema:  schema,
+		Subject: subject,
+		Version: len(m.subjects[subject]) + 1,
+	}
+	m.subjects[subject] = append(m.subjects[subject], id)
+
+	return id, nil
+}
+
+// GetSchemaByID returns the schema with its subject and version by the schema ID
+func (m *MemoryRegistry) GetSchemaByID(_ context.Context, id int) (*models.Schema, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	schema, ok := m.schemas[id]
+	if !ok {
+		return nil, ErrSchemaNotFound
+	}
+	result := *schema
+	return &result, nil
+}
+
+// Handler returns an HTTP handler serving the registry REST API
+func (m *MemoryRegistry) Handler() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc(
+		"GET /schemas/ids/{id}", func(w http.ResponseWriter, r *http.Request) {
+			schema, ok := m.lookup(w, r)
+			if !ok {
+				return
+			}
+			response := map[string]string{"schema": schema.Schema}
+			if schema.Type != models.SchemaTypeAvro {
+				response["schemaType"] = schema.Type
+			}
+			writeJSON(w, http.StatusOK, response)
+		},
+	)
+
+	mux.HandleFunc(
+		"GET /schemas/ids/{id}/versions", func(w http.ResponseWriter, r *http.Request) {
+			schema, ok := m.lookup(w, r)
+			if !ok {
+				return
+			}
+			writeJSON(w, http.StatusOK, []subjectVersion{{Subject: schema.Subject, Version: schema.Version}})
+		},
+	)
+
+	mux.HandleFunc(
+		"POST /subjects/{subject}/versions", func(w http.ResponseWriter, r *http.Request) {
+			var request struct {
+				Schema     string `json:"schema"`
+				SchemaType string `json:"schemaType"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&request); err != nil || strings.TrimSpace(request.Schema) == "" {
+				writeJSON(w, http.StatusUnprocessableEntity, errorResponse{ErrorCode: 42201, Message: "Invalid schema"})
+				return
+			}
+			id, _ := m.Register(r.Context(), r.PathValue("subject"), request.SchemaType, request.Schema)
+			writeJSON(w, http.StatusOK, map[string]int{"id": id})
+		},
+	)
+
+	return mux
+}
+
+// lookup finds the schema by the ID from the request path and writes the error response if it's not found
+func (m *MemoryRegistry) lookup(w http.ResponseWriter, r *http.Request) (*models.Schema, bool) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err == nil {
+		if schema, err := m.GetSchemaByID(r.Context(), id); err == nil {
+			return schema, true
+		}
+	}
+	writeJSON(w, http.StatusNotFound, errorResponse{ErrorCode: 40403, Message: "Schema not found"})
+	return nil, false
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
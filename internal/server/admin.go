@@ -0,0 +1,141 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"l0/internal/models"
+)
+
+// SeekRequest represents a request to move the consumer group to the offset of the topic partition
+type SeekRequest struct {
+	Topic     string `json:"topic"`
+	Partition *int   `json:"partition"`
+	Offset    *int64 `json:"offset"`
+}
+
+// handleConsumerStatus handles GET /admin/consumer requests
+func (s *Server) handleConsumerStatus(w http.ResponseWriter, r *http.Request) {
+	s.writeJSONResponse(w, http.StatusOK, s.consumer.Status())
+}
+
+// handlePauseConsumer handles POST /admin/consumer/pause requests
+func (s *Server) handlePauseConsumer(w http.ResponseWriter, r *http.Request) {
+	s.consumer.Pause()
+	s.requestLogger(r).Warn().Msg("Kafka consumer paused by admin")
+	s.writeJSONResponse(w, http.StatusOK, s.consumer.Status())
+}
+
+// handleResumeConsumer handles POST /admin/consumer/resume requests
+func (s *Server) handleResumeConsumer(w http.ResponseWriter, r *http.Request) {
+	s.consumer.Resume()
+	s.requestLogger(r).Info().Msg("Kafka consumer resumed by admin")
+	s.writeJSONResponse(w, http.StatusOK, s.consumer.Status())
+}
+
+// handleSeekConsumer handles POST /admin/consumer/seek requests
+func (s *Server) handleSeekConsumer(w http.ResponseWriter, r *http.Request) {
+	var request SeekRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		s.writeErrorResponse(w, http.StatusBadRequest, "Invalid request body", err.Error())
+		return
+	}
+	request.Topic = strings.TrimSpace(request.Topic)
+	if request.Topic == "" || request.Partition == nil || request.Offset == nil {
+		s.writeErrorResponse(w, http.StatusBadRequest, "Topic, partition and offset are required", "")
+		return
+	}
+	if *request.Partition < 0 || *request.Offset < 0 {
+		s.writeErrorResponse(w, http.StatusBadRequest, "Partition and offset cannot be negative", "")
+		return
+	}
+
+	err := s.consumer.Seek(r.Context(), request.Topic, *request.Partition, *request.Offset)
+	if err != nil {
+		s.requestLogger(r).Error().
+			Err(err).
+			Str("topic", request.Topic).
+			Int("partition", *request.Partition).
+			Int64("offset", *request.Offset).
+			Msg("Failed to seek Kafka consumer")
+		s.writeErrorResponse(w, http.StatusConflict, "Failed to seek consumer", err.Error())
+		return
+	}
+
+	s.requestLogger(r).Warn().
+		Str("topic", request.Topic).
+		Int("partition", *request.Partition).
+		Int64("offset", *request.Offset).
+		Msg("Kafka consumer moved by admin")
+	s.writeJSONResponse(w, http.StatusOK, s.consumer.Status())
+}
+
+// A ResetResponse represents offsets of the consumer group after reset
+type ResetResponse struct {
+	Topic   string        `json:"topic"`
+	Offsets map[int]int64 `json:"offsets"`
+}
+
+// handleResetConsumer handles POST /admin/consumer/reset requests
+func (s *Server) handleResetConsumer(w http.ResponseWriter, r *http.Request) {
+	var request models.OffsetReset
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		s.writeErrorResponse(w, http.StatusBadRequest, "Invalid request body", err.Error())
+		return
+	}
+	request.Topic = strings.TrimSpace(request.Topic)
+	if request.Topic == "" || request.To == "" {
+		s.writeErrorResponse(w, http.StatusBadRequest, "Topic and reset strategy are required", "")
+		return
+	}
+
+	partitionOffsets, err := s.consumer.ResetGroup(r.Context(), request)
+	if err != nil {
+		s.requestLogger(r).Error().
+			Err(err).
+			Str("topic", request.Topic).
+			Str("to", request.To).
+			Msg("Failed to reset Kafka consumer offsets")
+		s.writeErrorResponse(w, http.StatusConflict, "Failed to reset consumer offsets", err.Error())
+		return
+	}
+
+	s.requestLogger(r).Warn().
+		Str("topic", request.Topic).
+		Str("to", request.To).
+		Interface("offsets", partitionOffsets).
+		Msg("Kafka consumer offsets reset by admin")
+	s.writeJSONResponse(w, http.StatusOK, ResetResponse{Topic: request.Topic, Offsets: partitionOffsets})
+}
+
+// handleReplay handles POST /admin/consumer/replay requests, the replay runs in background
+func (s *Server) handleReplay(w http.ResponseWriter, r *http.Request) {
+	var request models.Replay
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		s.writeErrorResponse(w, http.StatusBadRequest, "Invalid request body", err.Error())
+		return
+	}
+	request.Topic = strings.TrimSpace(request.Topic)
+	if request.Topic == "" {
+		s.writeErrorResponse(w, http.StatusBadRequest, "Topic is required", "")
+		return
+	}
+	if request.FromTime != nil && request.ToTime != nil && !request.FromTime.Before(*request.ToTime) {
+		s.writeErrorResponse(w, http.StatusBadRequest, "Replay range is empty", "")
+		return
+	}
+
+	status, err := s.consumer.StartReplay(r.Context(), request)
+	if err != nil {
+		s.requestLogger(r).Error().Err(err).Str("topic", request.Topic).Msg("Failed to start replay")
+		s.writeErrorResponse(w, http.StatusConflict, "Failed to start replay", err.Error())
+		return
+	}
+
+	s.requestLogger(r).Warn().
+		Str("topic", status.Topic).
+		Str("group_id", status.GroupID).
+		Msg("Replay started by admin")
+	s.writeJSONResponse(w, http.StatusAccepted, status)
+}
@@ -0,0 +1,205 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+
+	"l0/internal/auth"
+	"l0/internal/models"
+)
+
+// maxAuditedBody limits the size of request bodies recorded in the audit log
+const maxAuditedBody = 64 << 10
+
+// redactedFields are fields of request bodies which values are not recorded in the audit log
+var redactedFields = map[string]bool{
+	"secret": true,
+}
+
+// An AuditEntriesResponse represents a page of audit entries
+type AuditEntriesResponse struct {
+	Entries []models.AuditEntry `json:"entries"`
+	Limit   int                 `json:"limit"`
+	Offset  int                 `json:"offset"`
+	HasMore bool                `json:"has_more"`
+}
+
+// auditDetails are request details recorded in the audit log
+type auditDetails struct {
+	Method  string                    `json:"method"`
+	Path    string                    `json:"path"`
+	Query   string                    `json:"query,omitempty"`
+	Status  int                       `json:"status,omitempty"`
+	Request json.RawMessage           `json:"request,omitempty"`
+	Filter  *models.OrderChangeFilter `json:"filter,omitempty"`
+}
+
+// auditSubjectKey is a context key for auditSubject
+type auditSubjectKey struct{}
+
+// An auditSubject is the customer the audited request has read data of, handlers set it
+// when the customer isn't in the path, e.g. for single orders
+type auditSubject struct {
+	customerID string
+}
+
+// setAuditedCustomer records the customer of the data returned by the audited request
+func setAuditedCustomer(r *http.Request, customerID string) {
+	if subject, ok := r.Context().Value(auditSubjectKey{}).(*auditSubject); ok {
+		subject.customerID = customerID
+	}
+}
+
+// handleAdmin registers the admin handler requiring the admin scope, requests are recorded in the audit log
+func (s *Server) handleAdmin(mux *http.ServeMux, pattern, action string, handler http.HandlerFunc) {
+	mux.Handle(pattern, s.audited(action, s.requireScope(auth.ScopeAdmin, handler)))
+}
+
+// audited records requests in the audit log after they are served, including rejected ones.
+// JSON request bodies are recorded with the request. The customer is taken from the path
+// or from the handler if it isn't in the path
+func (s *Server) audited(action string, next http.Handler) http.Handler {
+	if s.audit == nil {
+		return next
+	}
+
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			body := readAuditedBody(r)
+			wrapper := &responseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+			subject := &auditSubject{customerID: r.PathValue("customer_id")}
+
+			next.ServeHTTP(wrapper, r.WithContext(context.WithValue(r.Context(), auditSubjectKey{}, subject)))
+
+			details := auditDetails{
+				Method: r.Method,
+				Path:   r.URL.Path,
+				Query:  r.URL.RawQuery,
+				Status: wrapper.statusCode,
+			}
+			if json.Valid(body) {
+				details.Request = redactSecrets(body)
+			}
+			s.recordAudit(r, action, subject.customerID, details)
+		},
+	)
+}
+
+// recordAudit records the request of the principal in the audit log
+func (s *Server) recordAudit(r *http.Request, action, customerID string, details auditDetails) {
+	encoded, _ := json.Marshal(details)
+
+	s.audit.Record(
+		models.AuditEntry{
+			Principal:  principalName(r),
+			Action:     action,
+			OrderUID:   r.PathValue("order_uid"),
+			CustomerID: customerID,
+			IP:         s.clientIP(r),
+			Details:    encoded,
+		},
+	)
+}
+
+// readAuditedBody reads at most maxAuditedBody bytes of the request body and puts them back for the handler
+func readAuditedBody(r *http.Request) []byte {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil
+	}
+
+	body, _ := io.ReadAll(io.LimitReader(r.Body, maxAuditedBody))
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+	return body
+}
+
+// redactSecrets replaces values of redacted fields of the JSON object, other bodies are returned unchanged
+func redactSecrets(body []byte) []byte {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return body
+	}
+
+	redacted := false
+	for field := range fields {
+		if redactedFields[field] {
+			fields[field] = json.RawMessage(`"***"`)
+			redacted = true
+		}
+	}
+	if !redacted {
+		return body
+	}
+
+	encoded, err := json.Marshal(fields)
+	if err != nil {
+		return nil
+	}
+	return encoded
+}
+
+// handleAuditEntries handles GET /admin/audit requests.
+// Entries are filtered by principal, action, order_uid, customer_id and the range of time
+func (s *Server) handleAuditEntries(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := models.AuditFilter{
+		Principal:  strings.TrimSpace(query.Get("principal")),
+		Action:     strings.TrimSpace(query.Get("action")),
+		OrderUID:   strings.TrimSpace(query.Get("order_uid")),
+		CustomerID: strings.TrimSpace(query.Get("customer_id")),
+	}
+
+	var err error
+	filter.From, filter.To, err = parseTimeRange(r)
+	if err != nil {
+		s.writeErrorResponse(w, http.StatusBadRequest, "Invalid range", err.Error())
+		return
+	}
+	limit, offset, err := parsePagination(r)
+	if err != nil {
+		s.writeErrorResponse(w, http.StatusBadRequest, "Invalid pagination", err.Error())
+		return
+	}
+	filter.Limit, filter.Offset = limit+1, offset
+
+	entries, err := s.audit.Entries(r.Context(), filter)
+	if err != nil {
+		s.requestLogger(r).Error().Err(err).Msg("Failed to get audit entries")
+		s.writeErrorResponse(w, http.StatusInternalServerError, "Internal server error", "")
+		return
+	}
+
+	hasMore := len(entries) > limit
+	if hasMore {
+		entries = entries[:limit]
+	}
+
+	s.writeJSONResponse(
+		w, http.StatusOK, AuditEntriesResponse{Entries: entries, Limit: limit, Offset: offset, HasMore: hasMore},
+	)
+}
+
+// handleVerifyAudit handles GET /admin/audit/verify requests
+func (s *Server) handleVerifyAudit(w http.ResponseWriter, r *http.Request) {
+	verification, err := s.audit.Verify(r.Context())
+	if err != nil {
+		s.requestLogger(r).Error().Err(err).Msg("Failed to verify audit log")
+		s.writeErrorResponse(w, http.StatusInternalServerError, "Internal server error", "")
+		return
+	}
+
+	if !verification.Valid {
+		s.requestLogger(r).Error().
+			Int64("broken_seq", verification.BrokenSeq).
+			Int64("broken_id", verification.BrokenID).
+			Str("reason", verification.Error).
+			Msg("Audit log hash chain is broken")
+	}
+	s.writeJSONResponse(w, http.StatusOK, verification)
+}
@@ -0,0 +1,138 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"l0/internal/export"
+	"l0/internal/models"
+)
+
+// exportFlushInterval is the number of exported orders between flushes of the response
+const exportFlushInterval = 100
+
+// handleExportOrders handles GET /orders/export requests.
+// Orders are streamed as they are read, so an error after the first bytes can only abort the response
+func (s *Server) handleExportOrders(w http.ResponseWriter, r *http.Request) {
+	format := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("format")))
+	if format == "" {
+		format = export.FormatCSV
+	}
+
+	filter, err := parseOrderFilter(r)
+	if err != nil {
+		s.writeErrorResponse(w, http.StatusBadRequest, "Invalid filter", err.Error())
+		return
+	}
+
+	counter := &countingWriter{writer: w}
+	writer, err := export.NewWriter(format, counter)
+	if err != nil {
+		s.writeErrorResponse(w, http.StatusBadRequest, "Unknown export format", format)
+		return
+	}
+
+	controller := http.NewResponseController(w)
+	// Exports may take longer than the server write timeout
+	_ = controller.SetWriteDeadline(time.Time{})
+
+	w.Header().Set("Content-Type", export.ContentType(format))
+	w.Header().Set("Content-Disposition", `attachment; filename="orders.`+format+`"`)
+
+	exported := 0
+	err = s.service.ExportOrders(
+		r.Context(), filter, func(order *models.Order) error {
+			if err := writer.Write(s.maskOrder(r, order)); err != nil {
+				return err
+			}
+			exported++
+			if exported%exportFlushInterval == 0 {
+				_ = controller.Flush()
+			}
+			return nil
+		},
+	)
+	if err == nil {
+		err = writer.Close()
+	}
+	if err == nil {
+		return
+	}
+
+	s.requestLogger(r).Error().
+		Err(err).
+		Str("format", format).
+		Int("exported", exported).
+		Msg("Failed to export orders")
+	if counter.written == 0 {
+		w.Header().Del("Content-Disposition")
+		s.writeErrorResponse(w, http.StatusInternalServerError, "Internal server error", "")
+		return
+	}
+	// Abort the response, so the client doesn't take a truncated export for a complete one
+	panic(http.ErrAbortHandler)
+}
+
+// parseOrderFilter reads order filters from query parameters
+func parseOrderFilter(r *http.Request) (models.OrderFilter, error) {
+	query := r.URL.Query()
+	filter := models.OrderFilter{
+		CustomerID:      strings.TrimSpace(query.Get("customer_id")),
+		DeliveryService: strings.TrimSpace(query.Get("delivery_service")),
+		Currency:        strings.ToUpper(strings.TrimSpace(query.Get("currency"))),
+		Locale:          strings.TrimSpace(query.Get("locale")),
+	}
+
+	if filter.Currency != "" && !currencyPattern.MatchString(filter.Currency) {
+		return filter, errors.New("currency must be a 3-letter currency code")
+	}
+	var err error
+	filter.From, filter.To, err = parseTimeRange(r)
+	if err != nil {
+		return filter, err
+	}
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit <= 0 {
+			return filter, errors.New("limit must be positive")
+		}
+		filter.Limit = limit
+	}
+
+	return filter, nil
+}
+
+// parseTimeRange reads from and to query parameters as RFC 3339 times, the range has to be non-empty
+func parseTimeRange(r *http.Request) (from, to *time.Time, err error) {
+	for name, bound := range map[string]**time.Time{"from": &from, "to": &to} {
+		value := r.URL.Query().Get(name)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return nil, nil, errors.New(name + " must be an RFC 3339 time")
+		}
+		*bound = &t
+	}
+	if from != nil && to != nil && !from.Before(*to) {
+		return nil, nil, errors.New("from must be before to")
+	}
+	return from, to, nil
+}
+
+// A countingWriter counts bytes written to the response
+type countingWriter struct {
+	writer  http.ResponseWriter
+	written int64
+}
+
+// Write writes to the response
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.writer.Write(p)
+	c.written += int64(n)
+	return n, err
+}
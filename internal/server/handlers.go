@@ -3,9 +3,17 @@ package server
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
+	"regexp"
+	"strconv"
 	"strings"
 	"time"
+
+	"l0/internal/auth"
+	"l0/internal/currency"
+	"l0/internal/models"
+	"l0/internal/privacy"
 )
 
 // ErrorResponse represents an error response
@@ -14,6 +22,30 @@ type ErrorResponse struct {
 	Message string `json:"message,omitempty"`
 }
 
+// OrderResponse represents an order response with the payment converted into the requested currency
+type OrderResponse struct {
+	*models.Order
+	ConvertedPayment *models.ConvertedPayment `json:"converted_payment,omitempty"`
+}
+
+// CustomerOrdersResponse represents a page of customer orders
+type CustomerOrdersResponse struct {
+	CustomerID string         `json:"customer_id"`
+	Orders     []models.Order `json:"orders"`
+	Limit      int            `json:"limit"`
+	Offset     int            `json:"offset"`
+	HasMore    bool           `json:"has_more"`
+}
+
+// Pagination defaults for list endpoints
+const (
+	defaultPageLimit = 20
+	maxPageLimit     = 100
+)
+
+// currencyPattern matches 3-letter currency codes
+var currencyPattern = regexp.MustCompile(`^[A-Z]{3}$`)
+
 // HealthResponse represents a health check response
 type HealthResponse struct {
 	Status string `json:"status"`
@@ -41,7 +73,7 @@ func (s *Server) handleGetOrder(w http.ResponseWriter, r *http.Request) {
 	duration := time.Since(start)
 
 	if err != nil {
-		s.logger.Error().
+		s.requestLogger(r).Error().
 			Err(err).
 			Str("order_uid", orderUID).
 			Str("remote_addr", r.RemoteAddr).
@@ -63,7 +95,101 @@ func (s *Server) handleGetOrder(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	s.writeJSONResponse(w, http.StatusOK, order)
+	setAuditedCustomer(r, order.CustomerID)
+	order = s.maskOrder(r, order)
+
+	targetCurrency := strings.ToUpper(strings.TrimSpace(r.URL.Query().Get("currency")))
+	if targetCurrency == "" {
+		s.writeJSONResponse(w, http.StatusOK, order)
+		return
+	}
+	if !currencyPattern.MatchString(targetCurrency) {
+		s.writeErrorResponse(w, http.StatusBadRequest, "Currency must be a 3-letter currency code", targetCurrency)
+		return
+	}
+
+	converted, err := s.service.ConvertPayment(r.Context(), &order.Payment, targetCurrency)
+	if err != nil {
+		switch {
+		case errors.Is(err, currency.ErrUnknownCurrency):
+			s.writeErrorResponse(w, http.StatusBadRequest, "Unknown currency", targetCurrency)
+		case errors.Is(err, currency.ErrConversionUnavailable):
+			s.writeErrorResponse(w, http.StatusNotImplemented, "Currency conversion is not configured", "")
+		default:
+			s.writeErrorResponse(w, http.StatusInternalServerError, "Internal server error", "")
+		}
+		return
+	}
+
+	s.writeJSONResponse(w, http.StatusOK, OrderResponse{Order: order, ConvertedPayment: converted})
+}
+
+// handleGetCustomerOrders handles GET /customers/{customer_id}/orders requests
+func (s *Server) handleGetCustomerOrders(w http.ResponseWriter, r *http.Request) {
+	customerID := strings.TrimSpace(r.PathValue("customer_id"))
+	if customerID == "" {
+		s.writeErrorResponse(w, http.StatusBadRequest, "Customer ID is required", "")
+		return
+	}
+
+	limit, offset, err := parsePagination(r)
+	if err != nil {
+		s.writeErrorResponse(w, http.StatusBadRequest, "Invalid pagination", err.Error())
+		return
+	}
+
+	orders, err := s.service.GetCustomerOrders(r.Context(), customerID, limit+1, offset)
+	if err != nil {
+		s.requestLogger(r).Error().
+			Err(err).
+			Str("customer_id", customerID).
+			Msg("Failed to get customer orders")
+		s.writeErrorResponse(w, http.StatusInternalServerError, "Internal server error", "")
+		return
+	}
+
+	hasMore := len(orders) > limit
+	if hasMore {
+		orders = orders[:limit]
+	}
+	for i := range orders {
+		orders[i] = *s.maskOrder(r, &orders[i])
+	}
+
+	s.writeJSONResponse(
+		w, http.StatusOK, CustomerOrdersResponse{
+			CustomerID: customerID,
+			Orders:     orders,
+			Limit:      limit,
+			Offset:     offset,
+			HasMore:    hasMore,
+		},
+	)
+}
+
+// handleGetCustomerSummary handles GET /customers/{customer_id}/summary requests
+func (s *Server) handleGetCustomerSummary(w http.ResponseWriter, r *http.Request) {
+	customerID := strings.TrimSpace(r.PathValue("customer_id"))
+	if customerID == "" {
+		s.writeErrorResponse(w, http.StatusBadRequest, "Customer ID is required", "")
+		return
+	}
+
+	summary, err := s.service.GetCustomerSummary(r.Context(), customerID)
+	if err != nil {
+		s.requestLogger(r).Error().
+			Err(err).
+			Str("customer_id", customerID).
+			Msg("Failed to get customer summary")
+		s.writeErrorResponse(w, http.StatusInternalServerError, "Internal server error", "")
+		return
+	}
+	if summary.OrderCount == 0 {
+		s.writeErrorResponse(w, http.StatusNotFound, "Customer not found", customerID)
+		return
+	}
+
+	s.writeJSONResponse(w, http.StatusOK, summary)
 }
 
 // handleHealth handles GET /health requests
@@ -97,6 +223,46 @@ func (s *Server) writeErrorResponse(w http.ResponseWriter, statusCode int, messa
 	s.writeJSONResponse(w, statusCode, errorResp)
 }
 
+// maskOrder returns a copy of the order with personal data masked according to the request access level
+func (s *Server) maskOrder(r *http.Request, order *models.Order) *models.Order {
+	masked := *order
+	masked.Delivery = privacy.MaskDelivery(order.Delivery, s.accessLevel(r))
+	return &masked
+}
+
+// parsePagination reads limit and offset query parameters
+func parsePagination(r *http.Request) (limit, offset int, err error) {
+	limit, offset = defaultPageLimit, 0
+
+	if value := r.URL.Query().Get("limit"); value != "" {
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit <= 0 || limit > maxPageLimit {
+			return 0, 0, fmt.Errorf("limit must be between 1 and %d", maxPageLimit)
+		}
+	}
+	if value := r.URL.Query().Get("offset"); value != "" {
+		offset, err = strconv.Atoi(value)
+		if err != nil || offset < 0 {
+			return 0, 0, errors.New("offset must be non-negative")
+		}
+	}
+
+	return limit, offset, nil
+}
+
+// accessLevel returns the level of access to personal data for the principal of the request
+func (s *Server) accessLevel(r *http.Request) privacy.Access {
+	principal := auth.PrincipalFromContext(r.Context())
+	switch {
+	case principal.HasScope(auth.ScopePIIRead):
+		return privacy.AccessPrivileged
+	case principal.HasScope(auth.ScopeOrdersRead):
+		return privacy.AccessSupport
+	default:
+		return privacy.AccessPublic
+	}
+}
+
 // isNotFoundError checks if an error indicates that a resource was not found
 func isNotFoundError(err error) bool {
 	if err == nil {
@@ -0,0 +1,246 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/rs/zerolog"
+
+	"l0/internal/config"
+	"l0/internal/models"
+)
+
+// A mockOrderService is a mock implementation of OrderService serving orders of customers
+type mockOrderService struct {
+	orders    map[string][]models.Order
+	err       error
+	lastLimit int
+}
+
+func (m *mockOrderService) ProcessOrder(ctx context.Context, order *models.Order) error {
+	return nil
+}
+
+func (m *mockOrderService) GetOrder(ctx context.Context, orderUID string) (*models.Order, error) {
+	for _, orders := range m.orders {
+		for i := range orders {
+			if orders[i].OrderUID == orderUID {
+				order := orders[i]
+				return &order, nil
+			}
+		}
+	}
+	return nil, errors.New("order not found")
+}
+
+func (m *mockOrderService) WarmCache(ctx context.Context) error {
+	return nil
+}
+
+func (m *mockOrderService) GetCustomerOrders(
+	ctx context.Context, customerID string, limit, offset int,
+) ([]models.Order, error) {
+	m.lastLimit = limit
+	if m.err != nil {
+		return nil, m.err
+	}
+	orders := m.orders[customerID]
+	if offset >= len(orders) {
+		return []models.Order{}, nil
+	}
+	orders = orders[offset:]
+	if len(orders) > limit {
+		orders = orders[:limit]
+	}
+	return append([]models.Order(nil), orders...), nil
+}
+
+func (m *mockOrderService) GetCustomerSummary(ctx context.Context, customerID string) (*models.CustomerSummary, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	summary := &models.CustomerSummary{CustomerID: customerID, TotalSpend: make(map[string]int64)}
+	for _, order := range m.orders[customerID] {
+		summary.OrderCount++
+		summary.TotalSpend[order.Payment.Currency] += int64(order.Payment.Amount)
+	}
+	return summary, nil
+}
+
+func (m *mockOrderService) ConvertPayment(
+	ctx context.Context, payment *models.Payment, currency string,
+) (*models.ConvertedPayment, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (m *mockOrderService) ExportOrders(
+	ctx context.Context, filter models.OrderFilter, fn func(order *models.Order) error,
+) error {
+	return nil
+}
+
+func (m *mockOrderService) DeleteOrder(ctx context.Context, orderUID, principal string) (bool, error) {
+	return false, nil
+}
+
+func (m *mockOrderService) EraseCustomer(
+	ctx context.Context, customerID, principal string,
+) (*models.ErasureResult, error) {
+	return nil, nil
+}
+
+func newCustomerServer(orders int) (*Server, *mockOrderService) {
+	service := &mockOrderService{orders: make(map[string][]models.Order)}
+	for i := 0; i < orders; i++ {
+		service.orders["customer1"] = append(
+			service.orders["customer1"], models.Order{
+				OrderUID:   fmt.Sprintf("order%d", i+1),
+				CustomerID: "customer1",
+				Delivery:   models.Delivery{Name: "Test Testov", Phone: "+9720000000"},
+				Payment:    models.Payment{Currency: "USD", Amount: 100},
+			},
+		)
+	}
+	logger := zerolog.New(os.Stdout)
+	return &Server{logger: &logger, config: &config.Config{}, service: service}, service
+}
+
+// serveCustomer sends the request to the customer handler and returns the response
+func serveCustomer(handler http.HandlerFunc, customerID, query string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, "/customers/orders"+query, nil)
+	r.SetPathValue("customer_id", customerID)
+	w := httptest.NewRecorder()
+	handler(w, r)
+	return w
+}
+
+func TestServer_GetCustomerOrders(t *testing.T) {
+	server, service := newCustomerServer(5)
+
+	tests := []struct {
+		query   string
+		uids    []string
+		limit   int
+		hasMore bool
+	}{
+		{"?limit=2", []string{"order1", "order2"}, 2, true},
+		{"?limit=2&offset=2", []string{"order3", "order4"}, 2, true},
+		{"?limit=2&offset=4", []string{"order5"}, 2, false},
+		{"?limit=5", []string{"order1", "order2", "order3", "order4", "order5"}, 5, false},
+		{"?offset=10", []string{}, defaultPageLimit, false},
+	}
+	for _, test := range tests {
+		w := serveCustomer(server.handleGetCustomerOrders, "customer1", test.query)
+		if w.Code != http.StatusOK {
+			t.Fatalf("error: expected 200 for %s, got %d", test.query, w.Code)
+		}
+		var response CustomerOrdersResponse
+		if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+			t.Fatalf("error: %v", err)
+		}
+		if service.lastLimit != test.limit+1 {
+			t.Errorf("error: expected one more order than the limit to be requested, got %d", service.lastLimit)
+		}
+
+		uids := make([]string, len(response.Orders))
+		for i, order := range response.Orders {
+			uids[i] = order.OrderUID
+		}
+		if fmt.Sprint(uids) != fmt.Sprint(test.uids) || response.Limit != test.limit ||
+			response.HasMore != test.hasMore {
+			t.Errorf(
+				"error: expected %v with limit %d and has_more %t for %s, got %v, %d, %t",
+				test.uids, test.limit, test.hasMore, test.query, uids, response.Limit, response.HasMore,
+			)
+		}
+		if response.Orders == nil {
+			t.Errorf("error: expected an empty list rather than null for %s", test.query)
+		}
+	}
+
+	w := serveCustomer(server.handleGetCustomerOrders, "customer1", "?limit=1")
+	var response CustomerOrdersResponse
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if response.Orders[0].Delivery.Name == "Test Testov" {
+		t.Errorf("error: expected delivery of anonymous requests to be masked")
+	}
+}
+
+func TestServer_GetCustomerOrdersErrors(t *testing.T) {
+	server, service := newCustomerServer(1)
+
+	for _, query := range []string{"?limit=0", "?limit=101", "?limit=ten", "?offset=-1", "?offset=one"} {
+		if w := serveCustomer(server.handleGetCustomerOrders, "customer1", query); w.Code != http.StatusBadRequest {
+			t.Errorf("error: expected 400 for %s, got %d", query, w.Code)
+		}
+	}
+	if w := serveCustomer(server.handleGetCustomerOrders, " ", ""); w.Code != http.StatusBadRequest {
+		t.Errorf("error: expected 400 without customer ID, got %d", w.Code)
+	}
+
+	if w := serveCustomer(server.handleGetCustomerOrders, "customer1", "?limit=100"); w.Code != http.StatusOK {
+		t.Errorf("error: expected the maximum limit to be allowed, got %d", w.Code)
+	}
+
+	service.err = errors.New("database is not available")
+	if w := serveCustomer(server.handleGetCustomerOrders, "customer1", ""); w.Code != http.StatusInternalServerError {
+		t.Errorf("error: expected 500 for a service error, got %d", w.Code)
+	}
+}
+
+func TestServer_GetCustomerSummary(t *testing.T) {
+	server, service := newCustomerServer(3)
+
+	w := serveCustomer(server.handleGetCustomerSummary, "customer1", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("error: expected 200, got %d", w.Code)
+	}
+	var summary models.CustomerSummary
+	if err := json.NewDecoder(w.Body).Decode(&summary); err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if summary.OrderCount != 3 || summary.TotalSpend["USD"] != 300 {
+		t.Errorf("error: unexpected summary %+v", summary)
+	}
+
+	if w := serveCustomer(server.handleGetCustomerSummary, "customer2", ""); w.Code != http.StatusNotFound {
+		t.Errorf("error: expected 404 for a customer without orders, got %d", w.Code)
+	}
+
+	service.err = errors.New("database is not available")
+	if w := serveCustomer(server.handleGetCustomerSummary, "customer1", ""); w.Code != http.StatusInternalServerError {
+		t.Errorf("error: expected 500 for a service error, got %d", w.Code)
+	}
+}
+
+func TestServer_GetOrderAudited(t *testing.T) {
+	server, _ := newCustomerServer(1)
+	audit := &mockAuditLog{}
+	server.audit = audit
+	handler := server.audited(models.AuditOrderRead, http.HandlerFunc(server.handleGetOrder))
+
+	for _, orderUID := range []string{"order1", "order2"} {
+		r := httptest.NewRequest(http.MethodGet, "/order/"+orderUID, nil)
+		r.SetPathValue("order_uid", orderUID)
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+	}
+
+	entries, _ := audit.Entries(context.Background(), models.AuditFilter{})
+	if len(entries) != 2 {
+		t.Fatalf("error: expected 2 audit entries, got %d", len(entries))
+	}
+	if entries[0].OrderUID != "order1" || entries[0].CustomerID != "customer1" {
+		t.Errorf("error: expected the read to be audited with the customer of the order, got %+v", entries[0])
+	}
+	if entries[1].OrderUID != "order2" || entries[1].CustomerID != "" {
+		t.Errorf("error: expected the missing order to be audited without a customer, got %+v", entries[1])
+	}
+}
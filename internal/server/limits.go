@@ -0,0 +1,158 @@
+package server
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"l0/internal/auth"
+	"l0/internal/metrics"
+	"l0/internal/ratelimit"
+)
+
+// limitExemptPaths are not rate limited and never shed, so health checks and scraping keep working under load
+var limitExemptPaths = map[string]bool{
+	"/health":  true,
+	"/metrics": true,
+}
+
+// inFlightLimitMiddleware sheds requests with 503 when MaxInFlight requests are already being served.
+// The slot of a request is released only when its handler returns
+func (s *Server) inFlightLimitMiddleware(next http.Handler) http.Handler {
+	inFlight := metrics.DefaultRegistry.Gauge("http_in_flight_requests", "Number of HTTP requests being served")
+	shed := metrics.DefaultRegistry.Counter("http_shed_requests_total", "Number of HTTP requests rejected by load shedding")
+
+	var slots chan struct{}
+	if s.config.Server.MaxInFlight > 0 {
+		slots = make(chan struct{}, s.config.Server.MaxInFlight)
+	}
+
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			if slots == nil || limitExemptPaths[r.URL.Path] || subscriptionPaths[r.URL.Path] {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			select {
+			case slots <- struct{}{}:
+			default:
+				shed.Inc()
+				s.logger.Warn().
+					Str("method", r.Method).
+					Str("path", r.URL.Path).
+					Str("remote_addr", r.RemoteAddr).
+					Msg("Request shed, too many requests in flight")
+				w.Header().Set("Retry-After", "1")
+				s.writeErrorResponse(w, http.StatusServiceUnavailable, "Server is overloaded", "")
+				return
+			}
+
+			inFlight.Inc()
+			defer func() {
+				inFlight.Dec()
+				<-slots
+			}()
+
+			next.ServeHTTP(w, r)
+		},
+	)
+}
+
+// rateLimitMiddleware limits requests per client with a token bucket and rejects excess requests with 429
+func (s *Server) rateLimitMiddleware(next http.Handler) http.Handler {
+	cfg := s.config.Server.RateLimit
+	if !cfg.Enabled {
+		return next
+	}
+
+	limiter := ratelimit.NewKeyedLimiter(cfg.RequestsPerSecond, cfg.Burst, cfg.ClientTTL)
+	metrics.DefaultRegistry.GaugeFunc(
+		"http_rate_limited_clients", "Number of clients tracked by the rate limiter",
+		func() int64 { return int64(limiter.Size()) },
+	)
+
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			if limitExemptPaths[r.URL.Path] {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			key := s.clientKey(r)
+			ok, retryAfter := limiter.Allow(key)
+			if !ok {
+				s.rejectRateLimited(w, r, key, retryAfter)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		},
+	)
+}
+
+// failedAuthLimiter returns the limiter of failed authentications per IP address or nil if it's disabled
+func (s *Server) failedAuthLimiter() *ratelimit.KeyedLimiter {
+	cfg := s.config.Server.RateLimit
+	if !cfg.Enabled || cfg.FailedAuthBurst <= 0 {
+		return nil
+	}
+
+	limiter := ratelimit.NewKeyedLimiter(cfg.FailedAuthPerSecond, cfg.FailedAuthBurst, cfg.ClientTTL)
+	metrics.DefaultRegistry.GaugeFunc(
+		"http_failed_auth_clients", "Number of IP addresses tracked by the failed authentication limiter",
+		func() int64 { return int64(limiter.Size()) },
+	)
+	return limiter
+}
+
+// rejectRateLimited responds with 429 and the time to retry after
+func (s *Server) rejectRateLimited(w http.ResponseWriter, r *http.Request, client string, retryAfter time.Duration) {
+	metrics.DefaultRegistry.Counter("http_rate_limited_requests_total", "Number of rate limited HTTP requests").Inc()
+	s.requestLogger(r).Warn().
+		Str("client", client).
+		Str("path", r.URL.Path).
+		Dur("retry_after", retryAfter).
+		Msg("Request rate limited")
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfterSeconds(retryAfter)))
+	s.writeErrorResponse(w, http.StatusTooManyRequests, "Too many requests", "")
+}
+
+// clientKey identifies the client of the request by the authenticated principal or by IP address
+func (s *Server) clientKey(r *http.Request) string {
+	if principal := auth.PrincipalFromContext(r.Context()); principal != nil {
+		return principal.Method + ":" + principal.Subject
+	}
+	return "ip:" + s.clientIP(r)
+}
+
+// clientIP returns the IP address of the client, X-Forwarded-For is used only if it's trusted
+func (s *Server) clientIP(r *http.Request) string {
+	if s.config.Server.RateLimit.TrustForwardedFor {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			first, _, _ := strings.Cut(forwarded, ",")
+			return strings.TrimSpace(first)
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// retryAfterSeconds rounds the duration up to whole seconds for the Retry-After header
+func retryAfterSeconds(d time.Duration) int {
+	seconds := math.Ceil(d.Seconds())
+	if seconds < 1 {
+		return 1
+	}
+	if seconds > 3600 {
+		return 3600
+	}
+	return int(seconds)
+}
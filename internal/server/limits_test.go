@@ -0,0 +1,156 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"l0/internal/auth"
+	"l0/internal/config"
+)
+
+func newLimitServer(cfg *config.Config) *Server {
+	logger := zerolog.New(os.Stdout)
+	return &Server{logger: &logger, config: cfg}
+}
+
+// serve sends the request from the IP address to the handler
+func serve(handler http.Handler, ip string, header http.Header) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, "/order/order1", nil)
+	r.RemoteAddr = ip + ":40000"
+	for key, values := range header {
+		r.Header[key] = values
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	return w
+}
+
+var okHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+func TestServer_RateLimit(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Server.RateLimit = config.RateLimitConfig{Enabled: true, RequestsPerSecond: 0.5, Burst: 2}
+	handler := newLimitServer(cfg).rateLimitMiddleware(okHandler)
+
+	for i := 0; i < 2; i++ {
+		if w := serve(handler, "10.0.0.1", nil); w.Code != http.StatusOK {
+			t.Fatalf("error: expected burst request %d to be allowed, got %d", i+1, w.Code)
+		}
+	}
+
+	w := serve(handler, "10.0.0.1", nil)
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("error: expected 429 over the burst, got %d", w.Code)
+	}
+	if retryAfter := w.Header().Get("Retry-After"); retryAfter != "2" {
+		t.Errorf("error: expected Retry-After 2, got %q", retryAfter)
+	}
+
+	if w := serve(handler, "10.0.0.2", nil); w.Code != http.StatusOK {
+		t.Errorf("error: expected another client to be allowed, got %d", w.Code)
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/health", nil)
+	r.RemoteAddr = "10.0.0.1:40000"
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Errorf("error: expected health checks not to be limited, got %d", w.Code)
+	}
+}
+
+func TestServer_FailedAuthLimit(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Server.RateLimit = config.RateLimitConfig{
+		Enabled: true, RequestsPerSecond: 100, Burst: 100, FailedAuthPerSecond: 0.1, FailedAuthBurst: 2,
+	}
+	authenticator, err := auth.NewAuthenticator(
+		config.AuthConfig{
+			APIKeys: []config.APIKeyConfig{{Name: "dashboard", Key: "key1", Scopes: []string{auth.ScopeOrdersRead}}},
+		},
+	)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	server := newLimitServer(cfg)
+	server.authenticator = authenticator
+	handler := server.authMiddleware(server.rateLimitMiddleware(okHandler))
+
+	invalid := http.Header{"X-Api-Key": {"guess"}}
+	valid := http.Header{"X-Api-Key": {"key1"}}
+	for i := 0; i < 2; i++ {
+		if w := serve(handler, "10.0.0.1", invalid); w.Code != http.StatusUnauthorized {
+			t.Fatalf("error: expected 401 for invalid credentials, got %d", w.Code)
+		}
+	}
+
+	w := serve(handler, "10.0.0.1", valid)
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "10" {
+		t.Errorf(
+			"error: expected 429 with Retry-After 10 before credentials are checked, got %d, %q",
+			w.Code, w.Header().Get("Retry-After"),
+		)
+	}
+	if w := serve(handler, "10.0.0.1", nil); w.Code != http.StatusOK {
+		t.Errorf("error: expected requests without credentials to pass, got %d", w.Code)
+	}
+	if w := serve(handler, "10.0.0.2", valid); w.Code != http.StatusOK {
+		t.Errorf("error: expected valid credentials from another IP to be accepted, got %d", w.Code)
+	}
+}
+
+func TestServer_InFlightLimit(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Server.MaxInFlight = 1
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	handler := newLimitServer(cfg).inFlightLimitMiddleware(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/order/order1" {
+				close(started)
+				<-release
+			}
+		}),
+	)
+
+	done := make(chan int)
+	go func() {
+		done <- serve(handler, "10.0.0.1", nil).Code
+	}()
+	<-started
+
+	w := serve(handler, "10.0.0.2", nil)
+	if w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") != "1" {
+		t.Errorf("error: expected 503 with Retry-After 1 while the slot is held, got %d", w.Code)
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/health", nil)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Errorf("error: expected health checks not to be shed, got %d", w.Code)
+	}
+
+	close(release)
+	select {
+	case code := <-done:
+		if code != http.StatusOK {
+			t.Errorf("error: expected the first request to be served, got %d", code)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("error: expected the first request to finish")
+	}
+
+	started = make(chan struct{})
+	release = make(chan struct{})
+	close(release)
+	if w := serve(handler, "10.0.0.2", nil); w.Code != http.StatusOK {
+		t.Errorf("error: expected the released slot to be reused, got %d", w.Code)
+	}
+}
@@ -0,0 +1,75 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+
+	"l0/internal/auth"
+)
+
+// anonymousPrincipal is recorded in the audit log for requests without credentials
+const anonymousPrincipal = "anonymous"
+
+// handleDeleteOrder handles DELETE /order/{order_uid} requests, the order is soft deleted
+func (s *Server) handleDeleteOrder(w http.ResponseWriter, r *http.Request) {
+	orderUID := strings.TrimSpace(r.PathValue("order_uid"))
+	if orderUID == "" {
+		s.writeErrorResponse(w, http.StatusBadRequest, "Order UID is required", "")
+		return
+	}
+
+	deleted, err := s.service.DeleteOrder(r.Context(), orderUID, principalName(r))
+	if err != nil {
+		s.requestLogger(r).Error().
+			Err(err).
+			Str("order_uid", orderUID).
+			Msg("Failed to delete order")
+		s.writeErrorResponse(w, http.StatusInternalServerError, "Internal server error", "")
+		return
+	}
+	if !deleted {
+		s.writeErrorResponse(w, http.StatusNotFound, "Order not found", orderUID)
+		return
+	}
+
+	s.requestLogger(r).Warn().Str("order_uid", orderUID).Msg("Order deleted")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleEraseCustomer handles POST /customers/{customer_id}/erasure requests. Personal data of all
+// customer orders are scrubbed, the orders themselves are kept for reports
+func (s *Server) handleEraseCustomer(w http.ResponseWriter, r *http.Request) {
+	customerID := strings.TrimSpace(r.PathValue("customer_id"))
+	if customerID == "" {
+		s.writeErrorResponse(w, http.StatusBadRequest, "Customer ID is required", "")
+		return
+	}
+
+	result, err := s.service.EraseCustomer(r.Context(), customerID, principalName(r))
+	if err != nil {
+		s.requestLogger(r).Error().
+			Err(err).
+			Str("customer_id", customerID).
+			Msg("Failed to erase customer data")
+		s.writeErrorResponse(w, http.StatusInternalServerError, "Internal server error", "")
+		return
+	}
+	if result.Orders == 0 {
+		s.writeErrorResponse(w, http.StatusNotFound, "Customer not found", customerID)
+		return
+	}
+
+	s.requestLogger(r).Warn().
+		Str("customer_id", customerID).
+		Int("orders", result.Orders).
+		Msg("Customer data erased")
+	s.writeJSONResponse(w, http.StatusOK, result)
+}
+
+// principalName returns the subject of the request principal or anonymous without authentication
+func principalName(r *http.Request) string {
+	if principal := auth.PrincipalFromContext(r.Context()); principal != nil {
+		return principal.Subject
+	}
+	return anonymousPrincipal
+}
@@ -0,0 +1,44 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"l0/internal/models"
+)
+
+// handleSalesReport handles GET /reports/sales requests.
+// Groupings are set as group_by=day,brand, sales are grouped by day by default
+func (s *Server) handleSalesReport(w http.ResponseWriter, r *http.Request) {
+	query := models.SalesReportQuery{GroupBy: []string{models.GroupDay}}
+	if value := r.URL.Query().Get("group_by"); value != "" {
+		query.GroupBy = nil
+		for _, group := range strings.Split(value, ",") {
+			query.GroupBy = append(query.GroupBy, strings.ToLower(strings.TrimSpace(group)))
+		}
+	}
+
+	var err error
+	query.From, query.To, err = parseTimeRange(r)
+	if err != nil {
+		s.writeErrorResponse(w, http.StatusBadRequest, "Invalid range", err.Error())
+		return
+	}
+
+	report, err := s.reports.SalesReport(r.Context(), query)
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidReportQuery) {
+			s.writeErrorResponse(w, http.StatusBadRequest, "Invalid report query", err.Error())
+			return
+		}
+		s.requestLogger(r).Error().
+			Err(err).
+			Strs("group_by", query.GroupBy).
+			Msg("Failed to build sales report")
+		s.writeErrorResponse(w, http.StatusInternalServerError, "Internal server error", "")
+		return
+	}
+
+	s.writeJSONResponse(w, http.StatusOK, report)
+}
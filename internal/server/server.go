@@ -1,32 +1,65 @@
 package server
 
 import (
+	"bufio"
 	"context"
 	"errors"
 	"fmt"
+	"net"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/rs/zerolog"
 
+	"l0/internal/auth"
 	"l0/internal/config"
+	"l0/internal/events"
 	"l0/internal/interfaces"
+	"l0/internal/metrics"
+	"l0/internal/models"
 )
 
+// streamingPaths are paths of responses streamed for longer than the request timeout
+var streamingPaths = map[string]bool{
+	"/orders/export": true,
+	"/orders/stream": true,
+	"/orders/ws":     true,
+}
+
 // Server represents the HTTP server
 type Server struct {
-	httpServer *http.Server
-	logger     *zerolog.Logger
-	service    interfaces.OrderService
-	config     *config.Config
+	httpServer    *http.Server
+	logger        *zerolog.Logger
+	service       interfaces.OrderService
+	reports       interfaces.ReportService
+	consumer      interfaces.ConsumerController
+	audit         interfaces.AuditLog
+	bus           *events.Bus
+	webhooks      interfaces.WebhookService
+	config        *config.Config
+	authenticator *auth.Authenticator
 }
 
-// New creates a new HTTP server instance
-func New(cfg *config.Config, service interfaces.OrderService, logger *zerolog.Logger) *Server {
+// New creates a new HTTP server instance, authentication is disabled if authenticator is nil.
+// Reports are served only if reports is not nil, consumer admin endpoints are served only if consumer is not nil.
+// Order reads and admin actions are recorded only if audit is not nil, order changes are streamed only
+// if bus is not nil, webhook subscriptions are managed only if webhooks is not nil
+func New(
+	cfg *config.Config, service interfaces.OrderService, reports interfaces.ReportService,
+	consumer interfaces.ConsumerController, audit interfaces.AuditLog, bus *events.Bus,
+	webhooks interfaces.WebhookService, authenticator *auth.Authenticator, logger *zerolog.Logger,
+) *Server {
 	server := &Server{
-		logger:  logger,
-		service: service,
-		config:  cfg,
+		logger:        logger,
+		service:       service,
+		reports:       reports,
+		consumer:      consumer,
+		audit:         audit,
+		bus:           bus,
+		webhooks:      webhooks,
+		config:        cfg,
+		authenticator: authenticator,
 	}
 
 	server.httpServer = &http.Server{
@@ -62,12 +95,61 @@ func (s *Server) Stop(ctx context.Context) error {
 func (s *Server) setupRoutes() http.Handler {
 	mux := http.NewServeMux()
 
-	mux.HandleFunc("GET /order/{order_uid}", s.handleGetOrder)
+	mux.Handle(
+		"GET /order/{order_uid}",
+		s.audited(models.AuditOrderRead, s.requireScope(auth.ScopeOrdersRead, s.handleGetOrder)),
+	)
+	mux.Handle("DELETE /order/{order_uid}", s.requireScope(auth.ScopeOrdersWrite, s.handleDeleteOrder))
+	mux.Handle(
+		"GET /customers/{customer_id}/orders",
+		s.audited(models.AuditCustomerOrdersRead, s.requireScope(auth.ScopeOrdersRead, s.handleGetCustomerOrders)),
+	)
+	mux.Handle(
+		"GET /customers/{customer_id}/summary",
+		s.audited(models.AuditCustomerSummaryRead, s.requireScope(auth.ScopeOrdersRead, s.handleGetCustomerSummary)),
+	)
+	s.handleAdmin(mux, "POST /customers/{customer_id}/erasure", models.AuditErasureRequested, s.handleEraseCustomer)
+	mux.Handle(
+		"GET /orders/export",
+		s.audited(models.AuditOrdersExported, s.requireScope(auth.ScopeOrdersRead, s.handleExportOrders)),
+	)
+	if s.bus != nil {
+		mux.Handle("GET /orders/stream", s.requireScope(auth.ScopeOrdersRead, s.handleOrderStream))
+		mux.Handle("GET /orders/ws", s.requireScope(auth.ScopeOrdersRead, s.handleOrderWebSocket))
+	}
+	if s.reports != nil {
+		mux.Handle("GET /reports/sales", s.requireScope(auth.ScopeOrdersRead, s.handleSalesReport))
+	}
+	if s.consumer != nil {
+		s.handleAdmin(mux, "GET /admin/consumer", models.AuditConsumerStatus, s.handleConsumerStatus)
+		s.handleAdmin(mux, "POST /admin/consumer/pause", models.AuditConsumerPaused, s.handlePauseConsumer)
+		s.handleAdmin(mux, "POST /admin/consumer/resume", models.AuditConsumerResumed, s.handleResumeConsumer)
+		s.handleAdmin(mux, "POST /admin/consumer/seek", models.AuditConsumerSeek, s.handleSeekConsumer)
+		s.handleAdmin(mux, "POST /admin/consumer/reset", models.AuditConsumerReset, s.handleResetConsumer)
+		s.handleAdmin(mux, "POST /admin/consumer/replay", models.AuditConsumerReplay, s.handleReplay)
+	}
+	if s.webhooks != nil {
+		s.handleAdmin(mux, "POST /admin/webhooks", models.AuditWebhookCreated, s.handleCreateWebhook)
+		s.handleAdmin(mux, "GET /admin/webhooks", models.AuditWebhooksRead, s.handleListWebhooks)
+		s.handleAdmin(mux, "GET /admin/webhooks/{id}", models.AuditWebhooksRead, s.handleGetWebhook)
+		s.handleAdmin(mux, "DELETE /admin/webhooks/{id}", models.AuditWebhookDeleted, s.handleDeleteWebhook)
+		s.handleAdmin(mux, "GET /admin/webhooks/{id}/deliveries", models.AuditWebhooksRead, s.handleWebhookDeliveries)
+	}
+	if s.audit != nil {
+		s.handleAdmin(mux, "GET /admin/audit", models.AuditLogRead, s.handleAuditEntries)
+		s.handleAdmin(mux, "GET /admin/audit/verify", models.AuditLogVerified, s.handleVerifyAudit)
+	}
 	mux.HandleFunc("GET /health", s.handleHealth)
+	mux.Handle("GET /metrics", metrics.DefaultRegistry.Handler())
 
 	mux.Handle("GET /", http.FileServer(http.Dir("web/")))
 
-	handler := s.loggingMiddleware(mux)
+	handler := s.rateLimitMiddleware(mux)
+	handler = s.loggingMiddleware(handler)
+	handler = s.authMiddleware(handler)
+	// The in-flight limit is applied inside the timeout, so a slot is held until the handler returns
+	// even if the client already got the timeout response
+	handler = s.inFlightLimitMiddleware(handler)
 	handler = s.timeoutMiddleware(handler)
 	handler = s.recoveryMiddleware(handler)
 
@@ -78,13 +160,112 @@ func (s *Server) setupRoutes() http.Handler {
 func (s *Server) loggingMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(
 		func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
 			wrapper := &responseWriter{ResponseWriter: w, statusCode: http.StatusOK}
 
 			next.ServeHTTP(wrapper, r)
+
+			metrics.DefaultRegistry.Counter(
+				"http_requests_total", "Number of served HTTP requests", "code", strconv.Itoa(wrapper.statusCode),
+			).Inc()
+
+			s.requestLogger(r).Info().
+				Str("method", r.Method).
+				Str("path", r.URL.Path).
+				Str("remote_addr", r.RemoteAddr).
+				Int("status", wrapper.statusCode).
+				Dur("duration", time.Since(start)).
+				Msg("HTTP request")
 		},
 	)
 }
 
+// authMiddleware authenticates requests with credentials and puts the principal into the request context.
+// Requests without credentials pass as anonymous, scopes are checked by requireScope.
+// Requests with credentials are rejected with 429 before authentication while their IP address has failed
+// too many times, so credentials can't be brute forced
+func (s *Server) authMiddleware(next http.Handler) http.Handler {
+	failures := s.failedAuthLimiter()
+
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			if s.authenticator == nil || !s.authenticator.HasCredentials(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			ip := s.clientIP(r)
+			if failures != nil {
+				if delay := failures.Delay(ip); delay > 0 {
+					s.rejectRateLimited(w, r, "ip:"+ip, delay)
+					return
+				}
+			}
+
+			principal, err := s.authenticator.Authenticate(r)
+			if errors.Is(err, auth.ErrNoCredentials) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if err != nil {
+				if failures != nil {
+					failures.Allow(ip)
+				}
+				s.logger.Warn().
+					Err(err).
+					Str("method", r.Method).
+					Str("path", r.URL.Path).
+					Str("remote_addr", r.RemoteAddr).
+					Msg("Authentication failed")
+				w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+				s.writeErrorResponse(w, http.StatusUnauthorized, "Invalid credentials", "")
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(auth.WithPrincipal(r.Context(), principal)))
+		},
+	)
+}
+
+// requireScope allows the request only for principals with the scope if authentication is enabled
+func (s *Server) requireScope(scope string, next http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			if s.authenticator == nil {
+				next(w, r)
+				return
+			}
+
+			principal := auth.PrincipalFromContext(r.Context())
+			if principal == nil {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				s.writeErrorResponse(w, http.StatusUnauthorized, "Authentication required", "")
+				return
+			}
+			if !principal.HasScope(scope) {
+				s.writeErrorResponse(w, http.StatusForbidden, "Insufficient scope", scope)
+				return
+			}
+
+			next(w, r)
+		},
+	)
+}
+
+// requestLogger returns the server logger with the identity of the request principal
+func (s *Server) requestLogger(r *http.Request) *zerolog.Logger {
+	principal := auth.PrincipalFromContext(r.Context())
+	if principal == nil {
+		return s.logger
+	}
+
+	logger := s.logger.With().
+		Str("principal", principal.Subject).
+		Str("auth_method", principal.Method).
+		Logger()
+	return &logger
+}
+
 // responseWriter wraps http.ResponseWriter to capture status code
 type responseWriter struct {
 	http.ResponseWriter
@@ -96,10 +277,25 @@ func (rw *responseWriter) WriteHeader(code int) {
 	rw.ResponseWriter.WriteHeader(code)
 }
 
-// timeoutMiddleware adds request timeout handling
+// Unwrap returns the original response writer, so http.ResponseController can flush it
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+// Hijack takes over the connection of the original response writer for WebSocket handlers
+func (rw *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return http.NewResponseController(rw.ResponseWriter).Hijack()
+}
+
+// timeoutMiddleware adds request timeout handling, streaming requests aren't limited
 func (s *Server) timeoutMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(
 		func(w http.ResponseWriter, r *http.Request) {
+			if streamingPaths[r.URL.Path] {
+				next.ServeHTTP(w, r)
+				return
+			}
+
 			ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
 			defer cancel()
 
@@ -129,6 +325,9 @@ func (s *Server) recoveryMiddleware(next http.Handler) http.Handler {
 		func(w http.ResponseWriter, r *http.Request) {
 			defer func() {
 				if err := recover(); err != nil {
+					if err == http.ErrAbortHandler {
+						panic(err)
+					}
 					s.logger.Error().
 						Interface("panic", err).
 						Str("method", r.Method).
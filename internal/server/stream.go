@@ -0,0 +1,235 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"golang.org/x/net/websocket"
+
+	"l0/internal/events"
+	"l0/internal/models"
+)
+
+// Stream defaults used if they aren't configured
+const (
+	defaultHeartbeatInterval  = 15 * time.Second
+	defaultStreamWriteTimeout = 10 * time.Second
+)
+
+// subscriptionPaths are paths of long-lived subscriptions, they're limited by the number of subscribers
+// of the bus instead of the in-flight limit
+var subscriptionPaths = map[string]bool{
+	"/orders/stream": true,
+	"/orders/ws":     true,
+}
+
+// A changeSink writes order changes to a streaming connection
+type changeSink interface {
+	// WriteChange writes the change to the client
+	WriteChange(change models.OrderChange) error
+	// WriteHeartbeat keeps the idle connection alive
+	WriteHeartbeat() error
+	// WriteClose tells the client why the stream is closed by the server
+	WriteClose(reason error) error
+}
+
+// handleOrderStream handles GET /orders/stream requests with Server-Sent Events.
+// Changes are filtered by customer_id and delivery_service, every event is named by the change type
+func (s *Server) handleOrderStream(w http.ResponseWriter, r *http.Request) {
+	subscription, ok := s.subscribe(w, r)
+	if !ok {
+		return
+	}
+	defer subscription.Close()
+
+	sink := &sseSink{
+		writer:     w,
+		controller: http.NewResponseController(w),
+		timeout:    s.streamWriteTimeout(),
+	}
+	// Streams outlive the server write timeout, every write sets its own deadline instead
+	_ = sink.controller.SetWriteDeadline(time.Time{})
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	if err := sink.controller.Flush(); err != nil {
+		return
+	}
+
+	s.streamChanges(r, subscription, sink, r.Context().Done())
+}
+
+// handleOrderWebSocket handles GET /orders/ws requests, changes are sent as JSON text messages.
+// Filters are the same as for the SSE stream, messages from the client are ignored
+func (s *Server) handleOrderWebSocket(w http.ResponseWriter, r *http.Request) {
+	subscription, ok := s.subscribe(w, r)
+	if !ok {
+		return
+	}
+	defer subscription.Close()
+
+	server := websocket.Server{
+		// Origin isn't checked, clients are authenticated by credentials of the upgrade request
+		Handshake: func(*websocket.Config, *http.Request) error { return nil },
+		Handler: func(conn *websocket.Conn) {
+			// Deadlines of the server timeouts stay on the hijacked connection
+			_ = conn.SetDeadline(time.Time{})
+
+			closed := make(chan struct{})
+			go func() {
+				defer close(closed)
+				// Reading handles pings and detects the closed connection
+				_, _ = io.Copy(io.Discard, conn)
+			}()
+
+			sink := &webSocketSink{conn: conn, timeout: s.streamWriteTimeout()}
+			s.streamChanges(r, subscription, sink, closed)
+			_ = conn.Close()
+		},
+	}
+	server.ServeHTTP(w, r)
+}
+
+// subscribe subscribes to changes matching the request filters or writes the error response.
+// Every subscription is recorded in the audit log when it's opened, streams may stay open for days
+func (s *Server) subscribe(w http.ResponseWriter, r *http.Request) (*events.Subscription, bool) {
+	query := r.URL.Query()
+	filter := models.OrderChangeFilter{
+		CustomerID:      strings.TrimSpace(query.Get("customer_id")),
+		DeliveryService: strings.TrimSpace(query.Get("delivery_service")),
+	}
+
+	subscription, err := s.bus.Subscribe(filter)
+	if err != nil {
+		s.requestLogger(r).Warn().Err(err).Msg("Order change subscription rejected")
+		w.Header().Set("Retry-After", "5")
+		s.writeErrorResponse(w, http.StatusServiceUnavailable, "Too many subscribers", "")
+		return nil, false
+	}
+
+	if s.audit != nil {
+		s.recordAudit(
+			r, models.AuditOrdersStreamed, filter.CustomerID,
+			auditDetails{Method: r.Method, Path: r.URL.Path, Filter: &filter},
+		)
+	}
+	return subscription, true
+}
+
+// streamChanges writes changes of the subscription to the sink until the client is gone,
+// a write fails or the subscription is closed by the bus
+func (s *Server) streamChanges(
+	r *http.Request, subscription *events.Subscription, sink changeSink, gone <-chan struct{},
+) {
+	interval := s.config.Server.Stream.HeartbeatInterval
+	if interval <= 0 {
+		interval = defaultHeartbeatInterval
+	}
+	heartbeat := time.NewTicker(interval)
+	defer heartbeat.Stop()
+
+	logger := s.requestLogger(r)
+	logger.Info().Str("path", r.URL.Path).Msg("Order change stream opened")
+
+	var err error
+	for err == nil {
+		select {
+		case <-gone:
+			logger.Info().Str("path", r.URL.Path).Msg("Order change stream closed by client")
+			return
+		case <-subscription.Done():
+			logger.Warn().Err(subscription.Err()).Str("path", r.URL.Path).Msg("Order change stream closed")
+			_ = sink.WriteClose(subscription.Err())
+			return
+		case change := <-subscription.Changes():
+			if change.Order != nil {
+				change.Order = s.maskOrder(r, change.Order)
+			}
+			err = sink.WriteChange(change)
+		case <-heartbeat.C:
+			err = sink.WriteHeartbeat()
+		}
+	}
+	logger.Warn().Err(err).Str("path", r.URL.Path).Msg("Failed to write order change stream")
+}
+
+// streamWriteTimeout returns the write timeout of streaming connections
+func (s *Server) streamWriteTimeout() time.Duration {
+	if timeout := s.config.Server.Stream.WriteTimeout; timeout > 0 {
+		return timeout
+	}
+	return defaultStreamWriteTimeout
+}
+
+// An sseSink writes changes as Server-Sent Events
+type sseSink struct {
+	writer     http.ResponseWriter
+	controller *http.ResponseController
+	timeout    time.Duration
+}
+
+// WriteChange writes the change as an event named by the change type
+func (s *sseSink) WriteChange(change models.OrderChange) error {
+	data, err := json.Marshal(change)
+	if err != nil {
+		return err
+	}
+	return s.write(fmt.Sprintf("event: %s\ndata: %s\n\n", change.Type, data))
+}
+
+// WriteHeartbeat writes a comment, it's ignored by clients
+func (s *sseSink) WriteHeartbeat() error {
+	return s.write(": heartbeat\n\n")
+}
+
+// WriteClose writes the error event with the reason
+func (s *sseSink) WriteClose(reason error) error {
+	data, _ := json.Marshal(ErrorResponse{Error: "Stream closed", Message: reason.Error()})
+	return s.write(fmt.Sprintf("event: error\ndata: %s\n\n", data))
+}
+
+// write writes and flushes the event within the write timeout
+func (s *sseSink) write(event string) error {
+	_ = s.controller.SetWriteDeadline(time.Now().Add(s.timeout))
+	if _, err := io.WriteString(s.writer, event); err != nil {
+		return err
+	}
+	return s.controller.Flush()
+}
+
+// A webSocketSink writes changes as WebSocket text messages
+type webSocketSink struct {
+	conn    *websocket.Conn
+	timeout time.Duration
+}
+
+// WriteChange writes the change as a JSON message
+func (s *webSocketSink) WriteChange(change models.OrderChange) error {
+	_ = s.conn.SetWriteDeadline(time.Now().Add(s.timeout))
+	return websocket.JSON.Send(s.conn, change)
+}
+
+// WriteHeartbeat writes a ping frame
+func (s *webSocketSink) WriteHeartbeat() error {
+	_ = s.conn.SetWriteDeadline(time.Now().Add(s.timeout))
+	s.conn.PayloadType = websocket.PingFrame
+	defer func() { s.conn.PayloadType = websocket.TextFrame }()
+	_, err := s.conn.Write(nil)
+	return err
+}
+
+// WriteClose writes the error message with the reason
+func (s *webSocketSink) WriteClose(reason error) error {
+	if errors.Is(reason, events.ErrClosed) {
+		return nil
+	}
+	_ = s.conn.SetWriteDeadline(time.Now().Add(s.timeout))
+	return websocket.JSON.Send(s.conn, ErrorResponse{Error: "Stream closed", Message: reason.Error()})
+}
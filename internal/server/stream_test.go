@@ -0,0 +1,192 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"l0/internal/config"
+	"l0/internal/events"
+	"l0/internal/models"
+)
+
+// A mockAuditLog is a thread-safe mock implementation of AuditLog for testing
+type mockAuditLog struct {
+	mu      sync.Mutex
+	entries []models.AuditEntry
+}
+
+func (m *mockAuditLog) Record(entry models.AuditEntry) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.entries = append(m.entries, entry)
+}
+
+func (m *mockAuditLog) Entries(ctx context.Context, filter models.AuditFilter) ([]models.AuditEntry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]models.AuditEntry(nil), m.entries...), nil
+}
+
+func (m *mockAuditLog) Verify(ctx context.Context) (*models.AuditVerification, error) {
+	return &models.AuditVerification{Valid: true}, nil
+}
+
+// A blockingSink is a changeSink which writes of changes wait until it's released
+type blockingSink struct {
+	release chan struct{}
+	changes chan models.OrderChange
+	closed  chan error
+}
+
+func (b *blockingSink) WriteChange(change models.OrderChange) error {
+	<-b.release
+	b.changes <- change
+	return nil
+}
+
+func (b *blockingSink) WriteHeartbeat() error {
+	return nil
+}
+
+func (b *blockingSink) WriteClose(reason error) error {
+	b.closed <- reason
+	return nil
+}
+
+func newStreamServer(bus *events.Bus, audit *mockAuditLog) *Server {
+	logger := zerolog.New(os.Stdout)
+	cfg := &config.Config{}
+	cfg.Server.Stream.HeartbeatInterval = 50 * time.Millisecond
+	cfg.Server.Stream.WriteTimeout = time.Second
+	server := &Server{logger: &logger, config: cfg, bus: bus}
+	if audit != nil {
+		server.audit = audit
+	}
+	return server
+}
+
+func TestServer_OrderStream(t *testing.T) {
+	bus := events.NewBus(config.StreamConfig{})
+	audit := &mockAuditLog{}
+	server := newStreamServer(bus, audit)
+	httpServer := httptest.NewServer(http.HandlerFunc(server.handleOrderStream))
+	defer httpServer.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	request, _ := http.NewRequestWithContext(ctx, http.MethodGet, httpServer.URL+"?customer_id=customer1", nil)
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	defer response.Body.Close()
+
+	if response.Header.Get("Content-Type") != "text/event-stream" || bus.Subscribers() != 1 {
+		t.Fatalf("error: expected an open event stream, got %s", response.Header.Get("Content-Type"))
+	}
+	entries, _ := audit.Entries(ctx, models.AuditFilter{})
+	if len(entries) != 1 || entries[0].Action != models.AuditOrdersStreamed || entries[0].CustomerID != "customer1" ||
+		entries[0].IP != "127.0.0.1" || !strings.Contains(string(entries[0].Details), `"customer_id":"customer1"`) {
+		t.Errorf("error: expected the subscription to be audited with the filter, got %+v", entries)
+	}
+
+	lines := bufio.NewScanner(response.Body)
+	next := func() string {
+		if !lines.Scan() {
+			t.Fatalf("error: stream ended: %v", lines.Err())
+		}
+		return lines.Text()
+	}
+	if line := next(); line != ": heartbeat" {
+		t.Errorf("error: expected a heartbeat comment, got %q", line)
+	}
+	if line := next(); line != "" {
+		t.Errorf("error: expected a blank line after the heartbeat, got %q", line)
+	}
+
+	order := &models.Order{OrderUID: "order1", CustomerID: "customer1"}
+	bus.Publish(models.NewOrderChange(models.ChangeOrderProcessed, &models.Order{CustomerID: "customer2"}, time.Now()))
+	bus.Publish(models.NewOrderChange(models.ChangeOrderProcessed, order, time.Now()))
+
+	line := next()
+	for line == ": heartbeat" || line == "" {
+		line = next()
+	}
+	if line != "event: "+models.ChangeOrderProcessed {
+		t.Errorf("error: expected the event named by the change type, got %q", line)
+	}
+	if line := next(); !strings.HasPrefix(line, "data: {") || !strings.Contains(line, `"order1"`) {
+		t.Errorf("error: expected the change as JSON data, got %q", line)
+	}
+	if line := next(); line != "" {
+		t.Errorf("error: expected a blank line after the event, got %q", line)
+	}
+
+	cancel()
+	deadline := time.Now().Add(time.Second)
+	for bus.Subscribers() > 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if bus.Subscribers() != 0 {
+		t.Errorf("error: expected the subscription to be closed with the connection")
+	}
+}
+
+func TestServer_StreamSlowSubscriber(t *testing.T) {
+	bus := events.NewBus(config.StreamConfig{BufferSize: 1})
+	server := newStreamServer(bus, nil)
+	subscription, err := bus.Subscribe(models.OrderChangeFilter{})
+	if err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	sink := &blockingSink{
+		release: make(chan struct{}),
+		changes: make(chan models.OrderChange, 10),
+		closed:  make(chan error, 1),
+	}
+	request := httptest.NewRequest(http.MethodGet, "/orders/stream", nil)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		server.streamChanges(request, subscription, sink, nil)
+	}()
+
+	// The first change is being written, the second one fills the buffer and the third one overflows it
+	order := &models.Order{OrderUID: "order1"}
+	bus.Publish(models.NewOrderChange(models.ChangeOrderProcessed, order, time.Now()))
+	time.Sleep(50 * time.Millisecond)
+	bus.Publish(models.NewOrderChange(models.ChangeOrderProcessed, order, time.Now()))
+	bus.Publish(models.NewOrderChange(models.ChangeOrderProcessed, order, time.Now()))
+	close(sink.release)
+
+	select {
+	case reason := <-sink.closed:
+		if !errors.Is(reason, events.ErrSlowSubscriber) {
+			t.Errorf("error: expected ErrSlowSubscriber, got %v", reason)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("error: expected the slow subscriber to be closed")
+	}
+	<-done
+
+	recorder := httptest.NewRecorder()
+	sse := &sseSink{writer: recorder, controller: http.NewResponseController(recorder), timeout: time.Second}
+	if err := sse.WriteClose(events.ErrSlowSubscriber); err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if body := recorder.Body.String(); !strings.HasPrefix(body, "event: error\ndata: {") ||
+		!strings.HasSuffix(body, "}\n\n") || !strings.Contains(body, events.ErrSlowSubscriber.Error()) {
+		t.Errorf("error: expected the error event with the reason, got %q", body)
+	}
+}
@@ -0,0 +1,147 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"l0/internal/models"
+	"l0/internal/webhook"
+)
+
+// A WebhookDeliveriesResponse represents a page of the delivery log of a webhook subscription
+type WebhookDeliveriesResponse struct {
+	SubscriptionID int64                    `json:"subscription_id"`
+	Deliveries     []models.WebhookDelivery `json:"deliveries"`
+	Limit          int                      `json:"limit"`
+	Offset         int                      `json:"offset"`
+	HasMore        bool                     `json:"has_more"`
+}
+
+// handleCreateWebhook handles POST /admin/webhooks requests, the secret is returned only in this response
+func (s *Server) handleCreateWebhook(w http.ResponseWriter, r *http.Request) {
+	var subscription models.WebhookSubscription
+	if err := json.NewDecoder(r.Body).Decode(&subscription); err != nil {
+		s.writeErrorResponse(w, http.StatusBadRequest, "Invalid request body", err.Error())
+		return
+	}
+	subscription.ID = 0
+
+	if err := s.webhooks.CreateSubscription(r.Context(), &subscription); err != nil {
+		if errors.Is(err, webhook.ErrInvalidSubscription) {
+			s.writeErrorResponse(w, http.StatusBadRequest, "Invalid webhook subscription", err.Error())
+			return
+		}
+		s.requestLogger(r).Error().Err(err).Str("url", subscription.URL).Msg("Failed to create webhook subscription")
+		s.writeErrorResponse(w, http.StatusInternalServerError, "Internal server error", "")
+		return
+	}
+
+	s.requestLogger(r).Info().
+		Int64("subscription_id", subscription.ID).
+		Str("url", subscription.URL).
+		Strs("event_types", subscription.EventTypes).
+		Msg("Webhook subscription created by admin")
+	s.writeJSONResponse(w, http.StatusCreated, subscription)
+}
+
+// handleListWebhooks handles GET /admin/webhooks requests
+func (s *Server) handleListWebhooks(w http.ResponseWriter, r *http.Request) {
+	subscriptions, err := s.webhooks.Subscriptions(r.Context())
+	if err != nil {
+		s.requestLogger(r).Error().Err(err).Msg("Failed to list webhook subscriptions")
+		s.writeErrorResponse(w, http.StatusInternalServerError, "Internal server error", "")
+		return
+	}
+
+	s.writeJSONResponse(w, http.StatusOK, subscriptions)
+}
+
+// handleGetWebhook handles GET /admin/webhooks/{id} requests
+func (s *Server) handleGetWebhook(w http.ResponseWriter, r *http.Request) {
+	id, ok := s.webhookID(w, r)
+	if !ok {
+		return
+	}
+
+	subscription, err := s.webhooks.Subscription(r.Context(), id)
+	if err != nil {
+		s.requestLogger(r).Error().Err(err).Int64("subscription_id", id).Msg("Failed to get webhook subscription")
+		s.writeErrorResponse(w, http.StatusInternalServerError, "Internal server error", "")
+		return
+	}
+	if subscription == nil {
+		s.writeErrorResponse(w, http.StatusNotFound, "Webhook subscription not found", r.PathValue("id"))
+		return
+	}
+
+	s.writeJSONResponse(w, http.StatusOK, subscription)
+}
+
+// handleDeleteWebhook handles DELETE /admin/webhooks/{id} requests, pending deliveries are dropped
+func (s *Server) handleDeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	id, ok := s.webhookID(w, r)
+	if !ok {
+		return
+	}
+
+	deleted, err := s.webhooks.DeleteSubscription(r.Context(), id)
+	if err != nil {
+		s.requestLogger(r).Error().Err(err).Int64("subscription_id", id).Msg("Failed to delete webhook subscription")
+		s.writeErrorResponse(w, http.StatusInternalServerError, "Internal server error", "")
+		return
+	}
+	if !deleted {
+		s.writeErrorResponse(w, http.StatusNotFound, "Webhook subscription not found", r.PathValue("id"))
+		return
+	}
+
+	s.requestLogger(r).Warn().Int64("subscription_id", id).Msg("Webhook subscription deleted by admin")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleWebhookDeliveries handles GET /admin/webhooks/{id}/deliveries requests
+func (s *Server) handleWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	id, ok := s.webhookID(w, r)
+	if !ok {
+		return
+	}
+	limit, offset, err := parsePagination(r)
+	if err != nil {
+		s.writeErrorResponse(w, http.StatusBadRequest, "Invalid pagination", err.Error())
+		return
+	}
+
+	deliveries, err := s.webhooks.Deliveries(r.Context(), id, limit+1, offset)
+	if err != nil {
+		s.requestLogger(r).Error().Err(err).Int64("subscription_id", id).Msg("Failed to get webhook deliveries")
+		s.writeErrorResponse(w, http.StatusInternalServerError, "Internal server error", "")
+		return
+	}
+
+	hasMore := len(deliveries) > limit
+	if hasMore {
+		deliveries = deliveries[:limit]
+	}
+
+	s.writeJSONResponse(
+		w, http.StatusOK, WebhookDeliveriesResponse{
+			SubscriptionID: id,
+			Deliveries:     deliveries,
+			Limit:          limit,
+			Offset:         offset,
+			HasMore:        hasMore,
+		},
+	)
+}
+
+// webhookID reads the subscription ID from the path or writes the error response
+func (s *Server) webhookID(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil || id <= 0 {
+		s.writeErrorResponse(w, http.StatusBadRequest, "Webhook subscription ID must be a positive integer", "")
+		return 0, false
+	}
+	return id, true
+}
@@ -11,18 +11,35 @@ import (
 	"github.com/sony/gobreaker"
 
 	"l0/internal/cache"
+	"l0/internal/currency"
+	"l0/internal/interfaces"
 	"l0/internal/models"
 )
 
+// favoriteBrandsLimit is the number of favorite brands in a customer summary
+const favoriteBrandsLimit = 5
+
 // An OrderService implements the business logic for order processing
 type OrderService struct {
 	cacheManager   *cache.Manager
 	logger         *zerolog.Logger
 	circuitBreaker *gobreaker.CircuitBreaker
+	converter      *currency.Converter
+	customers      interfaces.CustomerRepository
+	payments       interfaces.PaymentRepository
+	exports        interfaces.ExportRepository
+	privacy        interfaces.PrivacyRepository
+	changes        interfaces.OrderChangePublisher
 }
 
-// NewOrderService creates a new order service with the provided cache manager and logger
-func NewOrderService(cacheManager *cache.Manager, logger *zerolog.Logger) *OrderService {
+// NewOrderService creates a new order service with the provided cache manager, customer, payment, export
+// and privacy repositories, currency converter, publisher of order changes and logger. The converter may be nil,
+// then payments are not converted into the base currency. The publisher may be nil, then changes are not published
+func NewOrderService(
+	cacheManager *cache.Manager, customers interfaces.CustomerRepository, payments interfaces.PaymentRepository,
+	exports interfaces.ExportRepository, privacy interfaces.PrivacyRepository, converter *currency.Converter,
+	changes interfaces.OrderChangePublisher, logger *zerolog.Logger,
+) *OrderService {
 	cb := gobreaker.NewCircuitBreaker(
 		gobreaker.Settings{
 			Name:        "order-service",
@@ -39,6 +56,12 @@ func NewOrderService(cacheManager *cache.Manager, logger *zerolog.Logger) *Order
 		cacheManager:   cacheManager,
 		logger:         logger,
 		circuitBreaker: cb,
+		converter:      converter,
+		customers:      customers,
+		payments:       payments,
+		exports:        exports,
+		privacy:        privacy,
+		changes:        changes,
 	}
 }
 
@@ -68,10 +91,21 @@ func (s *OrderService) ProcessOrder(ctx context.Context, order *models.Order) er
 		order.DateCreated = time.Now()
 	}
 
-	_, err := s.circuitBreaker.Execute(
+	if s.converter != nil {
+		basePayment, err := s.converter.ToBase(processCtx, &order.Payment)
+		if err != nil {
+			s.logger.Warn().
+				Err(err).
+				Str("order_uid", order.OrderUID).
+				Str("currency", order.Payment.Currency).
+				Msg("ProcessOrder: failed to convert payment into base currency")
+		}
+		order.BasePayment = basePayment
+	}
+
+	inserted, err := s.circuitBreaker.Execute(
 		func() (interface{}, error) {
-			s.cacheManager.Set(processCtx, order)
-			return nil, nil
+			return s.cacheManager.Set(processCtx, order)
 		},
 	)
 
@@ -86,9 +120,79 @@ func (s *OrderService) ProcessOrder(ctx context.Context, order *models.Order) er
 		return fmt.Errorf("failed to process order: %w", err)
 	}
 
+	// Duplicates, retries and replays of stored orders are not new orders
+	if s.changes != nil && inserted.(bool) {
+		s.changes.Publish(models.NewOrderChange(models.ChangeOrderProcessed, order, time.Now().UTC()))
+	}
+
 	return nil
 }
 
+// UpdatePayment handles payment updates of existing orders from Kafka. The updated order is removed from the cache
+// to be read from the database on the next request
+func (s *OrderService) UpdatePayment(ctx context.Context, payment *models.Payment) error {
+	if payment == nil {
+		return errors.New("payment cannot be nil")
+	}
+	if err := payment.Validate(); err != nil {
+		return fmt.Errorf("payment validation failed: %w", err)
+	}
+
+	processCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	var basePayment *models.ConvertedPayment
+	if s.converter != nil {
+		var err error
+		basePayment, err = s.converter.ToBase(processCtx, payment)
+		if err != nil {
+			s.logger.Warn().
+				Err(err).
+				Str("transaction", payment.Transaction).
+				Str("currency", payment.Currency).
+				Msg("UpdatePayment: failed to convert payment into base currency")
+		}
+	}
+
+	_, err := s.circuitBreaker.Execute(
+		func() (interface{}, error) {
+			return nil, s.payments.UpdatePayment(processCtx, payment, basePayment)
+		},
+	)
+	if err != nil {
+		s.logger.Error().
+			Err(err).
+			Str("transaction", payment.Transaction).
+			Msg("UpdatePayment: payment update failed")
+		return fmt.Errorf("failed to update payment: %w", err)
+	}
+
+	if s.cacheManager.ContainsCache(payment.Transaction) {
+		s.cacheManager.DeleteCache(payment.Transaction)
+	}
+
+	s.publishPaymentUpdate(processCtx, payment.Transaction)
+
+	return nil
+}
+
+// publishPaymentUpdate publishes the updated order if there are subscribers to changes
+func (s *OrderService) publishPaymentUpdate(ctx context.Context, orderUID string) {
+	if s.changes == nil || !s.changes.HasSubscribers() {
+		return
+	}
+
+	order, err := s.cacheManager.Get(ctx, orderUID)
+	if err != nil || order == nil {
+		s.logger.Warn().
+			Err(err).
+			Str("order_uid", orderUID).
+			Msg("UpdatePayment: failed to read updated order for subscribers")
+		return
+	}
+	s.changes.Publish(models.NewOrderChange(models.ChangePaymentUpdated, order, time.Now().UTC()))
+}
+
 // GetOrder retrieves an order by UID, checking cache first, then database
 func (s *OrderService) GetOrder(ctx context.Context, orderUID string) (*models.Order, error) {
 	start := time.Now()
@@ -127,6 +231,178 @@ func (s *OrderService) GetOrder(ctx context.Context, orderUID string) (*models.O
 	return order, nil
 }
 
+// GetCustomerOrders retrieves a page of customer orders from the newest to the oldest
+func (s *OrderService) GetCustomerOrders(ctx context.Context, customerID string, limit, offset int) (
+	[]models.Order, error,
+) {
+	start := time.Now()
+
+	if strings.TrimSpace(customerID) == "" {
+		return nil, errors.New("customer ID cannot be empty")
+	}
+
+	retrieveCtx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+
+	result, err := s.circuitBreaker.Execute(
+		func() (interface{}, error) {
+			return s.customers.GetCustomerOrders(retrieveCtx, customerID, limit, offset)
+		},
+	)
+	if err != nil {
+		s.logger.Error().
+			Err(err).
+			Str("customer_id", customerID).
+			Dur("duration", time.Since(start)).
+			Msg("GetCustomerOrders: failed to retrieve orders")
+		return nil, fmt.Errorf("failed to retrieve customer orders: %w", err)
+	}
+
+	return result.([]models.Order), nil
+}
+
+// GetCustomerSummary retrieves aggregated statistics of customer orders
+func (s *OrderService) GetCustomerSummary(ctx context.Context, customerID string) (*models.CustomerSummary, error) {
+	start := time.Now()
+
+	if strings.TrimSpace(customerID) == "" {
+		return nil, errors.New("customer ID cannot be empty")
+	}
+
+	retrieveCtx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+
+	result, err := s.circuitBreaker.Execute(
+		func() (interface{}, error) {
+			return s.customers.GetCustomerSummary(retrieveCtx, customerID, favoriteBrandsLimit)
+		},
+	)
+	if err != nil {
+		s.logger.Error().
+			Err(err).
+			Str("customer_id", customerID).
+			Dur("duration", time.Since(start)).
+			Msg("GetCustomerSummary: failed to retrieve summary")
+		return nil, fmt.Errorf("failed to retrieve customer summary: %w", err)
+	}
+
+	return result.(*models.CustomerSummary), nil
+}
+
+// ConvertPayment converts the payment amounts into the target currency
+func (s *OrderService) ConvertPayment(ctx context.Context, payment *models.Payment, target string) (
+	*models.ConvertedPayment, error,
+) {
+	if s.converter == nil {
+		return nil, currency.ErrConversionUnavailable
+	}
+
+	converted, err := s.converter.Convert(ctx, payment, target)
+	if err != nil {
+		s.logger.Error().
+			Err(err).
+			Str("from", payment.Currency).
+			Str("to", target).
+			Msg("ConvertPayment: failed to convert payment")
+		return nil, fmt.Errorf("failed to convert payment: %w", err)
+	}
+
+	return converted, nil
+}
+
+// ExportOrders streams orders matching the filter to fn. Exports bypass the circuit breaker,
+// so failures of slow clients don't open it
+func (s *OrderService) ExportOrders(
+	ctx context.Context, filter models.OrderFilter, fn func(order *models.Order) error,
+) error {
+	start := time.Now()
+	count := 0
+
+	err := s.exports.StreamOrders(
+		ctx, filter, func(order *models.Order) error {
+			count++
+			return fn(order)
+		},
+	)
+	if err != nil {
+		s.logger.Error().
+			Err(err).
+			Int("count", count).
+			Dur("duration", time.Since(start)).
+			Msg("ExportOrders: failed to export orders")
+		return fmt.Errorf("failed to export orders: %w", err)
+	}
+
+	s.logger.Info().
+		Int("count", count).
+		Dur("duration", time.Since(start)).
+		Msg("ExportOrders: orders exported")
+	return nil
+}
+
+// DeleteOrder soft deletes the order on behalf of the principal and removes it from the cache.
+// It returns false if the order doesn't exist or is already deleted
+func (s *OrderService) DeleteOrder(ctx context.Context, orderUID, principal string) (bool, error) {
+	if strings.TrimSpace(orderUID) == "" {
+		return false, errors.New("order UID cannot be empty")
+	}
+
+	deleted, err := s.privacy.SoftDeleteOrder(ctx, orderUID, principal)
+	if err != nil {
+		s.logger.Error().
+			Err(err).
+			Str("order_uid", orderUID).
+			Msg("DeleteOrder: failed to delete order")
+		return false, fmt.Errorf("failed to delete order: %w", err)
+	}
+	s.EvictCache(orderUID)
+
+	if deleted {
+		s.logger.Info().
+			Str("order_uid", orderUID).
+			Str("principal", principal).
+			Msg("DeleteOrder: order deleted")
+	}
+	return deleted, nil
+}
+
+// EraseCustomer erases personal data of all customer orders on behalf of the principal
+// and removes the orders from the cache
+func (s *OrderService) EraseCustomer(ctx context.Context, customerID, principal string) (
+	*models.ErasureResult, error,
+) {
+	if strings.TrimSpace(customerID) == "" {
+		return nil, errors.New("customer ID cannot be empty")
+	}
+
+	orderUIDs, err := s.privacy.EraseCustomer(ctx, customerID, principal)
+	if err != nil {
+		s.logger.Error().
+			Err(err).
+			Str("customer_id", customerID).
+			Msg("EraseCustomer: failed to erase customer data")
+		return nil, fmt.Errorf("failed to erase customer data: %w", err)
+	}
+	s.EvictCache(orderUIDs...)
+
+	s.logger.Warn().
+		Str("customer_id", customerID).
+		Str("principal", principal).
+		Int("orders", len(orderUIDs)).
+		Msg("EraseCustomer: customer data erased")
+	return &models.ErasureResult{CustomerID: customerID, Orders: len(orderUIDs)}, nil
+}
+
+// EvictCache removes the orders from the cache, so they are read from the database on the next request
+func (s *OrderService) EvictCache(orderUIDs ...string) {
+	s.cacheManager.EvictCache(orderUIDs...)
+}
+
+// CircuitOpen reports whether the circuit breaker of the service is open
+func (s *OrderService) CircuitOpen() bool {
+	return s.circuitBreaker.State() == gobreaker.StateOpen
+}
+
 // WarmCache loads recent orders from database into cache on startup
 func (s *OrderService) WarmCache(ctx context.Context) error {
 	start := time.Now()
@@ -0,0 +1,149 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"l0/internal/config"
+	"l0/internal/interfaces"
+	"l0/internal/metrics"
+	"l0/internal/models"
+)
+
+// A ReportService builds sales reports and refreshes materialized views of sales if they are enabled
+type ReportService struct {
+	reports   interfaces.ReportRepository
+	config    config.ReportsConfig
+	logger    *zerolog.Logger
+	refreshes *metrics.Counter
+	failures  *metrics.Counter
+
+	mu      sync.Mutex
+	running bool
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+// NewReportService creates a new report service, a zero refresh interval is replaced with 10 minutes
+func NewReportService(
+	reports interfaces.ReportRepository, cfg config.ReportsConfig, logger *zerolog.Logger,
+) *ReportService {
+	if cfg.RefreshInterval <= 0 {
+		cfg.RefreshInterval = 10 * time.Minute
+	}
+
+	return &ReportService{
+		reports: reports,
+		config:  cfg,
+		logger:  logger,
+		refreshes: metrics.DefaultRegistry.Counter(
+			"report_view_refreshes_total", "Number of refreshes of materialized views of sales",
+		),
+		failures: metrics.DefaultRegistry.Counter(
+			"report_view_refresh_failures_total", "Number of failed refreshes of materialized views of sales",
+		),
+	}
+}
+
+// SalesReport returns sales grouped by the query groupings
+func (s *ReportService) SalesReport(ctx context.Context, query models.SalesReportQuery) (
+	*models.SalesReport, error,
+) {
+	start := time.Now()
+
+	if err := query.Validate(); err != nil {
+		return nil, fmt.Errorf("%w: %w", models.ErrInvalidReportQuery, err)
+	}
+
+	report, err := s.reports.SalesReport(ctx, query)
+	if err != nil {
+		s.logger.Error().
+			Err(err).
+			Strs("group_by", query.GroupBy).
+			Dur("duration", time.Since(start)).
+			Msg("SalesReport: failed to build sales report")
+		return nil, fmt.Errorf("failed to build sales report: %w", err)
+	}
+
+	s.logger.Debug().
+		Strs("group_by", query.GroupBy).
+		Str("source", report.Source).
+		Int("rows", len(report.Rows)).
+		Dur("duration", time.Since(start)).
+		Msg("SalesReport: sales report built")
+	return report, nil
+}
+
+// Start refreshes materialized views in background if they are enabled, the first refresh is done immediately
+func (s *ReportService) Start(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.config.MaterializedView {
+		return nil
+	}
+	if s.running {
+		return errors.New("report service is already running")
+	}
+
+	ctx, s.cancel = context.WithCancel(ctx)
+	s.running = true
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.run(ctx)
+	}()
+
+	return nil
+}
+
+// Stop stops refreshing materialized views and waits for the current refresh to finish
+func (s *ReportService) Stop() {
+	s.mu.Lock()
+	if !s.running {
+		s.mu.Unlock()
+		return
+	}
+	s.running = false
+	s.cancel()
+	s.mu.Unlock()
+
+	s.wg.Wait()
+}
+
+// run refreshes materialized views until the context is done
+func (s *ReportService) run(ctx context.Context) {
+	ticker := time.NewTicker(s.config.RefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		s.refresh(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// refresh refreshes materialized views of sales
+func (s *ReportService) refresh(ctx context.Context) {
+	start := time.Now()
+	if err := s.reports.RefreshSalesViews(ctx); err != nil {
+		if ctx.Err() == nil {
+			s.failures.Inc()
+			s.logger.Error().Err(err).Msg("Failed to refresh materialized views of sales")
+		}
+		return
+	}
+
+	s.refreshes.Inc()
+	s.logger.Info().Dur("duration", time.Since(start)).Msg("Materialized views of sales refreshed")
+}
@@ -0,0 +1,76 @@
+// Package webhook implements webhook subscriptions and delivery of outbox events to them
+package webhook
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"l0/internal/interfaces"
+	"l0/internal/models"
+)
+
+// secretSize is the number of random bytes of generated secrets
+const secretSize = 32
+
+// ErrInvalidSubscription is returned when the subscription is invalid
+var ErrInvalidSubscription = errors.New("invalid webhook subscription")
+
+// A Service manages webhook subscriptions and reads their delivery log
+type Service struct {
+	store interfaces.WebhookStore
+}
+
+// NewService creates a new webhook service
+func NewService(store interfaces.WebhookStore) *Service {
+	return &Service{store: store}
+}
+
+// CreateSubscription validates and adds the subscription. A secret is generated if it isn't set,
+// the subscription is returned with the secret only here
+func (s *Service) CreateSubscription(ctx context.Context, subscription *models.WebhookSubscription) error {
+	subscription.URL = strings.TrimSpace(subscription.URL)
+	subscription.CustomerID = strings.TrimSpace(subscription.CustomerID)
+	subscription.DeliveryService = strings.TrimSpace(subscription.DeliveryService)
+	slices.Sort(subscription.EventTypes)
+	subscription.EventTypes = slices.Compact(subscription.EventTypes)
+	if err := subscription.Validate(); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidSubscription, err)
+	}
+
+	if subscription.Secret == "" {
+		secret := make([]byte, secretSize)
+		if _, err := rand.Read(secret); err != nil {
+			return fmt.Errorf("failed to generate webhook secret: %w", err)
+		}
+		subscription.Secret = hex.EncodeToString(secret)
+	}
+
+	return s.store.CreateWebhookSubscription(ctx, subscription)
+}
+
+// Subscriptions returns all subscriptions without secrets
+func (s *Service) Subscriptions(ctx context.Context) ([]models.WebhookSubscription, error) {
+	return s.store.ListWebhookSubscriptions(ctx)
+}
+
+// Subscription returns the subscription without the secret or nil if it doesn't exist
+func (s *Service) Subscription(ctx context.Context, id int64) (*models.WebhookSubscription, error) {
+	return s.store.GetWebhookSubscription(ctx, id)
+}
+
+// DeleteSubscription removes the subscription with its pending deliveries and the delivery log
+func (s *Service) DeleteSubscription(ctx context.Context, id int64) (bool, error) {
+	return s.store.DeleteWebhookSubscription(ctx, id)
+}
+
+// Deliveries returns a page of the delivery log of the subscription
+func (s *Service) Deliveries(
+	ctx context.Context, subscriptionID int64, limit, offset int,
+) ([]models.WebhookDelivery, error) {
+	return s.store.ListWebhookDeliveries(ctx, subscriptionID, limit, offset)
+}
@@ -0,0 +1,92 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"l0/internal/models"
+)
+
+// A mockStore is a not thread-safe mock implementation of WebhookStore for testing
+type mockStore struct {
+	subscriptions []models.WebhookSubscription
+}
+
+func (m *mockStore) CreateWebhookSubscription(ctx context.Context, subscription *models.WebhookSubscription) error {
+	subscription.ID = int64(len(m.subscriptions) + 1)
+	m.subscriptions = append(m.subscriptions, *subscription)
+	return nil
+}
+
+func (m *mockStore) ListWebhookSubscriptions(ctx context.Context) ([]models.WebhookSubscription, error) {
+	return m.subscriptions, nil
+}
+
+func (m *mockStore) GetWebhookSubscription(ctx context.Context, id int64) (*models.WebhookSubscription, error) {
+	return nil, nil
+}
+
+func (m *mockStore) DeleteWebhookSubscription(ctx context.Context, id int64) (bool, error) {
+	return false, nil
+}
+
+func (m *mockStore) ListWebhookDeliveries(
+	ctx context.Context, subscriptionID int64, limit, offset int,
+) ([]models.WebhookDelivery, error) {
+	return nil, nil
+}
+
+func TestService_CreateSubscription(t *testing.T) {
+	service := NewService(&mockStore{})
+
+	subscription := &models.WebhookSubscription{
+		URL:        " https://partner.example.com/hooks ",
+		EventTypes: []string{models.EventOrderSaved, models.EventOrderSaved},
+	}
+	if err := service.CreateSubscription(context.Background(), subscription); err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	if subscription.ID == 0 || len(subscription.Secret) != 2*secretSize || len(subscription.EventTypes) != 1 {
+		t.Errorf("error: expected a stored subscription with a generated secret, got %+v", subscription)
+	}
+
+	for _, invalid := range []models.WebhookSubscription{
+		{URL: "partner.example.com/hooks", EventTypes: []string{models.EventOrderSaved}},
+		{URL: "ftp://partner.example.com", EventTypes: []string{models.EventOrderSaved}},
+		{URL: "https://partner.example.com"},
+		{URL: "https://partner.example.com", EventTypes: []string{"order.unknown"}},
+		{URL: "http://localhost:8080/hooks", EventTypes: []string{models.EventOrderSaved}},
+		{URL: "http://127.0.0.1/hooks", EventTypes: []string{models.EventOrderSaved}},
+		{URL: "http://10.0.0.5/hooks", EventTypes: []string{models.EventOrderSaved}},
+		{URL: "http://169.254.169.254/latest/meta-data", EventTypes: []string{models.EventOrderSaved}},
+		{URL: "http://[::ffff:127.0.0.1]/hooks", EventTypes: []string{models.EventOrderSaved}},
+		{URL: "http://[fd00:ec2::254]/hooks", EventTypes: []string{models.EventOrderSaved}},
+	} {
+		err := service.CreateSubscription(context.Background(), &invalid)
+		if !errors.Is(err, ErrInvalidSubscription) {
+			t.Errorf("error: expected ErrInvalidSubscription for %+v, got %v", invalid, err)
+		}
+	}
+}
+
+func TestSignature(t *testing.T) {
+	body := []byte(`{"order_uid":"order1"}`)
+	signedAt := time.Unix(1767225600, 0)
+	signature := Sign("secret", signedAt, body)
+
+	timestamp, err := Verify("secret", signature, body)
+	if err != nil || !timestamp.Equal(signedAt) {
+		t.Errorf("error: expected the signature to be valid, got %v at %v", err, timestamp)
+	}
+	if _, err := Verify("other", signature, body); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("error: expected a wrong secret to be rejected")
+	}
+	if _, err := Verify("secret", signature, []byte(`{"order_uid":"order2"}`)); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("error: expected a changed body to be rejected")
+	}
+	if _, err := Verify("secret", "v1=abc", body); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("error: expected a signature without time to be rejected")
+	}
+}
@@ -0,0 +1,60 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Headers of webhook requests
+const (
+	HeaderSignature = "X-Webhook-Signature"
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderDelivery  = "X-Webhook-Delivery"
+)
+
+// ErrInvalidSignature is returned when the signature doesn't match the body
+var ErrInvalidSignature = errors.New("invalid webhook signature")
+
+// Sign returns the signature header value t=<unix time>,v1=<hex HMAC-SHA256 of "<unix time>.<body>">.
+// The timestamp is signed, so receivers can reject replayed requests
+func Sign(secret string, timestamp time.Time, body []byte) string {
+	unix := strconv.FormatInt(timestamp.Unix(), 10)
+	return "t=" + unix + ",v1=" + hex.EncodeToString(mac(secret, unix, body))
+}
+
+// Verify checks the signature header of the body and returns the signed time
+func Verify(secret, signature string, body []byte) (time.Time, error) {
+	var unix, digest string
+	for _, part := range strings.Split(signature, ",") {
+		key, value, _ := strings.Cut(part, "=")
+		switch key {
+		case "t":
+			unix = value
+		case "v1":
+			digest = value
+		}
+	}
+
+	seconds, err := strconv.ParseInt(unix, 10, 64)
+	if err != nil {
+		return time.Time{}, ErrInvalidSignature
+	}
+	expected, err := hex.DecodeString(digest)
+	if err != nil || !hmac.Equal(expected, mac(secret, unix, body)) {
+		return time.Time{}, ErrInvalidSignature
+	}
+	return time.Unix(seconds, 0), nil
+}
+
+// mac returns HMAC-SHA256 of the timestamp and the body
+func mac(secret, unix string, body []byte) []byte {
+	hash := hmac.New(sha256.New, []byte(secret))
+	hash.Write([]byte(unix + "."))
+	hash.Write(body)
+	return hash.Sum(nil)
+}
@@ -0,0 +1,357 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/sony/gobreaker"
+
+	"l0/internal/config"
+	"l0/internal/interfaces"
+	"l0/internal/metrics"
+	"l0/internal/models"
+)
+
+// maxErrorBody limits the part of an error response body kept in the delivery log
+const maxErrorBody = 512
+
+// ErrForbiddenDestination is returned when a webhook host resolves to a non-public address
+var ErrForbiddenDestination = errors.New("webhook destination is not a public address")
+
+// A Worker fans outbox events out to webhook deliveries and delivers them with signed POST requests.
+// Deliveries are retried with exponential backoff, and every subscription has its own circuit breaker,
+// so a failing partner doesn't waste attempts of its deliveries and doesn't slow down others
+type Worker struct {
+	queue     interfaces.WebhookQueue
+	client    *http.Client
+	config    config.WebhooksConfig
+	logger    *zerolog.Logger
+	delivered *metrics.Counter
+	retried   *metrics.Counter
+	failed    *metrics.Counter
+
+	breakersMu sync.Mutex
+	breakers   map[int64]*gobreaker.CircuitBreaker
+
+	mu      sync.Mutex
+	running bool
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+// NewWorker creates a new worker, zero settings are replaced with defaults
+func NewWorker(queue interfaces.WebhookQueue, cfg config.WebhooksConfig, logger *zerolog.Logger) *Worker {
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = time.Second
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 100
+	}
+	if cfg.Concurrency <= 0 {
+		cfg.Concurrency = 4
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 8
+	}
+	if cfg.InitialBackoff <= 0 {
+		cfg.InitialBackoff = 10 * time.Second
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = time.Hour
+	}
+	if cfg.BreakerFailures <= 0 {
+		cfg.BreakerFailures = 5
+	}
+	if cfg.BreakerTimeout <= 0 {
+		cfg.BreakerTimeout = time.Minute
+	}
+
+	return &Worker{
+		queue:  queue,
+		client: newClient(cfg.Timeout),
+		config: cfg,
+		logger: logger,
+		delivered: metrics.DefaultRegistry.Counter(
+			"webhook_deliveries_total", "Number of webhook delivery results", "result", models.WebhookDelivered,
+		),
+		retried: metrics.DefaultRegistry.Counter(
+			"webhook_deliveries_total", "Number of webhook delivery results", "result", "retried",
+		),
+		failed: metrics.DefaultRegistry.Counter(
+			"webhook_deliveries_total", "Number of webhook delivery results", "result", models.WebhookFailed,
+		),
+		breakers: make(map[int64]*gobreaker.CircuitBreaker),
+	}
+}
+
+// newClient creates an HTTP client which connects only to public addresses. Addresses are checked after
+// resolution, so host names resolving to internal addresses and redirects to them are rejected too.
+// Proxies aren't used, otherwise the proxy address would be checked instead of the partner one
+func newClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return err
+			}
+			if !models.WebhookAddrAllowed(addrPort.Addr()) {
+				return fmt.Errorf("%w: %s", ErrForbiddenDestination, addrPort.Addr())
+			}
+			return nil
+		},
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{Timeout: timeout, Transport: transport}
+}
+
+// Start starts the worker in background
+func (w *Worker) Start(ctx context.Context) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.running {
+		return errors.New("webhook worker is already running")
+	}
+
+	ctx, w.cancel = context.WithCancel(ctx)
+	w.running = true
+
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		w.run(ctx)
+	}()
+
+	return nil
+}
+
+// Stop stops the worker and waits for the current deliveries to finish
+func (w *Worker) Stop() {
+	w.mu.Lock()
+	if !w.running {
+		w.mu.Unlock()
+		return
+	}
+	w.running = false
+	w.cancel()
+	w.mu.Unlock()
+
+	w.wg.Wait()
+}
+
+// run enqueues and delivers webhooks until the context is done
+func (w *Worker) run(ctx context.Context) {
+	ticker := time.NewTicker(w.config.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			w.enqueue(ctx)
+			w.deliverDue(ctx)
+		}
+	}
+}
+
+// enqueue fans out batches of outbox events while there are full batches
+func (w *Worker) enqueue(ctx context.Context) {
+	for ctx.Err() == nil {
+		processed, err := w.queue.EnqueueWebhookDeliveries(ctx, w.config.BatchSize)
+		if err != nil {
+			w.logger.Error().Err(err).Msg("Failed to enqueue webhook deliveries")
+			return
+		}
+		if processed < w.config.BatchSize {
+			return
+		}
+	}
+}
+
+// deliverDue delivers batches of due deliveries while there are full batches
+func (w *Worker) deliverDue(ctx context.Context) {
+	for ctx.Err() == nil {
+		processed, err := w.DeliverBatch(ctx)
+		if err != nil {
+			w.logger.Error().Err(err).Msg("Failed to deliver webhooks")
+			return
+		}
+		if processed < w.config.BatchSize {
+			return
+		}
+	}
+}
+
+// DeliverBatch claims one batch of due deliveries, delivers them concurrently and records the results.
+// It returns the number of claimed deliveries
+func (w *Worker) DeliverBatch(ctx context.Context) (int, error) {
+	deliveries, err := w.queue.ClaimWebhookDeliveries(ctx, w.config.BatchSize, w.lease())
+	if err != nil {
+		return 0, err
+	}
+
+	slots := make(chan struct{}, w.config.Concurrency)
+	var wg sync.WaitGroup
+	for _, delivery := range deliveries {
+		slots <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+
+			attempt := w.deliver(ctx, delivery)
+			// The result is recorded even if the worker is stopping, so the delivery isn't repeated
+			if err := w.queue.RecordWebhookAttempt(context.WithoutCancel(ctx), attempt); err != nil {
+				w.logger.Error().
+					Err(err).
+					Int64("delivery_id", delivery.ID).
+					Msg("Failed to record webhook attempt")
+			}
+		}()
+	}
+	wg.Wait()
+
+	return len(deliveries), nil
+}
+
+// lease returns how long claimed deliveries aren't due for other workers. Claimed deliveries are retried
+// by another worker if this one doesn't record them within the lease, so it covers the whole batch:
+// deliveries are sent Concurrency at a time and every request takes at most Timeout
+func (w *Worker) lease() time.Duration {
+	rounds := (w.config.BatchSize + w.config.Concurrency - 1) / w.config.Concurrency
+	// The margin covers recording results and a slow claim
+	return time.Duration(rounds+1)*w.config.Timeout + w.config.PollInterval
+}
+
+// deliver sends the delivery through the circuit breaker of its subscription and returns the attempt result
+func (w *Worker) deliver(ctx context.Context, delivery models.WebhookDelivery) models.WebhookAttempt {
+	var statusCode int
+	_, err := w.breaker(delivery.SubscriptionID).Execute(
+		func() (any, error) {
+			var err error
+			statusCode, err = w.post(ctx, delivery)
+			return nil, err
+		},
+	)
+
+	attempt := models.WebhookAttempt{DeliveryID: delivery.ID, StatusCode: statusCode, Counted: true}
+	switch {
+	case err == nil:
+		w.delivered.Inc()
+		attempt.Status = models.WebhookDelivered
+		attempt.NextAttemptAt = time.Now()
+	case errors.Is(err, gobreaker.ErrOpenState) || errors.Is(err, gobreaker.ErrTooManyRequests):
+		// The partner is known to be failing, the delivery waits for the breaker without spending attempts
+		attempt.Status = models.WebhookPending
+		attempt.Counted = false
+		attempt.Error = err.Error()
+		attempt.NextAttemptAt = time.Now().Add(w.config.BreakerTimeout)
+	case ctx.Err() != nil:
+		// The worker is stopping, the interrupted delivery is repeated without spending attempts
+		attempt.Status = models.WebhookPending
+		attempt.Counted = false
+		attempt.Error = err.Error()
+		attempt.NextAttemptAt = time.Now()
+	case delivery.Attempts+1 >= w.config.MaxAttempts:
+		w.failed.Inc()
+		attempt.Status = models.WebhookFailed
+		attempt.Error = err.Error()
+		attempt.NextAttemptAt = time.Now()
+		w.logger.Error().
+			Err(err).
+			Int64("delivery_id", delivery.ID).
+			Int64("subscription_id", delivery.SubscriptionID).
+			Int("attempts", delivery.Attempts+1).
+			Msg("Webhook delivery failed permanently")
+	default:
+		w.retried.Inc()
+		attempt.Status = models.WebhookPending
+		attempt.Error = err.Error()
+		attempt.NextAttemptAt = time.Now().Add(w.backoff(delivery.Attempts + 1))
+	}
+	return attempt
+}
+
+// post sends the signed delivery and returns the response status code, responses other than 2xx are errors
+func (w *Worker) post(ctx context.Context, delivery models.WebhookDelivery) (int, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(HeaderEvent, delivery.EventType)
+	request.Header.Set(HeaderDelivery, strconv.FormatInt(delivery.ID, 10))
+	request.Header.Set(HeaderSignature, Sign(delivery.Secret, time.Now(), delivery.Payload))
+
+	response, err := w.client.Do(request)
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(response.Body, maxErrorBody))
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return response.StatusCode, fmt.Errorf("unexpected status %d: %s", response.StatusCode, bytes.TrimSpace(body))
+	}
+	return response.StatusCode, nil
+}
+
+// backoff returns the delay before the next attempt, it doubles with every attempt up to the maximum
+func (w *Worker) backoff(attempts int) time.Duration {
+	delay := w.config.InitialBackoff
+	for i := 1; i < attempts && delay < w.config.MaxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, w.config.MaxBackoff)
+}
+
+// breaker returns the circuit breaker of the subscription, it's created on the first delivery
+func (w *Worker) breaker(subscriptionID int64) *gobreaker.CircuitBreaker {
+	w.breakersMu.Lock()
+	defer w.breakersMu.Unlock()
+
+	if breaker, ok := w.breakers[subscriptionID]; ok {
+		return breaker
+	}
+
+	breaker := gobreaker.NewCircuitBreaker(
+		gobreaker.Settings{
+			Name:        "webhook-" + strconv.FormatInt(subscriptionID, 10),
+			MaxRequests: 1,
+			Timeout:     w.config.BreakerTimeout,
+			ReadyToTrip: func(counts gobreaker.Counts) bool {
+				return counts.ConsecutiveFailures >= uint32(w.config.BreakerFailures)
+			},
+			OnStateChange: func(name string, from, to gobreaker.State) {
+				w.logger.Warn().
+					Str("breaker", name).
+					Str("from", from.String()).
+					Str("to", to.String()).
+					Msg("Webhook circuit breaker state changed")
+			},
+		},
+	)
+	w.breakers[subscriptionID] = breaker
+	return breaker
+}
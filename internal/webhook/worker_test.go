@@ -0,0 +1,254 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"l0/internal/config"
+	"l0/internal/models"
+)
+
+// A mockQueue is a thread-safe mock implementation of WebhookQueue for testing
+type mockQueue struct {
+	mu         sync.Mutex
+	deliveries []models.WebhookDelivery
+	attempts   []models.WebhookAttempt
+	lease      time.Duration
+}
+
+func (m *mockQueue) EnqueueWebhookDeliveries(ctx context.Context, limit int) (int, error) {
+	return 0, nil
+}
+
+func (m *mockQueue) ClaimWebhookDeliveries(
+	ctx context.Context, limit int, lease time.Duration,
+) ([]models.WebhookDelivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.lease = lease
+	claimed := m.deliveries[:min(limit, len(m.deliveries))]
+	m.deliveries = m.deliveries[len(claimed):]
+	return claimed, nil
+}
+
+func (m *mockQueue) RecordWebhookAttempt(ctx context.Context, attempt models.WebhookAttempt) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.attempts = append(m.attempts, attempt)
+	return nil
+}
+
+func (m *mockQueue) add(deliveries ...models.WebhookDelivery) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.deliveries = append(m.deliveries, deliveries...)
+}
+
+func (m *mockQueue) recorded() []models.WebhookAttempt {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	attempts := m.attempts
+	m.attempts = nil
+	return attempts
+}
+
+func newDelivery(id, subscriptionID int64, url string, attempts int) models.WebhookDelivery {
+	payload, _ := json.Marshal(models.OrderSavedEvent{EventType: models.EventOrderSaved, OrderUID: "order1"})
+	return models.WebhookDelivery{
+		ID:             id,
+		SubscriptionID: subscriptionID,
+		EventType:      models.EventOrderSaved,
+		Payload:        payload,
+		Attempts:       attempts,
+		URL:            url,
+		Secret:         "secret",
+	}
+}
+
+func TestWorker_DeliverSigned(t *testing.T) {
+	logger := zerolog.New(os.Stdout)
+	var received atomic.Int32
+	server := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				if _, err := Verify("secret", r.Header.Get(HeaderSignature), body); err != nil {
+					t.Errorf("error: expected a valid signature, got %v", err)
+				}
+				if r.Header.Get(HeaderEvent) != models.EventOrderSaved || r.Header.Get(HeaderDelivery) != "1" {
+					t.Errorf("error: expected event and delivery headers, got %v", r.Header)
+				}
+				received.Add(1)
+			},
+		),
+	)
+	defer server.Close()
+
+	queue := &mockQueue{}
+	queue.add(newDelivery(1, 1, server.URL, 0))
+	worker := NewWorker(queue, config.WebhooksConfig{}, &logger)
+	worker.client = server.Client()
+
+	if _, err := worker.DeliverBatch(context.Background()); err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	attempts := queue.recorded()
+	if received.Load() != 1 || len(attempts) != 1 {
+		t.Fatalf("error: expected one delivery, got %d requests and %d attempts", received.Load(), len(attempts))
+	}
+	if attempts[0].Status != models.WebhookDelivered || attempts[0].StatusCode != http.StatusOK {
+		t.Errorf("error: expected the delivery to succeed, got %+v", attempts[0])
+	}
+}
+
+func TestWorker_RejectsInternalDestinations(t *testing.T) {
+	logger := zerolog.New(os.Stdout)
+	var received atomic.Int32
+	server := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				received.Add(1)
+			},
+		),
+	)
+	defer server.Close()
+
+	queue := &mockQueue{}
+	queue.add(newDelivery(1, 1, server.URL, 0))
+	worker := NewWorker(queue, config.WebhooksConfig{}, &logger)
+
+	if _, err := worker.DeliverBatch(context.Background()); err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	attempts := queue.recorded()
+	if received.Load() != 0 || len(attempts) != 1 || attempts[0].Status == models.WebhookDelivered {
+		t.Fatalf("error: expected the loopback destination to be rejected at dial time, got %+v", attempts)
+	}
+	if !strings.Contains(attempts[0].Error, ErrForbiddenDestination.Error()) {
+		t.Errorf("error: expected ErrForbiddenDestination, got %s", attempts[0].Error)
+	}
+}
+
+func TestWorker_LeaseCoversBatch(t *testing.T) {
+	logger := zerolog.New(os.Stdout)
+	queue := &mockQueue{}
+	worker := NewWorker(
+		queue,
+		config.WebhooksConfig{BatchSize: 100, Concurrency: 4, Timeout: 10 * time.Second, PollInterval: time.Second},
+		&logger,
+	)
+
+	if _, err := worker.DeliverBatch(context.Background()); err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	// 25 rounds of requests taking up to 10s each
+	if queue.lease <= 250*time.Second {
+		t.Errorf("error: expected the lease to outlast the slowest batch, got %s", queue.lease)
+	}
+}
+
+func TestWorker_RetryAndFail(t *testing.T) {
+	logger := zerolog.New(os.Stdout)
+	server := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, "maintenance", http.StatusServiceUnavailable)
+			},
+		),
+	)
+	defer server.Close()
+
+	queue := &mockQueue{}
+	queue.add(newDelivery(1, 1, server.URL, 2), newDelivery(2, 2, server.URL, 4))
+	worker := NewWorker(
+		queue, config.WebhooksConfig{MaxAttempts: 5, InitialBackoff: time.Second, MaxBackoff: time.Hour}, &logger,
+	)
+	worker.client = server.Client()
+
+	start := time.Now()
+	if _, err := worker.DeliverBatch(context.Background()); err != nil {
+		t.Fatalf("error: %v", err)
+	}
+	for _, attempt := range queue.recorded() {
+		if !attempt.Counted || attempt.StatusCode != http.StatusServiceUnavailable || attempt.Error == "" {
+			t.Errorf("error: expected a counted attempt with the status and the error, got %+v", attempt)
+		}
+		switch attempt.DeliveryID {
+		case 1:
+			delay := attempt.NextAttemptAt.Sub(start)
+			if attempt.Status != models.WebhookPending || delay < 4*time.Second || delay > 5*time.Second {
+				t.Errorf("error: expected the third attempt to be retried in 4s, got %s in %s", attempt.Status, delay)
+			}
+		case 2:
+			if attempt.Status != models.WebhookFailed {
+				t.Errorf("error: expected the last attempt to fail the delivery, got %s", attempt.Status)
+			}
+		}
+	}
+
+	if worker.backoff(1) != time.Second || worker.backoff(3) != 4*time.Second || worker.backoff(100) != time.Hour {
+		t.Errorf("error: expected exponential backoff capped by the maximum")
+	}
+}
+
+func TestWorker_CircuitBreaker(t *testing.T) {
+	logger := zerolog.New(os.Stdout)
+	var failing, healthy atomic.Int32
+	failingServer := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				failing.Add(1)
+				w.WriteHeader(http.StatusInternalServerError)
+			},
+		),
+	)
+	defer failingServer.Close()
+	healthyServer := httptest.NewServer(
+		http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				healthy.Add(1)
+			},
+		),
+	)
+	defer healthyServer.Close()
+
+	queue := &mockQueue{}
+	worker := NewWorker(
+		queue, config.WebhooksConfig{Concurrency: 1, BreakerFailures: 2, BreakerTimeout: time.Minute}, &logger,
+	)
+	worker.client = healthyServer.Client()
+	for i := range int64(4) {
+		queue.add(newDelivery(i+1, 1, failingServer.URL, 0), newDelivery(i+11, 2, healthyServer.URL, 0))
+	}
+	if _, err := worker.DeliverBatch(context.Background()); err != nil {
+		t.Fatalf("error: %v", err)
+	}
+
+	if failing.Load() != 2 || healthy.Load() != 4 {
+		t.Errorf("error: expected the breaker to stop requests only to the failing subscription, got %d and %d",
+			failing.Load(), healthy.Load())
+	}
+	deferred := 0
+	for _, attempt := range queue.recorded() {
+		if !attempt.Counted {
+			deferred++
+			if attempt.Status != models.WebhookPending || time.Until(attempt.NextAttemptAt) < 50*time.Second {
+				t.Errorf("error: expected the deferred delivery to wait for the breaker, got %+v", attempt)
+			}
+		}
+	}
+	if deferred != 2 {
+		t.Errorf("error: expected 2 deliveries deferred without attempts, got %d", deferred)
+	}
+}